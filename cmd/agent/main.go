@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"qng_agent/internal/agent"
 	"qng_agent/internal/config"
+	"qng_agent/internal/logging"
 	"qng_agent/internal/mcp"
+	"qng_agent/internal/metrics"
 	"qng_agent/internal/service"
+	"sync"
 	"syscall"
 	"time"
 
@@ -23,12 +30,94 @@ type WebSocketClient struct {
 	SessionID string
 	Conn      *websocket.Conn
 	Send      chan []byte
+	// Policy 发送缓冲区已满（慢客户端）时的处理策略
+	Policy string
+	// PingInterval 心跳间隔，PongWait 内没有收到 pong 或消息时视为连接已断开
+	PingInterval time.Duration
+	PongWait     time.Duration
+	// done 客户端关闭时关闭，通知工作流监控协程退出
+	done chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// 慢客户端处理策略
+const (
+	SlowClientDrop  = "drop"  // 丢弃新消息，保留连接
+	SlowClientClose = "close" // 断开客户端
+)
+
+var (
+	errClientClosed   = errors.New("websocket client closed")
+	errMessageDropped = errors.New("websocket send buffer full, message dropped")
+)
+
+// enqueue 序列化消息并以非阻塞方式放入发送缓冲区，所有写入都经由写协程完成。
+// 缓冲区已满时按策略丢弃消息或断开客户端，避免慢客户端阻塞调用方。
+func (c *WebSocketClient) enqueue(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode websocket message: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClientClosed
+	}
+
+	select {
+	case c.Send <- data:
+		return nil
+	default:
+	}
+
+	if c.Policy == SlowClientDrop {
+		log.Printf("⚠️  WebSocket客户端 %s 发送缓冲区已满，丢弃消息\n", c.SessionID)
+		return errMessageDropped
+	}
+	log.Printf("⚠️  WebSocket客户端 %s 发送缓冲区已满，断开慢客户端\n", c.SessionID)
+	c.closeLocked()
+	return errClientClosed
+}
+
+// close 关闭发送缓冲区，写协程随之退出并关闭连接，可重复调用
+func (c *WebSocketClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked()
+}
+
+func (c *WebSocketClient) closeLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+		close(c.done)
+	}
+}
+
+// websocketWriteWait 单次写入（消息或 ping）的超时
+const websocketWriteWait = 10 * time.Second
+
+// heartbeatIntervals 返回心跳间隔与 pong 等待时间，pong 等待时间必须大于心跳间隔
+func heartbeatIntervals(wsConfig config.WebSocketConfig) (time.Duration, time.Duration) {
+	pingInterval := time.Duration(wsConfig.PingInterval) * time.Second
+	if pingInterval <= 0 {
+		pingInterval = 30 * time.Second
+	}
+	pongWait := time.Duration(wsConfig.PongTimeout) * time.Second
+	if pongWait <= pingInterval {
+		pongWait = 2 * pingInterval
+	}
+	return pingInterval, pongWait
 }
 
 type ChatMessage struct {
 	Type      string `json:"type"`
 	SessionID string `json:"session_id"`
 	Message   string `json:"message"`
+	Format    string `json:"format,omitempty"`
 	Timestamp int64  `json:"timestamp"`
 }
 
@@ -40,6 +129,8 @@ type ChatResponse struct {
 	ActionType string `json:"action_type,omitempty"`
 	ActionData any    `json:"action_data,omitempty"`
 	WorkflowID string `json:"workflow_id,omitempty"`
+	HandledBy  string `json:"handled_by,omitempty"`
+	ToolUsed   string `json:"tool_used,omitempty"`
 	Timestamp  int64  `json:"timestamp"`
 }
 
@@ -49,18 +140,32 @@ var (
 			return true // 允许跨域
 		},
 	}
-	clients = make(map[string]*WebSocketClient)
+	// clients 按会话ID索引的WebSocket连接，由 clientsMu 保护
+	clients   = make(map[string]*WebSocketClient)
+	clientsMu sync.RWMutex
 )
 
 func main() {
+	configPath := config.PathFlag()
+	flag.Parse()
+
 	log.Println("=== QNG Agent 管理器启动 ===")
 
 	// 加载配置
-	cfg := config.LoadConfig("config/config.yaml")
+	cfg := config.LoadConfig(*configPath)
 	if cfg == nil {
 		log.Fatal("Failed to load config")
 	}
 
+	// 按 logging 配置初始化结构化日志
+	logCloser, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatal("Failed to set up logging:", err)
+	}
+	if logCloser != nil {
+		defer logCloser.Close()
+	}
+
 	// 获取服务注册中心
 	registry := service.GetRegistry()
 
@@ -96,6 +201,7 @@ func main() {
 		Port:    9090,
 		Endpoints: []string{
 			"/api/chat",
+			"/api/workflow/simulate",
 			"/api/workflow/:id/status",
 			"/api/workflow/:id/signature",
 			"/api/capabilities",
@@ -124,11 +230,12 @@ func main() {
 	mcpClient := mcp.NewHTTPClient(cfg.MCP)
 
 	// 初始化Agent管理器
-	agentManager := agent.NewManager(mcpClient, cfg.LLM)
+	agentManager := agent.NewManager(mcpClient, cfg.LLM, cfg.Agent)
 
 	// 创建HTTP服务器
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	router.Use(logging.Middleware())
 
 	// 添加CORS中间件
 	router.Use(func(c *gin.Context) {
@@ -160,7 +267,7 @@ func main() {
 
 	// WebSocket路由
 	router.GET("/ws", func(c *gin.Context) {
-		handleWebSocket(c, agentManager)
+		handleWebSocket(c, agentManager, cfg.Frontend.WebSocket)
 	})
 
 	// API路由
@@ -177,6 +284,7 @@ func main() {
 		api.POST("/agent/process", func(c *gin.Context) {
 			var msg struct {
 				Message string `json:"message"`
+				Format  string `json:"format"`
 			}
 
 			if err := c.ShouldBindJSON(&msg); err != nil {
@@ -184,15 +292,25 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), agentManager.RequestTimeout())
+			defer cancel()
 			req := agent.ProcessRequest{
 				SessionID: uuid.New().String(),
 				Message:   msg.Message,
+				Format:    msg.Format,
 			}
 
 			response, err := agentManager.ProcessMessage(ctx, req)
+			if errors.Is(err, agent.ErrUnsupportedFormat) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, agent.ErrRequestTimeout) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+				return
+			}
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -204,6 +322,8 @@ func main() {
 				"action_data": response.ActionData,
 				"workflow_id": response.WorkflowID,
 				"session_id":  response.WorkflowID, // 为前端兼容性添加
+				"handled_by":  response.HandledBy,
+				"tool_used":   response.ToolUsed,
 			}
 
 			c.JSON(http.StatusOK, result)
@@ -212,10 +332,11 @@ func main() {
 		api.GET("/agent/poll/:sessionId", func(c *gin.Context) {
 			sessionId := c.Param("sessionId")
 
-			ctx := context.Background()
-			status, err := agentManager.GetWorkflowStatus(ctx, sessionId)
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			status, err := agentManager.PollWorkflowStatus(ctx, sessionId)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -226,6 +347,8 @@ func main() {
 			var req struct {
 				SessionID string `json:"session_id"`
 				Signature string `json:"signature"`
+				// Gas 可选的 gas 参数，提高 gas 重新广播交易时提交
+				Gas map[string]any `json:"gas"`
 			}
 
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -233,10 +356,11 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
-			result, err := agentManager.ContinueWorkflowWithSignature(ctx, req.SessionID, req.Signature)
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.ContinueWorkflowWithSignature(ctx, req.SessionID, req.Signature, req.Gas)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -252,6 +376,7 @@ func main() {
 			var msg struct {
 				SessionID string `json:"session_id"`
 				Message   string `json:"message"`
+				Format    string `json:"format"`
 			}
 
 			if err := c.ShouldBindJSON(&msg); err != nil {
@@ -259,28 +384,97 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), agentManager.RequestTimeout())
+			defer cancel()
 			req := agent.ProcessRequest{
 				SessionID: msg.SessionID,
 				Message:   msg.Message,
+				Format:    msg.Format,
 			}
 
 			response, err := agentManager.ProcessMessage(ctx, req)
+			if errors.Is(err, agent.ErrUnsupportedFormat) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, agent.ErrRequestTimeout) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+				return
+			}
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
 			c.JSON(http.StatusOK, response)
 		})
 
+		// 会话的LLM token用量：累计值与逐次调用明细
+		api.GET("/usage/:sessionId", func(c *gin.Context) {
+			report, err := agentManager.Usage(c.Param("sessionId"))
+			if errors.Is(err, agent.ErrSessionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, report)
+		})
+
+		api.GET("/staking/:address", func(c *gin.Context) {
+			// 保留请求中的关联ID，不随客户端断开而取消，但节点无响应时按请求超时返回
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), agentManager.RequestTimeout())
+			defer cancel()
+			result, err := agentManager.StakingPosition(ctx, c.Param("address"), c.Query("pool"))
+			if errors.Is(err, agent.ErrRequestTimeout) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, result)
+		})
+
+		// 预演自然语言请求，返回按顺序排列的计划交易，不请求签名
+		api.POST("/workflow/simulate", func(c *gin.Context) {
+			var req struct {
+				Message     string `json:"message"`
+				UserAddress string `json:"user_address"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if req.Message == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), agentManager.RequestTimeout())
+			defer cancel()
+			result, err := agentManager.SimulateWorkflow(ctx, req.Message, req.UserAddress)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, result)
+		})
+
 		api.GET("/workflow/:id/status", func(c *gin.Context) {
 			workflowID := c.Param("id")
 
-			ctx := context.Background()
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
 			status, err := agentManager.GetWorkflowStatus(ctx, workflowID)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -291,7 +485,8 @@ func main() {
 			workflowID := c.Param("id")
 
 			var req struct {
-				Signature string `json:"signature"`
+				Signature string         `json:"signature"`
+				Gas       map[string]any `json:"gas"`
 			}
 
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -299,10 +494,11 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
-			result, err := agentManager.ContinueWorkflowWithSignature(ctx, workflowID, req.Signature)
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.ContinueWorkflowWithSignature(ctx, workflowID, req.Signature, req.Gas)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -317,6 +513,91 @@ func main() {
 		broadcastWorkflowUpdate(workflowID, "signature_received", 60, "签名已提交，继续执行工作流...")
 	})
 
+		api.POST("/workflow/:id/resume", func(c *gin.Context) {
+			workflowID := c.Param("id")
+
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.ResumeWorkflow(ctx, workflowID)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":      "resuming",
+				"workflow_id": workflowID,
+				"result":      result,
+			})
+
+			broadcastWorkflowUpdate(workflowID, "resuming", 50, "正在恢复未完成的任务...")
+		})
+
+		api.POST("/workflow/:id/retry-confirmation", func(c *gin.Context) {
+			workflowID := c.Param("id")
+
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.RetryConfirmation(ctx, workflowID)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":      "retrying_confirmation",
+				"workflow_id": workflowID,
+				"result":      result,
+			})
+
+			broadcastWorkflowUpdate(workflowID, "retrying_confirmation", 70, "正在重新等待交易确认...")
+		})
+
+		// 取消工作流，进行中的 LLM 与 RPC 调用被放弃
+		api.POST("/workflow/:id/cancel", func(c *gin.Context) {
+			workflowID := c.Param("id")
+
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.CancelWorkflow(ctx, workflowID)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":      "cancelled",
+				"workflow_id": workflowID,
+				"result":      result,
+			})
+
+			broadcastWorkflowUpdate(workflowID, "cancelled", 100, "工作流已取消")
+		})
+
+		// 取消或加速确认超时的交易，kind 为 cancel 或 speed_up
+		api.POST("/workflow/:id/replace-transaction/:kind", func(c *gin.Context) {
+			workflowID := c.Param("id")
+			kind := c.Param("kind")
+			if kind != "cancel" && kind != "speed_up" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be cancel or speed_up"})
+				return
+			}
+
+			// 保留请求中的关联ID，不随客户端断开而取消
+			ctx := context.WithoutCancel(c.Request.Context())
+			result, err := agentManager.ReplaceTransaction(ctx, workflowID, kind)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":      "replacing_transaction",
+				"workflow_id": workflowID,
+				"result":      result,
+			})
+		})
+
 	// 配置管理API
 	api.GET("/config", func(c *gin.Context) {
 		// 读取当前配置文件
@@ -363,6 +644,9 @@ func main() {
 		}
 	}()
 
+	// 导出Prometheus指标
+	metricsServer := metrics.Serve(cfg.Monitoring)
+
 	// 启动健康检查
 	registry.StartHealthCheck()
 
@@ -385,12 +669,15 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Agent服务关闭失败: %v", err)
 	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
+	}
 
 	log.Println("Agent服务已关闭")
 }
 
 // WebSocket处理函数
-func handleWebSocket(c *gin.Context, agentManager *agent.Manager) {
+func handleWebSocket(c *gin.Context, agentManager *agent.Manager, wsConfig config.WebSocketConfig) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v\n", err)
@@ -398,13 +685,25 @@ func handleWebSocket(c *gin.Context, agentManager *agent.Manager) {
 	}
 
 	sessionID := uuid.New().String()
+	sendBuffer := wsConfig.SendBuffer
+	if sendBuffer <= 0 {
+		sendBuffer = 256
+	}
+	pingInterval, pongWait := heartbeatIntervals(wsConfig)
 	client := &WebSocketClient{
-		SessionID: sessionID,
-		Conn:      conn,
-		Send:      make(chan []byte, 256),
+		SessionID:    sessionID,
+		Conn:         conn,
+		Send:         make(chan []byte, sendBuffer),
+		Policy:       wsConfig.SlowClientPolicy,
+		PingInterval: pingInterval,
+		PongWait:     pongWait,
+		done:         make(chan struct{}),
 	}
 
+	clientsMu.Lock()
 	clients[sessionID] = client
+	clientsMu.Unlock()
+	metrics.WebSocketConnected()
 
 	// 启动WebSocket处理goroutine
 	go handleWebSocketClient(client, agentManager)
@@ -413,16 +712,30 @@ func handleWebSocket(c *gin.Context, agentManager *agent.Manager) {
 
 func handleWebSocketClient(client *WebSocketClient, agentManager *agent.Manager) {
 	defer func() {
-		delete(clients, client.SessionID)
+		clientsMu.Lock()
+		// 同一会话重连后 clients 中已是新连接，只移除自己
+		if clients[client.SessionID] == client {
+			delete(clients, client.SessionID)
+		}
+		clientsMu.Unlock()
+		client.close()
 		client.Conn.Close()
+		metrics.WebSocketDisconnected()
 	}()
 
+	// 半开连接收不到 pong，读超时后退出并从 clients 中移除
+	client.Conn.SetReadDeadline(time.Now().Add(client.PongWait))
+	client.Conn.SetPongHandler(func(string) error {
+		return client.Conn.SetReadDeadline(time.Now().Add(client.PongWait))
+	})
+
 	for {
 		var msg ChatMessage
 		if err := client.Conn.ReadJSON(&msg); err != nil {
 			log.Printf("WebSocket read error: %v\n", err)
 			break
 		}
+		client.Conn.SetReadDeadline(time.Now().Add(client.PongWait))
 
 		msg.SessionID = client.SessionID
 
@@ -430,12 +743,41 @@ func handleWebSocketClient(client *WebSocketClient, agentManager *agent.Manager)
 		req := agent.ProcessRequest{
 			SessionID: msg.SessionID,
 			Message:   msg.Message,
+			Format:    msg.Format,
 		}
 
-		ctx := context.Background()
-		response, err := agentManager.ProcessMessage(ctx, req)
+		// LLM 直接回答时逐段推送 chat_chunk，最终仍发送完整的 chat_response
+		ctx, cancel := context.WithTimeout(logging.EnsureCorrelationID(context.Background()), agentManager.RequestTimeout())
+		response, err := agentManager.ProcessMessageStream(ctx, req, func(chunk string) {
+			client.enqueue(ChatResponse{
+				Type:      "chat_chunk",
+				SessionID: msg.SessionID,
+				Response:  chunk,
+				Timestamp: time.Now().Unix(),
+			})
+		})
+		cancel()
+		if errors.Is(err, agent.ErrRequestTimeout) {
+			// 超时需要告知客户端，否则前端会一直等待回复
+			client.enqueue(ChatResponse{
+				Type:       "chat_response",
+				SessionID:  msg.SessionID,
+				Response:   "请求处理超时，请稍后重试。",
+				ActionType: "timeout",
+				Timestamp:  time.Now().Unix(),
+			})
+			continue
+		}
 		if err != nil {
+			// 流式回复中途失败时客户端已收到部分 chat_chunk，需要告知其回复不完整
 			log.Printf("Agent process error: %v\n", err)
+			client.enqueue(ChatResponse{
+				Type:       "chat_response",
+				SessionID:  msg.SessionID,
+				Response:   "回复生成中断，请重试。",
+				ActionType: "error",
+				Timestamp:  time.Now().Unix(),
+			})
 			continue
 		}
 
@@ -447,13 +789,16 @@ func handleWebSocketClient(client *WebSocketClient, agentManager *agent.Manager)
 			ActionType: response.ActionType,
 			ActionData: response.ActionData,
 			WorkflowID: response.WorkflowID,
+			HandledBy:  response.HandledBy,
+			ToolUsed:   response.ToolUsed,
 			Timestamp:  time.Now().Unix(),
 		}
 
 		// 发送响应
-		if err := client.Conn.WriteJSON(chatResponse); err != nil {
-			log.Printf("WebSocket write error: %v\n", err)
+		if err := client.enqueue(chatResponse); errors.Is(err, errClientClosed) {
 			break
+		} else if err != nil {
+			log.Printf("WebSocket write error: %v\n", err)
 		}
 
 		// 如果是工作流执行，启动状态监控
@@ -464,7 +809,11 @@ func handleWebSocketClient(client *WebSocketClient, agentManager *agent.Manager)
 }
 
 func writeWebSocketClient(client *WebSocketClient) {
-	defer client.Conn.Close()
+	ticker := time.NewTicker(client.PingInterval)
+	defer func() {
+		ticker.Stop()
+		client.Conn.Close()
+	}()
 
 	for {
 		select {
@@ -473,23 +822,33 @@ func writeWebSocketClient(client *WebSocketClient) {
 				return
 			}
 
+			client.Conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
 			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				log.Printf("WebSocket write error: %v\n", err)
 				return
 			}
+		case <-ticker.C:
+			// 写协程是唯一的写入方，ping 与消息不会并发写入
+			if err := client.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteWait)); err != nil {
+				log.Printf("WebSocket ping error: %v\n", err)
+				return
+			}
 		}
 	}
 }
 
 func monitorWorkflow(client *WebSocketClient, workflowID string, agentManager *agent.Manager) {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(agentManager.PollingInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-client.done:
+			// 客户端已断开，停止监控
+			return
 		case <-ticker.C:
-			ctx := context.Background()
-			status, err := agentManager.GetWorkflowStatus(ctx, workflowID)
+			ctx := logging.WithCorrelationID(context.Background(), workflowID)
+			status, err := agentManager.PollWorkflowStatus(ctx, workflowID)
 			if err != nil {
 				log.Printf("Get workflow status error: %v\n", err)
 				continue
@@ -504,13 +863,14 @@ func monitorWorkflow(client *WebSocketClient, workflowID string, agentManager *a
 				"timestamp":   time.Now().Unix(),
 			}
 
-			if err := client.Conn.WriteJSON(statusUpdate); err != nil {
-				log.Printf("WebSocket write error: %v\n", err)
+			if err := client.enqueue(statusUpdate); errors.Is(err, errClientClosed) {
 				return
+			} else if err != nil {
+				log.Printf("WebSocket write error: %v\n", err)
 			}
 
-			// 如果工作流完成或失败，停止监控
-			if status.Status == "completed" || status.Status == "failed" || status.Status == "cancelled" {
+			// 如果工作流完成、失败或轮询耗尽，停止监控
+			if agent.IsTerminalStatus(status.Status) {
 				return
 			}
 		}
@@ -528,9 +888,11 @@ func broadcastWorkflowUpdate(workflowID, status string, progress int, message st
 	}
 
 	// 向所有连接的客户端广播状态更新
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
 	for _, client := range clients {
-		// 直接通过 WebSocket 发送 JSON
-		if err := client.Conn.WriteJSON(statusUpdate); err != nil {
+		// 非阻塞放入发送缓冲区，慢客户端不会阻塞广播
+		if err := client.enqueue(statusUpdate); err != nil {
 			log.Printf("WebSocket broadcast error: %v\n", err)
 		}
 	}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"qng_agent/internal/config"
+	"qng_agent/internal/logging"
+	"qng_agent/internal/mcp"
+	"qng_agent/internal/metrics"
 	"qng_agent/internal/qng"
 	"qng_agent/internal/service"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -16,14 +21,26 @@ import (
 )
 
 func main() {
+	configPath := config.PathFlag()
+	flag.Parse()
+
 	log.Println("=== QNG Chain 服务启动 ===")
 
 	// 加载配置
-	cfg := config.LoadConfig("config/config.yaml")
+	cfg := config.LoadConfig(*configPath)
 	if cfg == nil {
 		log.Fatal("Failed to load config")
 	}
 
+	// 按 logging 配置初始化结构化日志
+	logCloser, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatal("Failed to set up logging:", err)
+	}
+	if logCloser != nil {
+		defer logCloser.Close()
+	}
+
 	// 获取服务注册中心
 	registry := service.GetRegistry()
 
@@ -36,6 +53,7 @@ func main() {
 			"/api/chain/process",
 			"/api/chain/status",
 			"/api/chain/nodes",
+			"/api/chain/graph",
 			"/health",
 		},
 		Metadata: map[string]string{
@@ -44,16 +62,34 @@ func main() {
 		},
 	}
 
+	if cfg.MCP.QNG.Chain.Sessions {
+		chainService.Endpoints = append(chainService.Endpoints, "/api/chain/sessions")
+	}
+
 	if err := registry.RegisterService(chainService); err != nil {
 		log.Fatal("Failed to register Chain service:", err)
 	}
 
 	// 初始化QNG Chain
-	chain := qng.NewChain(cfg.MCP.QNG)
+	chain, err := qng.NewChain(cfg.MCP.QNG)
+	if err != nil {
+		log.Fatal("Failed to create chain:", err)
+	}
 	log.Printf("🔗 初始化QNG链，RPC: %s", cfg.MCP.QNG.Chain.RPCURL)
 
-	// 启动Chain服务
-	if err := chain.Start(); err != nil {
+	// 可选的会话与长轮询，复用 MCP QNG 服务的会话管理，会话只保存在内存中
+	var sessions *mcp.QNGServer
+	if cfg.MCP.QNG.Chain.Sessions {
+		sessions = mcp.NewQNGServerWithChain(cfg.MCP.QNG, chain)
+	}
+
+	// 启动Chain服务，启用会话时由会话服务器启动Chain
+	if sessions != nil {
+		err = sessions.Start()
+	} else {
+		err = chain.Start()
+	}
+	if err != nil {
 		log.Fatal("Failed to start chain:", err)
 	}
 	log.Println("✅ QNG Chain已启动")
@@ -61,6 +97,7 @@ func main() {
 	// 创建HTTP服务器
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	router.Use(logging.Middleware())
 
 	// 健康检查端点
 	router.GET("/health", func(c *gin.Context) {
@@ -71,6 +108,9 @@ func main() {
 		})
 	})
 
+	// Prometheus指标，monitoring.metrics.port 由 agent 进程监听，本服务在API端口导出
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// Chain API端点
 	api := router.Group("/api/chain")
 	{
@@ -85,7 +125,7 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
+			ctx := context.WithoutCancel(c.Request.Context())
 			result, err := chain.ProcessMessage(ctx, req.Message)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -100,7 +140,7 @@ func main() {
 			status := map[string]interface{}{
 				"running":       true,
 				"chain_rpc":     cfg.MCP.QNG.Chain.RPCURL,
-				"graph_nodes":   len(cfg.MCP.QNG.Chain.LangGraph.Nodes),
+				"graph_nodes":   len(chain.Nodes()),
 				"poll_interval": 5000, // 默认5秒
 				"timestamp":     time.Now().Unix(),
 			}
@@ -110,37 +150,25 @@ func main() {
 
 		// 获取节点信息
 		api.GET("/nodes", func(c *gin.Context) {
-			nodes := map[string]interface{}{
-				"task_decomposer": map[string]interface{}{
-					"name":   "task_decomposer",
-					"type":   "llm_processor",
-					"status": "active",
-				},
-				"swap_executor": map[string]interface{}{
-					"name":   "swap_executor",
-					"type":   "transaction_executor",
-					"status": "active",
-				},
-				"stake_executor": map[string]interface{}{
-					"name":   "stake_executor",
-					"type":   "transaction_executor",
-					"status": "active",
-				},
-				"signature_validator": map[string]interface{}{
-					"name":   "signature_validator",
-					"type":   "validator",
-					"status": "active",
-				},
-				"result_aggregator": map[string]interface{}{
-					"name":   "result_aggregator",
-					"type":   "aggregator",
-					"status": "active",
-				},
-			}
+			// 直接读取已注册的图节点，避免与实际节点不一致
+			nodes := chain.Nodes()
 
 			c.JSON(http.StatusOK, gin.H{"nodes": nodes})
 		})
 
+		// 导出工作流图结构，format=dot 时返回 Graphviz DOT，默认返回JSON
+		api.GET("/graph", func(c *gin.Context) {
+			export := chain.Graph()
+			switch c.DefaultQuery("format", "json") {
+			case "json":
+				c.JSON(http.StatusOK, gin.H{"graph": export})
+			case "dot":
+				c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(export.DOT()))
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, use json or dot"})
+			}
+		})
+
 		// 继续工作流（带签名）
 		api.POST("/continue", func(c *gin.Context) {
 			var req struct {
@@ -153,7 +181,7 @@ func main() {
 				return
 			}
 
-			ctx := context.Background()
+			ctx := context.WithoutCancel(c.Request.Context())
 			result, err := chain.ContinueWithSignature(ctx, req.WorkflowContext, req.Signature)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -162,6 +190,11 @@ func main() {
 
 			c.JSON(http.StatusOK, gin.H{"result": result})
 		})
+
+		if sessions != nil {
+			registerSessionRoutes(api.Group("/sessions"), sessions)
+			log.Println("✅ 已启用会话与长轮询接口: /api/chain/sessions")
+		}
 	}
 
 	// 启动HTTP服务器
@@ -212,8 +245,13 @@ func main() {
 	// 注销服务
 	registry.UnregisterService("chain")
 
-	// 关闭Chain
-	if err := chain.Stop(); err != nil {
+	// 关闭Chain，启用会话时同时取消仍在执行的工作流
+	if sessions != nil {
+		err = sessions.Stop()
+	} else {
+		err = chain.Stop()
+	}
+	if err != nil {
 		log.Printf("关闭Chain服务时出错: %v", err)
 	}
 
@@ -227,3 +265,109 @@ func main() {
 
 	log.Println("Chain服务已关闭")
 }
+
+// defaultPollTimeout 长轮询未指定 timeout 时的等待时间（秒）
+const defaultPollTimeout = 30
+
+// registerSessionRoutes 注册会话接口：提交工作流后通过长轮询等待签名请求或结果，
+// 签名提交到会话，调用方不再需要保存工作流上下文
+func registerSessionRoutes(group *gin.RouterGroup, sessions *mcp.QNGServer) {
+	// 创建会话并异步执行工作流
+	group.POST("", func(c *gin.Context) {
+		var req struct {
+			Message     string `json:"message"`
+			UserID      string `json:"user_id"`
+			UserAddress string `json:"user_address"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := sessions.Call(c.Request.Context(), "execute_workflow", map[string]any{
+			"message":      req.Message,
+			"user_id":      req.UserID,
+			"user_address": req.UserAddress,
+		})
+		if err != nil {
+			c.JSON(mcp.ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	})
+
+	// 获取会话状态
+	group.GET("/:id", func(c *gin.Context) {
+		result, err := sessions.Call(c.Request.Context(), "get_session_status", map[string]any{
+			"session_id": c.Param("id"),
+		})
+		if err != nil {
+			c.JSON(mcp.ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	})
+
+	// 长轮询等待会话的下一个更新，timeout 为秒数
+	group.GET("/:id/poll", func(c *gin.Context) {
+		timeout, err := strconv.Atoi(c.DefaultQuery("timeout", strconv.Itoa(defaultPollTimeout)))
+		if err != nil || timeout <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be a positive number of seconds"})
+			return
+		}
+
+		result, err := sessions.Call(c.Request.Context(), "poll_session", map[string]any{
+			"session_id": c.Param("id"),
+			"timeout":    timeout,
+		})
+		if err != nil {
+			c.JSON(mcp.ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	})
+
+	// 提交签名，工作流在会话中继续执行
+	group.POST("/:id/signature", func(c *gin.Context) {
+		var req struct {
+			Signature string `json:"signature"`
+			// Gas 可选的 gas 参数，提高 gas 重新广播交易时提交
+			Gas map[string]any `json:"gas"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		params := map[string]any{
+			"session_id": c.Param("id"),
+			"signature":  req.Signature,
+		}
+		qng.CopyGasParams(params, req.Gas)
+		result, err := sessions.Call(c.Request.Context(), "submit_signature", params)
+		if err != nil {
+			c.JSON(mcp.ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"result": result})
+	})
+
+	// 取消会话
+	group.POST("/:id/cancel", func(c *gin.Context) {
+		result, err := sessions.Call(c.Request.Context(), "cancel_workflow", map[string]any{
+			"session_id": c.Param("id"),
+		})
+		if err != nil {
+			c.JSON(mcp.ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"result": result})
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/mcp"
+	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newSessionRouter 使用仓库配置、模拟LLM与模拟RPC节点注册会话接口。
+// release 关闭前交易收据查询一直阻塞，签名后的工作流停留在 running 状态
+func newSessionRouter(t *testing.T) (http.Handler, chan struct{}) {
+	t.Helper()
+	// 合约配置按仓库根目录的相对路径加载
+	t.Chdir("../..")
+
+	cfg := config.LoadConfig("config/config.yaml")
+	if cfg == nil {
+		t.Fatal("failed to load config/config.yaml")
+	}
+
+	release := make(chan struct{})
+	node := rpc.NewMockNode()
+	node.On("eth_getTransactionReceipt", func(params []interface{}) (interface{}, *rpc.RPCError) {
+		<-release
+		return nil, nil
+	})
+	t.Cleanup(node.Close)
+
+	qngConfig := cfg.MCP.QNG
+	qngConfig.Chain.LLM = config.LLMConfig{Provider: llm.ProviderMock}
+	qngConfig.Chain.RPCURL = node.URL()
+	qngConfig.Chain.Signer.Enabled = false
+	qngConfig.Chain.Transaction = config.TransactionConfig{
+		ConfirmationTimeout:   10,
+		PollingInterval:       1,
+		RequiredConfirmations: 1,
+		ConfirmationStrategy:  rpc.StrategyConfirmations,
+		// 模拟节点的交易哈希没有对应的签名数据，无法恢复签名者
+		PermissiveSignatures: true,
+	}
+
+	chain, err := qng.NewChain(qngConfig)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	sessions := mcp.NewQNGServerWithChain(qngConfig, chain)
+	if err := sessions.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		close(release)
+		sessions.Stop()
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerSessionRoutes(router.Group("/api/chain/sessions"), sessions)
+	return router, release
+}
+
+// serveJSON 发送请求并解码 JSON 响应
+func serveJSON(t *testing.T, router http.Handler, method, path string, body any) map[string]any {
+	t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", method, path, rec.Code, rec.Body.String())
+	}
+	var result map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%s %s: decode response: %v", method, path, err)
+	}
+	return result
+}
+
+// TestSessionRoutes 创建会话 → 长轮询到签名请求 → 提交签名 → 取消会话
+func TestSessionRoutes(t *testing.T) {
+	router, _ := newSessionRouter(t)
+	const base = "/api/chain/sessions"
+
+	created := serveJSON(t, router, http.MethodPost, base, map[string]any{
+		"message":      "兑换1 MEER的MTK",
+		"user_address": "0x00000000000000000000000000000000000000aa",
+	})
+	sessionID, _ := created["session_id"].(string)
+	if sessionID == "" {
+		t.Fatalf("create response = %v, want a session_id", created)
+	}
+
+	var status *mcp.WorkflowStatus
+	for range 5 {
+		poll := serveJSON(t, router, http.MethodGet, base+"/"+sessionID+"/poll?timeout=5", nil)
+		var err error
+		if status, err = mcp.WorkflowStatusFromPoll(sessionID, poll); err != nil {
+			t.Fatalf("WorkflowStatusFromPoll: %v", err)
+		}
+		if status.Status == "waiting_signature" {
+			break
+		}
+	}
+	if status.Status != "waiting_signature" || status.SignatureRequest == nil || status.SignatureRequest.Action != "swap" {
+		t.Fatalf("poll status = %+v, want a swap signature request", status)
+	}
+
+	// gas 中只有 gas 参数被转发，其中的 session_id 不能把签名提交到其他会话
+	serveJSON(t, router, http.MethodPost, base+"/"+sessionID+"/signature", map[string]any{
+		"signature": fmt.Sprintf("0x%064x", 0x5e55),
+		"gas":       map[string]any{"gas_price": "2000000000", "session_id": "session_other"},
+	})
+	if got := serveJSON(t, router, http.MethodGet, base+"/"+sessionID, nil); got["status"] != "running" {
+		t.Fatalf("status after signature = %v, want running", got["status"])
+	}
+
+	serveJSON(t, router, http.MethodPost, base+"/"+sessionID+"/cancel", nil)
+	if got := serveJSON(t, router, http.MethodGet, base+"/"+sessionID, nil); got["status"] != mcp.StatusCancelled {
+		t.Errorf("status after cancel = %v, want %s", got["status"], mcp.StatusCancelled)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"qng_agent/internal/config"
+	"qng_agent/internal/logging"
 	"qng_agent/internal/mcp"
+	"qng_agent/internal/metrics"
+	"qng_agent/internal/qng"
 	"qng_agent/internal/service"
 	"time"
 
@@ -12,14 +16,26 @@ import (
 )
 
 func main() {
+	configPath := config.PathFlag()
+	flag.Parse()
+
 	log.Println("=== QNG MCP 服务启动 ===")
 
 	// 加载配置
-	cfg := config.LoadConfig("config/config.yaml")
+	cfg := config.LoadConfig(*configPath)
 	if cfg == nil {
 		log.Fatal("Failed to load config")
 	}
 
+	// 按 logging 配置初始化结构化日志
+	logCloser, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatal("Failed to set up logging:", err)
+	}
+	if logCloser != nil {
+		defer logCloser.Close()
+	}
+
 	// 获取服务注册中心
 	registry := service.GetRegistry()
 
@@ -51,6 +67,11 @@ func main() {
 	mcpServer := mcp.NewServer(cfg.MCP)
 	log.Println("✅ MCP服务器初始化成功")
 
+	// 持久化会话，重启后等待签名的工作流可以继续
+	if err := mcpServer.EnableSessionStore(cfg.Database); err != nil {
+		log.Fatal("Failed to open session store:", err)
+	}
+
 	// 启动MCP服务器
 	if err := mcpServer.Start(); err != nil {
 		log.Fatal("Failed to start MCP server:", err)
@@ -65,6 +86,7 @@ func main() {
 	// 创建HTTP服务器
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	router.Use(logging.Middleware())
 
 	// 添加CORS中间件
 	router.Use(func(c *gin.Context) {
@@ -89,6 +111,9 @@ func main() {
 		})
 	})
 
+	// Prometheus指标，monitoring.metrics.port 由 agent 进程监听，本服务在API端口导出
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// API路由
 	api := router.Group("/api/mcp")
 	{
@@ -108,7 +133,7 @@ func main() {
 			ctx := c.Request.Context()
 			result, err := mcpServer.Call(ctx, req.Server, req.Method, req.Params)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -131,7 +156,7 @@ func main() {
 				"message": req.Message,
 			})
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -149,7 +174,7 @@ func main() {
 				"session_id": workflowID,
 			})
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
@@ -162,6 +187,8 @@ func main() {
 
 			var req struct {
 				Signature string `json:"signature"`
+				// Gas 可选的 gas 参数，提高 gas 重新广播交易时提交
+				Gas map[string]any `json:"gas"`
 			}
 
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -170,22 +197,49 @@ func main() {
 			}
 
 			ctx := c.Request.Context()
-			result, err := mcpServer.Call(ctx, "qng", "submit_signature", map[string]any{
+			params := map[string]any{
 				"session_id": workflowID,
 				"signature":  req.Signature,
+			}
+			qng.CopyGasParams(params, req.Gas)
+			result, err := mcpServer.Call(ctx, "qng", "submit_signature", params)
+			if err != nil {
+				c.JSON(mcp.ErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"result": result})
+		})
+
+		// 取消工作流
+		api.POST("/qng/workflow/:id/cancel", func(c *gin.Context) {
+			workflowID := c.Param("id")
+
+			ctx := c.Request.Context()
+			result, err := mcpServer.Call(ctx, "qng", "cancel_workflow", map[string]any{
+				"session_id": workflowID,
 			})
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.JSON(mcp.ErrorResponse(err))
 				return
 			}
 
 			c.JSON(http.StatusOK, gin.H{"result": result})
 		})
 
-		// 获取能力
+		// 获取能力，描述按 Accept-Language 本地化，能力与参数名称不变
 		api.GET("/capabilities", func(c *gin.Context) {
-			capabilities := mcpServer.GetCapabilities()
-			c.JSON(http.StatusOK, gin.H{"capabilities": capabilities})
+			locale := mcp.NegotiateLocale(c.GetHeader("Accept-Language"))
+			report, errs := mcpServer.CapabilityReport()
+			capabilities := mcp.LocalizeCapabilities(report, locale)
+			c.Header("Content-Language", locale)
+			c.Header("Vary", "Accept-Language")
+			response := gin.H{"capabilities": capabilities}
+			// 部分服务失败时仍返回其它服务的能力，失败的服务列在 errors 中
+			if len(errs) > 0 {
+				response["errors"] = errs
+			}
+			c.JSON(http.StatusOK, response)
 		})
 	}
 
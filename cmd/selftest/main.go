@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/mcp"
+	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+	"time"
+)
+
+// selftestMessage 标准的兑换后质押工作流
+const selftestMessage = "兑换1 MEER的MTK，然后质押"
+
+// selftestAddress 模拟用户地址，用于覆盖余额检查
+const selftestAddress = "0x00000000000000000000000000000000000000aa"
+
+// expectedActions 标准工作流依次需要签名的操作
+var expectedActions = []string{"swap", "approve", "stake"}
+
+// check 单项检查结果
+type check struct {
+	name   string
+	passed bool
+	detail string
+}
+
+func main() {
+	configPath := config.PathFlag()
+	timeout := flag.Duration("timeout", 2*time.Minute, "整个自检的超时时间")
+	flag.Parse()
+
+	log.Println("=== QNG Agent 自检 ===")
+
+	checks := run(*configPath, *timeout)
+
+	fmt.Println()
+	fmt.Println("========== 自检结果 ==========")
+	failed := 0
+	for _, c := range checks {
+		mark := "✅ PASS"
+		if !c.passed {
+			mark = "❌ FAIL"
+			failed++
+		}
+		if c.detail != "" {
+			fmt.Printf("%s  %s (%s)\n", mark, c.name, c.detail)
+		} else {
+			fmt.Printf("%s  %s\n", mark, c.name)
+		}
+	}
+	fmt.Printf("共 %d 项，通过 %d 项，失败 %d 项\n", len(checks), len(checks)-failed, failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// run 使用模拟LLM与模拟RPC节点端到端执行标准工作流，返回各项检查结果
+func run(configPath string, timeout time.Duration) []check {
+	var checks []check
+	pass := func(name, detail string) {
+		checks = append(checks, check{name: name, passed: true, detail: detail})
+	}
+	fail := func(name string, err error) []check {
+		return append(checks, check{name: name, passed: false, detail: err.Error()})
+	}
+
+	// 加载配置
+	cfg := config.LoadConfig(configPath)
+	if cfg == nil {
+		return fail("加载配置", fmt.Errorf("无法加载 %s", configPath))
+	}
+	pass("加载配置", configPath)
+
+	// 启动模拟RPC节点
+	node := rpc.NewMockNode()
+	defer node.Close()
+
+	// 使用模拟LLM与模拟节点，其余配置保持部署时的设置
+	qngConfig := cfg.MCP.QNG
+	qngConfig.Chain.LLM = config.LLMConfig{Provider: llm.ProviderMock}
+	qngConfig.Chain.RPCURL = node.URL()
+	qngConfig.Chain.Signer.Enabled = false
+	qngConfig.Chain.Transaction = config.TransactionConfig{
+		ConfirmationTimeout:   10,
+		PollingInterval:       1,
+		RequiredConfirmations: 1,
+		ConfirmationStrategy:  rpc.StrategyConfirmations,
+		GasBufferPercent:      qngConfig.Chain.Transaction.GasBufferPercent,
+		// 模拟节点的交易哈希没有对应的签名数据，无法恢复签名者
+		PermissiveSignatures: true,
+	}
+
+	// 构建合约管理器与工作流图
+	chain, err := qng.NewChain(qngConfig)
+	if err != nil {
+		return fail("构建工作流链", err)
+	}
+	if err := chain.Start(); err != nil {
+		return fail("启动工作流链", err)
+	}
+	defer chain.Stop()
+
+	tokens, err := chain.GetTokens()
+	if err != nil {
+		return fail("加载合约配置", err)
+	}
+	pass("加载合约配置", fmt.Sprintf("%d 个代币", len(tokens)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, "workflow_id", "selftest")
+	ctx = context.WithValue(ctx, "session_id", "selftest")
+	ctx = context.WithValue(ctx, "user_address", selftestAddress)
+
+	result, err := chain.ProcessMessage(ctx, selftestMessage)
+	if err != nil {
+		return fail("执行工作流", err)
+	}
+	pass("执行工作流", selftestMessage)
+
+	// 依次提交模拟交易哈希，校验签名请求顺序
+	for i, expected := range expectedActions {
+		name := fmt.Sprintf("签名请求 %d/%d: %s", i+1, len(expectedActions), expected)
+		if result == nil || !result.NeedSignature {
+			return fail(name, fmt.Errorf("工作流没有请求签名"))
+		}
+
+		// 经规范状态转换后签名请求不丢失字段，经 JSON 往返（HTTP 调用）后仍一致
+		status := mcp.StatusFromResult(result)
+		if err := checkStatus(status, "waiting_signature", expected); err != nil {
+			return fail(name, err)
+		}
+		if err := checkStatus(roundTrip(status), "waiting_signature", expected); err != nil {
+			return fail(name, fmt.Errorf("JSON 往返后: %w", err))
+		}
+		pass(name, "")
+
+		txHash := fmt.Sprintf("0x%064x", 0x5e1f7e57+i)
+		result, err = chain.ContinueWithSignature(ctx, result.WorkflowContext, txHash)
+		if err != nil {
+			return fail(fmt.Sprintf("确认交易: %s", expected), err)
+		}
+	}
+
+	// 校验聚合结果
+	if result == nil || result.NeedSignature {
+		return fail("聚合结果", fmt.Errorf("签名完成后工作流仍未结束"))
+	}
+	finalResult, _ := result.FinalResult.(map[string]any)
+	status, _ := finalResult["status"].(string)
+	success, _ := finalResult["success"].(bool)
+	if status != "completed" || !success {
+		return fail("聚合结果", fmt.Errorf("status=%q success=%v", status, success))
+	}
+	if err := checkStatus(roundTrip(mcp.StatusFromResult(result)), "completed", ""); err != nil {
+		return fail("聚合结果", err)
+	}
+	pass("聚合结果", status)
+
+	if receipts := node.CallsTo("eth_getTransactionReceipt"); receipts < len(expectedActions) {
+		return fail("交易确认", fmt.Errorf("只查询了 %d 次交易收据", receipts))
+	}
+	pass("交易确认", fmt.Sprintf("%d 次RPC调用", len(node.Calls())))
+
+	return checks
+}
+
+// checkStatus 检查工作流状态与签名请求的操作
+func checkStatus(status *mcp.WorkflowStatus, expectedStatus, expectedAction string) error {
+	if status.Status != expectedStatus {
+		return fmt.Errorf("状态为 %q，期望 %q", status.Status, expectedStatus)
+	}
+	if expectedAction == "" {
+		return nil
+	}
+	if status.SignatureRequest == nil {
+		return fmt.Errorf("缺少签名请求")
+	}
+	if status.SignatureRequest.Action != expectedAction {
+		return fmt.Errorf("收到 %q", status.SignatureRequest.Action)
+	}
+	if status.SignatureRequest.ToAddress == "" || status.SignatureRequest.Data == "" {
+		return fmt.Errorf("签名请求缺少交易字段")
+	}
+	return nil
+}
+
+// roundTrip 按 get_session_status 经 HTTP 返回的形式编码后重新转换工作流状态
+func roundTrip(status *mcp.WorkflowStatus) *mcp.WorkflowStatus {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return &mcp.WorkflowStatus{}
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return &mcp.WorkflowStatus{}
+	}
+	return mcp.WorkflowStatusFromMap(fields)
+}
@@ -728,25 +728,41 @@ function App() {
       const fromAddress = accounts[0];
       console.log('📋 发送方地址:', fromAddress);
 
-      // 构建交易数据
-      const transactionData = {
-        from: fromAddress, // 添加发送方地址
-        to: signatureRequest.to_address || signatureRequest.ToAddress,
-        value: signatureRequest.value || signatureRequest.Value || '0x0',
-        data: signatureRequest.data || signatureRequest.Data || '0x',
-        gas: signatureRequest.gas_limit || signatureRequest.GasLimit || '0x186A0', // 100000 gas
-        gasPrice: signatureRequest.gas_price || signatureRequest.GasPrice || '0x3B9ACA00' // 1 gwei
-      };
+      // 构建交易数据，批量签名请求中的每笔交易互不依赖
+      const requests = signatureRequest.type === 'batch_transaction_signature'
+        ? signatureRequest.requests
+        : [signatureRequest];
+      const transactions = requests.map((request) => {
+        const transactionData = {
+          from: fromAddress, // 添加发送方地址
+          to: request.to_address || request.ToAddress,
+          value: request.value || request.Value || '0x0',
+          data: request.data || request.Data || '0x',
+          gas: request.gas_limit || request.GasLimit || '0x186A0', // 100000 gas
+        };
+        // EIP-1559 网络使用 maxFeePerGas/maxPriorityFeePerGas，传统网络使用 gasPrice
+        if (request.max_fee_per_gas) {
+          transactionData.maxFeePerGas = request.max_fee_per_gas;
+          transactionData.maxPriorityFeePerGas = request.max_priority_fee_per_gas;
+        } else {
+          transactionData.gasPrice = request.gas_price || request.GasPrice || '0x3B9ACA00'; // 1 gwei
+        }
+        // 取消或加速交易必须复用被替换交易的 nonce
+        if (request.nonce) {
+          transactionData.nonce = request.nonce;
+        }
 
-      console.log('📝 交易数据:', transactionData);
-      
-      // 验证必需字段
-      if (!transactionData.to) {
-        throw new Error('缺少交易目标地址 (to)');
-      }
-      if (!transactionData.from) {
-        throw new Error('缺少发送方地址 (from)');
-      }
+        // 验证必需字段
+        if (!transactionData.to) {
+          throw new Error('缺少交易目标地址 (to)');
+        }
+        if (!transactionData.from) {
+          throw new Error('缺少发送方地址 (from)');
+        }
+        return transactionData;
+      });
+
+      console.log('📝 交易数据:', transactions);
 
       // 检查MetaMask状态
       console.log('🔍 检查MetaMask状态...');
@@ -782,16 +798,20 @@ function App() {
 
       // 请求用户授权（确保MetaMask获得焦点）
       console.log('🚀 发起MetaMask签名请求...');
-      console.log('📋 请求参数:', JSON.stringify(transactionData, null, 2));
+      console.log('📋 请求参数:', JSON.stringify(transactions, null, 2));
       
       // 尝试不同的方法来确保弹窗显示
       let signature;
       try {
-        // 方法1: 使用 eth_sendTransaction
-        signature = await window.ethereum.request({
-          method: 'eth_sendTransaction',
-          params: [transactionData]
-        });
+        // 方法1: 使用 eth_sendTransaction，批量请求按顺序逐笔发送，交易哈希以逗号分隔提交
+        const hashes = [];
+        for (const transactionData of transactions) {
+          hashes.push(await window.ethereum.request({
+            method: 'eth_sendTransaction',
+            params: [transactionData]
+          }));
+        }
+        signature = hashes.join(',');
       } catch (sendError) {
         console.log('❌ eth_sendTransaction 失败:', sendError);
         
@@ -1168,16 +1188,22 @@ function App() {
         {signatureRequest && (
           <div className="signature-request">
             <h3>✍️ 交易签名请求</h3>
-            <div className="signature-details">
-              <p><strong>操作:</strong> {signatureRequest.action}</p>
-              <p><strong>从:</strong> {signatureRequest.from_token}</p>
-              <p><strong>到:</strong> {signatureRequest.to_token}</p>
-              <p><strong>数量:</strong> {signatureRequest.amount}</p>
-              <p><strong>Gas费:</strong> {signatureRequest.gas_fee}</p>
-              <p><strong>滑点:</strong> {signatureRequest.slippage}</p>
-              <p><strong>合约地址:</strong> {signatureRequest.to_address}</p>
-              <p><strong>交易值:</strong> {signatureRequest.value}</p>
-            </div>
+            {(signatureRequest.requests || [signatureRequest]).map((request, index) => (
+              <div className="signature-details" key={request.task_id || index}>
+                {signatureRequest.requests && <p><strong>交易 {index + 1}/{signatureRequest.requests.length}:</strong> {request.task_id}</p>}
+                <p><strong>操作:</strong> {request.action}</p>
+                <p><strong>从:</strong> {request.from_token}</p>
+                <p><strong>到:</strong> {request.to_token}</p>
+                <p><strong>数量:</strong> {request.amount_display || request.amount}</p>
+                {request.min_output_display && <p><strong>最少获得:</strong> {request.min_output_display}</p>}
+                <p><strong>Gas费:</strong> {request.gas_fee}</p>
+                <p><strong>滑点:</strong> {request.slippage}</p>
+                <p><strong>合约地址:</strong> {request.to_address}</p>
+                {request.spender_address && <p><strong>授权对象:</strong> {request.spender} ({request.spender_address})</p>}
+                {request.warning && <p className="signature-warning">⚠️ {request.warning}</p>}
+                <p><strong>交易值:</strong> {request.value}</p>
+              </div>
+            ))}
             
             <div className="signature-actions">
               <button 
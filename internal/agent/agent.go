@@ -2,12 +2,18 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"qng_agent/internal/config"
+	"qng_agent/internal/contracts"
 	"qng_agent/internal/llm"
 	"qng_agent/internal/mcp"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +22,9 @@ type Agent struct {
 	llmClient llm.Client
 	mcpServer *mcp.Server
 	running   bool
+
+	tokens   []string
+	tokensMu sync.Mutex
 }
 
 type WorkflowExecution struct {
@@ -31,9 +40,14 @@ type WorkflowExecution struct {
 	UpdatedAt    time.Time              `json:"updated_at"`
 }
 
-func NewAgent(config config.AgentConfig) *Agent {
+// NewAgent 创建智能体。严格模式下没有可用的LLM提供商时返回错误，调用方应拒绝启动
+func NewAgent(config config.AgentConfig) (*Agent, error) {
 	// 创建LLM客户端
 	llmClient, err := llm.NewClient(config.LLM)
+	if errors.Is(err, llm.ErrNoProvider) {
+		log.Printf("❌ 无法创建LLM客户端: %v", err)
+		return nil, fmt.Errorf("failed to create LLM client: %w", err)
+	}
 	if err != nil {
 		log.Printf("⚠️  无法创建LLM客户端: %v", err)
 		llmClient = nil
@@ -48,7 +62,7 @@ func NewAgent(config config.AgentConfig) *Agent {
 		mcpServer: mcpServer,
 	}
 
-	return agent
+	return agent, nil
 }
 
 func (a *Agent) Start() error {
@@ -132,20 +146,21 @@ func (a *Agent) analyzeMessage(ctx context.Context, message string) (*WorkflowIn
 3. transfer - 代币转账
 4. query - 余额查询
 
-请按以下JSON格式返回分析结果:
+支持的代币: %s
+
+请按以下JSON格式返回分析结果，参数必须来自用户消息，不要编造:
 {
   "type": "swap",
-  "description": "用户想要将USDT兑换成BTC",
+  "description": "用户想要将MEER兑换成MTK",
   "parameters": {
-    "from_token": "USDT",
-    "to_token": "BTC", 
-    "amount": "1000"
+    "from_token": "MEER",
+    "to_token": "MTK"
   }
 }
 
 只返回JSON格式，不要其他文字。
 如果找到相近的多个tool，请提示用户选择。3 tool [{},{},{}]
-`, message)
+`, message, strings.Join(a.supportedTokens(), ", "))
 	log.Printf("📝 构建LLM提示完成")
 	log.Printf("📝 提示长度: %d", len(prompt))
 
@@ -176,81 +191,170 @@ func (a *Agent) simpleMessageAnalysis(message string) (*WorkflowInfo, error) {
 	log.Printf("🔄 使用简单规则分析消息")
 	log.Printf("📝 消息: %s", message)
 	
-	lowerMsg := strings.ToLower(message)
+	return a.keywordWorkflow(message), nil
+}
+
+func (a *Agent) parseWorkflowFromResponse(response string) (*WorkflowInfo, error) {
+	log.Printf("🔄 解析LLM响应中的工作流")
+	log.Printf("📄 响应内容: %s", response)
+	
+	workflow, err := decodeWorkflowJSON(response)
+	if err == nil {
+		log.Printf("✅ 成功解析JSON工作流: %s %+v", workflow.Type, workflow.Parameters)
+		return workflow, nil
+	}
+	
+	log.Printf("⚠️  JSON解析失败，使用关键词匹配: %v", err)
+	return a.keywordWorkflowFromResponse(response)
+}
+
+// decodeWorkflowJSON 从LLM响应中提取并解析工作流JSON，响应为候选列表时取第一项
+func decodeWorkflowJSON(response string) (*WorkflowInfo, error) {
+	objStart := strings.Index(response, "{")
+	arrStart := strings.Index(response, "[")
+	
+	var candidates []WorkflowInfo
+	switch {
+	case arrStart >= 0 && (objStart < 0 || arrStart < objStart):
+		end := strings.LastIndex(response, "]")
+		if end <= arrStart {
+			return nil, fmt.Errorf("unterminated JSON array in response")
+		}
+		if err := json.Unmarshal([]byte(response[arrStart:end+1]), &candidates); err != nil {
+			return nil, err
+		}
+	case objStart >= 0:
+		end := strings.LastIndex(response, "}")
+		if end <= objStart {
+			return nil, fmt.Errorf("unterminated JSON object in response")
+		}
+		var workflow WorkflowInfo
+		if err := json.Unmarshal([]byte(response[objStart:end+1]), &workflow); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, workflow)
+	default:
+		return nil, fmt.Errorf("no JSON found in response")
+	}
+	
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("empty workflow list in response")
+	}
+	if len(candidates) > 1 {
+		log.Printf("⚠️  LLM返回了 %d 个候选工作流，使用第一个", len(candidates))
+	}
+	
+	workflow := candidates[0]
+	// 数量由链上任务分解统一解析，这里不保留LLM给出的数量
+	delete(workflow.Parameters, "amount")
+	workflow.Type = strings.ToLower(strings.TrimSpace(workflow.Type))
+	switch workflow.Type {
+	case "swap", "stake", "transfer", "query":
+	default:
+		return nil, fmt.Errorf("unsupported workflow type: %q", workflow.Type)
+	}
+	if workflow.Parameters == nil {
+		workflow.Parameters = map[string]any{}
+	}
+	return &workflow, nil
+}
+
+// keywordWorkflowFromResponse 在响应无法解析为JSON时按关键词判断工作流类型
+func (a *Agent) keywordWorkflowFromResponse(response string) (*WorkflowInfo, error) {
+	return a.keywordWorkflow(response), nil
+}
+
+// keywordWorkflow 按关键词判断工作流类型，并从文本中提取支持的代币，
+// 未能识别的参数不会填充默认值
+func (a *Agent) keywordWorkflow(text string) *WorkflowInfo {
+	lowerText := strings.ToLower(text)
+	tokens := a.mentionedTokens(text)
 	
-	if strings.Contains(lowerMsg, "兑换") || strings.Contains(lowerMsg, "swap") {
+	if strings.Contains(lowerText, "兑换") || strings.Contains(lowerText, "swap") {
 		log.Printf("✅ 检测到兑换工作流")
+		params := map[string]any{}
+		if len(tokens) > 0 {
+			params["from_token"] = tokens[0]
+		}
+		if len(tokens) > 1 {
+			params["to_token"] = tokens[1]
+		}
 		return &WorkflowInfo{
-			Type: "swap",
+			Type:        "swap",
 			Description: "代币兑换",
-			Parameters: map[string]any{
-				"from_token": "USDT",
-				"to_token":   "BTC",
-				"amount":     "1000",
-			},
-		}, nil
+			Parameters:  params,
+		}
 	}
 	
-	if strings.Contains(lowerMsg, "质押") || strings.Contains(lowerMsg, "stake") {
+	if strings.Contains(lowerText, "质押") || strings.Contains(lowerText, "stake") {
 		log.Printf("✅ 检测到质押工作流")
+		params := map[string]any{}
+		if len(tokens) > 0 {
+			params["token"] = tokens[len(tokens)-1]
+		}
 		return &WorkflowInfo{
-			Type: "stake",
+			Type:        "stake",
 			Description: "代币质押",
-			Parameters: map[string]any{
-				"token":  "BTC",
-				"amount": "0.1",
-				"pool":   "compound",
-			},
-		}, nil
+			Parameters:  params,
+		}
 	}
 	
 	log.Printf("⚠️  未检测到具体工作流，使用默认查询")
 	return &WorkflowInfo{
-		Type: "query",
+		Type:        "query",
 		Description: "余额查询",
-		Parameters: map[string]any{},
-	}, nil
+		Parameters:  map[string]any{},
+	}
 }
 
-func (a *Agent) parseWorkflowFromResponse(response string) (*WorkflowInfo, error) {
-	log.Printf("🔄 解析LLM响应中的工作流")
-	log.Printf("📄 响应内容: %s", response)
+// tokenWordPattern 文本中的字母数字片段，代币符号必须完整匹配一个片段，MTK 不会匹配 MTKX
+var tokenWordPattern = regexp.MustCompile(`[A-Z0-9]+`)
+
+// mentionedTokens 按出现顺序返回文本中提到的支持代币
+func (a *Agent) mentionedTokens(text string) []string {
+	symbols := make(map[string]string)
+	for _, symbol := range a.supportedTokens() {
+		symbols[strings.ToUpper(symbol)] = symbol
+	}
 	
-	// 简化的解析，实际应该使用json.Unmarshal
-	lowerResponse := strings.ToLower(response)
+	var tokens []string
+	for _, word := range tokenWordPattern.FindAllString(strings.ToUpper(text), -1) {
+		if symbol, ok := symbols[word]; ok && !slices.Contains(tokens, symbol) {
+			tokens = append(tokens, symbol)
+		}
+	}
+	return tokens
+}
+
+// supportedTokens 通过QNG服务获取合约管理器中配置的代币，获取成功后缓存
+func (a *Agent) supportedTokens() []string {
+	a.tokensMu.Lock()
+	defer a.tokensMu.Unlock()
 	
-	if strings.Contains(lowerResponse, "swap") {
-		log.Printf("✅ 检测到swap工作流")
-		return &WorkflowInfo{
-			Type: "swap",
-			Description: "代币兑换",
-			Parameters: map[string]any{
-				"from_token": "USDT",
-				"to_token":   "BTC",
-				"amount":     "1000",
-			},
-		}, nil
+	if a.tokens != nil {
+		return a.tokens
 	}
 	
-	if strings.Contains(lowerResponse, "stake") {
-		log.Printf("✅ 检测到stake工作流")
-		return &WorkflowInfo{
-			Type: "stake",
-			Description: "代币质押",
-			Parameters: map[string]any{
-				"token":  "BTC",
-				"amount": "0.1",
-				"pool":   "compound",
-			},
-		}, nil
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	result, err := a.mcpServer.Call(ctx, "qng", "get_tokens", map[string]any{})
+	if err != nil {
+		log.Printf("⚠️  获取支持的代币失败: %v", err)
+		return nil
+	}
+	resMap, _ := result.(map[string]any)
+	tokens, ok := resMap["tokens"].([]contracts.TokenConfig)
+	if !ok {
+		return nil
 	}
 	
-	log.Printf("⚠️  未检测到具体工作流")
-	return &WorkflowInfo{
-		Type: "query",
-		Description: "余额查询",
-		Parameters: map[string]any{},
-	}, nil
+	a.tokens = make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		a.tokens = append(a.tokens, token.Symbol)
+	}
+	log.Printf("📋 支持的代币: %v", a.tokens)
+	return a.tokens
 }
 
 func (a *Agent) executeWorkflow(ctx context.Context, message string, workflow *WorkflowInfo) (*WorkflowExecution, error) {
@@ -337,69 +441,34 @@ func (a *Agent) PollWorkflowStatus(ctx context.Context, sessionID string) (*Work
 		return nil, fmt.Errorf("invalid result format")
 	}
 	
-	// 检查是否超时
-	if timeout, ok := resultMap["timeout"].(bool); ok && timeout {
-		log.Printf("⏰ 轮询超时")
-		return &WorkflowExecution{
-			SessionID: sessionID,
-			Status:    "timeout",
-			Message:   "轮询超时，请重试",
-			UpdatedAt: time.Now(),
-		}, nil
+	status, err := mcp.WorkflowStatusFromPoll(sessionID, resultMap)
+	if err != nil {
+		log.Printf("❌ 解析轮询结果失败: %v", err)
+		return nil, err
 	}
 	
-	// 检查是否取消
-	if cancelled, ok := resultMap["cancelled"].(bool); ok && cancelled {
-		log.Printf("🛑 会话已取消")
-		return &WorkflowExecution{
-			SessionID: sessionID,
-			Status:    "cancelled",
-			Message:   "会话已取消",
-			UpdatedAt: time.Now(),
-		}, nil
+	log.Printf("📋 工作流状态: %s", status.Status)
+	return executionFromStatus(status), nil
+}
+
+// executionFromStatus 从规范的工作流状态构建智能体的工作流执行视图
+func executionFromStatus(status *mcp.WorkflowStatus) *WorkflowExecution {
+	execution := &WorkflowExecution{
+		SessionID:     status.SessionID,
+		Status:        status.Status,
+		Message:       status.Message,
+		NeedSignature: status.NeedSignature,
+		CreatedAt:     status.CreatedAt,
+		UpdatedAt:     status.UpdatedAt,
 	}
-	
-	// 解析更新
-	update, ok := resultMap["update"].(map[string]any)
-	if !ok {
-		log.Printf("❌ 缺少update")
-		return nil, fmt.Errorf("missing update")
+	// 保持 nil 接口，避免序列化出 null 字段
+	if status.SignatureRequest != nil {
+		execution.SignatureRequest = status.SignatureRequest
 	}
-	
-	updateType, ok := update["type"].(string)
-	if !ok {
-		log.Printf("❌ 缺少update type")
-		return nil, fmt.Errorf("missing update type")
-	}
-	
-	log.Printf("📋 更新类型: %s", updateType)
-	
-	execution := &WorkflowExecution{
-		SessionID: sessionID,
-		UpdatedAt: time.Now(),
-	}
-	
-	switch updateType {
-	case "signature_request":
-		log.Printf("✍️  需要用户签名")
-		execution.Status = "waiting_signature"
-		execution.NeedSignature = true
-		execution.SignatureRequest = update["data"]
-		execution.Message = "需要用户签名授权"
-		
-	case "result":
-		log.Printf("✅ 工作流完成")
-		execution.Status = "completed"
-		execution.Result = update["data"]
-		execution.Message = "工作流执行完成"
-		
-	default:
-		log.Printf("⚠️  未知更新类型: %s", updateType)
-		execution.Status = "unknown"
-		execution.Message = fmt.Sprintf("未知更新类型: %s", updateType)
+	if status.Result != nil {
+		execution.Result = status.Result
 	}
-	
-	return execution, nil
+	return execution
 }
 
 func (a *Agent) SubmitSignature(ctx context.Context, sessionID, signature string) error {
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+)
+
+func TestNewAgentStrictWithoutProvider(t *testing.T) {
+	agent, err := NewAgent(config.AgentConfig{LLM: config.LLMConfig{Provider: llm.ProviderOpenAI, Strict: true}})
+	if !errors.Is(err, llm.ErrNoProvider) {
+		t.Fatalf("NewAgent error = %v, want ErrNoProvider", err)
+	}
+	if agent != nil {
+		t.Error("NewAgent returned an agent with an error")
+	}
+}
+
+func TestMentionedTokens(t *testing.T) {
+	a := &Agent{tokens: []string{"MEER", "MTK", "usdt"}}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "兑换1 MEER的MTK", want: []string{"MEER", "MTK"}},
+		{text: "把mtk换成meer", want: []string{"MTK", "MEER"}},
+		{text: "swap USDT to mtk, then stake MTK", want: []string{"usdt", "MTK"}},
+		{text: "MTKX 不是支持的代币"},
+		{text: "你好"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := a.mentionedTokens(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("mentionedTokens(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
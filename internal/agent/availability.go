@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"qng_agent/internal/mcp"
+)
+
+// serverUnavailableMessages 服务未启用时返回给用户的提示
+var serverUnavailableMessages = map[string]string{
+	"qng":      "区块链工作流服务未启用，暂时无法执行兑换、质押等操作。",
+	"metamask": "钱包功能未启用，暂时无法连接钱包或签名。请联系管理员在配置中开启 MetaMask 服务。",
+}
+
+// serverAvailable 通过 MCP 能力列表判断服务是否已启用
+func (m *Manager) serverAvailable(server string) bool {
+	return serverEnabled(m.mcpClient.GetCapabilities(), server)
+}
+
+// serverEnabled 判断能力列表中是否包含服务，服务标记为查询失败时视为不可用。
+// 能力列表为空（查询失败）时无法判断，视为可用，由实际调用返回错误。
+func serverEnabled(capabilities map[string]any, server string) bool {
+	if len(capabilities) == 0 {
+		return true
+	}
+	_, ok := capabilities[server]
+	return ok && serverError(capabilities, server) == ""
+}
+
+// serverError 返回能力列表中服务的失败信息，服务正常时为空
+func serverError(capabilities map[string]any, server string) string {
+	entry, ok := capabilities[server].(map[string]any)
+	if !ok {
+		return ""
+	}
+	message, _ := entry[mcp.CapabilityErrorKey].(string)
+	return message
+}
+
+// unavailableResponse 构建服务未启用时的友好回复
+func unavailableResponse(server string) *ProcessResponse {
+	message, ok := serverUnavailableMessages[server]
+	if !ok {
+		message = fmt.Sprintf("%s 服务未启用，暂时无法处理该请求。", server)
+	}
+	log.Printf("⚠️  服务 %s 未启用，返回提示信息", server)
+	return &ProcessResponse{
+		Response:   message,
+		ActionType: "service_unavailable",
+		ActionData: map[string]any{"server": server},
+		HandledBy:  HandledByUnavailable,
+	}
+}
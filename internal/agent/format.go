@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedFormat 不支持的回复格式
+var ErrUnsupportedFormat = errors.New("unsupported response format")
+
+// 聊天回复的输出格式
+const (
+	FormatMarkdown = "markdown"
+	FormatPlain    = "plain"
+	FormatJSON     = "json"
+)
+
+// formatInstructions 各输出格式追加到系统提示中的格式要求
+var formatInstructions = map[string]string{
+	FormatMarkdown: "请使用Markdown格式回复，可以使用标题、列表和代码块组织内容。",
+	FormatPlain:    "请使用纯文本回复，不要使用Markdown、代码块或任何标记语法。",
+	FormatJSON: `请只返回一个JSON对象，不要包含任何其他文字或代码块标记，格式如下：
+{"summary": "一句话总结", "details": "详细说明", "data": {工具结果中的关键字段}}`,
+}
+
+// normalizeFormat 校验并规范化输出格式，空值表示不限制格式
+func normalizeFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return "", nil
+	}
+	if _, ok := formatInstructions[format]; !ok {
+		return "", fmt.Errorf("%w: %q (expected markdown, plain or json)", ErrUnsupportedFormat, format)
+	}
+	return format, nil
+}
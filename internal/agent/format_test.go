@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"qng_agent/internal/llm"
+	"qng_agent/internal/mcp/mcptest"
+)
+
+// recordingLLM 记录每次 Chat 请求的消息后交给模拟LLM回复
+type recordingLLM struct {
+	llm.Client
+	requests [][]llm.Message
+}
+
+func (r *recordingLLM) Chat(ctx context.Context, messages []llm.Message, opts ...llm.ChatOption) (string, error) {
+	r.requests = append(r.requests, messages)
+	return r.Client.Chat(ctx, messages, opts...)
+}
+
+func TestProcessMessageFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		message string
+		want    string
+	}{
+		{name: "default tool result", format: "", message: "连接钱包"},
+		{name: "markdown tool result", format: "markdown", message: "连接钱包", want: FormatMarkdown},
+		{name: "plain tool result", format: "plain", message: "连接钱包", want: FormatPlain},
+		{name: "json tool result", format: "json", message: "连接钱包", want: FormatJSON},
+		{name: "format is case insensitive", format: " JSON ", message: "连接钱包", want: FormatJSON},
+		{name: "plain direct answer", format: "plain", message: "你好", want: FormatPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := mcptest.NewMockMCPServer().On("metamask", "connect_wallet", map[string]any{"connected": true}, nil)
+			manager := newTestManager(server)
+			recorder := &recordingLLM{Client: llm.NewMockClient()}
+			manager.llmClient = recorder
+
+			if _, err := manager.ProcessMessage(context.Background(), ProcessRequest{SessionID: "s1", Message: tt.message, Format: tt.format}); err != nil {
+				t.Fatalf("ProcessMessage: %v", err)
+			}
+			if len(recorder.requests) != 1 {
+				t.Fatalf("LLM called %d times, want 1", len(recorder.requests))
+			}
+
+			system := recorder.requests[0][0]
+			if system.Role != llm.RoleSystem {
+				t.Fatalf("first message role = %q, want system", system.Role)
+			}
+			for format, instruction := range formatInstructions {
+				if got := strings.Contains(system.Content, instruction); got != (format == tt.want) {
+					t.Errorf("system prompt contains %s instruction = %v, want %v", format, got, format == tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessMessageRejectsUnsupportedFormat(t *testing.T) {
+	server := mcptest.NewMockMCPServer()
+	manager := newTestManager(server)
+	recorder := &recordingLLM{Client: llm.NewMockClient()}
+	manager.llmClient = recorder
+
+	_, err := manager.ProcessMessage(context.Background(), ProcessRequest{SessionID: "s1", Message: "连接钱包", Format: "xml"})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+	if len(recorder.requests) != 0 || len(server.Calls()) != 0 {
+		t.Errorf("unsupported format reached the LLM (%d) or MCP (%d)", len(recorder.requests), len(server.Calls()))
+	}
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"log"
+	"qng_agent/internal/config"
+	"regexp"
+)
+
+// defaultGuardPatterns 内置的可疑指令规则：试图跳过确认、自动签名或清空资产转给指定地址
+var defaultGuardPatterns = []string{
+	`(sign|approve|confirm)\s+(it\s+|them\s+|everything\s+)?automatically`,
+	`auto[-\s]?(sign|approve|confirm)`,
+	`(without|skip|bypass|no)\s+(any\s+|the\s+)?(confirmation|confirm|signature|approval|prompt)`,
+	`自动(签名|确认|授权|批准)`,
+	`(跳过|绕过|不需要|无需|不用)(用户)?(确认|签名|授权)`,
+	`(send|transfer|move)\s+(all|everything|entire|whole)\b.*0x[0-9a-f]{40}`,
+	`(全部|所有|全部的).*(转|发送|转账).*0x[0-9a-f]{40}`,
+	`ignore\s+(all\s+)?(previous|prior|above)\s+(instructions|rules)`,
+	`忽略(之前|以上|上面|前面)的?(所有)?(指令|规则|说明|提示)`,
+}
+
+// promptGuard 在执行前检测试图绕过确认的可疑指令
+type promptGuard struct {
+	patterns []*regexp.Regexp
+}
+
+// newPromptGuard 编译检测规则，未启用时返回 nil；无效的自定义规则会被跳过
+func newPromptGuard(cfg config.PromptGuardConfig) *promptGuard {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	sources := cfg.Patterns
+	if len(sources) == 0 {
+		sources = defaultGuardPatterns
+	}
+
+	guard := &promptGuard{}
+	for _, source := range sources {
+		re, err := regexp.Compile("(?i)" + source)
+		if err != nil {
+			log.Printf("⚠️  忽略无效的检测规则 %q: %v", source, err)
+			continue
+		}
+		guard.patterns = append(guard.patterns, re)
+	}
+	return guard
+}
+
+// check 返回消息命中的片段，未命中时返回 nil
+func (g *promptGuard) check(message string) []string {
+	if g == nil {
+		return nil
+	}
+
+	var matches []string
+	for _, re := range g.patterns {
+		if match := re.FindString(message); match != "" {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
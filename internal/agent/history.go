@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"qng_agent/internal/llm"
+)
+
+// summaryPrompt 将较早的对话压缩为摘要的提示，%s 依次为已有摘要与新裁剪的消息
+const summaryPrompt = `请将以下对话概括为不超过200字的摘要，保留用户的目标、提到的代币、数量、地址和已完成的操作，只返回摘要文本。
+
+已有摘要：
+%s
+
+新的对话：
+%s`
+
+// contextWindow 返回本次请求附带的会话消息：最多 context.max_messages 条最近消息，
+// 开启 summarize 时先把窗口之外新增的较早消息合并进会话摘要
+func (m *Manager) contextWindow(ctx context.Context, session *Session) []Message {
+	limit := m.config.Context.MaxMessages
+	if limit <= 0 || len(session.Messages) <= limit {
+		return session.Messages
+	}
+
+	cut := len(session.Messages) - limit
+	// 窗口从用户消息开始，部分提供商（如 Anthropic）要求第一条消息是用户消息
+	for cut < len(session.Messages)-1 && session.Messages[cut].Role != "user" {
+		cut++
+	}
+
+	if m.config.Context.Summarize && cut > session.summarized {
+		m.summarizeHistory(ctx, session, cut)
+	}
+	return session.Messages[cut:]
+}
+
+// summarizeHistory 将 summarized 到 cut 之间的消息合并进会话摘要。摘要失败时保留原摘要，
+// 不影响本次请求，下次裁剪时会重新尝试
+func (m *Manager) summarizeHistory(ctx context.Context, session *Session, cut int) {
+	var transcript strings.Builder
+	for _, msg := range session.Messages[session.summarized:cut] {
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+	}
+
+	previous := session.Summary
+	if previous == "" {
+		previous = "（无）"
+	}
+
+	summary, err := m.llmClient.Chat(ctx, []llm.Message{{
+		Role:    llm.RoleUser,
+		Content: fmt.Sprintf(summaryPrompt, previous, transcript.String()),
+	}})
+	if err != nil {
+		log.Printf("⚠️  对话摘要失败，保留原摘要: %v", err)
+		return
+	}
+
+	log.Printf("📝 会话 %s 已将 %d 条较早消息合并进摘要", session.ID, cut-session.summarized)
+	session.Summary = strings.TrimSpace(summary)
+	session.summarized = cut
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/mcp/mcptest"
+)
+
+// longSession 构建用户与助手交替发言的会话
+func longSession(n int) *Session {
+	session := &Session{ID: "long"}
+	for i := 0; i < n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		session.Messages = append(session.Messages, Message{Role: role, Content: fmt.Sprintf("消息 %d", i)})
+	}
+	return session
+}
+
+func TestBuildLLMMessagesBoundsLongSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		context     config.ContextConfig
+		wantLen     int
+		wantFirst   string
+		wantSummary bool
+	}{
+		{name: "no limit", context: config.ContextConfig{}, wantLen: 201, wantFirst: "消息 0"},
+		{name: "limit larger than session", context: config.ContextConfig{MaxMessages: 500}, wantLen: 201, wantFirst: "消息 0"},
+		{name: "limit 20", context: config.ContextConfig{MaxMessages: 20}, wantLen: 21, wantFirst: "消息 180"},
+		// 窗口的第一条落在助手消息上时顺延到下一条用户消息
+		{name: "window starts with a user message", context: config.ContextConfig{MaxMessages: 21}, wantLen: 21, wantFirst: "消息 180"},
+		{name: "summarize older turns", context: config.ContextConfig{MaxMessages: 20, Summarize: true}, wantLen: 21, wantFirst: "消息 180", wantSummary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(mcptest.NewMockMCPServer(), config.LLMConfig{Provider: llm.ProviderMock},
+				config.AgentConfig{Context: tt.context})
+			session := longSession(200)
+
+			messages := manager.buildLLMMessages(context.Background(), session, "")
+			if len(messages) != tt.wantLen {
+				t.Fatalf("request has %d messages, want %d", len(messages), tt.wantLen)
+			}
+			if messages[0].Role != llm.RoleSystem {
+				t.Errorf("first message role = %q, want system", messages[0].Role)
+			}
+			if messages[1].Role != "user" || messages[1].Content != tt.wantFirst {
+				t.Errorf("first windowed message = %s %q, want user %q", messages[1].Role, messages[1].Content, tt.wantFirst)
+			}
+			if last := messages[len(messages)-1].Content; last != "消息 199" {
+				t.Errorf("last message = %q, want the most recent message", last)
+			}
+
+			hasSummary := strings.Contains(messages[0].Content, "之前对话的摘要")
+			if hasSummary != tt.wantSummary {
+				t.Errorf("system prompt includes summary = %v, want %v", hasSummary, tt.wantSummary)
+			}
+			if tt.wantSummary && (session.Summary == "" || session.summarized != 180) {
+				t.Errorf("summary = %q summarized = %d, want a summary of the first 180 messages", session.Summary, session.summarized)
+			}
+			if len(session.Messages) != 200 {
+				t.Errorf("session has %d messages after trimming, want all 200 kept", len(session.Messages))
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"qng_agent/internal/llm"
+	"strings"
+)
+
+// 可选的意图分类器
+const (
+	ClassifierKeyword = "keyword"
+	ClassifierLLM     = "llm"
+	ClassifierNone    = "none"
+)
+
+// IntentClassifier 判断消息是否需要调用工具，以及调用哪个服务的哪个工具
+type IntentClassifier interface {
+	Classify(ctx context.Context, message string) (needsTools bool, info ToolInfo, err error)
+}
+
+// NewIntentClassifier 按名称创建意图分类器，名称为空时使用关键词分类器
+func NewIntentClassifier(name string, llmClient llm.Client) (IntentClassifier, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ClassifierKeyword:
+		return KeywordClassifier{}, nil
+	case ClassifierLLM:
+		return &LLMClassifier{llmClient: llmClient, fallback: KeywordClassifier{}}, nil
+	case ClassifierNone:
+		return NoopClassifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown intent classifier %q", name)
+	}
+}
+
+// KeywordClassifier 基于关键词的默认分类器
+type KeywordClassifier struct{}
+
+// workflowKeywords 命中后路由到QNG工作流的关键词
+var workflowKeywords = []string{
+	"兑换", "质押", "交易", "swap", "stake",
+	"transfer", "转账", "usdt", "btc", "eth",
+}
+
+// stakingPositionKeywords 命中后查询质押仓位的关键词，优先于工作流关键词（其中包含“质押”）
+var stakingPositionKeywords = []string{
+	"质押了多少", "质押余额", "质押仓位", "质押收益", "质押奖励", "待领取奖励", "赚了多少",
+	"how much have i staked", "how much have i earned", "staked balance", "staking position", "pending rewards",
+}
+
+// claimKeywords 领取奖励需要执行 claimRewards 交易，包含“质押奖励”“pending rewards”时也不是查询仓位
+var claimKeywords = []string{"领取", "提取奖励", "收取奖励", "claim"}
+
+// stakingPositionTool 查询质押仓位的工具，地址由 Manager 从请求中补充
+func stakingPositionTool() ToolInfo {
+	return ToolInfo{ServerName: "qng", ToolName: "get_staking_position", Parameters: map[string]any{}}
+}
+
+func (KeywordClassifier) Classify(ctx context.Context, message string) (bool, ToolInfo, error) {
+	lowerMsg := strings.ToLower(message)
+
+	// “待领取奖励”是查询用语，不算领取动作
+	actionMsg := strings.ReplaceAll(lowerMsg, "待领取", "")
+	for _, keyword := range claimKeywords {
+		if strings.Contains(actionMsg, keyword) {
+			return true, ToolInfo{IsQNGWorkflow: true}, nil
+		}
+	}
+
+	for _, keyword := range stakingPositionKeywords {
+		if strings.Contains(lowerMsg, keyword) {
+			return true, stakingPositionTool(), nil
+		}
+	}
+
+	// 检查是否是工作流相关消息
+	for _, keyword := range workflowKeywords {
+		if strings.Contains(lowerMsg, keyword) {
+			return true, ToolInfo{
+				IsQNGWorkflow: true,
+			}, nil
+		}
+	}
+
+	// 检查MetaMask相关操作
+	if strings.Contains(lowerMsg, "钱包") || strings.Contains(lowerMsg, "metamask") ||
+		strings.Contains(lowerMsg, "连接") || strings.Contains(lowerMsg, "签名") {
+		return true, ToolInfo{
+			ServerName: "metamask",
+			ToolName:   "connect_wallet",
+		}, nil
+	}
+
+	return false, ToolInfo{}, nil
+}
+
+// NoopClassifier 从不调用工具，所有消息都由LLM直接回答
+type NoopClassifier struct{}
+
+func (NoopClassifier) Classify(ctx context.Context, message string) (bool, ToolInfo, error) {
+	return false, ToolInfo{}, nil
+}
+
+// intentPrompt LLM分类器使用的提示
+const intentPrompt = `判断下面的用户消息属于哪一类，只返回JSON，例如 {"intent": "workflow"}：
+- workflow: 代币兑换、质押、领取奖励、转账等需要执行链上交易的请求
+- position: 查询自己质押了多少、赚了多少奖励（只查询，不领取）
+- wallet: 连接钱包、签名等钱包操作
+- chat: 其它问题，直接回答即可
+
+用户消息: %s`
+
+// LLMClassifier 由LLM判断意图，调用失败或无法解析时回退到关键词分类器
+type LLMClassifier struct {
+	llmClient llm.Client
+	fallback  IntentClassifier
+}
+
+func (c *LLMClassifier) Classify(ctx context.Context, message string) (bool, ToolInfo, error) {
+	response, err := c.llmClient.Chat(ctx, []llm.Message{
+		{Role: "user", Content: fmt.Sprintf(intentPrompt, message)},
+	})
+	if err != nil {
+		log.Printf("⚠️  LLM意图分类失败，使用关键词分类: %v", err)
+		return c.fallback.Classify(ctx, message)
+	}
+
+	var result struct {
+		Intent string `json:"intent"`
+	}
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start || json.Unmarshal([]byte(response[start:end+1]), &result) != nil {
+		log.Printf("⚠️  无法解析LLM意图分类结果，使用关键词分类: %s", response)
+		return c.fallback.Classify(ctx, message)
+	}
+
+	switch result.Intent {
+	case "workflow":
+		return true, ToolInfo{IsQNGWorkflow: true}, nil
+	case "wallet":
+		return true, ToolInfo{ServerName: "metamask", ToolName: "connect_wallet"}, nil
+	case "position":
+		return true, stakingPositionTool(), nil
+	case "chat":
+		return false, ToolInfo{}, nil
+	default:
+		log.Printf("⚠️  未知的意图 %q，使用关键词分类", result.Intent)
+		return c.fallback.Classify(ctx, message)
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeywordClassifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		tool     string
+		workflow bool
+	}{
+		{name: "staked balance", message: "我质押了多少MEER", tool: "get_staking_position"},
+		{name: "pending rewards query", message: "查看待领取奖励有多少", tool: "get_staking_position"},
+		{name: "english position", message: "What is my staking position?", tool: "get_staking_position"},
+		{name: "claim staking rewards", message: "领取质押奖励", workflow: true},
+		{name: "claim pending rewards", message: "claim my pending rewards", workflow: true},
+		{name: "claim pending staking rewards", message: "领取待领取的质押奖励", workflow: true},
+		{name: "stake", message: "质押100 MEER", workflow: true},
+		{name: "wallet", message: "连接钱包", tool: "connect_wallet"},
+		{name: "chat", message: "你好"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsTools, info, err := KeywordClassifier{}.Classify(context.Background(), tt.message)
+			if err != nil {
+				t.Fatalf("Classify: %v", err)
+			}
+			if needsTools != (tt.tool != "" || tt.workflow) || info.ToolName != tt.tool || info.IsQNGWorkflow != tt.workflow {
+				t.Errorf("Classify(%q) = %v, %+v; want tool %q, workflow %v", tt.message, needsTools, info, tt.tool, tt.workflow)
+			}
+		})
+	}
+}
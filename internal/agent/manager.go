@@ -2,19 +2,30 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"qng_agent/internal/config"
 	"qng_agent/internal/llm"
 	"qng_agent/internal/mcp"
+	"qng_agent/internal/qng"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Manager struct {
 	mcpClient mcp.ServerInterface
 	llmClient llm.Client
+	config    config.AgentConfig
 	sessions  map[string]*Session
+	// sessionsMu 保护 sessions，用量查询与对话处理可能并发访问
+	sessionsMu sync.Mutex
+	polls     map[string]*pollTracker
+	pollsMu   sync.Mutex
+	guard     *promptGuard
+	// classifier 判断消息路由到LLM、QNG工作流还是其它工具
+	classifier IntentClassifier
 }
 
 type Session struct {
@@ -22,6 +33,12 @@ type Session struct {
 	Messages     []Message
 	CurrentState string
 	CreatedAt    time.Time
+	// Usage 会话累计的LLM token用量，由 usageMu 保护
+	Usage   UsageReport
+	usageMu sync.Mutex
+	// Summary 裁剪出上下文窗口的较早消息的摘要，summarized 为已合并进摘要的消息条数
+	Summary    string
+	summarized int
 }
 
 type Message struct {
@@ -33,6 +50,12 @@ type Message struct {
 type ProcessRequest struct {
 	SessionID string `json:"session_id"`
 	Message   string `json:"message"`
+	// Format 回复格式: markdown, plain 或 json，为空时不限制
+	Format string `json:"format,omitempty"`
+	// UserID 用户ID，仅随会话记录；支出限额按签名交易的钱包地址匹配
+	UserID string `json:"user_id,omitempty"`
+	// UserAddress 用户钱包地址，用于在构建交易前检查链上余额
+	UserAddress string `json:"user_address,omitempty"`
 }
 
 type ProcessResponse struct {
@@ -41,23 +64,69 @@ type ProcessResponse struct {
 	ActionType string `json:"action_type,omitempty"`
 	ActionData any    `json:"action_data,omitempty"`
 	WorkflowID string `json:"workflow_id,omitempty"`
+	// HandledBy 本轮对话的处理方式，见 HandledBy* 常量
+	HandledBy string `json:"handled_by"`
+	// ToolUsed 本轮调用的 MCP 服务与方法（如 qng/execute_workflow），未调用工具时为空
+	ToolUsed string `json:"tool_used,omitempty"`
 }
 
-func NewManager(mcpClient mcp.ServerInterface, llmConfig config.LLMConfig) *Manager {
+// 本轮对话的处理方式
+const (
+	HandledByLLM         = "llm"          // LLM 直接回答
+	HandledByWorkflow    = "workflow"     // 启动 QNG 工作流
+	HandledByTool        = "tool"         // 调用其它 MCP 工具后由 LLM 整理结果
+	HandledByGuard       = "prompt_guard" // 可疑指令被拦截，未调用工具
+	HandledByUnavailable = "unavailable"  // 目标服务未启用，未调用工具
+)
+
+// toolName 返回 服务/方法 形式的工具标识
+func toolName(server, method string) string {
+	return server + "/" + method
+}
+
+func NewManager(mcpClient mcp.ServerInterface, llmConfig config.LLMConfig, agentConfig config.AgentConfig) *Manager {
 	llmClient, err := llm.NewClient(llmConfig)
 	if err != nil {
 		log.Fatal("Failed to create LLM client:", err)
 	}
 
+	classifier, err := NewIntentClassifier(agentConfig.IntentClassifier, llmClient)
+	if err != nil {
+		log.Fatal("Failed to create intent classifier:", err)
+	}
+	log.Printf("🧭 意图分类器: %T", classifier)
+
 	return &Manager{
 		mcpClient: mcpClient,
 		llmClient: llmClient,
+		config:    agentConfig,
 		sessions:  make(map[string]*Session),
+		polls:     make(map[string]*pollTracker),
+		guard:     newPromptGuard(agentConfig.PromptGuard),
+		classifier: classifier,
 	}
 }
 
 func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*ProcessResponse, error) {
+	return m.ProcessMessageStream(ctx, req, nil)
+}
+
+// ProcessMessageStream 与 ProcessMessage 相同，但 LLM 直接回答时以流式方式生成，
+// 每收到一段文本调用一次 onChunk；返回的响应仍包含完整回复。onChunk 为 nil 时不使用流式输出。
+// 请求上下文超时时返回 ErrRequestTimeout。
+func (m *Manager) ProcessMessageStream(ctx context.Context, req ProcessRequest, onChunk func(chunk string)) (*ProcessResponse, error) {
+	response, err := m.processMessage(ctx, req, onChunk)
+	return response, timeoutError(ctx, err)
+}
+
+func (m *Manager) processMessage(ctx context.Context, req ProcessRequest, onChunk func(chunk string)) (*ProcessResponse, error) {
+	format, err := normalizeFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+
 	session := m.getOrCreateSession(req.SessionID)
+	ctx = session.withUsage(ctx)
 
 	// 添加用户消息到会话
 	userMsg := Message{
@@ -68,11 +137,14 @@ func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*Proc
 	session.Messages = append(session.Messages, userMsg)
 
 	// 检查是否需要工具调用
-	needsTools, toolInfo := m.analyzeMessageForTools(req.Message)
+	needsTools, toolInfo, err := m.classifier.Classify(ctx, req.Message)
+	if err != nil {
+		return nil, fmt.Errorf("intent classification failed: %w", err)
+	}
 
 	if !needsTools {
 		// 直接调用LLM
-		response, err := m.llmClient.Chat(ctx, m.buildLLMMessages(session))
+		response, err := m.chat(ctx, m.buildLLMMessages(ctx, session, format), onChunk)
 		if err != nil {
 			return nil, fmt.Errorf("LLM call failed: %w", err)
 		}
@@ -86,17 +158,46 @@ func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*Proc
 		session.Messages = append(session.Messages, assistantMsg)
 
 		return &ProcessResponse{
-			Response: response,
+			Response:  response,
+			HandledBy: HandledByLLM,
 		}, nil
 	}
 
+	// 检测试图绕过确认的可疑指令
+	flagged := m.guard.check(req.Message)
+	if len(flagged) > 0 {
+		log.Printf("🚨 检测到可疑指令，需人工确认: %v", flagged)
+	}
+
+	// 路由前检查目标服务是否启用，未启用时返回提示而不是调用错误
+	server := toolInfo.ServerName
+	if toolInfo.IsQNGWorkflow {
+		server = "qng"
+	}
+	if !m.serverAvailable(server) {
+		response := unavailableResponse(server)
+		session.Messages = append(session.Messages, Message{
+			Role:      "assistant",
+			Content:   response.Response,
+			Timestamp: time.Now(),
+		})
+		return response, nil
+	}
+
 	// 需要工具调用
 	if toolInfo.IsQNGWorkflow {
-		// 调用QNG工作流
+		// 调用QNG工作流，可疑请求禁止自动签名，交易需在钱包中逐笔确认
 		log.Printf("调用QNG工作流，消息: %s", req.Message)
-		result, err := m.mcpClient.Call(ctx, "qng", "execute_workflow", map[string]any{
-			"message": req.Message,
-		})
+		params := map[string]any{
+			"message":             req.Message,
+			"user_id":             req.UserID,
+			"user_address":        req.UserAddress,
+			"manual_confirmation": len(flagged) > 0,
+		}
+		if history := m.recentHistory(session); len(history) > 0 {
+			params["history"] = history
+		}
+		result, err := m.mcpClient.Call(ctx, "qng", "execute_workflow", params)
 		if err != nil {
 			log.Printf("QNG工作流调用失败: %v", err)
 			return nil, fmt.Errorf("QNG workflow call failed: %w", err)
@@ -104,14 +205,63 @@ func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*Proc
 		resMap, _ := result.(map[string]any)
 		workflowID, _ := resMap["workflow_id"].(string)
 		log.Printf("QNG工作流启动成功，ID: %s", workflowID)
+
+		if len(flagged) > 0 {
+			return &ProcessResponse{
+				Response:   "⚠️ 您的请求包含跳过确认或转移全部资产等可疑内容，所有交易都需要您核对交易详情后在钱包中手动确认。",
+				NeedAction: true,
+				ActionType: "manual_confirmation_required",
+				ActionData: map[string]any{"flagged": flagged},
+				WorkflowID: workflowID,
+				HandledBy:  HandledByWorkflow,
+				ToolUsed:   toolName("qng", "execute_workflow"),
+			}, nil
+		}
 		return &ProcessResponse{
 			Response:   "任务正在执行中，请等待...",
 			NeedAction: true,
 			ActionType: "workflow_running",
 			WorkflowID: workflowID,
+			HandledBy:  HandledByWorkflow,
+			ToolUsed:   toolName("qng", "execute_workflow"),
 		}, nil
 	}
 
+	// 可疑请求不直接调用其它工具
+	if len(flagged) > 0 {
+		return &ProcessResponse{
+			Response:   "⚠️ 您的请求包含跳过确认等可疑内容，已暂停执行。请确认操作内容后重新发起明确的请求。",
+			NeedAction: true,
+			ActionType: "manual_confirmation_required",
+			ActionData: map[string]any{
+				"flagged": flagged,
+				"server":  toolInfo.ServerName,
+				"tool":    toolInfo.ToolName,
+				"params":  toolInfo.Parameters,
+			},
+			HandledBy: HandledByGuard,
+		}, nil
+	}
+
+	// 质押仓位按请求中的钱包地址查询
+	if toolInfo.ToolName == "get_staking_position" {
+		if req.UserAddress == "" {
+			response := "查询质押仓位需要您的钱包地址，请先连接钱包。"
+			session.Messages = append(session.Messages, Message{
+				Role:      "assistant",
+				Content:   response,
+				Timestamp: time.Now(),
+			})
+			return &ProcessResponse{
+				Response:   response,
+				NeedAction: true,
+				ActionType: "connect_wallet",
+				HandledBy:  HandledByTool,
+			}, nil
+		}
+		toolInfo.Parameters["address"] = req.UserAddress
+	}
+
 	// 调用其它MCP工具
 	log.Printf("调用MCP工具，服务器: %s, 工具: %s", toolInfo.ServerName, toolInfo.ToolName)
 	result, err := m.mcpClient.Call(ctx, toolInfo.ServerName, toolInfo.ToolName, toolInfo.Parameters)
@@ -120,11 +270,16 @@ func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*Proc
 		return nil, fmt.Errorf("MCP tool call failed: %w", err)
 	}
 
-	// 将结果传给LLM格式化
-	llmMessages := m.buildLLMMessages(session)
+	// 将结果作为工具结果消息传给LLM格式化
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		resultJSON = []byte(fmt.Sprintf("%v", result))
+	}
+	llmMessages := m.buildLLMMessages(ctx, session, format)
 	llmMessages = append(llmMessages, llm.Message{
-		Role:    "system",
-		Content: fmt.Sprintf("Tool result: %s", result),
+		Role:    llm.RoleTool,
+		Name:    toolInfo.ToolName,
+		Content: string(resultJSON),
 	})
 
 	response, err := m.llmClient.Chat(ctx, llmMessages)
@@ -140,47 +295,52 @@ func (m *Manager) ProcessMessage(ctx context.Context, req ProcessRequest) (*Proc
 	session.Messages = append(session.Messages, assistantMsg)
 
 	return &ProcessResponse{
-		Response: response,
+		Response:  response,
+		HandledBy: HandledByTool,
+		ToolUsed:  toolName(toolInfo.ServerName, toolInfo.ToolName),
 	}, nil
 }
 
-type ToolInfo struct {
-	IsQNGWorkflow bool
-	ServerName    string
-	ToolName      string
-	Parameters    map[string]any
-}
-
-func (m *Manager) analyzeMessageForTools(message string) (bool, ToolInfo) {
-	lowerMsg := strings.ToLower(message)
-
-	// 检查是否是工作流相关消息
-	workflowKeywords := []string{
-		"兑换", "质押", "交易", "swap", "stake",
-		"transfer", "转账", "usdt", "btc", "eth",
+// chat 调用LLM，提供 onChunk 时使用流式输出并逐段回调，返回拼接后的完整回复
+func (m *Manager) chat(ctx context.Context, messages []llm.Message, onChunk func(chunk string)) (string, error) {
+	if onChunk == nil {
+		return m.llmClient.Chat(ctx, messages)
 	}
 
-	for _, keyword := range workflowKeywords {
-		if strings.Contains(lowerMsg, keyword) {
-			return true, ToolInfo{
-				IsQNGWorkflow: true,
-			}
-		}
+	stream, err := m.llmClient.ChatStream(ctx, messages)
+	if err != nil {
+		return "", err
 	}
 
-	// 检查MetaMask相关操作
-	if strings.Contains(lowerMsg, "钱包") || strings.Contains(lowerMsg, "metamask") ||
-		strings.Contains(lowerMsg, "连接") || strings.Contains(lowerMsg, "签名") {
-		return true, ToolInfo{
-			ServerName: "metamask",
-			ToolName:   "connect_wallet",
-		}
+	var response strings.Builder
+	for chunk := range stream.Chunks {
+		response.WriteString(chunk)
+		onChunk(chunk)
+	}
+	// 中途失败或取消时已推送的分段不是完整回复，不能作为回复保存到会话
+	if err := stream.Err(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if response.Len() == 0 {
+		return "", fmt.Errorf("empty streamed response")
 	}
+	return response.String(), nil
+}
 
-	return false, ToolInfo{}
+type ToolInfo struct {
+	IsQNGWorkflow bool
+	ServerName    string
+	ToolName      string
+	Parameters    map[string]any
 }
 
 func (m *Manager) getOrCreateSession(sessionID string) *Session {
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
 	if session, exists := m.sessions[sessionID]; exists {
 		return session
 	}
@@ -195,22 +355,31 @@ func (m *Manager) getOrCreateSession(sessionID string) *Session {
 	return session
 }
 
-func (m *Manager) buildLLMMessages(session *Session) []llm.Message {
-	messages := make([]llm.Message, 0, len(session.Messages))
+// buildLLMMessages 构建LLM请求：系统提示加上下文窗口内的最近消息，较早消息的摘要附在系统提示后
+func (m *Manager) buildLLMMessages(ctx context.Context, session *Session, format string) []llm.Message {
+	window := m.contextWindow(ctx, session)
+	messages := make([]llm.Message, 0, len(window)+1)
 
 	// 添加系统提示
-	messages = append(messages, llm.Message{
-		Role: "system",
-		Content: `你是一个智能区块链助手，可以帮助用户进行各种DeFi操作。
+	systemPrompt := `你是一个智能区块链助手，可以帮助用户进行各种DeFi操作。
 你可以调用以下工具：
 1. QNG工作流 - 处理复杂的DeFi操作流程
 2. MetaMask - 钱包连接和签名操作
 
-请根据用户需求提供准确的帮助。`,
+请根据用户需求提供准确的帮助。`
+	if instruction, ok := formatInstructions[format]; ok {
+		systemPrompt += "\n\n" + instruction
+	}
+	if session.Summary != "" {
+		systemPrompt += "\n\n之前对话的摘要：\n" + session.Summary
+	}
+	messages = append(messages, llm.Message{
+		Role:    "system",
+		Content: systemPrompt,
 	})
 
-	// 转换会话消息
-	for _, msg := range session.Messages {
+	// 转换上下文窗口内的会话消息
+	for _, msg := range window {
 		messages = append(messages, llm.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -220,115 +389,116 @@ func (m *Manager) buildLLMMessages(session *Session) []llm.Message {
 	return messages
 }
 
+// recentHistory 返回当前消息之前最近的若干条对话，供工作流解析“它”等指代
+func (m *Manager) recentHistory(session *Session) []map[string]any {
+	limit := m.config.Workflow.HistoryTurns
+	// 最后一条是本次的用户消息，已作为 message 参数单独传递
+	previous := session.Messages[:len(session.Messages)-1]
+	if limit <= 0 || len(previous) == 0 {
+		return nil
+	}
+	if len(previous) > limit {
+		previous = previous[len(previous)-limit:]
+	}
+
+	history := make([]map[string]any, 0, len(previous))
+	for _, msg := range previous {
+		history = append(history, map[string]any{
+			"role":    msg.Role,
+			"content": msg.Content,
+		})
+	}
+	return history
+}
+
 func (m *Manager) GetWorkflowStatus(ctx context.Context, workflowID string) (*mcp.WorkflowStatus, error) {
 	result, err := m.mcpClient.Call(ctx, "qng", "get_session_status", map[string]any{"session_id": workflowID})
 	if err != nil {
 		return nil, err
 	}
 	
-	// 处理从 HTTP 响应解析的结果
-	var status mcp.WorkflowStatus
-	if resultMap, ok := result.(map[string]interface{}); ok {
-		// 从 map 转换为 WorkflowStatus 结构体
-		if statusStr, exists := resultMap["status"]; exists {
-			if s, ok := statusStr.(string); ok {
-				status.Status = s
-			}
-		}
-		if progressFloat, exists := resultMap["progress"]; exists {
-			if p, ok := progressFloat.(float64); ok {
-				status.Progress = int(p)
-			}
-		}
-		if message, exists := resultMap["message"]; exists {
-			if m, ok := message.(string); ok {
-				status.Message = m
-			}
-		}
-		if sessionID, exists := resultMap["session_id"]; exists {
-			if sid, ok := sessionID.(string); ok {
-				status.SessionID = sid
-			}
-		}
-		if needSig, exists := resultMap["need_signature"]; exists {
-			if ns, ok := needSig.(bool); ok {
-				status.NeedSignature = ns
-			}
-		}
-		if sigReq, exists := resultMap["signature_request"]; exists {
-			if sr, ok := sigReq.(map[string]interface{}); ok {
-				sigRequest := &mcp.SignatureRequest{}
-				if action, ok := sr["action"].(string); ok {
-					sigRequest.Action = action
-				}
-				if fromToken, ok := sr["from_token"].(string); ok {
-					sigRequest.FromToken = fromToken
-				}
-				if toToken, ok := sr["to_token"].(string); ok {
-					sigRequest.ToToken = toToken
-				}
-				if amount, ok := sr["amount"].(string); ok {
-					sigRequest.Amount = amount
-				}
-				if toAddr, ok := sr["to_address"].(string); ok {
-					sigRequest.ToAddress = toAddr
-				}
-				if value, ok := sr["value"].(string); ok {
-					sigRequest.Value = value
-				}
-				if data, ok := sr["data"].(string); ok {
-					sigRequest.Data = data
-				}
-				if gasLimit, ok := sr["gas_limit"].(string); ok {
-					sigRequest.GasLimit = gasLimit
-				}
-				if gasPrice, ok := sr["gas_price"].(string); ok {
-					sigRequest.GasPrice = gasPrice
-				}
-				if gasFee, ok := sr["gas_fee"].(string); ok {
-					sigRequest.GasFee = gasFee
-				}
-				if slippage, ok := sr["slippage"].(string); ok {
-					sigRequest.Slippage = slippage
-				}
-				status.SignatureRequest = sigRequest
-			}
-		}
-		if resultData, exists := resultMap["result"]; exists {
-			if rd, ok := resultData.(map[string]interface{}); ok {
-				status.Result = rd
-			}
-		}
-		if errorStr, exists := resultMap["error"]; exists {
-			if e, ok := errorStr.(string); ok {
-				status.Error = e
-			}
-		}
-		return &status, nil
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid workflow status type: %T", result)
 	}
-	
-	return nil, fmt.Errorf("invalid workflow status type: %T", result)
+	return mcp.WorkflowStatusFromMap(resultMap), nil
+}
+
+// ContinueWorkflowWithSignature 提交签名继续工作流。gas 为用户重新广播交易时声明的 gas 参数
+// （gas_price、max_fee_per_gas、max_priority_fee_per_gas、gas_limit），可为空
+func (m *Manager) ContinueWorkflowWithSignature(ctx context.Context, workflowID, signature string, gas map[string]any) (any, error) {
+	params := map[string]any{"session_id": workflowID, "signature": signature}
+	qng.CopyGasParams(params, gas)
+	return m.mcpClient.Call(ctx, "qng", "submit_signature", params)
+}
+
+// ResumeWorkflow 恢复失败的工作流，仅重新执行未完成的任务
+func (m *Manager) ResumeWorkflow(ctx context.Context, workflowID string) (any, error) {
+	m.clearPoll(workflowID)
+	return m.mcpClient.Call(ctx, "qng", "resume_workflow", map[string]any{"session_id": workflowID})
+}
+
+// RetryConfirmation 重新等待超时或RPC失败的交易确认
+func (m *Manager) RetryConfirmation(ctx context.Context, workflowID string) (any, error) {
+	m.clearPoll(workflowID)
+	return m.mcpClient.Call(ctx, "qng", "retry_confirmation", map[string]any{"session_id": workflowID})
+}
+
+// CancelWorkflow 取消进行中或等待签名的工作流，已广播的交易不受影响
+func (m *Manager) CancelWorkflow(ctx context.Context, workflowID string) (any, error) {
+	m.clearPoll(workflowID)
+	return m.mcpClient.Call(ctx, "qng", "cancel_workflow", map[string]any{"session_id": workflowID})
 }
 
-func (m *Manager) ContinueWorkflowWithSignature(ctx context.Context, workflowID, signature string) (any, error) {
-	return m.mcpClient.Call(ctx, "qng", "submit_signature", map[string]any{"session_id": workflowID, "signature": signature})
+// ReplaceTransaction 取消（cancel）或加速（speed_up）确认超时的交易，需要用户签名时返回签名请求
+func (m *Manager) ReplaceTransaction(ctx context.Context, workflowID, kind string) (any, error) {
+	m.clearPoll(workflowID)
+	method := "speed_up_transaction"
+	if kind == "cancel" {
+		method = "cancel_transaction"
+	}
+	return m.mcpClient.Call(ctx, "qng", method, map[string]any{"session_id": workflowID})
+}
+
+// SimulateWorkflow 以预演模式执行自然语言请求，返回按顺序排列的计划交易（含 gas 与 value），不请求签名。
+// userAddress 为空时不检查链上余额
+func (m *Manager) SimulateWorkflow(ctx context.Context, message, userAddress string) (any, error) {
+	params := map[string]any{
+		"message": message,
+		"dry_run": true,
+	}
+	if userAddress != "" {
+		params["user_address"] = userAddress
+	}
+	return m.mcpClient.Call(ctx, "qng", "execute_workflow", params)
+}
+
+// StakingPosition 查询地址在质押池中的质押数量与待领取奖励，pool 为空时使用默认池
+func (m *Manager) StakingPosition(ctx context.Context, address, pool string) (any, error) {
+	result, err := m.mcpClient.Call(ctx, "qng", "get_staking_position", map[string]any{
+		"address": address,
+		"pool":    pool,
+	})
+	return result, timeoutError(ctx, err)
 }
 
 func (m *Manager) GetCapabilities() map[string]any {
-	return map[string]any{
+	serverCapabilities := m.mcpClient.GetCapabilities()
+
+	capabilities := map[string]any{
 		"llm": map[string]any{
 			"enabled":   true,
 			"providers": []string{"openai", "anthropic"},
 		},
 		"mcp_servers": map[string]any{
 			"qng": map[string]any{
-				"enabled":     true,
+				"enabled":     serverEnabled(serverCapabilities, "qng"),
 				"workflows":   []string{"swap", "stake", "transfer"},
 				"description": "QNG blockchain workflow execution",
 			},
 			"metamask": map[string]any{
-				"enabled":     true,
-				"tools":       []string{"connect_wallet", "sign_transaction", "get_balance"},
+				"enabled":     serverEnabled(serverCapabilities, "metamask"),
+				"tools":       []string{"connect_wallet", "poll_connection", "sign_transaction", "get_balance"},
 				"description": "MetaMask wallet integration",
 			},
 		},
@@ -339,4 +509,13 @@ func (m *Manager) GetCapabilities() map[string]any {
 			"transaction_signing",
 		},
 	}
+
+	// 查询失败的服务附带错误信息，其它服务照常返回
+	servers := capabilities["mcp_servers"].(map[string]any)
+	for name, entry := range servers {
+		if message := serverError(serverCapabilities, name); message != "" {
+			entry.(map[string]any)["error"] = message
+		}
+	}
+	return capabilities
 }
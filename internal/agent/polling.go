@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"qng_agent/internal/mcp"
+	"time"
+)
+
+// StatusPollingExhausted 轮询次数或时间超过配置上限时返回的状态
+const StatusPollingExhausted = "polling_exhausted"
+
+// pollTracker 记录某个工作流自上次状态变化以来的轮询情况
+type pollTracker struct {
+	attempts   int
+	lastStatus string
+	since      time.Time
+}
+
+// PollingInterval 返回配置的轮询间隔
+func (m *Manager) PollingInterval() time.Duration {
+	if m.config.Polling.Interval <= 0 {
+		return 2 * time.Second
+	}
+	return time.Duration(m.config.Polling.Interval) * time.Second
+}
+
+// PollWorkflowStatus 查询工作流状态，并按照轮询配置限制轮询次数和时长。
+// 计数在工作流状态发生变化时重置，因此只有长时间没有进展的工作流才会被放弃。
+func (m *Manager) PollWorkflowStatus(ctx context.Context, workflowID string) (*mcp.WorkflowStatus, error) {
+	status, err := m.GetWorkflowStatus(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsTerminalStatus(status.Status) {
+		m.clearPoll(workflowID)
+		return status, nil
+	}
+
+	if reason := m.recordPoll(workflowID, status.Status); reason != "" {
+		log.Printf("⏰ 工作流轮询已耗尽: %s (%s)", workflowID, reason)
+		m.clearPoll(workflowID)
+		return &mcp.WorkflowStatus{
+			Status:    StatusPollingExhausted,
+			Progress:  status.Progress,
+			Message:   fmt.Sprintf("轮询已停止: %s，最后状态为 %s", reason, status.Status),
+			SessionID: status.SessionID,
+			Error:     reason,
+			UpdatedAt: time.Now(),
+		}, nil
+	}
+
+	return status, nil
+}
+
+// recordPoll 记录一次轮询，超过限制时返回原因
+func (m *Manager) recordPoll(workflowID, status string) string {
+	m.pollsMu.Lock()
+	defer m.pollsMu.Unlock()
+
+	tracker, exists := m.polls[workflowID]
+	if !exists || tracker.lastStatus != status {
+		tracker = &pollTracker{
+			lastStatus: status,
+			since:      time.Now(),
+		}
+		m.polls[workflowID] = tracker
+	}
+	tracker.attempts++
+
+	polling := m.config.Polling
+	if polling.MaxAttempts > 0 && tracker.attempts > polling.MaxAttempts {
+		return fmt.Sprintf("exceeded %d polling attempts", polling.MaxAttempts)
+	}
+	if polling.Timeout > 0 && time.Since(tracker.since) > time.Duration(polling.Timeout)*time.Second {
+		return fmt.Sprintf("exceeded polling timeout of %ds", polling.Timeout)
+	}
+	return ""
+}
+
+// clearPoll 清除工作流的轮询记录
+func (m *Manager) clearPoll(workflowID string) {
+	m.pollsMu.Lock()
+	delete(m.polls, workflowID)
+	m.pollsMu.Unlock()
+}
+
+// IsTerminalStatus 判断工作流状态是否为终止状态
+func IsTerminalStatus(status string) bool {
+	switch status {
+	case "completed", "failed", "confirmation_failed", "limit_exceeded", "no_tasks", "cancelled", StatusPollingExhausted:
+		return true
+	}
+	return false
+}
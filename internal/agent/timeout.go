@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrRequestTimeout 单次对话请求超过 agent.request_timeout
+var ErrRequestTimeout = errors.New("request timed out")
+
+// defaultRequestTimeout 未配置 request_timeout 时单次对话请求的处理时限
+const defaultRequestTimeout = 60 * time.Second
+
+// RequestTimeout 单次对话请求的处理时限，HTTP 与 WebSocket 处理器据此派生请求上下文
+func (m *Manager) RequestTimeout() time.Duration {
+	if m.config.RequestTimeout > 0 {
+		return time.Duration(m.config.RequestTimeout) * time.Second
+	}
+	return defaultRequestTimeout
+}
+
+// timeoutError 请求上下文已超时时将错误包装为 ErrRequestTimeout，其它错误原样返回
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	log.Printf("⏰ 对话请求处理超时: %v", err)
+	return fmt.Errorf("%w: %v", ErrRequestTimeout, err)
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"qng_agent/internal/llm"
+	"time"
+)
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.New("session not found")
+
+// UsageRecord 单次LLM调用的用量
+type UsageRecord struct {
+	llm.Usage
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UsageReport 会话的LLM token用量：累计值与逐次调用明细
+type UsageReport struct {
+	SessionID        string `json:"session_id"`
+	Calls            int    `json:"calls"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	// EstimatedCalls 用量为估算值的调用次数
+	EstimatedCalls int           `json:"estimated_calls"`
+	Breakdown      []UsageRecord `json:"breakdown"`
+}
+
+// withUsage 返回记录会话用量的上下文，本次请求内的所有LLM调用都累计到会话上
+func (s *Session) withUsage(ctx context.Context) context.Context {
+	return llm.WithUsageRecorder(ctx, func(usage llm.Usage) {
+		s.usageMu.Lock()
+		defer s.usageMu.Unlock()
+
+		s.Usage.Calls++
+		s.Usage.PromptTokens += usage.PromptTokens
+		s.Usage.CompletionTokens += usage.CompletionTokens
+		s.Usage.TotalTokens += usage.TotalTokens
+		if usage.Estimated {
+			s.Usage.EstimatedCalls++
+		}
+		s.Usage.Breakdown = append(s.Usage.Breakdown, UsageRecord{Usage: usage, Timestamp: time.Now()})
+		log.Printf("🧮 会话 %s LLM用量: +%d tokens (累计 %d)", s.ID, usage.TotalTokens, s.Usage.TotalTokens)
+	})
+}
+
+// Usage 返回会话的LLM token用量
+func (m *Manager) Usage(sessionID string) (*UsageReport, error) {
+	m.sessionsMu.Lock()
+	session, exists := m.sessions[sessionID]
+	m.sessionsMu.Unlock()
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+
+	session.usageMu.Lock()
+	defer session.usageMu.Unlock()
+	report := session.Usage
+	report.SessionID = session.ID
+	report.Breakdown = append([]UsageRecord{}, session.Usage.Breakdown...)
+	return &report, nil
+}
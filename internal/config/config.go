@@ -1,9 +1,16 @@
 package config
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
@@ -21,20 +28,22 @@ type Config struct {
 }
 
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Host string `mapstructure:"host" yaml:"host"`
+	Port int    `mapstructure:"port" yaml:"port"`
+	Mode string `mapstructure:"mode" yaml:"mode"`
 }
 
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
-	Output string `mapstructure:"output"`
-	File   string `mapstructure:"file"`
+	Level  string `mapstructure:"level" yaml:"level"`
+	Format string `mapstructure:"format" yaml:"format"`
+	Output string `mapstructure:"output" yaml:"output"`
+	File   string `mapstructure:"file" yaml:"file"`
 }
 
 type LLMConfig struct {
 	Provider string                 `mapstructure:"provider" yaml:"provider"`
+	// Strict 为 true 时未配置提供商或缺少API密钥会在启动时报错，而不是回退到模拟客户端
+	Strict   bool                   `mapstructure:"strict" yaml:"strict"`
 	OpenAI   OpenAIConfig          `mapstructure:"openai" yaml:"openai"`
 	Gemini   GeminiConfig          `mapstructure:"gemini" yaml:"gemini"`
 	Anthropic AnthropicConfig      `mapstructure:"anthropic" yaml:"anthropic"`
@@ -46,211 +55,370 @@ type OpenAIConfig struct {
 	BaseURL  string `mapstructure:"base_url" yaml:"base_url"`
 	Timeout  int    `mapstructure:"timeout" yaml:"timeout"`
 	MaxTokens int   `mapstructure:"max_tokens" yaml:"max_tokens"`
+	// MaxRetries 429、5xx 与网络错误的最大重试次数，0 表示不重试
+	MaxRetries int `mapstructure:"max_retries" yaml:"max_retries"`
+	// RetryBackoff 首次重试间隔（毫秒），之后按指数增长；429 响应优先使用 Retry-After
+	RetryBackoff int `mapstructure:"retry_backoff" yaml:"retry_backoff"`
 }
 
 type GeminiConfig struct {
-	APIKey  string `mapstructure:"api_key"`
-	Model   string `mapstructure:"model"`
-	Timeout int    `mapstructure:"timeout"`
+	APIKey  string `mapstructure:"api_key" yaml:"api_key"`
+	Model   string `mapstructure:"model" yaml:"model"`
+	Timeout int    `mapstructure:"timeout" yaml:"timeout"`
 }
 
 type AnthropicConfig struct {
-	APIKey  string `mapstructure:"api_key"`
-	Model   string `mapstructure:"model"`
-	Timeout int    `mapstructure:"timeout"`
+	APIKey  string `mapstructure:"api_key" yaml:"api_key"`
+	Model   string `mapstructure:"model" yaml:"model"`
+	Timeout int    `mapstructure:"timeout" yaml:"timeout"`
 }
 
 type MCPConfig struct {
-	Mode     string      `mapstructure:"mode"`
-	Host     string      `mapstructure:"host"`
-	Port     int         `mapstructure:"port"`
-	Timeout  int         `mapstructure:"timeout"`
-	QNG      QNGConfig   `mapstructure:"qng"`
-	MetaMask MetaMaskConfig `mapstructure:"metamask"`
+	Mode     string      `mapstructure:"mode" yaml:"mode"`
+	Host     string      `mapstructure:"host" yaml:"host"`
+	Port     int         `mapstructure:"port" yaml:"port"`
+	Timeout  int         `mapstructure:"timeout" yaml:"timeout"`
+	QNG      QNGConfig   `mapstructure:"qng" yaml:"qng"`
+	MetaMask MetaMaskConfig `mapstructure:"metamask" yaml:"metamask"`
+	Retry    MCPRetryConfig `mapstructure:"retry" yaml:"retry"`
+}
+
+// MCPRetryConfig MCP HTTP 调用重试配置。只重试幂等（只读）方法，创建工作流、提交签名等写操作不会重试。
+type MCPRetryConfig struct {
+	MaxAttempts int `mapstructure:"max_attempts" yaml:"max_attempts"` // 总尝试次数（含首次），<=1 表示不重试
+	Backoff     int `mapstructure:"backoff" yaml:"backoff"`      // 首次重试等待（毫秒），之后每次翻倍
+	MaxBackoff  int `mapstructure:"max_backoff" yaml:"max_backoff"`  // 最大等待（毫秒）
+	// IdempotentMethods 可安全重试的方法，为空时使用内置只读方法列表
+	IdempotentMethods []string `mapstructure:"idempotent_methods" yaml:"idempotent_methods"`
 }
 
 type QNGConfig struct {
-	Enabled bool        `mapstructure:"enabled"`
-	Host    string      `mapstructure:"host"`
-	Port    int         `mapstructure:"port"`
-	Timeout int         `mapstructure:"timeout"`
-	Chain   ChainConfig `mapstructure:"chain"`
+	Enabled bool        `mapstructure:"enabled" yaml:"enabled"`
+	Host    string      `mapstructure:"host" yaml:"host"`
+	Port    int         `mapstructure:"port" yaml:"port"`
+	Timeout int         `mapstructure:"timeout" yaml:"timeout"`
+	Chain   ChainConfig `mapstructure:"chain" yaml:"chain"`
+	// AllowedMethods 允许调用的方法，对 /api/mcp/call 与工作流路由同样生效，为空时不限制
+	AllowedMethods []string `mapstructure:"allowed_methods" yaml:"allowed_methods"`
+	// InstanceID 多副本部署时本副本的实例ID，作为会话与工作流ID的命名空间；
+	// 为空时依次使用 QNG_INSTANCE_ID 环境变量、主机名
+	InstanceID string `mapstructure:"instance_id" yaml:"instance_id"`
+	// SessionTTL 已结束（completed/failed/cancelled）会话的保留时间（分钟），超过后被清理
+	SessionTTL int `mapstructure:"session_ttl" yaml:"session_ttl"`
+	// MaxSignatureRounds 每个工作流最多请求的签名轮数，超过后工作流失败，防止节点循环请求签名
+	MaxSignatureRounds int `mapstructure:"max_signature_rounds" yaml:"max_signature_rounds"`
+	// DefaultLLM 顶层 llm 配置，由加载配置时填充；chain.llm 未配置提供商时任务分解使用它
+	DefaultLLM LLMConfig `mapstructure:"-" yaml:"-"`
 }
 
 type ChainConfig struct {
-	Enabled     bool               `mapstructure:"enabled"`
-	Network     string             `mapstructure:"network"`
-	RPCURL      string             `mapstructure:"rpc_url"`
-	Transaction TransactionConfig  `mapstructure:"transaction"`
-	LangGraph   LangGraphConfig    `mapstructure:"langgraph"`
-	LLM         LLMConfig          `mapstructure:"llm"`
+	Enabled     bool               `mapstructure:"enabled" yaml:"enabled"`
+	Network     string             `mapstructure:"network" yaml:"network"`
+	RPCURL      string             `mapstructure:"rpc_url" yaml:"rpc_url"`
+	Transaction TransactionConfig  `mapstructure:"transaction" yaml:"transaction"`
+	LangGraph   LangGraphConfig    `mapstructure:"langgraph" yaml:"langgraph"`
+	LLM         LLMConfig          `mapstructure:"llm" yaml:"llm"`
+	Signer      SignerConfig       `mapstructure:"signer" yaml:"signer"`
+	SpendingLimits SpendingLimitsConfig `mapstructure:"spending_limits" yaml:"spending_limits"`
+	// Sessions 为 true 时独立部署的 chain 服务提供与 MCP QNG 服务相同的会话与长轮询接口
+	Sessions bool `mapstructure:"sessions" yaml:"sessions"`
+}
+
+// SpendingLimitsConfig 支出限额配置。超出限额时拒绝构建交易，需要人工调整限额后再执行。
+type SpendingLimitsConfig struct {
+	Enabled       bool `mapstructure:"enabled" yaml:"enabled"`
+	SpendingLimit `mapstructure:",squash" yaml:",inline"`
+	// Users 按钱包地址覆盖的限额，未设置的字段沿用全局限额。
+	// 地址取自服务端签名账户或经签名者校验的 user_address，开启 permissive_signatures 时不生效
+	Users map[string]SpendingLimit `mapstructure:"users" yaml:"users"`
+}
+
+// SpendingLimit 一组支出限额，0 表示不限制
+type SpendingLimit struct {
+	// MaxTransactionValue 单笔交易价值上限，以原生代币计
+	MaxTransactionValue float64 `mapstructure:"max_transaction_value" yaml:"max_transaction_value"`
+	// MaxTokenAmounts 单个工作流内每种代币的累计数量上限
+	MaxTokenAmounts map[string]float64 `mapstructure:"max_token_amounts" yaml:"max_token_amounts"`
+}
+
+// SignerConfig 服务端签名配置。启用后由后端持有私钥签名并广播交易，跳过前端钱包签名。
+// 私钥只从环境变量读取，不允许写入配置文件。
+type SignerConfig struct {
+	Enabled       bool   `mapstructure:"enabled" yaml:"enabled"`
+	PrivateKeyEnv string `mapstructure:"private_key_env" yaml:"private_key_env"`
 }
 
 type TransactionConfig struct {
-	ConfirmationTimeout    int `mapstructure:"confirmation_timeout"`
-	PollingInterval        int `mapstructure:"polling_interval"`
-	RequiredConfirmations  int `mapstructure:"required_confirmations"`
+	ConfirmationTimeout    int `mapstructure:"confirmation_timeout" yaml:"confirmation_timeout"`
+	PollingInterval        int `mapstructure:"polling_interval" yaml:"polling_interval"`
+	// RequiredConfirmations 默认确认数，ActionConfirmations 中没有配置的交易类型使用该值
+	RequiredConfirmations  int `mapstructure:"required_confirmations" yaml:"required_confirmations"`
+	// ActionConfirmations 按交易类型（approve、swap、stake、transfer）覆盖的确认数
+	ActionConfirmations map[string]int `mapstructure:"action_confirmations" yaml:"action_confirmations"`
+	// ConfirmationStrategy 确认策略: confirmations（固定确认数）或 finalized（等待区块最终确定）
+	ConfirmationStrategy   string `mapstructure:"confirmation_strategy" yaml:"confirmation_strategy"`
+	// GasBufferPercent 在 eth_estimateGas 估算结果上增加的安全余量（百分比）
+	GasBufferPercent int `mapstructure:"gas_buffer_percent" yaml:"gas_buffer_percent"`
+	// PermissiveSignatures 为 true 时跳过交易签名者校验，仅用于本地模拟流程
+	PermissiveSignatures bool `mapstructure:"permissive_signatures" yaml:"permissive_signatures"`
+	// ProgressInterval 推送确认进度的最小间隔（秒），0 表示确认数每次变化都推送
+	ProgressInterval int `mapstructure:"progress_interval" yaml:"progress_interval"`
+	// TrustedAddresses 可信的合约地址，交易调用其它合约时签名请求带警告；为空时信任 contracts.json 中配置的地址
+	TrustedAddresses []string `mapstructure:"trusted_addresses" yaml:"trusted_addresses"`
 }
 
 type LangGraphConfig struct {
-	Enabled bool     `mapstructure:"enabled"`
-	Nodes   []string `mapstructure:"nodes"`
+	Enabled bool     `mapstructure:"enabled" yaml:"enabled"`
+	Nodes   []string `mapstructure:"nodes" yaml:"nodes"`
+	// NodeTimeout 单个节点的默认执行超时（秒），0 表示不限制
+	NodeTimeout int `mapstructure:"node_timeout" yaml:"node_timeout"`
+	// NodeTimeouts 按节点名覆盖的执行超时（秒）
+	NodeTimeouts map[string]int `mapstructure:"node_timeouts" yaml:"node_timeouts"`
+	// Retries 按节点名配置的重试策略，为空时只有任务分解节点重试。
+	// 签名验证、交易执行等非幂等节点始终不会重试。
+	Retries map[string]NodeRetryConfig `mapstructure:"retries" yaml:"retries"`
+	// ParseFailure LLM任务分解结果无法解析时的处理策略: reprompt（默认，追加一次纠正请求要求只返回JSON，
+	// 仍失败时规则解析）、fallback（直接规则解析用户消息）或 clarify（请用户澄清请求）
+	ParseFailure string `mapstructure:"parse_failure" yaml:"parse_failure"`
+	// Generation 按节点名配置调用LLM时的生成参数，未配置的节点使用内置默认值（任务分解温度为 0）
+	Generation map[string]NodeGenerationConfig `mapstructure:"generation" yaml:"generation"`
+}
+
+// NodeGenerationConfig 节点调用LLM的生成参数，未设置的字段使用提供商默认值
+type NodeGenerationConfig struct {
+	Temperature *float64 `mapstructure:"temperature" yaml:"temperature,omitempty"`
+	MaxTokens   int      `mapstructure:"max_tokens" yaml:"max_tokens,omitempty"`
+}
+
+// NodeRetryConfig 节点重试策略，只重试临时性失败（LLM调用失败、节点超时）
+type NodeRetryConfig struct {
+	MaxAttempts int `mapstructure:"max_attempts" yaml:"max_attempts"`
+	Backoff     int `mapstructure:"backoff" yaml:"backoff"` // 首次重试间隔（毫秒），之后按指数增长
 }
 
 type MetaMaskConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Timeout  int    `mapstructure:"timeout"`
-	Network  string `mapstructure:"network"`
-	ChainID  string `mapstructure:"chain_id"`
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled"`
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     int    `mapstructure:"port" yaml:"port"`
+	Timeout  int    `mapstructure:"timeout" yaml:"timeout"`
+	Network  string `mapstructure:"network" yaml:"network"`
+	ChainID  string `mapstructure:"chain_id" yaml:"chain_id"`
+	// DefaultAccount 钱包未连接时只读查询（余额、报价、授权额度）使用的账户
+	DefaultAccount string `mapstructure:"default_account" yaml:"default_account"`
+	// AllowedMethods 允许调用的方法，对 /api/mcp/call 与工作流路由同样生效，为空时不限制
+	AllowedMethods []string `mapstructure:"allowed_methods" yaml:"allowed_methods"`
+	// Mock 使用模拟钱包（立即连接固定账户、随机签名），用于离线开发
+	Mock bool `mapstructure:"mock" yaml:"mock"`
+	// PairingTTL 钱包配对请求的有效期（秒）
+	PairingTTL int `mapstructure:"pairing_ttl" yaml:"pairing_ttl"`
 }
 
 type AgentConfig struct {
-	Name     string           `mapstructure:"name"`
-	Version  string           `mapstructure:"version"`
-	Workflow WorkflowConfig   `mapstructure:"workflow"`
-	Polling  PollingConfig    `mapstructure:"polling"`
-	LLM      LLMConfig        `mapstructure:"llm"`
-	MCP      MCPConfig        `mapstructure:"mcp"`
+	Name     string           `mapstructure:"name" yaml:"name"`
+	Version  string           `mapstructure:"version" yaml:"version"`
+	Workflow WorkflowConfig   `mapstructure:"workflow" yaml:"workflow"`
+	Polling  PollingConfig    `mapstructure:"polling" yaml:"polling"`
+	LLM      LLMConfig        `mapstructure:"llm" yaml:"llm"`
+	MCP      MCPConfig        `mapstructure:"mcp" yaml:"mcp"`
+	PromptGuard PromptGuardConfig `mapstructure:"prompt_guard" yaml:"prompt_guard"`
+	// IntentClassifier 消息路由使用的意图分类器: keyword（默认）、llm 或 none
+	IntentClassifier string `mapstructure:"intent_classifier" yaml:"intent_classifier"`
+	// RequestTimeout 单次对话请求（LLM 与 MCP 调用）的处理时限（秒）
+	RequestTimeout int `mapstructure:"request_timeout" yaml:"request_timeout"`
+	// Context 发送给LLM的对话上下文窗口
+	Context ContextConfig `mapstructure:"context" yaml:"context"`
+}
+
+// ContextConfig 对话上下文裁剪配置，系统提示总是保留
+type ContextConfig struct {
+	// MaxMessages 每次请求附带的最近会话消息条数，0 表示不裁剪
+	MaxMessages int `mapstructure:"max_messages" yaml:"max_messages"`
+	// Summarize 为被裁剪的较早消息调用LLM生成摘要，随系统提示一起发送
+	Summarize bool `mapstructure:"summarize" yaml:"summarize"`
+}
+
+// PromptGuardConfig 可疑指令检测配置，命中的请求需要用户在钱包中手动确认交易
+type PromptGuardConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Patterns 自定义正则（不区分大小写），为空时使用内置规则
+	Patterns []string `mapstructure:"patterns" yaml:"patterns"`
 }
 
 type WorkflowConfig struct {
-	Timeout     int `mapstructure:"timeout"`
-	MaxRetries  int `mapstructure:"max_retries"`
-	RetryDelay  int `mapstructure:"retry_delay"`
+	Timeout     int `mapstructure:"timeout" yaml:"timeout"`
+	MaxRetries  int `mapstructure:"max_retries" yaml:"max_retries"`
+	RetryDelay  int `mapstructure:"retry_delay" yaml:"retry_delay"`
+	// HistoryTurns 启动工作流时附带的最近对话消息条数，0 表示不附带
+	HistoryTurns int `mapstructure:"history_turns" yaml:"history_turns"`
 }
 
 type PollingConfig struct {
-	Interval     int `mapstructure:"interval"`
-	Timeout      int `mapstructure:"timeout"`
-	MaxAttempts  int `mapstructure:"max_attempts"`
+	Interval     int `mapstructure:"interval" yaml:"interval"`
+	Timeout      int `mapstructure:"timeout" yaml:"timeout"`
+	MaxAttempts  int `mapstructure:"max_attempts" yaml:"max_attempts"`
 }
 
 type FrontendConfig struct {
-	Enabled  bool         `mapstructure:"enabled"`
-	Host     string       `mapstructure:"host"`
-	Port     int          `mapstructure:"port"`
-	BuildDir string       `mapstructure:"build_dir"`
-	API      APIConfig    `mapstructure:"api"`
-	WebSocket WebSocketConfig `mapstructure:"websocket"`
+	Enabled  bool         `mapstructure:"enabled" yaml:"enabled"`
+	Host     string       `mapstructure:"host" yaml:"host"`
+	Port     int          `mapstructure:"port" yaml:"port"`
+	BuildDir string       `mapstructure:"build_dir" yaml:"build_dir"`
+	API      APIConfig    `mapstructure:"api" yaml:"api"`
+	WebSocket WebSocketConfig `mapstructure:"websocket" yaml:"websocket"`
 }
 
 type APIConfig struct {
-	BaseURL string `mapstructure:"base_url"`
-	Timeout int    `mapstructure:"timeout"`
+	BaseURL string `mapstructure:"base_url" yaml:"base_url"`
+	Timeout int    `mapstructure:"timeout" yaml:"timeout"`
 }
 
 type WebSocketConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	URL     string `mapstructure:"url"`
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	URL     string `mapstructure:"url" yaml:"url"`
+	// SendBuffer 每个客户端的发送缓冲区大小（消息数）
+	SendBuffer int `mapstructure:"send_buffer" yaml:"send_buffer"`
+	// SlowClientPolicy 发送缓冲区已满时的策略: close（断开客户端）或 drop（丢弃消息）
+	SlowClientPolicy string `mapstructure:"slow_client_policy" yaml:"slow_client_policy"`
+	// PingInterval 心跳 ping 的发送间隔（秒）
+	PingInterval int `mapstructure:"ping_interval" yaml:"ping_interval"`
+	// PongTimeout 等待 pong 或任意消息的超时（秒），超时后断开连接；不大于 PingInterval 时取其两倍
+	PongTimeout int `mapstructure:"pong_timeout" yaml:"pong_timeout"`
 }
 
 type DatabaseConfig struct {
-	Driver  string            `mapstructure:"driver"`
-	SQLite  SQLiteConfig      `mapstructure:"sqlite"`
-	Postgres PostgresConfig   `mapstructure:"postgres"`
-	MySQL   MySQLConfig       `mapstructure:"mysql"`
+	Driver  string            `mapstructure:"driver" yaml:"driver"`
+	SQLite  SQLiteConfig      `mapstructure:"sqlite" yaml:"sqlite"`
+	Postgres PostgresConfig   `mapstructure:"postgres" yaml:"postgres"`
+	MySQL   MySQLConfig       `mapstructure:"mysql" yaml:"mysql"`
 }
 
 type SQLiteConfig struct {
-	Path string `mapstructure:"path"`
+	Path string `mapstructure:"path" yaml:"path"`
 }
 
 type PostgresConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Database string `mapstructure:"database"`
-	SSLMode  string `mapstructure:"ssl_mode"`
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     int    `mapstructure:"port" yaml:"port"`
+	User     string `mapstructure:"user" yaml:"user"`
+	Password string `mapstructure:"password" yaml:"password"`
+	Database string `mapstructure:"database" yaml:"database"`
+	SSLMode  string `mapstructure:"ssl_mode" yaml:"ssl_mode"`
 }
 
 type MySQLConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Database string `mapstructure:"database"`
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     int    `mapstructure:"port" yaml:"port"`
+	User     string `mapstructure:"user" yaml:"user"`
+	Password string `mapstructure:"password" yaml:"password"`
+	Database string `mapstructure:"database" yaml:"database"`
 }
 
 type CacheConfig struct {
-	Driver string       `mapstructure:"driver"`
-	Redis  RedisConfig  `mapstructure:"redis"`
+	Driver string       `mapstructure:"driver" yaml:"driver"`
+	Redis  RedisConfig  `mapstructure:"redis" yaml:"redis"`
 }
 
 type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Password string `mapstructure:"password"`
-	Database int    `mapstructure:"database"`
-	Timeout  int    `mapstructure:"timeout"`
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     int    `mapstructure:"port" yaml:"port"`
+	Password string `mapstructure:"password" yaml:"password"`
+	Database int    `mapstructure:"database" yaml:"database"`
+	Timeout  int    `mapstructure:"timeout" yaml:"timeout"`
 }
 
 type SecurityConfig struct {
-	JWTSecret string     `mapstructure:"jwt_secret"`
-	JWTExpiry string     `mapstructure:"jwt_expiry"`
-	CORS      CORSConfig `mapstructure:"cors"`
+	JWTSecret string     `mapstructure:"jwt_secret" yaml:"jwt_secret"`
+	JWTExpiry string     `mapstructure:"jwt_expiry" yaml:"jwt_expiry"`
+	CORS      CORSConfig `mapstructure:"cors" yaml:"cors"`
 }
 
 type CORSConfig struct {
-	Enabled bool     `mapstructure:"enabled"`
-	Origins []string `mapstructure:"origins"`
-	Methods []string `mapstructure:"methods"`
-	Headers []string `mapstructure:"headers"`
+	Enabled bool     `mapstructure:"enabled" yaml:"enabled"`
+	Origins []string `mapstructure:"origins" yaml:"origins"`
+	Methods []string `mapstructure:"methods" yaml:"methods"`
+	Headers []string `mapstructure:"headers" yaml:"headers"`
 }
 
 type MonitoringConfig struct {
-	Enabled   bool         `mapstructure:"enabled"`
-	Metrics   MetricsConfig `mapstructure:"metrics"`
-	HealthCheck HealthCheckConfig `mapstructure:"health_check"`
+	Enabled   bool         `mapstructure:"enabled" yaml:"enabled"`
+	Metrics   MetricsConfig `mapstructure:"metrics" yaml:"metrics"`
+	HealthCheck HealthCheckConfig `mapstructure:"health_check" yaml:"health_check"`
 }
 
 type MetricsConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	Port    int  `mapstructure:"port"`
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	Port    int  `mapstructure:"port" yaml:"port"`
 }
 
 type HealthCheckConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Port    int    `mapstructure:"port"`
-	Path    string `mapstructure:"path"`
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	Port    int    `mapstructure:"port" yaml:"port"`
+	Path    string `mapstructure:"path" yaml:"path"`
 }
 
 type DevelopmentConfig struct {
-	HotReload bool `mapstructure:"hot_reload"`
-	Debug     bool `mapstructure:"debug"`
-	CORS      bool `mapstructure:"cors"`
+	HotReload bool `mapstructure:"hot_reload" yaml:"hot_reload"`
+	Debug     bool `mapstructure:"debug" yaml:"debug"`
+	CORS      bool `mapstructure:"cors" yaml:"cors"`
 }
 
-func LoadConfig(configPath string) *Config {
-	viper.SetConfigFile(configPath)
-	
-	// 设置默认值
-	setDefaults()
-	
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			log.Printf("⚠️  配置文件读取失败: %v", err)
-		}
+// 配置文件路径
+const (
+	// DefaultConfigPath 默认配置文件路径
+	DefaultConfigPath = "config/config.yaml"
+	// ConfigEnv 配置文件路径环境变量，优先级低于 -config 命令行参数
+	ConfigEnv = "QNG_CONFIG"
+)
+
+// DefaultPath 返回 QNG_CONFIG 指定的配置文件路径，未设置时返回 DefaultConfigPath
+func DefaultPath() string {
+	if path := strings.TrimSpace(os.Getenv(ConfigEnv)); path != "" {
+		return path
 	}
+	return DefaultConfigPath
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+// PathFlag 注册 -config 命令行参数，需在 flag.Parse 之前调用。
+// 优先级: -config > QNG_CONFIG > DefaultConfigPath
+func PathFlag() *string {
+	return flag.String("config", DefaultPath(), "配置文件路径（也可通过 "+ConfigEnv+" 环境变量设置）")
+}
+
+// 覆盖配置相关的环境变量
+const (
+	// OverlayEnv 覆盖配置文件路径，合并在基础配置之上
+	OverlayEnv = "QNG_CONFIG_OVERLAY"
+	// EnvironmentEnv 运行环境（如 dev/staging/prod），对应基础配置同目录下的 config.<env>.yaml
+	EnvironmentEnv = "QNG_ENV"
+)
+
+func LoadConfig(configPath string) *Config {
+	config, err := load(configPath, OverlayPath(configPath), false)
+	if err != nil {
 		log.Printf("❌ 配置解析失败: %v", err)
 		return nil
 	}
-
-	return &config
+	return config
 }
 
-// Load 函数，使用默认配置文件路径
+// Load 函数，使用默认配置文件路径（QNG_CONFIG 或 config/config.yaml）
 func Load() (*Config, error) {
-	return LoadFromFile("config/config.yaml")
+	return LoadFromFile(DefaultPath())
 }
 
-// LoadFromFile 从指定文件加载配置
+// LoadFromFile 从指定文件加载配置，并合并环境变量选择的覆盖配置
 func LoadFromFile(configPath string) (*Config, error) {
+	return LoadFromFiles(configPath, OverlayPath(configPath))
+}
+
+// LoadFromFiles 加载基础配置并合并覆盖配置，优先级: 覆盖配置 > 基础配置 > 默认值。
+// overlayPath 为空时只加载基础配置。
+func LoadFromFiles(configPath, overlayPath string) (*Config, error) {
+	return load(configPath, overlayPath, true)
+}
+
+// load 加载配置，strict 为 false 时基础配置读取失败只记录日志并使用默认值
+func load(configPath, overlayPath string, strict bool) (*Config, error) {
 	viper.SetConfigFile(configPath)
 	
 	// 设置默认值
@@ -258,107 +426,179 @@ func LoadFromFile(configPath string) (*Config, error) {
 	
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
+			if strict {
+				return nil, err
+			}
+			log.Printf("⚠️  配置文件读取失败: %v", err)
+		}
+	}
+
+	if overlayPath != "" {
+		log.Printf("🔧 合并覆盖配置: %s", overlayPath)
+		viper.SetConfigFile(overlayPath)
+		if err := viper.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("merge overlay config %s: %w", overlayPath, err)
 		}
+		// 保存时仍写回基础配置
+		viper.SetConfigFile(configPath)
 	}
 
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, err
 	}
+	config.MCP.QNG.DefaultLLM = config.LLM
 
 	return &config, nil
 }
 
-// Save 保存配置到文件
-func Save(cfg *Config) error {
-	return SaveToFile(cfg, "config/config.yaml")
-}
+// OverlayPath 返回环境变量选择的覆盖配置路径，未设置时返回空字符串。
+// QNG_CONFIG_OVERLAY 优先；否则按 QNG_ENV 查找 config.<env>.yaml，文件不存在时忽略。
+func OverlayPath(configPath string) string {
+	if overlay := strings.TrimSpace(os.Getenv(OverlayEnv)); overlay != "" {
+		return overlay
+	}
 
-// SaveToFile 保存配置到指定文件
-func SaveToFile(cfg *Config, configPath string) error {
-	// 创建新的viper实例避免冲突
-	v := viper.New()
-	v.SetConfigFile(configPath)
-	if err := v.ReadInConfig(); err != nil {
-		return fmt.Errorf("read existing config: %w", err)
+	env := strings.TrimSpace(os.Getenv(EnvironmentEnv))
+	if env == "" {
+		return ""
 	}
-	
-	// 更新LLM配置
-	v.Set("llm.provider", cfg.LLM.Provider)
-	if cfg.LLM.OpenAI.APIKey != "" {
-		v.Set("llm.openai.api_key", cfg.LLM.OpenAI.APIKey)
+
+	ext := filepath.Ext(configPath)
+	overlay := strings.TrimSuffix(configPath, ext) + "." + env + ext
+	if _, err := os.Stat(overlay); err != nil {
+		log.Printf("⚠️  未找到 %s 环境的覆盖配置: %s", env, overlay)
+		return ""
 	}
-	if cfg.LLM.OpenAI.Model != "" {
-		v.Set("llm.openai.model", cfg.LLM.OpenAI.Model)
+	return overlay
+}
+
+// Save 保存配置到文件。启用覆盖配置时只把与基础配置不同的字段写入覆盖配置，基础配置保持不变
+func Save(cfg *Config) error {
+	configPath := DefaultPath()
+	if overlayPath := OverlayPath(configPath); overlayPath != "" {
+		return SaveOverlay(cfg, configPath, overlayPath)
 	}
-	if cfg.LLM.OpenAI.BaseURL != "" {
-		v.Set("llm.openai.base_url", cfg.LLM.OpenAI.BaseURL)
+	return SaveToFile(cfg, configPath)
+}
+
+// SaveOverlay 将 cfg 中与基础配置不同的字段原子写入覆盖配置文件。
+// cfg 通常是合并了覆盖配置的完整配置，直接写回基础配置会把覆盖值固化到所有环境
+func SaveOverlay(cfg *Config, configPath, overlayPath string) error {
+	if cfg == nil {
+		return fmt.Errorf("nil config")
 	}
-	if cfg.LLM.OpenAI.Timeout > 0 {
-		v.Set("llm.openai.timeout", cfg.LLM.OpenAI.Timeout)
+
+	base, err := LoadFromFiles(configPath, "")
+	if err != nil {
+		return fmt.Errorf("load base config: %w", err)
 	}
-	if cfg.LLM.OpenAI.MaxTokens > 0 {
-		v.Set("llm.openai.max_tokens", cfg.LLM.OpenAI.MaxTokens)
+	baseValues, err := yamlValues(base)
+	if err != nil {
+		return err
 	}
-	
-	if cfg.LLM.Gemini.APIKey != "" {
-		v.Set("llm.gemini.api_key", cfg.LLM.Gemini.APIKey)
+	values, err := yamlValues(cfg)
+	if err != nil {
+		return err
 	}
-	if cfg.LLM.Gemini.Model != "" {
-		v.Set("llm.gemini.model", cfg.LLM.Gemini.Model)
+
+	overlay := diffValues(values, baseValues)
+	if overlay == nil {
+		overlay = map[string]any{}
 	}
-	if cfg.LLM.Gemini.Timeout > 0 {
-		v.Set("llm.gemini.timeout", cfg.LLM.Gemini.Timeout)
+	data, err := yaml.Marshal(overlay)
+	if err != nil {
+		return fmt.Errorf("marshal overlay config: %w", err)
 	}
-	
-	if cfg.LLM.Anthropic.APIKey != "" {
-		v.Set("llm.anthropic.api_key", cfg.LLM.Anthropic.APIKey)
+	log.Printf("💾 配置变更写入覆盖配置: %s", overlayPath)
+	return writeFileAtomic(overlayPath, data)
+}
+
+// yamlValues 按 yaml 标签将配置转换为嵌套 map，便于逐字段比较
+func yamlValues(cfg *Config) (map[string]any, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
 	}
-	if cfg.LLM.Anthropic.Model != "" {
-		v.Set("llm.anthropic.model", cfg.LLM.Anthropic.Model)
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
 	}
-	if cfg.LLM.Anthropic.Timeout > 0 {
-		v.Set("llm.anthropic.timeout", cfg.LLM.Anthropic.Timeout)
+	return values, nil
+}
+
+// diffValues 返回 values 中与 base 不同的字段，嵌套 map 逐层比较，列表与标量整体比较；没有差异时返回 nil
+func diffValues(values, base map[string]any) map[string]any {
+	var diff map[string]any
+	for key, value := range values {
+		baseValue, exists := base[key]
+		nested, isMap := value.(map[string]any)
+		baseNested, baseIsMap := baseValue.(map[string]any)
+
+		if isMap && baseIsMap {
+			sub := diffValues(nested, baseNested)
+			if sub == nil {
+				continue
+			}
+			value = sub
+		} else if exists && reflect.DeepEqual(value, baseValue) {
+			continue
+		}
+		if diff == nil {
+			diff = map[string]any{}
+		}
+		diff[key] = value
 	}
-	
-	// 更新MCP配置
-	if cfg.MCP.Host != "" {
-		v.Set("mcp.host", cfg.MCP.Host)
+	return diff
+}
+
+// SaveToFile 将完整配置按 yaml 标签序列化并原子写入指定文件
+func SaveToFile(cfg *Config, configPath string) error {
+	if cfg == nil {
+		return fmt.Errorf("nil config")
 	}
-	if cfg.MCP.Port > 0 {
-		v.Set("mcp.port", cfg.MCP.Port)
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
 	}
-	if cfg.MCP.Timeout > 0 {
-		v.Set("mcp.timeout", cfg.MCP.Timeout)
+	return writeFileAtomic(configPath, data)
+}
+
+// writeFileAtomic 先写入同目录临时文件并同步到磁盘，再重命名替换目标文件，
+// 写入中途失败不会损坏原文件
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
 	}
-	
-	// QNG MCP配置
-	v.Set("mcp.qng.enabled", cfg.MCP.QNG.Enabled)
-	if cfg.MCP.QNG.Host != "" {
-		v.Set("mcp.qng.host", cfg.MCP.QNG.Host)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp config: %w", err)
 	}
-	if cfg.MCP.QNG.Port > 0 {
-		v.Set("mcp.qng.port", cfg.MCP.QNG.Port)
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp config: %w", err)
 	}
-	if cfg.MCP.QNG.Timeout > 0 {
-		v.Set("mcp.qng.timeout", cfg.MCP.QNG.Timeout)
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp config: %w", err)
 	}
-	
-	// MetaMask MCP配置
-	v.Set("mcp.metamask.enabled", cfg.MCP.MetaMask.Enabled)
-	if cfg.MCP.MetaMask.Host != "" {
-		v.Set("mcp.metamask.host", cfg.MCP.MetaMask.Host)
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp config: %w", err)
 	}
-	if cfg.MCP.MetaMask.Port > 0 {
-		v.Set("mcp.metamask.port", cfg.MCP.MetaMask.Port)
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("chmod temp config: %w", err)
 	}
-	if cfg.MCP.MetaMask.Timeout > 0 {
-		v.Set("mcp.metamask.timeout", cfg.MCP.MetaMask.Timeout)
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replace config: %w", err)
 	}
-	
-	// 写回配置文件
-	return v.WriteConfig()
+	return nil
 }
 
 func setDefaults() {
@@ -378,26 +618,41 @@ func setDefaults() {
 	viper.SetDefault("llm.openai.base_url", "https://api.openai.com/v1")
 	viper.SetDefault("llm.openai.timeout", 30)
 	viper.SetDefault("llm.openai.max_tokens", 2000)
+	viper.SetDefault("llm.openai.max_retries", 3)
+	viper.SetDefault("llm.openai.retry_backoff", 500)
 	
 	// MCP默认值
 	viper.SetDefault("mcp.mode", "distributed")
 	viper.SetDefault("mcp.host", "localhost")
 	viper.SetDefault("mcp.port", 8081)
 	viper.SetDefault("mcp.timeout", 30)
+	viper.SetDefault("mcp.retry.max_attempts", 3)
+	viper.SetDefault("mcp.retry.backoff", 500)
+	viper.SetDefault("mcp.retry.max_backoff", 5000)
 	
 	// QNG默认值
 	viper.SetDefault("mcp.qng.enabled", true)
 	viper.SetDefault("mcp.qng.host", "localhost")
 	viper.SetDefault("mcp.qng.port", 8082)
 	viper.SetDefault("mcp.qng.timeout", 30)
+	viper.SetDefault("mcp.qng.session_ttl", 30)
+	viper.SetDefault("mcp.qng.max_signature_rounds", 10)
 	viper.SetDefault("mcp.qng.chain.enabled", true)
 	viper.SetDefault("mcp.qng.chain.network", "mainnet")
 	viper.SetDefault("mcp.qng.chain.langgraph.enabled", true)
+	viper.SetDefault("mcp.qng.chain.langgraph.node_timeout", 60)
+	viper.SetDefault("mcp.qng.chain.langgraph.parse_failure", "reprompt")
+	viper.SetDefault("mcp.qng.chain.transaction.confirmation_strategy", "confirmations")
+	viper.SetDefault("mcp.qng.chain.transaction.gas_buffer_percent", 20)
+	viper.SetDefault("mcp.qng.chain.signer.enabled", false)
+	viper.SetDefault("mcp.qng.chain.signer.private_key_env", "QNG_SIGNER_PRIVATE_KEY")
 	
 	// MetaMask默认值
 	viper.SetDefault("mcp.metamask.enabled", true)
 	viper.SetDefault("mcp.metamask.network", "Ethereum Mainnet")
 	viper.SetDefault("mcp.metamask.chain_id", "1")
+	viper.SetDefault("mcp.metamask.mock", false)
+	viper.SetDefault("mcp.metamask.pairing_ttl", 300)
 	
 	// 智能体默认值
 	viper.SetDefault("agent.name", "QNG Agent")
@@ -405,9 +660,15 @@ func setDefaults() {
 	viper.SetDefault("agent.workflow.timeout", 300)
 	viper.SetDefault("agent.workflow.max_retries", 3)
 	viper.SetDefault("agent.workflow.retry_delay", 5)
+	viper.SetDefault("agent.workflow.history_turns", 6)
 	viper.SetDefault("agent.polling.interval", 2)
 	viper.SetDefault("agent.polling.timeout", 30)
 	viper.SetDefault("agent.polling.max_attempts", 15)
+	viper.SetDefault("agent.prompt_guard.enabled", true)
+	viper.SetDefault("agent.intent_classifier", "keyword")
+	viper.SetDefault("agent.request_timeout", 60)
+	viper.SetDefault("agent.context.max_messages", 40)
+	viper.SetDefault("agent.context.summarize", false)
 	
 	// 前端默认值
 	viper.SetDefault("frontend.enabled", true)
@@ -417,6 +678,10 @@ func setDefaults() {
 	viper.SetDefault("frontend.api.timeout", 30)
 	viper.SetDefault("frontend.websocket.enabled", true)
 	viper.SetDefault("frontend.websocket.url", "ws://localhost:8080/ws")
+	viper.SetDefault("frontend.websocket.send_buffer", 256)
+	viper.SetDefault("frontend.websocket.slow_client_policy", "close")
+	viper.SetDefault("frontend.websocket.ping_interval", 30)
+	viper.SetDefault("frontend.websocket.pong_timeout", 60)
 	
 	// 数据库默认值
 	viper.SetDefault("database.driver", "sqlite")
@@ -432,7 +697,7 @@ func setDefaults() {
 	// 监控默认值
 	viper.SetDefault("monitoring.enabled", true)
 	viper.SetDefault("monitoring.metrics.enabled", true)
-	viper.SetDefault("monitoring.metrics.port", 9090)
+	viper.SetDefault("monitoring.metrics.port", 9100)
 	viper.SetDefault("monitoring.health_check.enabled", true)
 	viper.SetDefault("monitoring.health_check.port", 8080)
 	viper.SetDefault("monitoring.health_check.path", "/health")
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeConfig 在临时目录写入配置文件并返回路径
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestSaveOverlayKeepsBaseConfig(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfig(t, dir, "config.yaml", `
+server:
+  host: 0.0.0.0
+  port: 8080
+llm:
+  provider: openai
+`)
+	overlayPath := writeConfig(t, dir, "config.prod.yaml", `
+server:
+  port: 9000
+`)
+	baseBefore, err := os.ReadFile(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromFiles(basePath, overlayPath)
+	if err != nil {
+		t.Fatalf("LoadFromFiles: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Fatalf("merged port = %d, want 9000", cfg.Server.Port)
+	}
+
+	cfg.LLM.Provider = "anthropic"
+	if err := SaveOverlay(cfg, basePath, overlayPath); err != nil {
+		t.Fatalf("SaveOverlay: %v", err)
+	}
+
+	baseAfter, err := os.ReadFile(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(baseAfter) != string(baseBefore) {
+		t.Errorf("base config was modified:\n%s", baseAfter)
+	}
+
+	base, err := LoadFromFiles(basePath, "")
+	if err != nil {
+		t.Fatalf("load base: %v", err)
+	}
+	if base.Server.Port != 8080 || base.LLM.Provider != "openai" {
+		t.Errorf("base = port %d provider %s, want 8080 openai", base.Server.Port, base.LLM.Provider)
+	}
+
+	merged, err := LoadFromFiles(basePath, overlayPath)
+	if err != nil {
+		t.Fatalf("reload merged: %v", err)
+	}
+	if merged.Server.Port != 9000 || merged.LLM.Provider != "anthropic" || merged.Server.Host != "0.0.0.0" {
+		t.Errorf("merged = host %s port %d provider %s, want 0.0.0.0 9000 anthropic",
+			merged.Server.Host, merged.Server.Port, merged.LLM.Provider)
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]any
+		base   map[string]any
+		want   map[string]any
+	}{
+		{
+			name:   "identical",
+			values: map[string]any{"server": map[string]any{"port": 8080}},
+			base:   map[string]any{"server": map[string]any{"port": 8080}},
+			want:   nil,
+		},
+		{
+			name:   "nested scalar changed",
+			values: map[string]any{"server": map[string]any{"host": "a", "port": 9000}},
+			base:   map[string]any{"server": map[string]any{"host": "a", "port": 8080}},
+			want:   map[string]any{"server": map[string]any{"port": 9000}},
+		},
+		{
+			name:   "list replaced as a whole",
+			values: map[string]any{"methods": []any{"a", "b"}},
+			base:   map[string]any{"methods": []any{"a"}},
+			want:   map[string]any{"methods": []any{"a", "b"}},
+		},
+		{
+			name:   "new key",
+			values: map[string]any{"users": map[string]any{"0xabc": 1}},
+			base:   map[string]any{},
+			want:   map[string]any{"users": map[string]any{"0xabc": 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffValues(tt.values, tt.base)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffValues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveToFileRoundTrip(t *testing.T) {
+	cfg, err := LoadFromFiles("../../config/config.yaml", "")
+	if err != nil {
+		t.Fatalf("load repository config: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config.yaml", "server:\n  port: 1\n")
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveToFile(cfg, path); err != nil {
+		t.Fatalf("SaveToFile: %v", err)
+	}
+
+	loaded, err := LoadFromFiles(path, "")
+	if err != nil {
+		t.Fatalf("load saved config: %v", err)
+	}
+	// nil 与空列表在 yaml 中等价，按序列化后的字段比较
+	want, err := yamlValues(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := yamlValues(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffValues(got, want); diff != nil {
+		t.Errorf("round trip changed fields: %v", diff)
+	}
+	if diff := diffValues(want, got); diff != nil {
+		t.Errorf("round trip lost fields: %v", diff)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %d entries in %s", len(entries), dir)
+	}
+}
@@ -0,0 +1,234 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ErrInvalidFunctionCall 函数配置或调用参数无法编码
+var ErrInvalidFunctionCall = errors.New("invalid function call")
+
+// builtinFunctions 合约未在 contracts.json 中声明函数时使用的默认函数定义
+var builtinFunctions = map[string]FunctionInfo{
+	"stake":        {Signature: "stake(uint256)", Parameters: []ParameterInfo{{Name: "amount", Type: "uint256"}}},
+	"unstake":      {Signature: "unstake(uint256)", Parameters: []ParameterInfo{{Name: "amount", Type: "uint256"}}},
+	"claimRewards": {Signature: "claimRewards()"},
+	"approve":      {Signature: "approve(address,uint256)", Parameters: []ParameterInfo{{Name: "spender", Type: "address"}, {Name: "amount", Type: "uint256"}}},
+	"transfer":     {Signature: "transfer(address,uint256)", Parameters: []ParameterInfo{{Name: "to", Type: "address"}, {Name: "amount", Type: "uint256"}}},
+	"buyToken":     {Signature: "buyToken()", Payable: true},
+	"sellToken":    {Signature: "sellToken(uint256)", Parameters: []ParameterInfo{{Name: "tokenAmount", Type: "uint256", Source: "amount"}}},
+	// 兑换合约的报价函数
+	"getAmountOut": {Signature: "getAmountOut(uint256)", Parameters: []ParameterInfo{{Name: "amountIn", Type: "uint256", Source: "amount"}}},
+	"getRate":      {Signature: "getRate()"},
+	// 质押合约的只读函数
+	"balanceOf":       {Signature: "balanceOf(address)", Parameters: []ParameterInfo{{Name: "user", Type: "address"}}},
+	"calculateReward": {Signature: "calculateReward(address)", Parameters: []ParameterInfo{{Name: "user", Type: "address"}}},
+	// ERC20 授权额度
+	"allowance": {Signature: "allowance(address,address)", Parameters: []ParameterInfo{{Name: "owner", Type: "address"}, {Name: "spender", Type: "address"}}},
+}
+
+// FunctionSelector 计算函数签名的4字节选择器（不含0x前缀）
+func FunctionSelector(signature string) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return hex.EncodeToString(h.Sum(nil)[:4])
+}
+
+// EncodeFunctionCall 按 contracts.json 中合约声明的函数元数据编码调用数据。
+// 参数依次从 args 中按 source、name 取值，都没有时使用配置的 default；
+// 合约未声明该函数时回退到内置的默认定义
+func (cm *ContractManager) EncodeFunctionCall(contractName, function string, args map[string]any) (string, error) {
+	contract, exists := cm.config.Contracts[contractName]
+	if !exists {
+		contract.Name = contractName
+	}
+	return encodeContractCall(contract, function, args)
+}
+
+// encodeTokenCall 编码代币合约调用，按合约地址查找 contracts.json 中对应的合约配置
+func (cm *ContractManager) encodeTokenCall(tokenAddress, function string, args map[string]any) (string, error) {
+	for _, contract := range cm.config.Contracts {
+		if strings.EqualFold(contract.Address, tokenAddress) {
+			return encodeContractCall(contract, function, args)
+		}
+	}
+	return encodeContractCall(ContractInfo{Name: tokenAddress}, function, args)
+}
+
+// encodeContractCall 使用合约声明的函数编码调用，未声明时使用内置定义
+func encodeContractCall(contract ContractInfo, function string, args map[string]any) (string, error) {
+	fn, exists := contract.Functions[function]
+	if !exists {
+		if fn, exists = builtinFunctions[function]; !exists {
+			return "", fmt.Errorf("%w: %s.%s is not configured", ErrInvalidFunctionCall, contract.Name, function)
+		}
+	}
+	return encodeCall(function, fn, args)
+}
+
+// encodeCall 按函数签名和参数定义进行ABI编码（仅支持静态类型）
+func encodeCall(function string, fn FunctionInfo, args map[string]any) (string, error) {
+	types, err := signatureTypes(fn.Signature)
+	if err != nil {
+		return "", err
+	}
+	if len(types) != len(fn.Parameters) {
+		return "", fmt.Errorf("%w: %s declares %d parameters but signature %q has %d", ErrInvalidFunctionCall, function, len(fn.Parameters), fn.Signature, len(types))
+	}
+
+	var data strings.Builder
+	data.WriteString("0x")
+	data.WriteString(FunctionSelector(fn.Signature))
+	for i, param := range fn.Parameters {
+		if param.Type != "" && param.Type != types[i] {
+			return "", fmt.Errorf("%w: parameter %s of %s has type %s but signature %q expects %s", ErrInvalidFunctionCall, param.Name, function, param.Type, fn.Signature, types[i])
+		}
+		value, ok := parameterValue(param, args)
+		if !ok {
+			return "", fmt.Errorf("%w: missing value for parameter %s of %s", ErrInvalidFunctionCall, param.Name, function)
+		}
+		word, err := encodeWord(types[i], value)
+		if err != nil {
+			return "", fmt.Errorf("%w: parameter %s of %s: %v", ErrInvalidFunctionCall, param.Name, function, err)
+		}
+		data.WriteString(word)
+	}
+	return data.String(), nil
+}
+
+// functionTakesArg 合约函数（未声明时为内置定义）是否有按 source 或 name 从 arg 取值的参数
+func functionTakesArg(contract ContractInfo, function, arg string) bool {
+	fn, exists := contract.Functions[function]
+	if !exists {
+		fn = builtinFunctions[function]
+	}
+	for _, param := range fn.Parameters {
+		if param.Source == arg || param.Name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterValue 依次按 source、name、default 取参数值
+func parameterValue(param ParameterInfo, args map[string]any) (any, bool) {
+	if param.Source != "" {
+		if value, exists := args[param.Source]; exists {
+			return value, true
+		}
+	}
+	if value, exists := args[param.Name]; exists {
+		return value, true
+	}
+	if param.Default != "" {
+		return param.Default, true
+	}
+	return nil, false
+}
+
+// signatureTypes 解析函数签名中的参数类型列表
+func signatureTypes(signature string) ([]string, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("%w: malformed signature %q", ErrInvalidFunctionCall, signature)
+	}
+	inner := signature[open+1 : len(signature)-1]
+	if inner == "" {
+		return nil, nil
+	}
+	types := strings.Split(inner, ",")
+	for i, typ := range types {
+		types[i] = strings.TrimSpace(typ)
+	}
+	return types, nil
+}
+
+// encodeWord 将单个静态类型的值编码为32字节（64位十六进制）
+func encodeWord(typ string, value any) (string, error) {
+	switch {
+	case typ == "address":
+		addr, ok := value.(string)
+		if !ok || !addressPattern.MatchString(addr) {
+			return "", fmt.Errorf("invalid address %v", value)
+		}
+		return fmt.Sprintf("%064s", strings.ToLower(strings.TrimPrefix(addr, "0x"))), nil
+
+	case typ == "bool":
+		var b bool
+		switch v := value.(type) {
+		case bool:
+			b = v
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid bool %q", v)
+			}
+			b = parsed
+		default:
+			return "", fmt.Errorf("invalid bool %v", value)
+		}
+		if b {
+			return fmt.Sprintf("%064x", 1), nil
+		}
+		return fmt.Sprintf("%064x", 0), nil
+
+	case typ == "bytes32":
+		s, ok := value.(string)
+		raw := strings.TrimPrefix(s, "0x")
+		if !ok || len(raw) > 64 {
+			return "", fmt.Errorf("invalid bytes32 %v", value)
+		}
+		if _, err := hex.DecodeString(raw); err != nil {
+			return "", fmt.Errorf("invalid bytes32 %v", value)
+		}
+		return raw + strings.Repeat("0", 64-len(raw)), nil
+
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		n, err := toBigInt(value)
+		if err != nil {
+			return "", err
+		}
+		if n.Sign() < 0 {
+			if strings.HasPrefix(typ, "uint") {
+				return "", fmt.Errorf("negative value %s for %s", n, typ)
+			}
+			// 二进制补码表示
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		if n.BitLen() > 256 {
+			return "", fmt.Errorf("value %s overflows %s", n, typ)
+		}
+		return fmt.Sprintf("%064s", n.Text(16)), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", typ)
+}
+
+// toBigInt 将整数参数值（*big.Int、整数或十进制/0x十六进制字符串）转换为 *big.Int
+func toBigInt(value any) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case string:
+		s := strings.TrimSpace(v)
+		base := 10
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			s, base = s[2:], 16
+		}
+		if n, ok := new(big.Int).SetString(s, base); ok {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid integer %v", value)
+}
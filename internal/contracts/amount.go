@@ -0,0 +1,76 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAmount 数量无效（非正数或低于最小数量）
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// AmountFromPrevious 表示使用前一个任务的全部输出
+const AmountFromPrevious = "all_from_previous"
+
+// NormalizeAmount 将用户或LLM给出的数量规范化为十进制字符串（去除千分位、空白和多余的零）
+func NormalizeAmount(amount string) (string, error) {
+	raw := strings.TrimSpace(amount)
+	if raw == AmountFromPrevious {
+		return raw, nil
+	}
+
+	cleaned := strings.ReplaceAll(raw, ",", "")
+	cleaned = strings.ReplaceAll(cleaned, "，", "")
+	if cleaned == "" {
+		return "", fmt.Errorf("empty amount")
+	}
+
+	value, ok := new(big.Rat).SetString(cleaned)
+	if !ok {
+		return "", fmt.Errorf("invalid amount: %q", amount)
+	}
+
+	// 保留足够精度以覆盖 18 位小数的代币
+	normalized := value.FloatString(18)
+	if strings.Contains(normalized, ".") {
+		normalized = strings.TrimRight(strings.TrimRight(normalized, "0"), ".")
+	}
+	return normalized, nil
+}
+
+// ValidateAmount 校验数量为正数且不低于代币的最小数量
+func (cm *ContractManager) ValidateAmount(symbol, amount string) error {
+	_, err := cm.parseAmount(symbol, amount)
+	return err
+}
+
+// parseAmount 解析并校验数量，拒绝零、负数以及低于最小数量（粉尘）的值
+func (cm *ContractManager) parseAmount(symbol, amount string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%w: %s %s must be greater than zero", ErrInvalidAmount, amount, symbol)
+	}
+
+	if min := cm.minAmount(symbol); value < min {
+		return 0, fmt.Errorf("%w: %s %s is below the minimum of %g %s", ErrInvalidAmount, amount, symbol, min, symbol)
+	}
+	return value, nil
+}
+
+// minAmount 返回代币的最小数量，未配置时为代币最小单位
+func (cm *ContractManager) minAmount(symbol string) float64 {
+	decimals := 18
+	if token, exists := cm.config.Tokens[symbol]; exists {
+		if token.MinAmount > 0 {
+			return token.MinAmount
+		}
+		decimals = token.Decimals
+	}
+	return math.Pow10(-decimals)
+}
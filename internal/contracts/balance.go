@@ -0,0 +1,165 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrAmountTooLarge 数量超出代币配置上限或账户余额
+var ErrAmountTooLarge = errors.New("amount too large")
+
+// BalanceReader 读取账户的代币余额，tokenAddress 为空表示原生代币（见 rpc.Client.GetTokenBalance）
+type BalanceReader interface {
+	GetTokenBalance(ctx context.Context, tokenAddress, account string) (*big.Int, error)
+}
+
+// InsufficientBalanceError 账户余额不足以执行交易，数量均为十进制字符串
+type InsufficientBalanceError struct {
+	Token     string `json:"token"`
+	Address   string `json:"address"`
+	Required  string `json:"required"`
+	Available string `json:"available"`
+	// Missing 还需补足的数量（Required - Available）
+	Missing string `json:"missing"`
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("%v: %s %s exceeds the balance of %s (%s %s, missing %s %s)",
+		ErrAmountTooLarge, e.Required, e.Token, e.Address, e.Available, e.Token, e.Missing, e.Token)
+}
+
+// Unwrap 使 errors.Is(err, ErrAmountTooLarge) 对余额不足同样成立
+func (e *InsufficientBalanceError) Unwrap() error {
+	return ErrAmountTooLarge
+}
+
+// CheckMaxAmount 校验数量不超过代币配置的绝对上限（maxAmount），未配置时不限制
+func (cm *ContractManager) CheckMaxAmount(symbol, amount string) error {
+	token, exists := cm.config.Tokens[symbol]
+	if !exists || token.MaxAmount <= 0 {
+		return nil
+	}
+
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	limit := new(big.Rat).SetFloat64(token.MaxAmount)
+	if value.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: %s %s exceeds the maximum of %g %s per transaction", ErrAmountTooLarge, amount, symbol, token.MaxAmount, symbol)
+	}
+	return nil
+}
+
+// CheckBalance 校验数量不超过账户的代币余额（最小单位，见 GetBalance）
+func (cm *ContractManager) CheckBalance(symbol, amount, address string, balance *big.Int) error {
+	required, err := cm.toBaseUnits(symbol, amount)
+	if err != nil {
+		return err
+	}
+
+	if required.Cmp(balance) > 0 {
+		return &InsufficientBalanceError{
+			Token:     symbol,
+			Address:   address,
+			Required:  amount,
+			Available: cm.formatUnits(symbol, balance),
+			Missing:   cm.formatUnits(symbol, new(big.Int).Sub(required, balance)),
+		}
+	}
+	return nil
+}
+
+// GetBalance 读取账户的代币余额（最小单位），原生代币使用 eth_getBalance，ERC20 使用 balanceOf
+func (cm *ContractManager) GetBalance(ctx context.Context, reader BalanceReader, symbol, address string) (*big.Int, error) {
+	token, exists := cm.config.Tokens[symbol]
+	if !exists {
+		return nil, fmt.Errorf("unsupported token: %s", symbol)
+	}
+
+	tokenAddress := ""
+	if !token.IsNative {
+		if token.ContractAddress == "" {
+			return nil, fmt.Errorf("token %s has no contract address", symbol)
+		}
+		tokenAddress = token.ContractAddress
+	}
+
+	balance, err := reader.GetTokenBalance(ctx, tokenAddress, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s balance: %w", symbol, err)
+	}
+	return balance, nil
+}
+
+// GetAllowance 读取 owner 授权给 spender 的 ERC20 额度（最小单位）
+func (cm *ContractManager) GetAllowance(ctx context.Context, caller ContractCaller, symbol, owner, spender string) (*big.Int, error) {
+	token, exists := cm.config.Tokens[symbol]
+	if !exists {
+		return nil, fmt.Errorf("unsupported token: %s", symbol)
+	}
+	if token.IsNative || token.ContractAddress == "" {
+		return nil, fmt.Errorf("token %s has no contract address", symbol)
+	}
+
+	data, err := encodeCall("allowance", builtinFunctions["allowance"], map[string]any{"owner": owner, "spender": spender})
+	if err != nil {
+		return nil, err
+	}
+	result, err := caller.Call(ctx, token.ContractAddress, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s allowance: %w", symbol, err)
+	}
+
+	raw := strings.TrimPrefix(result, "0x")
+	if len(raw) < 64 {
+		return nil, fmt.Errorf("unexpected allowance result: %s", result)
+	}
+	allowance, ok := new(big.Int).SetString(raw[:64], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid allowance result: %s", result)
+	}
+	return allowance, nil
+}
+
+// toBaseUnits 将十进制数量换算为代币最小单位
+func (cm *ContractManager) toBaseUnits(symbol, amount string) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt(cm.decimalsUnit(symbol)))
+	return new(big.Int).Quo(value.Num(), value.Denom()), nil
+}
+
+// formatUnits 将最小单位换算为十进制字符串
+func (cm *ContractManager) formatUnits(symbol string, units *big.Int) string {
+	value := new(big.Rat).SetFrac(units, cm.decimalsUnit(symbol))
+	formatted := value.FloatString(6)
+	return strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+}
+
+// exactUnits 将最小单位换算为不损失精度的十进制字符串，可再由 toBaseUnits 还原
+func (cm *ContractManager) exactUnits(symbol string, units *big.Int) string {
+	formatted := new(big.Rat).SetFrac(units, cm.decimalsUnit(symbol)).FloatString(cm.tokenDecimals(symbol))
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted
+}
+
+// decimalsUnit 返回代币精度对应的 10^decimals
+func (cm *ContractManager) decimalsUnit(symbol string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(cm.tokenDecimals(symbol))), nil)
+}
+
+// tokenDecimals 返回代币精度，未配置的代币按 18 位处理
+func (cm *ContractManager) tokenDecimals(symbol string) int {
+	if token, exists := cm.config.Tokens[symbol]; exists {
+		return token.Decimals
+	}
+	return 18
+}
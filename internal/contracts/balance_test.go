@@ -0,0 +1,49 @@
+package contracts
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// recordingCaller 记录 eth_call 的目标与调用数据，返回固定结果
+type recordingCaller struct {
+	to, data string
+	result   string
+}
+
+func (c *recordingCaller) Call(ctx context.Context, to, data string) (string, error) {
+	c.to, c.data = to, data
+	return c.result, nil
+}
+
+func TestGetAllowance(t *testing.T) {
+	cm := newTestManager(t)
+	owner := "0x00000000000000000000000000000000000000c1"
+	spender := "0x00000000000000000000000000000000000000d2"
+
+	caller := &recordingCaller{result: "0x" + strings.Repeat("0", 48) + "0de0b6b3a7640000"}
+	allowance, err := cm.GetAllowance(context.Background(), caller, "MTK", owner, spender)
+	if err != nil {
+		t.Fatalf("GetAllowance: %v", err)
+	}
+	if got := cm.FormatUnits("MTK", allowance); got != "1 MTK" {
+		t.Errorf("allowance = %s, want 1 MTK", got)
+	}
+	if caller.to != cm.config.Tokens["MTK"].ContractAddress {
+		t.Errorf("called %s, want MTK contract", caller.to)
+	}
+	wantData := "0x" + FunctionSelector("allowance(address,address)") +
+		strings.Repeat("0", 24) + owner[2:] + strings.Repeat("0", 24) + spender[2:]
+	if caller.data != wantData {
+		t.Errorf("call data = %s, want %s", caller.data, wantData)
+	}
+
+	if _, err := cm.GetAllowance(context.Background(), caller, "MEER", owner, spender); err == nil {
+		t.Error("native token allowance: expected error")
+	}
+	caller.result = "0x"
+	if _, err := cm.GetAllowance(context.Background(), caller, "MTK", owner, spender); err == nil {
+		t.Error("empty result: expected error")
+	}
+}
@@ -0,0 +1,104 @@
+package contracts
+
+import (
+	"math/big"
+	"strings"
+)
+
+// defaultDisplayDecimals 代币未配置 displayDecimals 时显示的最多小数位数
+const defaultDisplayDecimals = 6
+
+// FormatAmount 将十进制数量格式化为面向用户的显示文本，如 "1,000.5 MTK"：
+// 按代币配置截断到 displayDecimals 位小数（不超过代币精度，向下取整，避免夸大数量），
+// 去除末尾的零并添加千分位；非零但低于显示精度的数量显示为 "< 0.000001 MTK"。无法解析的数量（如 all_from_previous）原样附加代币符号
+func (cm *ContractManager) FormatAmount(symbol, amount string) string {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return strings.TrimSpace(amount + " " + symbol)
+	}
+	return cm.formatDisplay(symbol, value)
+}
+
+// FormatUnits 将最小单位的数量格式化为面向用户的显示文本，规则同 FormatAmount
+func (cm *ContractManager) FormatUnits(symbol string, units *big.Int) string {
+	return cm.formatDisplay(symbol, new(big.Rat).SetFrac(units, cm.decimalsUnit(symbol)))
+}
+
+// formatDisplay 按代币的显示精度截断并格式化数量
+func (cm *ContractManager) formatDisplay(symbol string, value *big.Rat) string {
+	places := cm.displayDecimals(symbol)
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+
+	// 按绝对值向下截断到 places 位小数
+	scaled := new(big.Rat).Mul(new(big.Rat).Abs(value), new(big.Rat).SetInt(scale))
+	truncated := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	intPart, fracPart := new(big.Int).QuoRem(truncated, scale, new(big.Int))
+
+	var b strings.Builder
+	// 大于零但低于显示精度的数量显示为 "< 0.000001"，不显示为 0
+	if truncated.Sign() == 0 && value.Sign() > 0 {
+		b.WriteString("< ")
+		b.WriteString(new(big.Rat).SetFrac(big.NewInt(1), scale).FloatString(places))
+		if symbol != "" {
+			b.WriteByte(' ')
+			b.WriteString(symbol)
+		}
+		return b.String()
+	}
+	if value.Sign() < 0 && truncated.Sign() > 0 {
+		b.WriteByte('-')
+	}
+	b.WriteString(groupThousands(intPart.String()))
+	if places > 0 {
+		frac := strings.TrimRight(leftPad(fracPart.String(), places), "0")
+		if frac != "" {
+			b.WriteByte('.')
+			b.WriteString(frac)
+		}
+	}
+	if symbol != "" {
+		b.WriteByte(' ')
+		b.WriteString(symbol)
+	}
+	return b.String()
+}
+
+// displayDecimals 返回代币显示的小数位数：配置的 displayDecimals（默认 6），不超过代币精度
+func (cm *ContractManager) displayDecimals(symbol string) int {
+	places := defaultDisplayDecimals
+	token, exists := cm.config.Tokens[symbol]
+	if !exists {
+		return places
+	}
+	if token.DisplayDecimals > 0 {
+		places = token.DisplayDecimals
+	}
+	return min(places, token.Decimals)
+}
+
+// groupThousands 为整数部分添加千分位分隔符
+func groupThousands(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// leftPad 在左侧补零到指定长度
+func leftPad(digits string, width int) string {
+	if len(digits) >= width {
+		return digits
+	}
+	return strings.Repeat("0", width-len(digits)) + digits
+}
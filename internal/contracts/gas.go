@@ -0,0 +1,101 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// defaultGasPriceGwei 未配置时使用的 gas 价格
+const defaultGasPriceGwei = 1.0
+
+// defaultPriorityFeeGwei EIP-1559 网络未配置时使用的小费
+const defaultPriorityFeeGwei = 1.0
+
+// NativeSymbol 返回网络原生代币符号，优先使用网络配置，其次是标记为原生的代币
+func (cm *ContractManager) NativeSymbol() string {
+	if cm.config.Network.NativeSymbol != "" {
+		return cm.config.Network.NativeSymbol
+	}
+	for symbol, token := range cm.config.Tokens {
+		if token.IsNative {
+			if token.Symbol != "" {
+				return token.Symbol
+			}
+			return symbol
+		}
+	}
+	return cm.config.Network.Name
+}
+
+// gasPriceGwei 返回配置的 gas 价格（gwei）
+func (cm *ContractManager) gasPriceGwei() float64 {
+	if gwei := cm.config.Network.GasPriceGwei; gwei > 0 {
+		return gwei
+	}
+	return defaultGasPriceGwei
+}
+
+// applyGasFees 按网络配置填充交易费用：EIP-1559 网络设置 maxFeePerGas 与 maxPriorityFeePerGas，
+// 传统网络设置 gasPrice
+func (cm *ContractManager) applyGasFees(tx *TransactionData) {
+	network := cm.config.Network
+	if !network.EIP1559 {
+		tx.GasPrice = gweiToHex(cm.gasPriceGwei())
+		return
+	}
+
+	priorityFee := network.MaxPriorityFeeGwei
+	if priorityFee <= 0 {
+		priorityFee = defaultPriorityFeeGwei
+	}
+	maxFee := network.MaxFeeGwei
+	if maxFee <= 0 {
+		// 预留基础费用翻倍的空间
+		maxFee = 2*cm.gasPriceGwei() + priorityFee
+	}
+	tx.MaxPriorityFeePerGas = gweiToHex(priorityFee)
+	tx.MaxFeePerGas = gweiToHex(maxFee)
+}
+
+// gweiToHex 将 gwei 转换为十六进制 wei
+func gweiToHex(gwei float64) string {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return "0x" + wei.Text(16)
+}
+
+// FormatGasFee 按 gasLimit × gasPrice（EIP-1559 交易为 maxFeePerGas）计算手续费上限，并以原生代币单位显示
+func (cm *ContractManager) FormatGasFee(tx *TransactionData) string {
+	symbol := cm.NativeSymbol()
+
+	price := tx.GasPrice
+	if tx.MaxFeePerGas != "" {
+		price = tx.MaxFeePerGas
+	}
+	gasLimit, ok1 := parseHexBig(tx.GasLimit)
+	gasPrice, ok2 := parseHexBig(price)
+	if !ok1 || !ok2 {
+		return fmt.Sprintf("unknown %s", symbol)
+	}
+
+	feeWei := new(big.Int).Mul(gasLimit, gasPrice)
+	fee := new(big.Float).Quo(new(big.Float).SetInt(feeWei), big.NewFloat(1e18))
+	return fmt.Sprintf("%s %s", fee.Text('f', 6), symbol)
+}
+
+// parseHexBig 解析 0x 前缀的十六进制数
+func parseHexBig(value string) (*big.Int, bool) {
+	return new(big.Int).SetString(strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X"), 16)
+}
+
+// ExplorerTxURL 按网络配置的模板生成交易浏览器链接，未配置时返回空字符串
+func (cm *ContractManager) ExplorerTxURL(txHash string) string {
+	template := cm.config.Network.BlockExplorerURL
+	if template == "" || txHash == "" {
+		return ""
+	}
+	if strings.Contains(template, "{txHash}") {
+		return strings.ReplaceAll(template, "{txHash}", txHash)
+	}
+	return strings.TrimRight(template, "/") + "/tx/" + txHash
+}
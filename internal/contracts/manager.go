@@ -5,17 +5,45 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/big"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 	"regexp"
+	"sync"
 )
 
+// MaxParseInputLength 正则解析接受的最大输入长度（字节）
+const MaxParseInputLength = 1024
+
+// swapPatterns 兑换请求匹配模式
+var swapPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`兑换\s*(\d+(?:\.\d+)?)\s*(\w+)(?:\s*为?\s*(\w+))?`),
+	regexp.MustCompile(`将\s*(\d+(?:\.\d+)?)\s*(\w+)\s*换成\s*(\w+)`),
+	regexp.MustCompile(`swap\s+(\d+(?:\.\d+)?)\s+(\w+)\s+to\s+(\w+)`),
+	regexp.MustCompile(`exchange\s+(\d+(?:\.\d+)?)\s+(\w+)\s+for\s+(\w+)`),
+}
+
+// stakePatterns 质押请求匹配模式及对应操作，按顺序匹配；
+// 取消质押的模式包含质押的模式（如 unstake 包含 stake），必须排在前面
+var stakePatterns = []struct {
+	re     *regexp.Regexp
+	action string
+}{
+	{regexp.MustCompile(`取消质押\s*(\d+(?:\.\d+)?)\s*(\w+)`), "unstake"},
+	{regexp.MustCompile(`解质押\s*(\d+(?:\.\d+)?)\s*(\w+)`), "unstake"},
+	{regexp.MustCompile(`unstake\s+(\d+(?:\.\d+)?)\s+(\w+)`), "unstake"},
+	{regexp.MustCompile(`质押\s*(\d+(?:\.\d+)?)\s*(\w+)`), "stake"},
+	{regexp.MustCompile(`将\s*(\d+(?:\.\d+)?)\s*(\w+)\s*质押`), "stake"},
+	{regexp.MustCompile(`stake\s+(\d+(?:\.\d+)?)\s+(\w+)`), "stake"},
+	{regexp.MustCompile(`领取奖励|领取收益|claim\s+rewards|提取奖励|收取奖励`), "claimRewards"},
+}
+
 // ContractManager 合约管理器
 type ContractManager struct {
 	config     *ContractConfig
 	artifacts  map[string]*ContractArtifact
+	rates      map[string]cachedRate
+	ratesMu    sync.RWMutex
 }
 
 // ContractConfig 合约配置结构
@@ -25,6 +53,7 @@ type ContractConfig struct {
 	Tokens    map[string]TokenConfig        `json:"tokens"`
 	Contracts map[string]ContractInfo       `json:"contracts"`
 	Workflows map[string]WorkflowConfig     `json:"workflows"`
+	RateRefresh RateRefreshConfig           `json:"rateRefresh"`
 }
 
 // NetworkConfig 网络配置
@@ -32,6 +61,18 @@ type NetworkConfig struct {
 	ChainID int    `json:"chainId"`
 	Name    string `json:"name"`
 	RPCURL  string `json:"rpcUrl"`
+	// NativeSymbol 原生代币符号，未配置时使用 isNative 代币
+	NativeSymbol string `json:"nativeSymbol,omitempty"`
+	// GasPriceGwei gas 价格（gwei），未配置时为 1 gwei
+	GasPriceGwei float64 `json:"gasPriceGwei,omitempty"`
+	// EIP1559 网络支持 EIP-1559 时构建 type-2 交易，使用 maxFeePerGas/maxPriorityFeePerGas 代替 gasPrice
+	EIP1559 bool `json:"eip1559,omitempty"`
+	// MaxPriorityFeeGwei EIP-1559 小费（gwei），未配置时为 1 gwei
+	MaxPriorityFeeGwei float64 `json:"maxPriorityFeeGwei,omitempty"`
+	// MaxFeeGwei EIP-1559 最高费用（gwei），未配置时为 2 × gasPriceGwei + 小费
+	MaxFeeGwei float64 `json:"maxFeeGwei,omitempty"`
+	// BlockExplorerURL 交易浏览器地址模板，{txHash} 会被替换为交易哈希
+	BlockExplorerURL string `json:"blockExplorerUrl,omitempty"`
 }
 
 // TokenConfig 代币配置
@@ -43,6 +84,12 @@ type TokenConfig struct {
 	ContractAddress string `json:"contractAddress,omitempty"`
 	ContractName    string `json:"contractName,omitempty"`
 	Description     string `json:"description"`
+	// MinAmount 最小交易数量（粉尘阈值），未配置时为代币最小单位
+	MinAmount float64 `json:"minAmount,omitempty"`
+	// MaxAmount 单笔交易的最大数量，未配置时不限制
+	MaxAmount float64 `json:"maxAmount,omitempty"`
+	// DisplayDecimals 面向用户显示数量时最多保留的小数位数，未配置时为 6（不超过 Decimals）
+	DisplayDecimals int `json:"displayDecimals,omitempty"`
 }
 
 // ContractInfo 合约信息
@@ -54,6 +101,7 @@ type ContractInfo struct {
 	Description  string                    `json:"description"`
 	Functions    map[string]FunctionInfo   `json:"functions"`
 	SupportedPairs []SwapPair              `json:"supportedPairs,omitempty"`
+	StakingInfo    *StakingInfo            `json:"stakingInfo,omitempty"`
 }
 
 // FunctionInfo 函数信息
@@ -70,6 +118,10 @@ type ParameterInfo struct {
 	Name        string `json:"name"`
 	Type        string `json:"type"`
 	Description string `json:"description"`
+	// Source 从构建器提供的哪个值取参数（如 amount、spender），为空时按 Name 取值
+	Source      string `json:"source,omitempty"`
+	// Default 构建器未提供该参数时使用的默认值（如锁仓期）
+	Default     string `json:"default,omitempty"`
 }
 
 // SwapPair 交换对信息
@@ -79,6 +131,13 @@ type SwapPair struct {
 	Method      string  `json:"method"`
 	Rate        float64 `json:"rate"`
 	Description string  `json:"description"`
+	// PoolAddress AMM池地址，配置后会通过 getReserves() 刷新汇率
+	PoolAddress string `json:"poolAddress,omitempty"`
+	// FromReserveIndex 源代币在 getReserves() 返回值中的位置（0 或 1）
+	FromReserveIndex int `json:"fromReserveIndex,omitempty"`
+	// QuoteMethod 兑换合约上查询当前汇率的只读函数（如 getAmountOut、getRate），
+	// 配置后构建兑换交易前通过 eth_call 读取汇率，调用失败时使用 Rate
+	QuoteMethod string `json:"quoteMethod,omitempty"`
 }
 
 // WorkflowConfig 工作流配置
@@ -88,6 +147,9 @@ type WorkflowConfig struct {
 	SupportedTokens []string `json:"supportedTokens,omitempty"`
 	Contract        string   `json:"contract"`
 	Patterns        []string `json:"patterns"`
+	// Pools stake 工作流可选的质押池，DefaultPool 为用户未指定时使用的池
+	Pools           []StakingPool `json:"pools,omitempty"`
+	DefaultPool     string        `json:"defaultPool,omitempty"`
 }
 
 // ContractArtifact 合约编译产物
@@ -103,7 +165,12 @@ type TransactionData struct {
 	Value    string `json:"value"`
 	Data     string `json:"data"`
 	GasLimit string `json:"gasLimit"`
-	GasPrice string `json:"gasPrice"`
+	GasPrice string `json:"gasPrice,omitempty"`
+	// MaxFeePerGas 与 MaxPriorityFeePerGas 仅在 EIP-1559 网络上设置，此时 GasPrice 为空
+	MaxFeePerGas         string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	// Nonce 仅替换交易设置，必须与被替换的交易相同；为空时由钱包使用 pending nonce
+	Nonce string `json:"nonce,omitempty"`
 }
 
 // SwapRequest 兑换请求
@@ -120,6 +187,7 @@ type StakeRequest struct {
 	Amount      string
 	Action      string // "stake", "unstake", "claimRewards"
 	UserAddress string
+	Pool        string // 质押池名称或别名，为空时使用默认池
 }
 
 // NewContractManager 创建合约管理器
@@ -129,6 +197,7 @@ func NewContractManager(configPath string) (*ContractManager, error) {
 	
 	manager := &ContractManager{
 		artifacts: make(map[string]*ContractArtifact),
+		rates:     make(map[string]cachedRate),
 	}
 	
 	// 加载配置文件
@@ -202,16 +271,11 @@ func (cm *ContractManager) LoadArtifacts() error {
 func (cm *ContractManager) ParseSwapRequest(message string) (*SwapRequest, error) {
 	log.Printf("🔄 解析兑换请求: %s", message)
 	
-	// 定义匹配模式
-	patterns := []string{
-		`兑换\s*(\d+(?:\.\d+)?)\s*(\w+)(?:\s*为?\s*(\w+))?`,
-		`将\s*(\d+(?:\.\d+)?)\s*(\w+)\s*换成\s*(\w+)`,
-		`swap\s+(\d+(?:\.\d+)?)\s+(\w+)\s+to\s+(\w+)`,
-		`exchange\s+(\d+(?:\.\d+)?)\s+(\w+)\s+for\s+(\w+)`,
+	if len(message) > MaxParseInputLength {
+		return nil, fmt.Errorf("message too long: %d bytes (max %d)", len(message), MaxParseInputLength)
 	}
 	
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
+	for _, re := range swapPatterns {
 		matches := re.FindStringSubmatch(message)
 		
 		if len(matches) >= 3 {
@@ -234,6 +298,10 @@ func (cm *ContractManager) ParseSwapRequest(message string) (*SwapRequest, error
 				continue
 			}
 			
+			if err := cm.ValidateAmount(fromToken, amount); err != nil {
+				return nil, err
+			}
+			
 			log.Printf("✅ 解析成功: %s %s -> %s", amount, fromToken, toToken)
 			
 			return &SwapRequest{
@@ -247,71 +315,80 @@ func (cm *ContractManager) ParseSwapRequest(message string) (*SwapRequest, error
 	return nil, fmt.Errorf("unable to parse swap request from message")
 }
 
-// BuildSwapTransaction 构建兑换交易
+// BuildSwapTransaction 构建直接交换对的兑换交易，需要经过中间代币时使用 BuildSwapRoute
 func (cm *ContractManager) BuildSwapTransaction(req *SwapRequest) (*TransactionData, error) {
 	log.Printf("🔄 构建兑换交易")
 	log.Printf("📋 从 %s 兑换 %s 到 %s", req.FromToken, req.Amount, req.ToToken)
 	
-	// 查找支持的交换对
-	swapContract := cm.config.Contracts["SimpleSwap"]
-	if swapContract.Address == "" {
-		return nil, fmt.Errorf("SimpleSwap contract not found")
-	}
-	
-	var swapPair *SwapPair
-	for _, pair := range swapContract.SupportedPairs {
-		if pair.From == req.FromToken && pair.To == req.ToToken {
-			swapPair = &pair
-			break
-		}
+	hops, err := cm.BuildSwapRoute(req, 1)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported swap pair: %s -> %s: %w", req.FromToken, req.ToToken, err)
 	}
-	
-	if swapPair == nil {
-		return nil, fmt.Errorf("unsupported swap pair: %s -> %s", req.FromToken, req.ToToken)
+	return hops[0].Transaction, nil
+}
+
+// buildPairTransaction 按交换对的方法构建单跳兑换交易。兑换函数声明了 minAmountOut 参数时，
+// 以扣除滑点后的最少输出填充
+func (cm *ContractManager) buildPairTransaction(swapContract ContractInfo, swapPair SwapPair, amountStr, expectedOutput, minOutput string) (*TransactionData, error) {
+	if swapContract.Address == "" {
+		return nil, fmt.Errorf("%s contract address not configured", swapContract.Name)
 	}
 	
 	log.Printf("✅ 找到交换对: %s", swapPair.Description)
 	
-	// 解析金额
-	amount, err := strconv.ParseFloat(req.Amount, 64)
+	// 校验金额
+	if _, err := cm.parseAmount(swapPair.From, amountStr); err != nil {
+		return nil, err
+	}
+	minAmountOut, err := cm.toBaseUnits(swapPair.To, minOutput)
 	if err != nil {
-		return nil, fmt.Errorf("invalid amount: %s", req.Amount)
+		return nil, err
 	}
 	
 	// 构建交易数据
 	txData := &TransactionData{
 		To:       swapContract.Address,
 		GasLimit: "0x186A0",  // 100000 gas
-		GasPrice: "0x3B9ACA00", // 1 gwei
 	}
+	cm.applyGasFees(txData)
 	
-	if swapPair.Method == "buyToken" {
-		// MEER -> MTK：需要发送 ETH
-		weiAmount := new(big.Int)
-		weiAmount, _ = weiAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
+	switch swapPair.Method {
+	case "buyToken":
+		// 原生代币 -> 代币：需要发送原生代币
+		weiAmount, err := cm.toBaseUnits(swapPair.From, amountStr)
+		if err != nil {
+			return nil, err
+		}
+		data, err := encodeContractCall(swapContract, swapPair.Method, map[string]any{"amount": weiAmount, "minAmountOut": minAmountOut})
+		if err != nil {
+			return nil, err
+		}
 		txData.Value = "0x" + weiAmount.Text(16)
-		txData.Data = "0xa4821719" // buyToken() 函数签名 (ethers.js计算)
-		
-		log.Printf("📋 MEER -> MTK 交易")
-		log.Printf("📋 发送金额: %s MEER", req.Amount)
-		log.Printf("📋 预期获得: %.0f MTK", amount*swapPair.Rate)
+		txData.Data = data
 		
-	} else if swapPair.Method == "sellToken" {
-		// MTK -> MEER：调用 sellToken 函数
-		tokenAmount := new(big.Int)
-		tokenAmount, _ = tokenAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
+		log.Printf("📋 %s -> %s 交易", swapPair.From, swapPair.To)
+		log.Printf("📋 发送金额: %s %s", amountStr, swapPair.From)
+		log.Printf("📋 预期获得: %s %s (最少 %s)", expectedOutput, swapPair.To, minOutput)
 		
-		// sellToken(uint256) 函数调用数据
-		// 函数签名: 0x2397e4d7 (ethers.js计算)
-		// 参数: 代币数量 (uint256)
-		funcSig := "2397e4d7"
-		amountHex := fmt.Sprintf("%064s", tokenAmount.Text(16))
-		txData.Data = "0x" + funcSig + amountHex
+	case "sellToken":
+		// 代币 -> 原生代币：调用 sellToken 函数
+		tokenAmount, err := cm.toBaseUnits(swapPair.From, amountStr)
+		if err != nil {
+			return nil, err
+		}
+		data, err := encodeContractCall(swapContract, swapPair.Method, map[string]any{"amount": tokenAmount, "minAmountOut": minAmountOut})
+		if err != nil {
+			return nil, err
+		}
+		txData.Data = data
 		txData.Value = "0x0"
 		
-		log.Printf("📋 MTK -> MEER 交易")
-		log.Printf("📋 卖出金额: %s MTK", req.Amount)
-		log.Printf("📋 预期获得: %.6f MEER", amount*swapPair.Rate)
+		log.Printf("📋 %s -> %s 交易", swapPair.From, swapPair.To)
+		log.Printf("📋 卖出金额: %s %s", amountStr, swapPair.From)
+		log.Printf("📋 预期获得: %s %s (最少 %s)", expectedOutput, swapPair.To, minOutput)
+		
+	default:
+		return nil, fmt.Errorf("unsupported swap method %q for %s -> %s", swapPair.Method, swapPair.From, swapPair.To)
 	}
 	
 	log.Printf("✅ 交易数据构建完成")
@@ -322,44 +399,15 @@ func (cm *ContractManager) BuildSwapTransaction(req *SwapRequest) (*TransactionD
 func (cm *ContractManager) ParseStakeRequest(message string) (*StakeRequest, error) {
 	log.Printf("🔄 解析质押请求: %s", message)
 	
-	// 定义匹配模式
-	patterns := []map[string]string{
-		{
-			"pattern": `质押\s*(\d+(?:\.\d+)?)\s*(\w+)`,
-			"action":  "stake",
-		},
-		{
-			"pattern": `将\s*(\d+(?:\.\d+)?)\s*(\w+)\s*质押`,
-			"action":  "stake",
-		},
-		{
-			"pattern": `stake\s+(\d+(?:\.\d+)?)\s+(\w+)`,
-			"action":  "stake",
-		},
-		{
-			"pattern": `取消质押\s*(\d+(?:\.\d+)?)\s*(\w+)`,
-			"action":  "unstake",
-		},
-		{
-			"pattern": `解质押\s*(\d+(?:\.\d+)?)\s*(\w+)`,
-			"action":  "unstake",
-		},
-		{
-			"pattern": `unstake\s+(\d+(?:\.\d+)?)\s+(\w+)`,
-			"action":  "unstake",
-		},
-		{
-			"pattern": `领取奖励|领取收益|claim\s+rewards|提取奖励|收取奖励`,
-			"action":  "claimRewards",
-		},
-	}
-	
-	for _, patternInfo := range patterns {
-		re := regexp.MustCompile(patternInfo["pattern"])
-		matches := re.FindStringSubmatch(message)
+	if len(message) > MaxParseInputLength {
+		return nil, fmt.Errorf("message too long: %d bytes (max %d)", len(message), MaxParseInputLength)
+	}
+	
+	for _, pattern := range stakePatterns {
+		matches := pattern.re.FindStringSubmatch(message)
 		
 		if len(matches) > 0 {
-			action := patternInfo["action"]
+			action := pattern.action
 			
 			if action == "claimRewards" {
 				// 领取奖励不需要金额
@@ -368,17 +416,23 @@ func (cm *ContractManager) ParseStakeRequest(message string) (*StakeRequest, err
 					Token:  "MTK",
 					Amount: "0",
 					Action: action,
+					Pool:   cm.mentionedPool(message),
 				}, nil
 			} else if len(matches) >= 3 {
 				amount := matches[1]
 				token := strings.ToUpper(matches[2])
 				
+				if err := cm.ValidateAmount(token, amount); err != nil {
+					return nil, err
+				}
+				
 				log.Printf("✅ 解析成功: %s %s %s", action, amount, token)
 				
 				return &StakeRequest{
 					Token:  token,
 					Amount: amount,
 					Action: action,
+					Pool:   cm.mentionedPool(message),
 				}, nil
 			}
 		}
@@ -392,10 +446,10 @@ func (cm *ContractManager) BuildStakeTransaction(req *StakeRequest) (*Transactio
 	log.Printf("🔄 构建质押交易")
 	log.Printf("📋 操作: %s, 代币: %s, 数量: %s", req.Action, req.Token, req.Amount)
 	
-	// 查找质押合约
-	stakingContract := cm.config.Contracts["MTKStaking"]
-	if stakingContract.Address == "" {
-		return nil, fmt.Errorf("MTKStaking contract not found")
+	// 查找所选质押池的合约
+	contractName, stakingContract, err := cm.stakingContract(req.Pool)
+	if err != nil {
+		return nil, err
 	}
 	
 	// 构建交易数据
@@ -403,60 +457,41 @@ func (cm *ContractManager) BuildStakeTransaction(req *StakeRequest) (*Transactio
 		To:       stakingContract.Address,
 		Value:    "0x0", // 质押不需要发送原生代币
 		GasLimit: "0x30D40",  // 200000 gas (足够的余量)
-		GasPrice: "0x3B9ACA00", // 1 gwei
 	}
+	cm.applyGasFees(txData)
 	
+	// 函数签名和参数由 contracts.json 中质押合约的 functions 配置决定
+	args := map[string]any{}
 	switch req.Action {
-	case "stake":
-		// 质押操作
-		amount, err := strconv.ParseFloat(req.Amount, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid amount: %s", req.Amount)
+	case "stake", "unstake":
+		if _, err := cm.parseAmount(req.Token, req.Amount); err != nil {
+			return nil, err
 		}
-		
-		// 将金额转换为 wei
-		weiAmount := new(big.Int)
-		weiAmount, _ = weiAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
-		
-		// stake(uint256) 函数调用数据
-		// 函数签名: 0xa694fc3a
-		funcSig := "a694fc3a"
-		amountHex := fmt.Sprintf("%064s", weiAmount.Text(16))
-		txData.Data = "0x" + funcSig + amountHex
-		
-		log.Printf("📋 质押交易: %s %s", req.Amount, req.Token)
-		
-	case "unstake":
-		// 取消质押操作
-		amount, err := strconv.ParseFloat(req.Amount, 64)
+		weiAmount, err := cm.toBaseUnits(req.Token, req.Amount)
 		if err != nil {
-			return nil, fmt.Errorf("invalid amount: %s", req.Amount)
+			return nil, err
 		}
+		args["amount"] = weiAmount
 		
-		// 将金额转换为 wei
-		weiAmount := new(big.Int)
-		weiAmount, _ = weiAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
-		
-		// unstake(uint256) 函数调用数据
-		// 函数签名: 0x2e17de78
-		funcSig := "2e17de78"
-		amountHex := fmt.Sprintf("%064s", weiAmount.Text(16))
-		txData.Data = "0x" + funcSig + amountHex
-		
-		log.Printf("📋 取消质押交易: %s %s", req.Amount, req.Token)
+		if req.Action == "stake" {
+			log.Printf("📋 质押交易: %s %s", req.Amount, req.Token)
+		} else {
+			log.Printf("📋 取消质押交易: %s %s", req.Amount, req.Token)
+		}
 		
 	case "claimRewards":
-		// 领取奖励操作
-		// claimRewards() 函数调用数据
-		// 函数签名: 0xef5cfb8c
-		txData.Data = "0xef5cfb8c"
-		
 		log.Printf("📋 领取奖励交易")
 		
 	default:
 		return nil, fmt.Errorf("unsupported stake action: %s", req.Action)
 	}
 	
+	data, err := cm.EncodeFunctionCall(contractName, req.Action, args)
+	if err != nil {
+		return nil, err
+	}
+	txData.Data = data
+	
 	log.Printf("✅ 质押交易数据构建完成")
 	return txData, nil
 }
@@ -472,43 +507,38 @@ func (cm *ContractManager) BuildApproveTransaction(req *StakeRequest) (*Transact
 		return nil, fmt.Errorf("MTK token contract address not found")
 	}
 	
-	// 获取质押合约地址
-	stakingContract := cm.config.Contracts["MTKStaking"]
-	if stakingContract.Address == "" {
-		return nil, fmt.Errorf("MTKStaking contract address not found")
+	// 获取所选质押池的合约地址
+	_, stakingContract, err := cm.stakingContract(req.Pool)
+	if err != nil {
+		return nil, err
 	}
 	
 	// 解析授权金额
-	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if _, err := cm.parseAmount(req.Token, req.Amount); err != nil {
+		return nil, err
+	}
+	weiAmount, err := cm.toBaseUnits(req.Token, req.Amount)
 	if err != nil {
-		return nil, fmt.Errorf("invalid amount: %s", req.Amount)
+		return nil, err
 	}
 	
-	// 将金额转换为 wei
-	weiAmount := new(big.Int)
-	weiAmount, _ = weiAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
-	
 	// 构建交易数据
 	txData := &TransactionData{
 		To:       mtkToken.ContractAddress, // 发送给MTK代币合约
 		Value:    "0x0",
 		GasLimit: "0x1FBBF",  // 130000 gas (approve通常需要较少gas)
-		GasPrice: "0x3B9ACA00", // 1 gwei
 	}
+	cm.applyGasFees(txData)
 	
-	// approve(address spender, uint256 amount) 函数调用数据
-	// 函数签名: 0x095ea7b3
-	funcSig := "095ea7b3"
-	
-	// 第一个参数：spender地址（质押合约地址，去掉0x并填充到64位）
-	spenderAddr := strings.TrimPrefix(stakingContract.Address, "0x")
-	spenderAddrPadded := fmt.Sprintf("%064s", spenderAddr)
-	
-	// 第二个参数：授权金额（填充到64位十六进制）
-	amountHex := fmt.Sprintf("%064s", weiAmount.Text(16))
-	
-	// 组合完整的调用数据
-	txData.Data = "0x" + funcSig + spenderAddrPadded + amountHex
+	// approve(address spender, uint256 amount)：授权质押合约使用代币
+	data, err := cm.encodeTokenCall(mtkToken.ContractAddress, "approve", map[string]any{
+		"spender": stakingContract.Address,
+		"amount":  weiAmount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	txData.Data = data
 	
 	log.Printf("📋 授权交易: %s %s 给质押合约 %s", req.Amount, req.Token, stakingContract.Address)
 	log.Printf("📋 交易数据: %s", txData.Data)
@@ -517,6 +547,11 @@ func (cm *ContractManager) BuildApproveTransaction(req *StakeRequest) (*Transact
 	return txData, nil
 }
 
+// ChainID 返回配置的链ID
+func (cm *ContractManager) ChainID() int64 {
+	return int64(cm.config.Network.ChainID)
+}
+
 // GetContractInfo 获取合约信息
 func (cm *ContractManager) GetContractInfo(name string) *ContractInfo {
 	if contract, exists := cm.config.Contracts[name]; exists {
@@ -542,6 +577,22 @@ func (cm *ContractManager) GetSupportedTokens() []string {
 	return tokens
 }
 
+// GetTokens 获取完整的代币注册表，按符号排序
+func (cm *ContractManager) GetTokens() []TokenConfig {
+	symbols := cm.GetSupportedTokens()
+	sort.Strings(symbols)
+
+	tokens := make([]TokenConfig, 0, len(symbols))
+	for _, symbol := range symbols {
+		token := cm.config.Tokens[symbol]
+		if token.Symbol == "" {
+			token.Symbol = symbol
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
 // GetSupportedPairs 获取支持的交换对
 func (cm *ContractManager) GetSupportedPairs() []string {
 	pairs := make([]string, 0)
@@ -576,7 +627,7 @@ func (cm *ContractManager) GetWorkflowDescription() string {
 	for _, contract := range cm.config.Contracts {
 		for _, pair := range contract.SupportedPairs {
 			description += fmt.Sprintf("- %s -> %s (汇率: %.0f, 方法: %s)\n", 
-				pair.From, pair.To, pair.Rate, pair.Method)
+				pair.From, pair.To, cm.GetPairRate(pair), pair.Method)
 		}
 	}
 
@@ -0,0 +1,104 @@
+package contracts
+
+import (
+	"io"
+	"log"
+	"regexp"
+	"testing"
+)
+
+// parseMessages 基准测试使用的典型用户消息，覆盖各个匹配模式
+var parseMessages = []string{
+	"兑换1 MEER为MTK",
+	"将100 MTK换成MEER",
+	"swap 2.5 meer to mtk",
+	"质押100 MTK",
+	"unstake 50 mtk",
+	"领取奖励",
+}
+
+func TestParseRequests(t *testing.T) {
+	cm := newTestManager(t)
+
+	swaps := []struct {
+		message, amount, from, to string
+	}{
+		{message: "兑换1 MEER为MTK", amount: "1", from: "MEER", to: "MTK"},
+		{message: "兑换1.5 MEER", amount: "1.5", from: "MEER", to: "MTK"},
+		{message: "将100 MTK换成MEER", amount: "100", from: "MTK", to: "MEER"},
+		{message: "swap 2.5 meer to mtk", amount: "2.5", from: "MEER", to: "MTK"},
+	}
+	for _, tt := range swaps {
+		req, err := cm.ParseSwapRequest(tt.message)
+		if err != nil {
+			t.Errorf("ParseSwapRequest(%q): %v", tt.message, err)
+			continue
+		}
+		if req.Amount != tt.amount || req.FromToken != tt.from || req.ToToken != tt.to {
+			t.Errorf("ParseSwapRequest(%q) = %s %s -> %s, want %s %s -> %s", tt.message,
+				req.Amount, req.FromToken, req.ToToken, tt.amount, tt.from, tt.to)
+		}
+	}
+
+	stakes := []struct {
+		message, action, amount string
+	}{
+		{message: "质押100 MTK", action: "stake", amount: "100"},
+		{message: "将20 MTK质押", action: "stake", amount: "20"},
+		{message: "unstake 50 mtk", action: "unstake", amount: "50"},
+		{message: "取消质押30 MTK", action: "unstake", amount: "30"},
+		{message: "解质押10 MTK", action: "unstake", amount: "10"},
+		{message: "领取奖励", action: "claimRewards", amount: "0"},
+	}
+	for _, tt := range stakes {
+		req, err := cm.ParseStakeRequest(tt.message)
+		if err != nil {
+			t.Errorf("ParseStakeRequest(%q): %v", tt.message, err)
+			continue
+		}
+		if req.Action != tt.action || req.Amount != tt.amount {
+			t.Errorf("ParseStakeRequest(%q) = %s %s, want %s %s", tt.message, req.Action, req.Amount, tt.action, tt.amount)
+		}
+	}
+}
+
+// benchmarkParse 关闭日志输出后对每条消息依次调用 parse
+func benchmarkParse(b *testing.B, parse func(message string)) {
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(output)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, message := range parseMessages {
+			parse(message)
+		}
+	}
+}
+
+// BenchmarkParseRequests 使用包级预编译的模式解析兑换与质押请求
+func BenchmarkParseRequests(b *testing.B) {
+	cm, err := NewContractManager("../../config/contracts.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkParse(b, func(message string) {
+		cm.ParseSwapRequest(message)
+		cm.ParseStakeRequest(message)
+	})
+}
+
+// BenchmarkParseRequestsCompilePerCall 每次请求重新编译模式后匹配，对应预编译之前的实现，作为对照
+func BenchmarkParseRequestsCompilePerCall(b *testing.B) {
+	sources := make([]string, 0, len(swapPatterns)+len(stakePatterns))
+	for _, re := range swapPatterns {
+		sources = append(sources, re.String())
+	}
+	for _, pattern := range stakePatterns {
+		sources = append(sources, pattern.re.String())
+	}
+	benchmarkParse(b, func(message string) {
+		for _, source := range sources {
+			regexp.MustCompile(source).FindStringSubmatch(message)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownPool 质押池未在 stake 工作流中配置
+var ErrUnknownPool = errors.New("unknown staking pool")
+
+// defaultStakingContract 未配置质押池时使用的质押合约
+const defaultStakingContract = "MTKStaking"
+
+// StakingPool 质押池配置，每个池对应一个质押合约
+type StakingPool struct {
+	Name string `json:"name"`
+	// Aliases 自然语言中指代该池的名称，如 "30天锁定池"
+	Aliases     []string `json:"aliases,omitempty"`
+	Contract    string   `json:"contract"`
+	Description string   `json:"description,omitempty"`
+	LockDays    int      `json:"lockDays,omitempty"`
+	APY         string   `json:"apy,omitempty"`
+}
+
+// StakingPools 返回 stake 工作流配置的质押池；未配置时返回由工作流合约构成的单个默认池
+func (cm *ContractManager) StakingPools() []StakingPool {
+	workflow := cm.config.Workflows["stake"]
+	if len(workflow.Pools) > 0 {
+		return workflow.Pools
+	}
+
+	contract := workflow.Contract
+	if contract == "" {
+		contract = defaultStakingContract
+	}
+	return []StakingPool{{Name: "default", Contract: contract}}
+}
+
+// DefaultPool 返回默认质押池：defaultPool 指定的池，未指定时为第一个池
+func (cm *ContractManager) DefaultPool() StakingPool {
+	pools := cm.StakingPools()
+	if name := cm.config.Workflows["stake"].DefaultPool; name != "" {
+		for _, pool := range pools {
+			if strings.EqualFold(pool.Name, name) {
+				return pool
+			}
+		}
+	}
+	return pools[0]
+}
+
+// ResolvePool 按名称或别名查找质押池，名称为空时返回默认池
+func (cm *ContractManager) ResolvePool(name string) (StakingPool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return cm.DefaultPool(), nil
+	}
+
+	for _, pool := range cm.StakingPools() {
+		if strings.EqualFold(pool.Name, name) {
+			return pool, nil
+		}
+		for _, alias := range pool.Aliases {
+			if strings.EqualFold(alias, name) {
+				return pool, nil
+			}
+		}
+	}
+	return StakingPool{}, fmt.Errorf("%w: %q", ErrUnknownPool, name)
+}
+
+// MatchPool 在消息中查找提到的质押池（名称或别名，取最长匹配），没有提到时返回 false
+func (cm *ContractManager) MatchPool(message string) (StakingPool, bool) {
+	lower := strings.ToLower(message)
+
+	var matched StakingPool
+	longest := 0
+	for _, pool := range cm.StakingPools() {
+		for _, name := range append([]string{pool.Name}, pool.Aliases...) {
+			if len(name) > longest && strings.Contains(lower, strings.ToLower(name)) {
+				matched, longest = pool, len(name)
+			}
+		}
+	}
+	return matched, longest > 0
+}
+
+// mentionedPool 返回消息中提到的质押池名称，没有提到时为空（使用默认池）
+func (cm *ContractManager) mentionedPool(message string) string {
+	if pool, ok := cm.MatchPool(message); ok {
+		return pool.Name
+	}
+	return ""
+}
+
+// stakingContract 返回质押请求所选池对应的合约名称和配置
+func (cm *ContractManager) stakingContract(poolName string) (string, ContractInfo, error) {
+	pool, err := cm.ResolvePool(poolName)
+	if err != nil {
+		return "", ContractInfo{}, err
+	}
+
+	contract := cm.config.Contracts[pool.Contract]
+	if contract.Address == "" {
+		return "", ContractInfo{}, fmt.Errorf("%s contract address not found for pool %s", pool.Contract, pool.Name)
+	}
+	return pool.Contract, contract, nil
+}
@@ -0,0 +1,285 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// getReservesSelector getReserves() 函数签名
+const getReservesSelector = "0x0902f1ac"
+
+// ContractCaller 执行只读合约调用（eth_call）
+type ContractCaller interface {
+	Call(ctx context.Context, to, data string) (string, error)
+}
+
+// RateRefreshConfig 链上汇率刷新配置
+type RateRefreshConfig struct {
+	Enabled  bool `json:"enabled"`
+	Interval int  `json:"interval"` // 刷新间隔（秒）
+	TTL      int  `json:"ttl"`      // 刷新结果有效期（秒）
+	QuoteTTL int  `json:"quoteTTL"` // 构建兑换交易时复用报价的时间（秒）
+}
+
+// cachedRate 从链上储备或报价函数得到的汇率缓存
+type cachedRate struct {
+	rate      float64
+	updatedAt time.Time
+	// quotedAt 最近一次按需报价的时间（无论成功与否），在 quoteTTL 内不再重复调用
+	quotedAt time.Time
+}
+
+// rateScale getRate() 返回值的定点精度，1e18 表示 1 个源代币兑换 1 个目标代币
+var rateScale = new(big.Float).SetFloat64(1e18)
+
+// GetPairRate 获取交换对汇率，优先使用未过期的链上刷新汇率，否则使用配置中的静态汇率
+func (cm *ContractManager) GetPairRate(pair SwapPair) float64 {
+	cm.ratesMu.RLock()
+	cached, exists := cm.rates[pairKey(pair.From, pair.To)]
+	cm.ratesMu.RUnlock()
+
+	if exists && time.Since(cached.updatedAt) <= cm.rateTTL() {
+		return cached.rate
+	}
+	return pair.Rate
+}
+
+// StartRateRefresher 启动后台汇率刷新，直到 ctx 被取消
+func (cm *ContractManager) StartRateRefresher(ctx context.Context, caller ContractCaller) {
+	refresh := cm.config.RateRefresh
+	if !refresh.Enabled || caller == nil {
+		return
+	}
+
+	interval := time.Duration(refresh.Interval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	log.Printf("🔄 启动链上汇率刷新，间隔: %v", interval)
+
+	go func() {
+		cm.RefreshRates(ctx, caller)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("🛑 链上汇率刷新已停止")
+				return
+			case <-ticker.C:
+				cm.RefreshRates(ctx, caller)
+			}
+		}
+	}()
+}
+
+// RefreshRates 读取所有配置了报价函数或池地址的交换对的链上汇率并更新汇率缓存
+func (cm *ContractManager) RefreshRates(ctx context.Context, caller ContractCaller) {
+	for _, contract := range cm.config.Contracts {
+		for _, pair := range contract.SupportedPairs {
+			if pair.QuoteMethod == "" && pair.PoolAddress == "" {
+				continue
+			}
+
+			rate, err := cm.fetchRate(ctx, caller, contract, pair)
+			if err != nil {
+				log.Printf("⚠️  刷新汇率失败 %s -> %s: %v", pair.From, pair.To, err)
+				continue
+			}
+
+			cm.ratesMu.Lock()
+			cm.rates[pairKey(pair.From, pair.To)] = cachedRate{
+				rate:      rate,
+				updatedAt: time.Now(),
+			}
+			cm.ratesMu.Unlock()
+
+			log.Printf("✅ 汇率已刷新 %s -> %s: %.6f (配置值: %.6f)", pair.From, pair.To, rate, pair.Rate)
+		}
+	}
+}
+
+// RefreshRouteRates 构建兑换交易前读取路由上配置了 quoteMethod 的交换对的链上报价并更新汇率缓存。
+// 报价在 quoteTTL 内复用（失败也不立即重试），避免频繁调用RPC；读取失败时 GetPairRate 使用配置汇率
+func (cm *ContractManager) RefreshRouteRates(ctx context.Context, caller ContractCaller, from, to string) {
+	route, err := cm.findRoute(from, to, MaxSwapHops)
+	if err != nil {
+		return
+	}
+
+	for _, edge := range route {
+		if edge.pair.QuoteMethod == "" {
+			continue
+		}
+		key := pairKey(edge.pair.From, edge.pair.To)
+
+		cm.ratesMu.RLock()
+		cached := cm.rates[key]
+		cm.ratesMu.RUnlock()
+		if time.Since(cached.quotedAt) <= cm.quoteTTL() {
+			continue
+		}
+
+		rate, err := cm.fetchQuoteRate(ctx, caller, edge.contract, edge.pair)
+		cm.ratesMu.Lock()
+		cached = cm.rates[key]
+		cached.quotedAt = time.Now()
+		if err == nil {
+			cached.rate = rate
+			cached.updatedAt = cached.quotedAt
+		}
+		cm.rates[key] = cached
+		cm.ratesMu.Unlock()
+
+		if err != nil {
+			log.Printf("⚠️  读取链上报价失败 %s -> %s，使用配置汇率 %.6f: %v", edge.pair.From, edge.pair.To, edge.pair.Rate, err)
+			continue
+		}
+		log.Printf("💱 链上报价 %s -> %s: %.6f (配置值: %.6f)", edge.pair.From, edge.pair.To, rate, edge.pair.Rate)
+	}
+}
+
+// fetchRate 读取交换对的链上汇率，配置了报价函数时优先使用报价，否则读取池储备
+func (cm *ContractManager) fetchRate(ctx context.Context, caller ContractCaller, contract ContractInfo, pair SwapPair) (float64, error) {
+	if pair.QuoteMethod != "" {
+		return cm.fetchQuoteRate(ctx, caller, contract, pair)
+	}
+	return cm.fetchReserveRate(ctx, caller, pair)
+}
+
+// fetchQuoteRate 调用兑换合约的报价函数计算汇率。带一个参数的函数（如 getAmountOut(uint256)）
+// 按 1 个源代币报价，返回目标代币的最小单位数量；不带参数的函数（如 getRate()）返回 1e18 定点的汇率
+func (cm *ContractManager) fetchQuoteRate(ctx context.Context, caller ContractCaller, contract ContractInfo, pair SwapPair) (float64, error) {
+	if contract.Address == "" {
+		return 0, fmt.Errorf("%s contract address not configured", contract.Name)
+	}
+	fn, exists := contract.Functions[pair.QuoteMethod]
+	if !exists {
+		if fn, exists = builtinFunctions[pair.QuoteMethod]; !exists {
+			return 0, fmt.Errorf("%w: %s.%s is not configured", ErrInvalidFunctionCall, contract.Name, pair.QuoteMethod)
+		}
+	}
+
+	oneUnit := cm.decimalsUnit(pair.From)
+	data, err := encodeCall(pair.QuoteMethod, fn, map[string]any{"amount": oneUnit})
+	if err != nil {
+		return 0, err
+	}
+	result, err := caller.Call(ctx, contract.Address, data)
+	if err != nil {
+		return 0, err
+	}
+
+	raw := strings.TrimPrefix(result, "0x")
+	if len(raw) < 64 {
+		return 0, fmt.Errorf("unexpected %s result: %s", pair.QuoteMethod, result)
+	}
+	value, ok := new(big.Int).SetString(raw[:64], 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s result: %s", pair.QuoteMethod, result)
+	}
+	if value.Sign() == 0 {
+		return 0, fmt.Errorf("%s returned zero", pair.QuoteMethod)
+	}
+
+	scale := rateScale
+	if len(fn.Parameters) > 0 {
+		scale = cm.decimalsScale(pair.To)
+	}
+	rate, _ := new(big.Float).Quo(new(big.Float).SetInt(value), scale).Float64()
+	return rate, nil
+}
+
+// fetchReserveRate 通过 getReserves() 读取池储备并计算现货价格
+func (cm *ContractManager) fetchReserveRate(ctx context.Context, caller ContractCaller, pair SwapPair) (float64, error) {
+	result, err := caller.Call(ctx, pair.PoolAddress, getReservesSelector)
+	if err != nil {
+		return 0, err
+	}
+
+	// 返回值: (uint112 reserve0, uint112 reserve1, uint32 blockTimestampLast)
+	data := strings.TrimPrefix(result, "0x")
+	if len(data) < 128 {
+		return 0, fmt.Errorf("unexpected getReserves result: %s", result)
+	}
+
+	reserve0, ok0 := new(big.Int).SetString(data[0:64], 16)
+	reserve1, ok1 := new(big.Int).SetString(data[64:128], 16)
+	if !ok0 || !ok1 {
+		return 0, fmt.Errorf("invalid reserves: %s", result)
+	}
+
+	fromReserve, toReserve := reserve0, reserve1
+	if pair.FromReserveIndex == 1 {
+		fromReserve, toReserve = reserve1, reserve0
+	}
+
+	if fromReserve.Sign() == 0 {
+		return 0, fmt.Errorf("empty %s reserve", pair.From)
+	}
+
+	from := new(big.Float).Quo(new(big.Float).SetInt(fromReserve), cm.decimalsScale(pair.From))
+	to := new(big.Float).Quo(new(big.Float).SetInt(toReserve), cm.decimalsScale(pair.To))
+
+	rate, _ := new(big.Float).Quo(to, from).Float64()
+	return rate, nil
+}
+
+// decimalsScale 返回代币精度对应的 10^decimals
+func (cm *ContractManager) decimalsScale(symbol string) *big.Float {
+	return new(big.Float).SetInt(cm.decimalsUnit(symbol))
+}
+
+// quoteTTL 返回按需报价的复用时间
+func (cm *ContractManager) quoteTTL() time.Duration {
+	ttl := time.Duration(cm.config.RateRefresh.QuoteTTL) * time.Second
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return ttl
+}
+
+// rateTTL 返回刷新汇率的有效期
+func (cm *ContractManager) rateTTL() time.Duration {
+	ttl := time.Duration(cm.config.RateRefresh.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return ttl
+}
+
+func pairKey(from, to string) string {
+	return from + "-" + to
+}
+
+// NativeValue 按交换对汇率把代币数量换算为原生代币价值，找不到对应交换对时返回错误
+func (cm *ContractManager) NativeValue(symbol string, amount float64) (float64, error) {
+	native := cm.NativeSymbol()
+	if strings.EqualFold(symbol, native) {
+		return amount, nil
+	}
+
+	for _, contract := range cm.config.Contracts {
+		for _, pair := range contract.SupportedPairs {
+			rate := cm.GetPairRate(pair)
+			if rate <= 0 {
+				continue
+			}
+			switch {
+			case strings.EqualFold(pair.From, native) && strings.EqualFold(pair.To, symbol):
+				return amount / rate, nil
+			case strings.EqualFold(pair.From, symbol) && strings.EqualFold(pair.To, native):
+				return amount * rate, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("no %s price available for %s", native, symbol)
+}
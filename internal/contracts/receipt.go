@@ -0,0 +1,56 @@
+package contracts
+
+import (
+	"math/big"
+	"strings"
+)
+
+// transferEventTopic ERC20 Transfer(address,address,uint256) 事件的 topic0
+const transferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// EventLog 交易收据中的事件日志
+type EventLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// ReceivedAmount 汇总收据日志中代币转入 recipient 的 Transfer 事件，返回十进制数量（保留代币全部精度）。
+// 原生代币没有 Transfer 事件，与没有匹配事件时一样返回 false
+func (cm *ContractManager) ReceivedAmount(symbol, recipient string, logs []EventLog) (string, bool) {
+	token, exists := cm.config.Tokens[symbol]
+	if !exists || token.IsNative || token.ContractAddress == "" || recipient == "" {
+		return "", false
+	}
+
+	total := new(big.Int)
+	found := false
+	for _, entry := range logs {
+		if !strings.EqualFold(entry.Address, token.ContractAddress) || len(entry.Topics) != 3 ||
+			!strings.EqualFold(entry.Topics[0], transferEventTopic) || !topicIsAddress(entry.Topics[2], recipient) {
+			continue
+		}
+		value, ok := new(big.Int).SetString(strings.TrimPrefix(entry.Data, "0x"), 16)
+		if !ok {
+			continue
+		}
+		total.Add(total, value)
+		found = true
+	}
+	if !found {
+		return "", false
+	}
+
+	formatted := new(big.Rat).SetFrac(total, cm.decimalsUnit(symbol)).FloatString(token.Decimals)
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted, true
+}
+
+// topicIsAddress 检查 32 字节的 indexed 地址参数是否为指定地址
+func topicIsAddress(topic, address string) bool {
+	topic = strings.TrimPrefix(strings.ToLower(topic), "0x")
+	address = strings.TrimPrefix(strings.ToLower(address), "0x")
+	return len(topic) == 64 && len(address) == 40 && topic[24:] == address
+}
@@ -0,0 +1,86 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+// 替换交易的类型
+const (
+	// ReplaceCancel 向自己发送 0 值交易，占用原交易的 nonce 使其失效
+	ReplaceCancel = "cancel"
+	// ReplaceSpeedUp 以更高的费用重新发送相同的交易
+	ReplaceSpeedUp = "speed_up"
+)
+
+// ReplacementFeeBumpPercent 替换交易相对原交易的最小费用涨幅，节点通常要求至少 10%
+const ReplacementFeeBumpPercent = 20
+
+// ErrInvalidReplacement 无法为原交易构建替换交易
+var ErrInvalidReplacement = errors.New("invalid replacement transaction")
+
+// BuildReplacementTransaction 为待确认的交易构建使用相同 nonce 的替换交易。
+// 取消交易向 from 发送 0 值交易，加速交易保持原交易的目标、金额与数据；
+// 两者的费用都在原交易基础上至少上涨 ReplacementFeeBumpPercent，且不低于当前配置的费用
+func (cm *ContractManager) BuildReplacementTransaction(kind, from string, original *TransactionData) (*TransactionData, error) {
+	log.Printf("🔄 构建替换交易: %s (nonce: %s)", kind, original.Nonce)
+
+	if _, ok := parseHexBig(original.Nonce); !ok {
+		return nil, fmt.Errorf("%w: original nonce %q", ErrInvalidReplacement, original.Nonce)
+	}
+
+	var txData *TransactionData
+	switch kind {
+	case ReplaceCancel:
+		if !addressPattern.MatchString(from) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, from)
+		}
+		txData = &TransactionData{
+			To:       from,
+			Value:    "0x0",
+			Data:     "0x",
+			GasLimit: "0x5208", // 21000 gas
+		}
+	case ReplaceSpeedUp:
+		txData = &TransactionData{
+			To:       original.To,
+			Value:    original.Value,
+			Data:     original.Data,
+			GasLimit: original.GasLimit,
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown replacement kind %q", ErrInvalidReplacement, kind)
+	}
+	txData.Nonce = original.Nonce
+
+	// 当前配置的费用作为下限，原交易类型决定使用 gasPrice 还是 EIP-1559 费用
+	current := &TransactionData{}
+	cm.applyGasFees(current)
+	if original.MaxFeePerGas != "" {
+		txData.MaxFeePerGas = bumpFee(original.MaxFeePerGas, current.MaxFeePerGas, current.GasPrice)
+		txData.MaxPriorityFeePerGas = bumpFee(original.MaxPriorityFeePerGas, current.MaxPriorityFeePerGas)
+	} else {
+		txData.GasPrice = bumpFee(original.GasPrice, current.GasPrice, current.MaxFeePerGas)
+	}
+
+	log.Printf("✅ 替换交易数据构建完成")
+	return txData, nil
+}
+
+// bumpFee 返回原费用上涨 ReplacementFeeBumpPercent（向上取整）与各个下限中的最大值
+func bumpFee(original string, floors ...string) string {
+	fee := new(big.Int)
+	if value, ok := parseHexBig(original); ok {
+		fee.Mul(value, big.NewInt(100+ReplacementFeeBumpPercent))
+		fee.Add(fee, big.NewInt(99))
+		fee.Div(fee, big.NewInt(100))
+	}
+	for _, floor := range floors {
+		if value, ok := parseHexBig(floor); ok && value.Cmp(fee) > 0 {
+			fee = value
+		}
+	}
+	return "0x" + fee.Text(16)
+}
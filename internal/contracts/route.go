@@ -0,0 +1,171 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// MaxSwapHops 兑换路由允许的最大跳数，避免经过过多中间代币
+const MaxSwapHops = 3
+
+// ErrNoSwapRoute 在跳数限制内找不到兑换路径
+var ErrNoSwapRoute = errors.New("no swap route")
+
+// SwapSlippage 兑换允许的滑点，最少输出按预计输出扣除该比例计算
+const SwapSlippage = 0.005
+
+// SwapHop 兑换路由中的一跳
+type SwapHop struct {
+	FromToken string `json:"from_token"`
+	ToToken   string `json:"to_token"`
+	// Amount 本跳卖出的数量，中间跳为上一跳的最少输出
+	Amount string `json:"amount"`
+	// Output 按汇率估算的本跳输出数量，精确到目标代币的最小单位
+	Output string `json:"output"`
+	// MinOutput 扣除 SwapSlippage 后的最少输出数量，精确到目标代币的最小单位
+	MinOutput string `json:"min_output"`
+	// MinOutputEnforced 兑换函数声明了 minAmountOut 参数，最少输出已编码进交易；否则链上不保证，只是估算
+	MinOutputEnforced bool             `json:"min_output_enforced"`
+	Transaction       *TransactionData `json:"transaction"`
+}
+
+// routeEdge 兑换图中的一条边，记录提供该交换对的合约
+type routeEdge struct {
+	contract ContractInfo
+	pair     SwapPair
+}
+
+// swapGraph 根据所有合约的 SupportedPairs 构建兑换图，按合约名排序保证路由稳定
+func (cm *ContractManager) swapGraph() map[string][]routeEdge {
+	names := make([]string, 0, len(cm.config.Contracts))
+	for name := range cm.config.Contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	edges := make(map[string][]routeEdge)
+	for _, name := range names {
+		contract := cm.config.Contracts[name]
+		for _, pair := range contract.SupportedPairs {
+			edges[pair.From] = append(edges[pair.From], routeEdge{contract: contract, pair: pair})
+		}
+	}
+	return edges
+}
+
+// findRoute 广度优先查找跳数最少的兑换路径
+func (cm *ContractManager) findRoute(from, to string, maxHops int) ([]routeEdge, error) {
+	if maxHops <= 0 {
+		maxHops = MaxSwapHops
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrNoSwapRoute, from, to)
+	}
+
+	edges := cm.swapGraph()
+	paths := map[string][]routeEdge{from: nil}
+	frontier := []string{from}
+	for hops := 1; hops <= maxHops && len(frontier) > 0; hops++ {
+		var next []string
+		for _, token := range frontier {
+			for _, edge := range edges[token] {
+				if _, visited := paths[edge.pair.To]; visited {
+					continue
+				}
+				path := append(append([]routeEdge(nil), paths[token]...), edge)
+				if edge.pair.To == to {
+					return path, nil
+				}
+				paths[edge.pair.To] = path
+				next = append(next, edge.pair.To)
+			}
+		}
+		frontier = next
+	}
+
+	return nil, fmt.Errorf("%w: %s -> %s within %d hops", ErrNoSwapRoute, from, to, maxHops)
+}
+
+// FindSwapRoute 查找从 from 到 to 的兑换路径，返回每一跳使用的交换对
+func (cm *ContractManager) FindSwapRoute(from, to string, maxHops int) ([]SwapPair, error) {
+	route, err := cm.findRoute(from, to, maxHops)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]SwapPair, len(route))
+	for i, edge := range route {
+		pairs[i] = edge.pair
+	}
+	return pairs, nil
+}
+
+// BuildSwapRoute 构建兑换路由上每一跳的交易，需要按顺序签名执行。
+// 数量按代币最小单位精确计算：中间跳卖出上一跳的最少输出，不会超过上一跳实际获得的数量
+func (cm *ContractManager) BuildSwapRoute(req *SwapRequest, maxHops int) ([]SwapHop, error) {
+	route, err := cm.findRoute(req.FromToken, req.ToToken, maxHops)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := cm.toBaseUnits(req.FromToken, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	slippage := new(big.Rat).Sub(big.NewRat(1, 1), exactRat(SwapSlippage))
+
+	hops := make([]SwapHop, 0, len(route))
+	amount := req.Amount
+	for _, edge := range route {
+		rate := cm.GetPairRate(edge.pair)
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("invalid rate %g for %s -> %s", rate, edge.pair.From, edge.pair.To)
+		}
+
+		// 输出（最小单位）= 卖出数量 × 汇率 × 10^(目标精度 - 卖出精度)，向下取整
+		expected := new(big.Rat).SetInt(units)
+		expected.Mul(expected, exactRat(rate))
+		expected.Mul(expected, new(big.Rat).SetFrac(cm.decimalsUnit(edge.pair.To), cm.decimalsUnit(edge.pair.From)))
+		outputUnits := floorRat(expected)
+		minOutputUnits := floorRat(expected.Mul(expected, slippage))
+
+		output := cm.exactUnits(edge.pair.To, outputUnits)
+		minOutput := cm.exactUnits(edge.pair.To, minOutputUnits)
+		txData, err := cm.buildPairTransaction(edge.contract, edge.pair, amount, output, minOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build hop %s -> %s: %w", edge.pair.From, edge.pair.To, err)
+		}
+
+		hops = append(hops, SwapHop{
+			FromToken:   edge.pair.From,
+			ToToken:     edge.pair.To,
+			Amount:      amount,
+			Output:      output,
+			MinOutput:   minOutput,
+			Transaction: txData,
+			// 内置的 buyToken()/sellToken(uint256) 没有最少输出参数
+			MinOutputEnforced: functionTakesArg(edge.contract, edge.pair.Method, "minAmountOut"),
+		})
+		units, amount = minOutputUnits, minOutput
+	}
+
+	if len(hops) > 1 {
+		log.Printf("🔀 兑换路由: %s -> %s 共 %d 跳", req.FromToken, req.ToToken, len(hops))
+	}
+	return hops, nil
+}
+
+// exactRat 按十进制表示转换浮点数，0.005 等配置值不会带入二进制舍入误差
+func exactRat(value float64) *big.Rat {
+	rat, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	return rat
+}
+
+// floorRat 向下取整到整数
+func floorRat(value *big.Rat) *big.Int {
+	return new(big.Int).Quo(value.Num(), value.Denom())
+}
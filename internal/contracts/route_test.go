@@ -0,0 +1,126 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+)
+
+// newTestManager 加载仓库中的合约配置，并增加一个 6 位精度的 USDX 代币与 MTK -> USDX 交换对用于多跳路由
+func newTestManager(t *testing.T) *ContractManager {
+	t.Helper()
+	cm, err := NewContractManager("../../config/contracts.json")
+	if err != nil {
+		t.Fatalf("NewContractManager: %v", err)
+	}
+	cm.config.Tokens["USDX"] = TokenConfig{Name: "USDX", Symbol: "USDX", Decimals: 6, ContractAddress: "0x00000000000000000000000000000000000000aa"}
+	swap := cm.config.Contracts["SimpleSwap"]
+	swap.SupportedPairs = append(swap.SupportedPairs, SwapPair{From: "MTK", To: "USDX", Method: "sellToken", Rate: 0.3})
+	cm.config.Contracts["SimpleSwap"] = swap
+	return cm
+}
+
+func TestBuildSwapRoute(t *testing.T) {
+	cm := newTestManager(t)
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		amt  string
+		want []SwapHop
+	}{
+		{
+			name: "single hop",
+			from: "MEER", to: "MTK", amt: "1.5",
+			want: []SwapHop{{FromToken: "MEER", ToToken: "MTK", Amount: "1.5", Output: "1500", MinOutput: "1492.5"}},
+		},
+		{
+			name: "intermediate hop sells previous min output",
+			from: "MEER", to: "USDX", amt: "0.1",
+			want: []SwapHop{
+				{FromToken: "MEER", ToToken: "MTK", Amount: "0.1", Output: "100", MinOutput: "99.5"},
+				{FromToken: "MTK", ToToken: "USDX", Amount: "99.5", Output: "29.85", MinOutput: "29.70075"},
+			},
+		},
+		{
+			name: "output floored to target decimals",
+			from: "MTK", to: "USDX", amt: "1.000001",
+			want: []SwapHop{{FromToken: "MTK", ToToken: "USDX", Amount: "1.000001", Output: "0.3", MinOutput: "0.2985"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hops, err := cm.BuildSwapRoute(&SwapRequest{FromToken: tt.from, ToToken: tt.to, Amount: tt.amt}, MaxSwapHops)
+			if err != nil {
+				t.Fatalf("BuildSwapRoute: %v", err)
+			}
+			if len(hops) != len(tt.want) {
+				t.Fatalf("got %d hops, want %d", len(hops), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := hops[i]
+				if got.FromToken != want.FromToken || got.ToToken != want.ToToken || got.Amount != want.Amount ||
+					got.Output != want.Output || got.MinOutput != want.MinOutput {
+					t.Errorf("hop %d = %s->%s amount=%s output=%s min=%s, want %s->%s amount=%s output=%s min=%s", i,
+						got.FromToken, got.ToToken, got.Amount, got.Output, got.MinOutput,
+						want.FromToken, want.ToToken, want.Amount, want.Output, want.MinOutput)
+				}
+				if got.Transaction == nil || got.Transaction.Data == "" {
+					t.Errorf("hop %d has no transaction data", i)
+				}
+				// 配置的 buyToken()/sellToken(uint256) 没有 minAmountOut 参数
+				if got.MinOutputEnforced {
+					t.Errorf("hop %d min output enforced, want an estimate", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFunctionTakesArg(t *testing.T) {
+	withMin := ContractInfo{Functions: map[string]FunctionInfo{
+		"sellToken": {Signature: "sellToken(uint256,uint256)", Parameters: []ParameterInfo{
+			{Name: "tokenAmount", Type: "uint256", Source: "amount"},
+			{Name: "minOut", Type: "uint256", Source: "minAmountOut"},
+		}},
+		"buyToken": {Signature: "buyToken(uint256)", Payable: true, Parameters: []ParameterInfo{{Name: "minAmountOut", Type: "uint256"}}},
+	}}
+
+	tests := []struct {
+		name     string
+		contract ContractInfo
+		function string
+		want     bool
+	}{
+		{name: "builtin buyToken", function: "buyToken"},
+		{name: "builtin sellToken", function: "sellToken"},
+		{name: "declared source", contract: withMin, function: "sellToken", want: true},
+		{name: "declared name", contract: withMin, function: "buyToken", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := functionTakesArg(tt.contract, tt.function, "minAmountOut"); got != tt.want {
+				t.Errorf("functionTakesArg = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSwapRouteErrors(t *testing.T) {
+	cm := newTestManager(t)
+
+	if _, err := cm.BuildSwapRoute(&SwapRequest{FromToken: "USDX", ToToken: "MEER", Amount: "1"}, MaxSwapHops); !errors.Is(err, ErrNoSwapRoute) {
+		t.Errorf("USDX -> MEER: got %v, want ErrNoSwapRoute", err)
+	}
+	if _, err := cm.BuildSwapRoute(&SwapRequest{FromToken: "MEER", ToToken: "USDX", Amount: "1"}, 1); !errors.Is(err, ErrNoSwapRoute) {
+		t.Errorf("MEER -> USDX with one hop: got %v, want ErrNoSwapRoute", err)
+	}
+
+	swap := cm.config.Contracts["SimpleSwap"]
+	swap.SupportedPairs[0].Rate = 0
+	if _, err := cm.BuildSwapRoute(&SwapRequest{FromToken: "MEER", ToToken: "MTK", Amount: "1"}, MaxSwapHops); err == nil {
+		t.Error("zero rate: expected error")
+	}
+}
@@ -0,0 +1,89 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SpenderCheck 授权交易中实际被授权地址（spender）的解码与核验结果
+type SpenderCheck struct {
+	// Address 从 approve 调用数据中解码出的 spender 地址
+	Address string `json:"address"`
+	// Label 已配置合约的名称，未知地址时为空
+	Label string `json:"label,omitempty"`
+	// Known spender 是否为 contracts.json 中配置的合约
+	Known bool `json:"known"`
+	// Warning spender 未知或与预期合约不一致时的提示
+	Warning string `json:"warning,omitempty"`
+}
+
+// DecodeApproveCall 解码 approve(address,uint256) 调用数据，返回 spender 地址与授权数量（最小单位）
+func DecodeApproveCall(data string) (string, *big.Int, error) {
+	raw := strings.TrimPrefix(strings.ToLower(data), "0x")
+	selector := FunctionSelector(builtinFunctions["approve"].Signature)
+	if len(raw) != 8+64*2 || !strings.HasPrefix(raw, selector) {
+		return "", nil, fmt.Errorf("%w: not an approve call: %s", ErrInvalidFunctionCall, data)
+	}
+	spender := "0x" + raw[8+24:8+64]
+	amount, ok := new(big.Int).SetString(raw[8+64:], 16)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: invalid approve amount: %s", ErrInvalidFunctionCall, data)
+	}
+	return spender, amount, nil
+}
+
+// ContractByAddress 按地址查找 contracts.json 中配置的合约（不区分大小写）
+func (cm *ContractManager) ContractByAddress(address string) (*ContractInfo, bool) {
+	for _, contract := range cm.config.Contracts {
+		if contract.Address != "" && strings.EqualFold(contract.Address, address) {
+			return &contract, true
+		}
+	}
+	return nil, false
+}
+
+// CheckApproveSpender 解码授权交易的 spender 并核验它是已配置的合约，且与预期的合约一致。
+// expected 为 contracts.json 中合约的键（如质押池的 contract），按其地址比较，为空时不比较
+func (cm *ContractManager) CheckApproveSpender(data, expected string) (*SpenderCheck, error) {
+	spender, _, err := DecodeApproveCall(data)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &SpenderCheck{Address: spender}
+	contract, known := cm.ContractByAddress(spender)
+	if !known {
+		check.Warning = fmt.Sprintf("授权对象 %s 不是已配置的合约，请在钱包中核对后再签名", spender)
+		return check, nil
+	}
+
+	// 使用配置中的地址写法（校验和大小写），与钱包显示一致
+	check.Address = contract.Address
+	check.Known = true
+	check.Label = contract.Name
+	if expected == "" {
+		return check, nil
+	}
+	// 合约的 name 字段可以与配置中的键不同，只有地址能确定是否为同一合约
+	if expectedContract, exists := cm.config.Contracts[expected]; !exists || !strings.EqualFold(expectedContract.Address, contract.Address) {
+		check.Warning = fmt.Sprintf("授权对象 %s（%s）与质押合约 %s 不一致，请在钱包中核对后再签名", spender, contract.Name, expected)
+	}
+	return check, nil
+}
+
+// KnownAddresses 返回 contracts.json 中配置的代币与合约地址（小写）到名称的映射
+func (cm *ContractManager) KnownAddresses() map[string]string {
+	known := make(map[string]string)
+	for symbol, token := range cm.config.Tokens {
+		if token.ContractAddress != "" {
+			known[strings.ToLower(token.ContractAddress)] = symbol
+		}
+	}
+	for name, contract := range cm.config.Contracts {
+		if contract.Address != "" {
+			known[strings.ToLower(contract.Address)] = name
+		}
+	}
+	return known
+}
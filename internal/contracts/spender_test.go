@@ -0,0 +1,53 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// approveData 编码 approve(spender, 1) 调用数据
+func approveData(t *testing.T, spender string) string {
+	t.Helper()
+	data, err := encodeContractCall(ContractInfo{}, "approve", map[string]any{"spender": spender, "amount": big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("encode approve: %v", err)
+	}
+	return data
+}
+
+func TestCheckApproveSpender(t *testing.T) {
+	cm := newTestManager(t)
+	staking := cm.config.Contracts["MTKStaking"]
+	// 合约的 name 与配置中的键不同，核验仍按键解析的地址比较
+	staking.Name = "MTK Staking Pool"
+	cm.config.Contracts["MTKStaking"] = staking
+	swap := cm.config.Contracts["SimpleSwap"]
+	unknown := fmt.Sprintf("0x%040x", 0xbad)
+
+	tests := []struct {
+		name        string
+		spender     string
+		expected    string
+		wantKnown   bool
+		wantWarning bool
+	}{
+		{name: "expected contract", spender: staking.Address, expected: "MTKStaking", wantKnown: true},
+		{name: "no expectation", spender: swap.Address, wantKnown: true},
+		{name: "other configured contract", spender: swap.Address, expected: "MTKStaking", wantKnown: true, wantWarning: true},
+		{name: "unknown expected contract", spender: staking.Address, expected: "MissingStaking", wantKnown: true, wantWarning: true},
+		{name: "unknown spender", spender: unknown, expected: "MTKStaking", wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, err := cm.CheckApproveSpender(approveData(t, tt.spender), tt.expected)
+			if err != nil {
+				t.Fatalf("CheckApproveSpender: %v", err)
+			}
+			if check.Known != tt.wantKnown || (check.Warning != "") != tt.wantWarning {
+				t.Errorf("check = %+v, want known %v warning %v", check, tt.wantKnown, tt.wantWarning)
+			}
+		})
+	}
+}
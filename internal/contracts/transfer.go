@@ -0,0 +1,79 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidAddress 接收地址不是有效的以太坊地址
+var ErrInvalidAddress = errors.New("invalid address")
+
+// addressPattern 以太坊地址格式
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// BuildTransferTransaction 构建转账交易：原生代币直接转账，ERC20 代币调用 transfer(address,uint256)。
+// contracts.json 的 transfer 工作流配置了 supportedTokens 时只允许列出的代币
+func (cm *ContractManager) BuildTransferTransaction(token, to, amount string) (*TransactionData, error) {
+	log.Printf("🔄 构建转账交易")
+	log.Printf("📋 转账 %s %s 到 %s", amount, token, to)
+
+	if !addressPattern.MatchString(to) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, to)
+	}
+
+	tokenConfig, exists := cm.config.Tokens[token]
+	if !exists {
+		return nil, fmt.Errorf("unsupported transfer token: %s", token)
+	}
+	if workflow, exists := cm.config.Workflows["transfer"]; exists && len(workflow.SupportedTokens) > 0 {
+		supported := false
+		for _, symbol := range workflow.SupportedTokens {
+			if strings.EqualFold(symbol, token) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("unsupported transfer token: %s", token)
+		}
+	}
+
+	if _, err := cm.parseAmount(token, amount); err != nil {
+		return nil, err
+	}
+	units, err := cm.toBaseUnits(token, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	var txData *TransactionData
+	if tokenConfig.IsNative {
+		txData = &TransactionData{
+			To:       to,
+			Value:    "0x" + units.Text(16),
+			Data:     "0x",
+			GasLimit: "0x5208", // 21000 gas
+		}
+	} else {
+		if tokenConfig.ContractAddress == "" {
+			return nil, fmt.Errorf("%s token contract address not found", token)
+		}
+		data, err := cm.encodeTokenCall(tokenConfig.ContractAddress, "transfer", map[string]any{"to": to, "amount": units})
+		if err != nil {
+			return nil, err
+		}
+		txData = &TransactionData{
+			To:       tokenConfig.ContractAddress,
+			Value:    "0x0",
+			Data:     data,
+			GasLimit: "0xFDE8", // 65000 gas
+		}
+	}
+	cm.applyGasFees(txData)
+
+	log.Printf("✅ 转账交易数据构建完成")
+	return txData, nil
+}
@@ -1,11 +1,14 @@
 package llm
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"qng_agent/internal/config"
+	"qng_agent/internal/metrics"
 	"strings"
 	"time"
 )
@@ -15,16 +18,53 @@ type AnthropicClient struct {
 	client *http.Client
 }
 
+// anthropicMessagesURL Anthropic Messages API 地址
+const anthropicMessagesURL = "https://api.anthropic.com/v1/messages"
+
 type AnthropicRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	MaxTokens int      `json:"max_tokens,omitempty"`
+	Model     string             `json:"model"`
+	System    string             `json:"system,omitempty"`
+	Messages  []AnthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens,omitempty"`
+	// Temperature 为 nil 时使用 Anthropic 默认值
+	Temperature *float64 `json:"temperature,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+// AnthropicMessage Messages API 的消息，只包含 user/assistant 角色和文本内容
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// AnthropicStreamEvent 流式响应中每个 SSE 事件的数据
+type AnthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta,omitempty"`
+	Message *struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message,omitempty"`
+	Usage *struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
 }
 
 type AnthropicResponse struct {
 	Content []struct {
 		Text string `json:"text"`
 	} `json:"content"`
+	Usage *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -36,7 +76,8 @@ func NewAnthropicClient(config config.AnthropicConfig) (Client, error) {
 	}
 
 	client := &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
+		Timeout:   time.Duration(config.Timeout) * time.Second,
+		Transport: metrics.LLMTransport(ProviderAnthropic, nil),
 	}
 
 	return &AnthropicClient{
@@ -45,40 +86,29 @@ func NewAnthropicClient(config config.AnthropicConfig) (Client, error) {
 	}, nil
 }
 
-func (c *AnthropicClient) Chat(ctx context.Context, messages []Message) (string, error) {
+func (c *AnthropicClient) Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error) {
 	if c.config.APIKey == "" {
 		// 如果没有API密钥，使用模拟客户端
 		mockClient := NewMockClient()
 		return mockClient.Chat(ctx, messages)
 	}
 
-	requestBody := AnthropicRequest{
-		Model:     c.config.Model,
-		Messages:  messages,
-		MaxTokens: 2000,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	url := "https://api.anthropic.com/v1/messages"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+	req, err := c.newRequest(ctx, messages, false, resolveChatOptions(opts))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.config.APIKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// 先检查状态码，错误响应体不一定是 AnthropicResponse 的结构
+	if err := checkResponse("Anthropic", resp, "json"); err != nil {
+		return "", err
+	}
+
 	var response AnthropicResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
@@ -92,5 +122,160 @@ func (c *AnthropicClient) Chat(ctx context.Context, messages []Message) (string,
 		return "", fmt.Errorf("no response from Anthropic")
 	}
 
+	if response.Usage != nil {
+		recordUsage(ctx, Usage{
+			Provider:         ProviderAnthropic,
+			Model:            c.config.Model,
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+		})
+	} else {
+		recordEstimatedUsage(ctx, ProviderAnthropic, c.config.Model, messages, response.Content[0].Text)
+	}
+
 	return response.Content[0].Text, nil
 }
+
+// ChatStream 通过 Messages API 的 SSE 流式输出（stream: true）逐段发送 text_delta
+func (c *AnthropicClient) ChatStream(ctx context.Context, messages []Message) (*Stream, error) {
+	if c.config.APIKey == "" {
+		return NewMockClient().ChatStream(ctx, messages)
+	}
+
+	req, err := c.newRequest(ctx, messages, true, ChatOptions{})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse("Anthropic", resp, ""); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	stream := newStream(streamBuffer)
+	go func() {
+		defer stream.close(ctx)
+		defer resp.Body.Close()
+
+		// message_start 携带输入用量，message_delta 携带累计输出用量；缺失时按文本估算
+		var completion strings.Builder
+		var usage Usage
+		defer func() {
+			if usage.PromptTokens > 0 || usage.CompletionTokens > 0 {
+				usage.Provider, usage.Model = ProviderAnthropic, c.config.Model
+				recordUsage(ctx, usage)
+			} else {
+				recordEstimatedUsage(ctx, ProviderAnthropic, c.config.Model, messages, completion.String())
+			}
+		}()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+
+			var event AnthropicStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+				stream.fail("Anthropic", fmt.Errorf("failed to decode event: %w", err))
+				return
+			}
+
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					usage.PromptTokens = event.Message.Usage.InputTokens
+				}
+			case "content_block_delta":
+				if event.Delta == nil || event.Delta.Type != "text_delta" {
+					continue
+				}
+				completion.WriteString(event.Delta.Text)
+				if !stream.send(ctx, event.Delta.Text) {
+					return
+				}
+			case "message_delta":
+				if event.Usage != nil {
+					usage.CompletionTokens = event.Usage.OutputTokens
+				}
+			case "message_stop":
+				return
+			case "error":
+				message := "unknown error"
+				if event.Error != nil {
+					message = event.Error.Message
+				}
+				stream.fail("Anthropic", fmt.Errorf("Anthropic API error: %s", message))
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			stream.fail("Anthropic", err)
+			return
+		}
+		// 连接在 message_stop 之前结束，回复不完整
+		if ctx.Err() == nil {
+			stream.fail("Anthropic", io.ErrUnexpectedEOF)
+		}
+	}()
+	return stream, nil
+}
+
+// newRequest 构建 Messages API 请求，stream 为 true 时请求 SSE 流式输出，options 覆盖默认的生成参数
+func (c *AnthropicClient) newRequest(ctx context.Context, messages []Message, stream bool, options ChatOptions) (*http.Request, error) {
+	system, conversation, err := anthropicMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody := AnthropicRequest{
+		Model:     c.config.Model,
+		System:    system,
+		Messages:  conversation,
+		MaxTokens: options.maxTokensOr(2000),
+		Temperature: options.Temperature,
+		Stream:    stream,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", anthropicMessagesURL, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.config.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	return req, nil
+}
+
+// anthropicMessages 将消息拆分为顶层 system 提示和 user/assistant 对话。
+// Messages API 拒绝 messages 中的 system 角色以及 name、tool_call_id 等额外字段，
+// 工具结果降级为用户消息
+func anthropicMessages(messages []Message) (string, []AnthropicMessage, error) {
+	normalized, err := NormalizeMessages(messages)
+	if err != nil {
+		return "", nil, err
+	}
+	system, conversation := splitSystemMessages(fallbackToolMessages(normalized))
+	if len(conversation) == 0 {
+		return "", nil, fmt.Errorf("no user or assistant messages provided")
+	}
+
+	converted := make([]AnthropicMessage, 0, len(conversation))
+	for _, msg := range conversation {
+		converted = append(converted, AnthropicMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return system, converted, nil
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"qng_agent/internal/config"
+)
+
+func TestAnthropicMessages(t *testing.T) {
+	tests := []struct {
+		name         string
+		messages     []Message
+		wantSystem   string
+		wantMessages []AnthropicMessage
+		wantErr      bool
+	}{
+		{
+			name: "mixed roles",
+			messages: []Message{
+				{Role: RoleSystem, Content: "你是QNG助手"},
+				{Role: RoleUser, Content: "兑换1 MEER"},
+				{Role: RoleAssistant, Content: "好的"},
+				{Role: "developer", Content: "回复使用中文"},
+				{Role: RoleTool, Name: "get_balance", Content: `{"MEER": "1"}`, ToolCallID: "call_1"},
+			},
+			wantSystem: "你是QNG助手\n\n回复使用中文",
+			wantMessages: []AnthropicMessage{
+				{Role: RoleUser, Content: "兑换1 MEER"},
+				{Role: RoleAssistant, Content: "好的"},
+				{Role: RoleUser, Content: `Tool result (get_balance): {"MEER": "1"}`},
+			},
+		},
+		{
+			name:         "no system prompt",
+			messages:     []Message{{Role: "human", Content: "你好"}},
+			wantMessages: []AnthropicMessage{{Role: RoleUser, Content: "你好"}},
+		},
+		{
+			name:     "only system messages",
+			messages: []Message{{Role: RoleSystem, Content: "你是QNG助手"}},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown role",
+			messages: []Message{{Role: "narrator", Content: "..."}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, messages, err := anthropicMessages(tt.messages)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("anthropicMessages: %v", err)
+			}
+			if system != tt.wantSystem {
+				t.Errorf("system = %q, want %q", system, tt.wantSystem)
+			}
+			if !reflect.DeepEqual(messages, tt.wantMessages) {
+				t.Errorf("messages = %+v, want %+v", messages, tt.wantMessages)
+			}
+		})
+	}
+}
+
+// TestAnthropicRequestBody 校验发送的请求体中 system 位于顶层字段，messages 中没有 system 角色
+func TestAnthropicRequestBody(t *testing.T) {
+	client := &AnthropicClient{config: config.AnthropicConfig{APIKey: "test", Model: "claude-test"}}
+	req, err := client.newRequest(context.Background(), []Message{
+		{Role: RoleSystem, Content: "你是QNG助手"},
+		{Role: RoleUser, Content: "你好"},
+	}, true, ChatOptions{})
+	if err != nil {
+		t.Fatalf("newRequest: %v", err)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if fields["system"] != "你是QNG助手" || fields["stream"] != true {
+		t.Errorf("system = %v stream = %v, want top-level system prompt and stream", fields["system"], fields["stream"])
+	}
+	messages, _ := fields["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("messages = %v, want only the user message", fields["messages"])
+	}
+	if message, _ := messages[0].(map[string]any); message["role"] != RoleUser || len(message) != 2 {
+		t.Errorf("message = %v, want role and content only", message)
+	}
+}
@@ -2,32 +2,87 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"qng_agent/internal/config"
+	"strings"
 )
 
 type Client interface {
-	Chat(ctx context.Context, messages []Message) (string, error)
+	// Chat 生成完整回复，opts 指定本次调用的温度、最大 token 数等生成参数
+	Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error)
+	// ChatStream 流式生成回复，Stream.Chunks 按顺序输出文本分段，生成结束或上下文取消时关闭。
+	// 请求失败时返回错误；生成中途失败时提前关闭通道，Stream.Err 返回失败原因。
+	ChatStream(ctx context.Context, messages []Message) (*Stream, error)
 }
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Name 工具结果消息对应的工具名称
+	Name string `json:"name,omitempty"`
+	// ToolCallID 工具结果消息对应的工具调用ID，仅在提供商返回了工具调用时存在
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
+// 支持的LLM提供商
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+	ProviderMock      = "mock"
+)
+
+// ErrNoProvider 严格模式下未配置可用的LLM提供商
+var ErrNoProvider = errors.New("no LLM provider configured")
+
+// NewClient 按配置创建LLM客户端。
+// 未配置提供商、提供商未知或缺少API密钥时：严格模式返回错误，否则显式回退到MockClient并打印警告。
 func NewClient(config config.LLMConfig) (Client, error) {
-	switch config.Provider {
-	case "openai":
-		return NewOpenAIClient(config.OpenAI)
-	case "anthropic":
+	provider := strings.ToLower(strings.TrimSpace(config.Provider))
+
+	var apiKey string
+	switch provider {
+	case ProviderOpenAI:
+		apiKey = config.OpenAI.APIKey
+	case ProviderAnthropic:
+		apiKey = config.Anthropic.APIKey
+	case ProviderGemini:
+		apiKey = config.Gemini.APIKey
+	case ProviderMock:
+		log.Printf("🧪 使用模拟LLM客户端 (provider: mock)")
+		return NewMockClient(), nil
+	case "":
+		return fallbackClient(config, "未配置LLM提供商")
+	default:
+		return fallbackClient(config, fmt.Sprintf("未知的LLM提供商 %q", config.Provider))
+	}
+
+	if apiKey == "" {
+		return fallbackClient(config, fmt.Sprintf("LLM提供商 %s 未配置API密钥", provider))
+	}
+
+	switch provider {
+	case ProviderAnthropic:
 		return NewAnthropicClient(config.Anthropic)
-	case "gemini":
+	case ProviderGemini:
 		return NewGeminiClient(config.Gemini)
 	default:
 		return NewOpenAIClient(config.OpenAI)
 	}
 }
 
+// fallbackClient 严格模式下返回错误，否则回退到MockClient并打印醒目警告
+func fallbackClient(config config.LLMConfig, reason string) (Client, error) {
+	if config.Strict {
+		return nil, fmt.Errorf("%w: %s (strict mode)", ErrNoProvider, reason)
+	}
+	log.Printf("⚠️⚠️⚠️  %s，正在使用模拟LLM客户端，所有回复均为假数据，请勿用于生产环境！", reason)
+	log.Printf("⚠️  设置 llm.strict: true 可在启动时拒绝此回退")
+	return NewMockClient(), nil
+}
+
 // MockClient 模拟LLM客户端，用于测试
 type MockClient struct{}
 
@@ -35,12 +90,19 @@ func NewMockClient() Client {
 	return &MockClient{}
 }
 
-func (c *MockClient) Chat(ctx context.Context, messages []Message) (string, error) {
+func (c *MockClient) Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error) {
 	// 模拟LLM响应
 	if len(messages) == 0 {
 		return "", fmt.Errorf("no messages provided")
 	}
 
+	response := c.respond(messages)
+	recordEstimatedUsage(ctx, ProviderMock, "", messages, response)
+	return response, nil
+}
+
+// respond 根据最后一条消息的内容生成模拟回复
+func (c *MockClient) respond(messages []Message) string {
 	lastMessage := messages[len(messages)-1].Content
 	
 	// 根据消息内容返回模拟响应
@@ -54,7 +116,7 @@ func (c *MockClient) Chat(ctx context.Context, messages []Message) (string, erro
 					"amount": "1000"
 				}
 			]
-		}`, nil
+		}`
 	}
 	
 	if contains(lastMessage, "质押") || contains(lastMessage, "stake") {
@@ -63,11 +125,10 @@ func (c *MockClient) Chat(ctx context.Context, messages []Message) (string, erro
 				{
 					"type": "stake",
 					"token": "BTC",
-					"amount": "0.1",
-					"pool": "compound"
+					"amount": "0.1"
 				}
 			]
-		}`, nil
+		}`
 	}
 	
 	// 默认响应
@@ -80,7 +141,26 @@ func (c *MockClient) Chat(ctx context.Context, messages []Message) (string, erro
 				"amount": "500"
 			}
 		]
-	}`, nil
+	}`
+}
+
+// ChatStream 将模拟回复切分为几段依次发送
+func (c *MockClient) ChatStream(ctx context.Context, messages []Message) (*Stream, error) {
+	response, err := c.Chat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := newStream(streamBuffer)
+	go func() {
+		defer stream.close(ctx)
+		for _, chunk := range splitChunks(response, 4) {
+			if !stream.send(ctx, chunk) {
+				return
+			}
+		}
+	}()
+	return stream, nil
 }
 
 func contains(s, substr string) bool {
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody 错误响应体最多读取的字节数，网关返回的 HTML 错误页可能很大
+const maxErrorBody = 4096
+
+// APIError LLM提供商返回的非 2xx 响应或非预期内容类型的响应，Body 为原始响应体。
+// 响应体为 {"error": {"message": ...}} 结构（OpenAI、Anthropic、Gemini 均如此）时 Message 为其中的错误信息
+type APIError struct {
+	Provider    string
+	StatusCode  int
+	ContentType string
+	Message     string
+	Body        string
+}
+
+func (e *APIError) Error() string {
+	body := e.Body
+	if body == "" {
+		body = http.StatusText(e.StatusCode)
+	}
+	if e.StatusCode >= 200 && e.StatusCode < 300 {
+		return fmt.Sprintf("%s API error (status %d, unexpected content type %q): %s", e.Provider, e.StatusCode, e.ContentType, body)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("%s API error (status %d): %s, body: %s", e.Provider, e.StatusCode, e.Message, body)
+	}
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, body)
+}
+
+// errorMessage 从 {"error": {"message": ...}} 结构的响应体中取出错误信息，其它格式返回空字符串
+func errorMessage(body []byte) string {
+	var response struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || response.Error == nil {
+		return ""
+	}
+	return response.Error.Message
+}
+
+// checkResponse 先检查状态码，非 2xx 时读取原始响应体并返回 *APIError。
+// contentType 不为空时还要求成功响应的 Content-Type 包含它，避免把代理返回的 HTML 当作 JSON 解码；
+// 未设置 Content-Type 的响应不做检查。返回错误时响应体已读取，调用方仍负责关闭
+func checkResponse(provider string, resp *http.Response, contentType string) error {
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	actual := resp.Header.Get("Content-Type")
+	if success && (contentType == "" || actual == "" || strings.Contains(actual, contentType)) {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &APIError{
+		Provider:    provider,
+		StatusCode:  resp.StatusCode,
+		ContentType: actual,
+		Message:     errorMessage(body),
+		Body:        strings.TrimSpace(string(body)),
+	}
+}
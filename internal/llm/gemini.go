@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"qng_agent/internal/config"
+	"qng_agent/internal/metrics"
 	"strings"
 	"time"
 )
@@ -16,11 +17,24 @@ type GeminiClient struct {
 }
 
 type GeminiRequest struct {
-	Contents []struct {
-		Parts []struct {
-			Text string `json:"text"`
-		} `json:"parts"`
-	} `json:"contents"`
+	SystemInstruction *GeminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent `json:"contents"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiGenerationConfig 单次调用的生成参数，未设置的字段使用 Gemini 默认值
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
 }
 
 type GeminiResponse struct {
@@ -31,6 +45,11 @@ type GeminiResponse struct {
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -42,7 +61,8 @@ func NewGeminiClient(config config.GeminiConfig) (Client, error) {
 	}
 
 	client := &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
+		Timeout:   time.Duration(config.Timeout) * time.Second,
+		Transport: metrics.LLMTransport(ProviderGemini, nil),
 	}
 
 	return &GeminiClient{
@@ -51,36 +71,39 @@ func NewGeminiClient(config config.GeminiConfig) (Client, error) {
 	}, nil
 }
 
-func (c *GeminiClient) Chat(ctx context.Context, messages []Message) (string, error) {
+func (c *GeminiClient) Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error) {
 	if c.config.APIKey == "" {
 		// 如果没有API密钥，使用模拟客户端
 		mockClient := NewMockClient()
 		return mockClient.Chat(ctx, messages)
 	}
 
-	// 转换消息格式
-	var contents []struct {
-		Parts []struct {
-			Text string `json:"text"`
-		} `json:"parts"`
+	// 校验并规范化角色，Gemini 使用 user/model，系统提示放在 systemInstruction 中
+	normalized, err := NormalizeMessages(messages)
+	if err != nil {
+		return "", err
 	}
-
-	for _, msg := range messages {
-		contents = append(contents, struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		}{
-			Parts: []struct {
-				Text string `json:"text"`
-			}{
-				{Text: msg.Content},
-			},
-		})
+	system, conversation := splitSystemMessages(fallbackToolMessages(normalized))
+
+	requestBody := GeminiRequest{}
+	if options := resolveChatOptions(opts); options.Temperature != nil || options.MaxTokens > 0 {
+		requestBody.GenerationConfig = &GeminiGenerationConfig{
+			Temperature:     options.Temperature,
+			MaxOutputTokens: options.MaxTokens,
+		}
+	}
+	if system != "" {
+		requestBody.SystemInstruction = &GeminiContent{
+			Parts: []GeminiPart{{Text: system}},
+		}
 	}
 
-	requestBody := GeminiRequest{
-		Contents: contents,
+	// 转换消息格式
+	for _, msg := range conversation {
+		requestBody.Contents = append(requestBody.Contents, GeminiContent{
+			Role:  geminiRole(msg.Role),
+			Parts: []GeminiPart{{Text: msg.Content}},
+		})
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -104,6 +127,11 @@ func (c *GeminiClient) Chat(ctx context.Context, messages []Message) (string, er
 	}
 	defer resp.Body.Close()
 
+	// 先检查状态码，错误响应体不一定是 GeminiResponse 的结构
+	if err := checkResponse("Gemini", resp, "json"); err != nil {
+		return "", err
+	}
+
 	var response GeminiResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
@@ -121,5 +149,31 @@ func (c *GeminiClient) Chat(ctx context.Context, messages []Message) (string, er
 		return "", fmt.Errorf("no content in Gemini response")
 	}
 
-	return response.Candidates[0].Content.Parts[0].Text, nil
+	text := response.Candidates[0].Content.Parts[0].Text
+	if usage := response.UsageMetadata; usage != nil {
+		recordUsage(ctx, Usage{
+			Provider:         ProviderGemini,
+			Model:            c.config.Model,
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		})
+	} else {
+		recordEstimatedUsage(ctx, ProviderGemini, c.config.Model, messages, text)
+	}
+
+	return text, nil
+}
+
+// geminiRole 将规范角色映射为 Gemini 的角色名称
+func geminiRole(role string) string {
+	if role == RoleAssistant {
+		return "model"
+	}
+	return "user"
+}
+
+// ChatStream Gemini 暂不支持流式输出，完整回复生成后一次性发送
+func (c *GeminiClient) ChatStream(ctx context.Context, messages []Message) (*Stream, error) {
+	return streamWhole(ctx, c, messages)
 }
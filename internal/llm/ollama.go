@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"qng_agent/internal/metrics"
+	"strings"
 )
 
 type OllamaClient struct {
@@ -28,14 +30,33 @@ type OllamaMessage struct {
 }
 
 type OllamaOptions struct {
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"top_p,omitempty"`
-	TopK        int     `json:"top_k,omitempty"`
+	Temperature float64 `json:"temperature"`
+	// NumPredict 最大生成 token 数，0 时使用 Ollama 默认值
+	NumPredict int     `json:"num_predict,omitempty"`
+	TopP       float64 `json:"top_p,omitempty"`
+	TopK       int     `json:"top_k,omitempty"`
 }
 
 type OllamaResponse struct {
 	Message OllamaMessage `json:"message"`
 	Done    bool          `json:"done"`
+	// PromptEvalCount 与 EvalCount 仅在最后一个响应中返回
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+// recordUsage 记录 Ollama 返回的用量，没有返回时按文本估算
+func (c *OllamaClient) recordUsage(ctx context.Context, response OllamaResponse, messages []Message, completion string) {
+	if response.PromptEvalCount == 0 && response.EvalCount == 0 {
+		recordEstimatedUsage(ctx, "ollama", c.model, messages, completion)
+		return
+	}
+	recordUsage(ctx, Usage{
+		Provider:         "ollama",
+		Model:            c.model,
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+	})
 }
 
 func NewOllamaClient(configs map[string]string) (*OllamaClient, error) {
@@ -52,14 +73,82 @@ func NewOllamaClient(configs map[string]string) (*OllamaClient, error) {
 	return &OllamaClient{
 		baseURL: baseURL,
 		model:   model,
-		client:  &http.Client{},
+		client:  &http.Client{Transport: metrics.LLMTransport("ollama", nil)},
 	}, nil
 }
 
-func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (string, error) {
+func (c *OllamaClient) Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error) {
+	resp, err := c.send(ctx, messages, false, resolveChatOptions(opts))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// 解析响应
+	var response OllamaResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	c.recordUsage(ctx, response, messages, response.Message.Content)
+	return response.Message.Content, nil
+}
+
+// ChatStream 使用 stream:true 模式流式生成回复，Ollama 每行返回一个 JSON 对象
+func (c *OllamaClient) ChatStream(ctx context.Context, messages []Message) (*Stream, error) {
+	resp, err := c.send(ctx, messages, true, ChatOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	stream := newStream(streamBuffer)
+	go func() {
+		defer stream.close(ctx)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		var completion strings.Builder
+		for {
+			var response OllamaResponse
+			if err := decoder.Decode(&response); err != nil {
+				// 在 done 之前结束的响应同样不完整
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				if ctx.Err() == nil {
+					stream.fail("Ollama", err)
+				}
+				return
+			}
+			completion.WriteString(response.Message.Content)
+			if response.Done {
+				c.recordUsage(ctx, response, messages, completion.String())
+			}
+			if !stream.send(ctx, response.Message.Content) || response.Done {
+				return
+			}
+		}
+	}()
+	return stream, nil
+}
+
+// send 发送聊天请求，状态码不是 200 时读取错误信息并关闭响应
+func (c *OllamaClient) send(ctx context.Context, messages []Message, stream bool, options ChatOptions) (*http.Response, error) {
+	// 校验并规范化角色（Ollama 使用 system/user/assistant）
+	normalized, err := NormalizeMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
 	// 转换消息格式
-	ollamaMessages := make([]OllamaMessage, len(messages))
-	for i, msg := range messages {
+	ollamaMessages := make([]OllamaMessage, len(normalized))
+	for i, msg := range normalized {
 		ollamaMessages[i] = OllamaMessage{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -70,9 +159,10 @@ func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (string, er
 	request := OllamaRequest{
 		Model:    c.model,
 		Messages: ollamaMessages,
-		Stream:   false,
+		Stream:   stream,
 		Options: &OllamaOptions{
-			Temperature: 0.7,
+			Temperature: options.temperatureOr(0.7),
+			NumPredict:  options.MaxTokens,
 			TopP:        0.9,
 		},
 	}
@@ -80,14 +170,14 @@ func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (string, er
 	// 序列化请求
 	requestBody, err := json.Marshal(request)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// 创建HTTP请求
 	url := fmt.Sprintf("%s/api/chat", c.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -95,26 +185,14 @@ func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (string, er
 	// 发送请求
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
-	// 检查状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Ollama API request failed with status %d: %s", resp.StatusCode, string(responseBody))
+	// 检查状态码，流式与非流式响应的内容类型不同，不检查 Content-Type
+	if err := checkResponse("Ollama", resp, ""); err != nil {
+		resp.Body.Close()
+		return nil, err
 	}
 
-	// 解析响应
-	var response OllamaResponse
-	if err := json.Unmarshal(responseBody, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return response.Message.Content, nil
+	return resp, nil
 }
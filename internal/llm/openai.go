@@ -1,12 +1,18 @@
 package llm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"qng_agent/internal/config"
+	"qng_agent/internal/metrics"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,26 +26,67 @@ type OpenAIRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
 	MaxTokens int      `json:"max_tokens,omitempty"`
+	// Temperature 为 nil 时使用 OpenAI 默认值
+	Temperature *float64 `json:"temperature,omitempty"`
+	Stream   bool      `json:"stream,omitempty"`
+	// Tools 可供模型调用的函数，ToolChoice 为 "auto"、"required" 或指定函数
+	Tools      []OpenAITool `json:"tools,omitempty"`
+	ToolChoice any          `json:"tool_choice,omitempty"`
+}
+
+// OpenAITool OpenAI 格式的工具定义
+type OpenAITool struct {
+	Type     string `json:"type"`
+	Function Tool   `json:"function"`
+}
+
+// OpenAIToolCall OpenAI 响应中的函数调用
+type OpenAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// OpenAIMessage OpenAI 响应中的助手消息
+type OpenAIMessage struct {
+	Content   string           `json:"content"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
 }
 
 type OpenAIResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message OpenAIMessage `json:"message"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
+// OpenAIStreamChunk 流式响应中每个 SSE 事件的数据
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
 func NewOpenAIClient(config config.OpenAIConfig) (Client, error) {
 	if config.APIKey == "" {
 		return NewMockClient(), nil
 	}
 
 	client := &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
+		Timeout:   time.Duration(config.Timeout) * time.Second,
+		Transport: metrics.LLMTransport(ProviderOpenAI, nil),
 	}
 
 	return &OpenAIClient{
@@ -48,7 +95,7 @@ func NewOpenAIClient(config config.OpenAIConfig) (Client, error) {
 	}, nil
 }
 
-func (c *OpenAIClient) Chat(ctx context.Context, messages []Message) (string, error) {
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, opts ...ChatOption) (string, error) {
 	log.Printf("🔍 OpenAI客户端诊断信息:")
 	log.Printf("  - API密钥长度: %d", len(c.config.APIKey))
 	log.Printf("  - BaseURL: %s", c.config.BaseURL)
@@ -61,23 +108,211 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message) (string, er
 		return mockClient.Chat(ctx, messages)
 	}
 
+	jsonData, err := c.marshalRequest(messages, false, nil, resolveChatOptions(opts))
+	if err != nil {
+		return "", err
+	}
+
+	message, err := c.complete(ctx, messages, jsonData)
+	if err != nil {
+		return "", err
+	}
+	return message.Content, nil
+}
+
+// ChatWithTools 携带工具定义发起对话，返回文本回复与函数调用。只提供一个工具时强制模型调用该工具
+func (c *OpenAIClient) ChatWithTools(ctx context.Context, messages []Message, tools []Tool, opts ...ChatOption) (*ToolResponse, error) {
+	if c.config.APIKey == "" || c.config.BaseURL == "" {
+		log.Printf("⚠️  使用模拟客户端 (API密钥或BaseURL为空)")
+		content, err := NewMockClient().Chat(ctx, messages)
+		if err != nil {
+			return nil, err
+		}
+		return &ToolResponse{Content: content}, nil
+	}
+
+	jsonData, err := c.marshalRequest(messages, false, tools, resolveChatOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := c.complete(ctx, messages, jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ToolResponse{Content: message.Content}
+	for _, call := range message.ToolCalls {
+		response.ToolCalls = append(response.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	log.Printf("🔧 OpenAI返回 %d 个函数调用", len(response.ToolCalls))
+	return response, nil
+}
+
+// complete 发送非流式请求并记录用量，429、5xx 与网络错误按指数退避重试，其它错误立即返回
+func (c *OpenAIClient) complete(ctx context.Context, messages []Message, jsonData []byte) (*OpenAIMessage, error) {
+	url := c.config.BaseURL + "/chat/completions"
+	log.Printf("🌐 请求URL: %s", url)
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, url, jsonData)
+		if err == nil {
+			message := &response.Choices[0].Message
+			if response.Usage != nil {
+				recordUsage(ctx, Usage{
+					Provider:         ProviderOpenAI,
+					Model:            c.config.Model,
+					PromptTokens:     response.Usage.PromptTokens,
+					CompletionTokens: response.Usage.CompletionTokens,
+					TotalTokens:      response.Usage.TotalTokens,
+				})
+			} else {
+				recordEstimatedUsage(ctx, ProviderOpenAI, c.config.Model, messages, message.Content)
+			}
+			return message, nil
+		}
+
+		var retryErr *openAIRetryableError
+		if !errors.As(err, &retryErr) {
+			return nil, err
+		}
+		if attempt >= c.config.MaxRetries {
+			if c.config.MaxRetries > 0 {
+				return nil, fmt.Errorf("OpenAI request failed after %d retries: %w", c.config.MaxRetries, err)
+			}
+			return nil, err
+		}
+
+		delay := c.retryDelay(attempt, retryErr.retryAfter)
+		log.Printf("⚠️ OpenAI请求失败，%v 后重试 (%d/%d): %v", delay, attempt+1, c.config.MaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// ChatStream 使用 stream:true 的 SSE 模式流式生成回复，失败时不重试
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message) (*Stream, error) {
+	if c.config.APIKey == "" || c.config.BaseURL == "" {
+		log.Printf("⚠️  使用模拟客户端 (API密钥或BaseURL为空)")
+		return NewMockClient().ChatStream(ctx, messages)
+	}
+
+	jsonData, err := c.marshalRequest(messages, true, nil, ChatOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.config.BaseURL + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if err := checkResponse("OpenAI", resp, ""); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	stream := newStream(streamBuffer)
+	go func() {
+		defer stream.close(ctx)
+		defer resp.Body.Close()
+
+		// 流式响应不返回用量，按已生成的文本估算
+		var completion strings.Builder
+		defer func() {
+			recordEstimatedUsage(ctx, ProviderOpenAI, c.config.Model, messages, completion.String())
+		}()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				return
+			}
+
+			var chunk OpenAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				stream.fail("OpenAI", fmt.Errorf("failed to decode chunk: %w", err))
+				return
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			completion.WriteString(chunk.Choices[0].Delta.Content)
+			if !stream.send(ctx, chunk.Choices[0].Delta.Content) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			stream.fail("OpenAI", err)
+			return
+		}
+		// 连接在 [DONE] 之前结束，回复不完整
+		if ctx.Err() == nil {
+			stream.fail("OpenAI", io.ErrUnexpectedEOF)
+		}
+	}()
+	return stream, nil
+}
+
+// marshalRequest 规范化消息并序列化请求体，tools 不为空时附带工具定义，options 覆盖配置中的生成参数
+func (c *OpenAIClient) marshalRequest(messages []Message, stream bool, tools []Tool, options ChatOptions) ([]byte, error) {
+	// 校验并规范化角色（OpenAI 使用 system/user/assistant/tool）
+	normalized, err := NormalizeMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+	normalized = openAIToolMessages(normalized)
+
 	requestBody := OpenAIRequest{
 		Model:     c.config.Model,
-		Messages:  messages,
-		MaxTokens: c.config.MaxTokens,
+		Messages:  normalized,
+		MaxTokens: options.maxTokensOr(c.config.MaxTokens),
+		Temperature: options.Temperature,
+		Stream:    stream,
+	}
+	for _, tool := range tools {
+		requestBody.Tools = append(requestBody.Tools, OpenAITool{Type: "function", Function: tool})
+	}
+	if len(tools) == 1 {
+		requestBody.ToolChoice = map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": tools[0].Name},
+		}
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	return jsonData, nil
+}
 
-	url := c.config.BaseURL + "/chat/completions"
-	log.Printf("🌐 请求URL: %s", url)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+// send 发送一次请求，可重试的失败包装为 openAIRetryableError
+func (c *OpenAIClient) send(ctx context.Context, url string, jsonData []byte) (*OpenAIResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -85,22 +320,89 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message) (string, er
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		err = fmt.Errorf("failed to send request: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &openAIRetryableError{err: err}
 	}
 	defer resp.Body.Close()
 
+	// 先检查状态码，错误响应体不一定是 OpenAIResponse 的结构
+	if err := checkResponse("OpenAI", resp, "json"); err != nil {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return nil, &openAIRetryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		case resp.StatusCode >= 500:
+			return nil, &openAIRetryableError{err: err}
+		default:
+			return nil, err
+		}
+	}
+
 	var response OpenAIResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if response.Error != nil {
-		return "", fmt.Errorf("OpenAI API error: %s", response.Error.Message)
+		return nil, fmt.Errorf("OpenAI API error: %s", response.Error.Message)
 	}
 
 	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+		return nil, fmt.Errorf("no response from OpenAI")
 	}
 
-	return response.Choices[0].Message.Content, nil
+	return &response, nil
+}
+
+// openAIRetryableError 可重试的请求失败，retryAfter 为服务端通过 Retry-After 要求的等待时间
+type openAIRetryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *openAIRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *openAIRetryableError) Unwrap() error {
+	return e.err
+}
+
+// maxRetryAfter Retry-After 的等待上限，避免异常的响应头阻塞工作流
+const maxRetryAfter = 60 * time.Second
+
+// retryDelay 优先使用 Retry-After，否则按 RetryBackoff 指数增长
+func (c *OpenAIClient) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRetryAfter)
+	}
+	return time.Duration(c.config.RetryBackoff) * time.Millisecond << attempt
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After，无法解析或已过期时返回 0
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && time.Until(at) > 0 {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// openAIToolMessages OpenAI 的 tool 角色必须关联一个工具调用ID，没有ID的工具结果降级为用户消息
+func openAIToolMessages(messages []Message) []Message {
+	converted := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleTool && msg.ToolCallID == "" {
+			msg = toolResultAsUser(msg)
+		}
+		converted = append(converted, msg)
+	}
+	return converted
 }
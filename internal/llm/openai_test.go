@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"qng_agent/internal/config"
+)
+
+// rateLimitMessage 模拟 OpenAI 429 响应中的错误信息
+const rateLimitMessage = "Rate limit reached for gpt-4 in organization org-test on requests per min (RPM): Limit 3, Used 3, Requested 1."
+
+// rateLimitServer 前 limited 次请求返回带 Retry-After 的 429，之后返回正常回复
+func rateLimitServer(limited int32, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) <= limited {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"error": {"message": %q, "type": "requests", "code": "rate_limit_exceeded"}}`, rateLimitMessage)
+			return
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "pong"}}]}`)
+	}))
+	return server, &requests
+}
+
+func newTestOpenAIClient(t *testing.T, baseURL string, maxRetries, backoff int) Client {
+	t.Helper()
+	client, err := NewOpenAIClient(config.OpenAIConfig{
+		APIKey:       "test",
+		Model:        "gpt-4",
+		BaseURL:      baseURL,
+		Timeout:      5,
+		MaxRetries:   maxRetries,
+		RetryBackoff: backoff,
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAIClient: %v", err)
+	}
+	return client
+}
+
+func TestOpenAIRateLimitReturnsAPIError(t *testing.T) {
+	server, requests := rateLimitServer(100, "")
+	defer server.Close()
+
+	client := newTestOpenAIClient(t, server.URL, 1, 1)
+	_, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "ping"}})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error is not *APIError: %v", err)
+	}
+	if apiErr.Provider != "OpenAI" || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("APIError = %s status %d, want OpenAI status 429", apiErr.Provider, apiErr.StatusCode)
+	}
+	if apiErr.Message != rateLimitMessage {
+		t.Errorf("Message = %q, want %q", apiErr.Message, rateLimitMessage)
+	}
+	if !strings.Contains(apiErr.Body, "rate_limit_exceeded") {
+		t.Errorf("Body does not contain the raw response: %q", apiErr.Body)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2 (one retry)", n)
+	}
+}
+
+func TestOpenAIRateLimitHonoursRetryAfter(t *testing.T) {
+	server, requests := rateLimitServer(1, "1")
+	defer server.Close()
+
+	// 指数退避为 30 秒，只有按 Retry-After 等待才能在超时前完成
+	client := newTestOpenAIClient(t, server.URL, 1, 30000)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	reply, err := client.Chat(ctx, []Message{{Role: RoleUser, Content: "ping"}})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if reply != "pong" {
+		t.Errorf("reply = %q, want pong", reply)
+	}
+	if elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > 30*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want (0, 30s]", future, got)
+	}
+}
+
+func TestRetryDelayCapsRetryAfter(t *testing.T) {
+	client := &OpenAIClient{config: config.OpenAIConfig{RetryBackoff: 100}}
+	if got := client.retryDelay(0, 10*time.Minute); got != maxRetryAfter {
+		t.Errorf("retryDelay with 10m Retry-After = %v, want %v", got, maxRetryAfter)
+	}
+	if got := client.retryDelay(2, 0); got != 400*time.Millisecond {
+		t.Errorf("retryDelay backoff = %v, want 400ms", got)
+	}
+}
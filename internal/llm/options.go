@@ -0,0 +1,55 @@
+package llm
+
+// ChatOptions 单次调用的生成参数，未设置的字段使用提供商配置或默认值
+type ChatOptions struct {
+	// Temperature 采样温度，nil 表示使用提供商默认值，0 表示尽量确定的输出
+	Temperature *float64
+	// MaxTokens 最大生成 token 数，0 表示使用提供商配置
+	MaxTokens int
+}
+
+// ChatOption 设置单次调用的生成参数，共享同一客户端的节点可以各自指定
+type ChatOption func(*ChatOptions)
+
+// WithTemperature 设置采样温度
+func WithTemperature(temperature float64) ChatOption {
+	return func(o *ChatOptions) {
+		o.Temperature = &temperature
+	}
+}
+
+// WithMaxTokens 设置最大生成 token 数，不大于 0 时忽略
+func WithMaxTokens(maxTokens int) ChatOption {
+	return func(o *ChatOptions) {
+		if maxTokens > 0 {
+			o.MaxTokens = maxTokens
+		}
+	}
+}
+
+// resolveChatOptions 依次应用调用方传入的选项
+func resolveChatOptions(opts []ChatOption) ChatOptions {
+	var options ChatOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+// maxTokensOr 返回调用方指定的最大 token 数，未指定时使用 fallback
+func (o ChatOptions) maxTokensOr(fallback int) int {
+	if o.MaxTokens > 0 {
+		return o.MaxTokens
+	}
+	return fallback
+}
+
+// temperatureOr 返回调用方指定的温度，未指定时使用 fallback
+func (o ChatOptions) temperatureOr(fallback float64) float64 {
+	if o.Temperature != nil {
+		return *o.Temperature
+	}
+	return fallback
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 规范角色，所有客户端在调用API前都会先转换为这些角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// roleAliases 各提供商使用的角色名称到规范角色的映射
+var roleAliases = map[string]string{
+	"system":    RoleSystem,
+	"developer": RoleSystem,
+	"user":      RoleUser,
+	"human":     RoleUser,
+	"assistant": RoleAssistant,
+	"model":     RoleAssistant,
+	"tool":      RoleTool,
+	"function":  RoleTool,
+}
+
+// NormalizeRole 将角色名称转换为规范角色，未知角色返回错误
+func NormalizeRole(role string) (string, error) {
+	canonical, ok := roleAliases[strings.ToLower(strings.TrimSpace(role))]
+	if !ok {
+		return "", fmt.Errorf("unknown message role: %q", role)
+	}
+	return canonical, nil
+}
+
+// NormalizeMessages 校验并规范化消息角色，在发起API调用之前拒绝未知角色
+func NormalizeMessages(messages []Message) ([]Message, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	normalized := make([]Message, 0, len(messages))
+	for i, msg := range messages {
+		role, err := NormalizeRole(msg.Role)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message at index %d: %w", i, err)
+		}
+		msg.Role = role
+		normalized = append(normalized, msg)
+	}
+	return normalized, nil
+}
+
+// splitSystemMessages 将系统消息从对话中分离，供需要独立system字段的提供商使用
+func splitSystemMessages(messages []Message) (string, []Message) {
+	var systemParts []string
+	conversation := make([]Message, 0, len(messages))
+
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			systemParts = append(systemParts, msg.Content)
+			continue
+		}
+		conversation = append(conversation, msg)
+	}
+
+	return strings.Join(systemParts, "\n\n"), conversation
+}
+
+// toolResultAsUser 将工具结果消息降级为普通用户消息，供不支持工具角色的提供商使用
+func toolResultAsUser(msg Message) Message {
+	label := "Tool result"
+	if msg.Name != "" {
+		label = fmt.Sprintf("Tool result (%s)", msg.Name)
+	}
+	return Message{
+		Role:    RoleUser,
+		Content: fmt.Sprintf("%s: %s", label, msg.Content),
+	}
+}
+
+// fallbackToolMessages 将所有工具结果消息降级为用户消息
+func fallbackToolMessages(messages []Message) []Message {
+	converted := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleTool {
+			msg = toolResultAsUser(msg)
+		}
+		converted = append(converted, msg)
+	}
+	return converted
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// streamBuffer 流式输出通道的缓冲大小
+const streamBuffer = 16
+
+// Stream 流式回复。Chunks 按顺序输出文本分段，生成结束、中途失败或上下文取消时关闭；
+// Chunks 关闭后 Err 返回提前结束的原因，回复完整时为 nil
+type Stream struct {
+	Chunks <-chan string
+
+	chunks chan string
+	// err 在关闭通道之前写入，读取方在通道关闭后读取
+	err error
+}
+
+func newStream(buffer int) *Stream {
+	chunks := make(chan string, buffer)
+	return &Stream{Chunks: chunks, chunks: chunks}
+}
+
+// Err 返回流式输出提前结束的原因，只能在 Chunks 关闭后调用。返回非 nil 时已收到的分段不是完整回复
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// send 发送一段内容，上下文取消时记录取消原因并返回 false
+func (s *Stream) send(ctx context.Context, chunk string) bool {
+	if chunk == "" {
+		return true
+	}
+	select {
+	case s.chunks <- chunk:
+		return true
+	case <-ctx.Done():
+		s.err = ctx.Err()
+		return false
+	}
+}
+
+// fail 记录生成中途失败的原因，调用方随后关闭通道
+func (s *Stream) fail(provider string, err error) {
+	log.Printf("❌ %s 流式输出中断: %v", provider, err)
+	s.err = fmt.Errorf("%s stream interrupted: %w", provider, err)
+}
+
+// close 结束流式输出，上下文已取消时记录取消原因
+func (s *Stream) close(ctx context.Context) {
+	if s.err == nil && ctx.Err() != nil {
+		s.err = ctx.Err()
+	}
+	close(s.chunks)
+}
+
+// streamWhole 为不支持流式输出的提供商实现 ChatStream：完整回复生成后作为单个分段发送
+func streamWhole(ctx context.Context, client Client, messages []Message) (*Stream, error) {
+	response, err := client.Chat(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := newStream(1)
+	stream.chunks <- response
+	close(stream.chunks)
+	return stream, nil
+}
+
+// splitChunks 将文本按字符切分为最多 n 段，用于模拟流式输出
+func splitChunks(text string, n int) []string {
+	runes := []rune(text)
+	if n <= 1 || len(runes) <= n {
+		return []string{text}
+	}
+
+	size := (len(runes) + n - 1) / n
+	parts := make([]string, 0, n)
+	for start := 0; start < len(runes); start += size {
+		end := min(start+size, len(runes))
+		parts = append(parts, string(runes[start:end]))
+	}
+	return parts
+}
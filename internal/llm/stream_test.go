@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"qng_agent/internal/config"
+)
+
+// redirectTransport 将请求转发到测试服务器，用于固定地址的 Anthropic 接口
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// streamServer 以 200 返回固定的流式响应体
+func streamServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// collect 读取全部分段，返回拼接的文本与 Err
+func collect(stream *Stream) (string, error) {
+	var text strings.Builder
+	for chunk := range stream.Chunks {
+		text.WriteString(chunk)
+	}
+	return text.String(), stream.Err()
+}
+
+func openAIEvent(content string) string {
+	return fmt.Sprintf("data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", content)
+}
+
+func anthropicEvent(text string) string {
+	return fmt.Sprintf("data: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": %q}}\n\n", text)
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		body     string
+		wantText string
+		wantErr  bool
+	}{
+		{name: "openai complete", provider: ProviderOpenAI, body: openAIEvent("你") + openAIEvent("好") + "data: [DONE]\n\n", wantText: "你好"},
+		{name: "openai truncated", provider: ProviderOpenAI, body: openAIEvent("你"), wantText: "你", wantErr: true},
+		{name: "openai malformed chunk", provider: ProviderOpenAI, body: openAIEvent("你") + "data: {not json\n\n", wantText: "你", wantErr: true},
+		{
+			name: "anthropic complete", provider: ProviderAnthropic,
+			body:     anthropicEvent("你") + anthropicEvent("好") + "data: {\"type\": \"message_stop\"}\n\n",
+			wantText: "你好",
+		},
+		{
+			name: "anthropic error event", provider: ProviderAnthropic,
+			body:     anthropicEvent("你") + "data: {\"type\": \"error\", \"error\": {\"type\": \"overloaded_error\", \"message\": \"Overloaded\"}}\n\n",
+			wantText: "你", wantErr: true,
+		},
+		{name: "anthropic truncated", provider: ProviderAnthropic, body: anthropicEvent("你"), wantText: "你", wantErr: true},
+		{name: "ollama complete", provider: "ollama", body: `{"message": {"content": "你"}}` + "\n" + `{"message": {"content": "好"}, "done": true}`, wantText: "你好"},
+		{name: "ollama truncated", provider: "ollama", body: `{"message": {"content": "你"}}`, wantText: "你", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := streamServer(t, tt.body)
+			var client Client
+			switch tt.provider {
+			case ProviderOpenAI:
+				client = newTestOpenAIClient(t, server.URL, 0, 0)
+			case ProviderAnthropic:
+				target, _ := url.Parse(server.URL)
+				client = &AnthropicClient{
+					config: config.AnthropicConfig{APIKey: "test", Model: "claude-test"},
+					client: &http.Client{Transport: redirectTransport{target: target}},
+				}
+			default:
+				ollama, err := NewOllamaClient(map[string]string{"ollama_base_url": server.URL})
+				if err != nil {
+					t.Fatalf("NewOllamaClient: %v", err)
+				}
+				client = ollama
+			}
+
+			stream, err := client.ChatStream(context.Background(), []Message{{Role: RoleUser, Content: "你好"}})
+			if err != nil {
+				t.Fatalf("ChatStream: %v", err)
+			}
+			text, err := collect(stream)
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Err() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChatStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewMockClient().ChatStream(ctx, []Message{{Role: RoleUser, Content: "你好"}})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	cancel()
+	if _, err := collect(stream); err == nil {
+		t.Error("Err() = nil after the context was cancelled")
+	}
+}
@@ -0,0 +1,41 @@
+package llm
+
+import "context"
+
+// Tool 提供给模型调用的函数，Parameters 为参数的 JSON Schema
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+	// Strict 要求模型严格按照 Parameters 生成参数（所有属性必填、不允许额外属性）
+	Strict bool `json:"strict,omitempty"`
+}
+
+// ToolCall 模型返回的函数调用，Arguments 为 JSON 编码的参数
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResponse 带工具的对话结果：模型可能直接回复文本，也可能返回一个或多个函数调用
+type ToolResponse struct {
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCallByName 返回指定名称的第一个函数调用，没有时返回 nil
+func (r *ToolResponse) ToolCallByName(name string) *ToolCall {
+	for i := range r.ToolCalls {
+		if r.ToolCalls[i].Name == name {
+			return &r.ToolCalls[i]
+		}
+	}
+	return nil
+}
+
+// ToolClient 支持函数调用的LLM客户端，调用方通过类型断言判断客户端是否支持
+type ToolClient interface {
+	// ChatWithTools 携带工具定义发起对话。只提供一个工具时要求模型必须调用该工具
+	ChatWithTools(ctx context.Context, messages []Message, tools []Tool, opts ...ChatOption) (*ToolResponse, error)
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"unicode"
+)
+
+// Usage 单次LLM调用的token用量
+type Usage struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	// Estimated 提供商没有返回用量（如流式输出或模拟客户端）时按文本长度估算
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+type usageRecorderKey struct{}
+
+// WithUsageRecorder 返回携带用量回调的上下文，客户端每完成一次调用回调一次用量
+func WithUsageRecorder(ctx context.Context, fn func(Usage)) context.Context {
+	return context.WithValue(ctx, usageRecorderKey{}, fn)
+}
+
+// recordUsage 将用量交给上下文中的回调，未设置回调时忽略
+func recordUsage(ctx context.Context, usage Usage) {
+	fn, _ := ctx.Value(usageRecorderKey{}).(func(Usage))
+	if fn == nil {
+		return
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	fn(usage)
+}
+
+// recordEstimatedUsage 按消息与回复文本估算并记录用量
+func recordEstimatedUsage(ctx context.Context, provider, model string, messages []Message, completion string) {
+	prompt := 0
+	for _, msg := range messages {
+		prompt += EstimateTokens(msg.Content)
+	}
+	recordUsage(ctx, Usage{
+		Provider:         provider,
+		Model:            model,
+		PromptTokens:     prompt,
+		CompletionTokens: EstimateTokens(completion),
+		Estimated:        true,
+	})
+}
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符各算一个token，其它字符约每4个算一个
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// consoleTimeFormat 控制台格式的时间戳
+const consoleTimeFormat = "2006-01-02 15:04:05.000"
+
+// consoleHandler 人类可读的单行格式：时间 级别 [关联ID] 节点: 消息 key=value ...
+type consoleHandler struct {
+	mu      *sync.Mutex
+	out     io.Writer
+	options *slog.HandlerOptions
+	// attrs WithAttrs 附加的字段，键已带上分组前缀
+	attrs  []slog.Attr
+	prefix string
+}
+
+func newConsoleHandler(out io.Writer, options *slog.HandlerOptions) *consoleHandler {
+	return &consoleHandler{mu: &sync.Mutex{}, out: out, options: options}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minimum := slog.LevelInfo
+	if h.options.Level != nil {
+		minimum = h.options.Level.Level()
+	}
+	return level >= minimum
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var correlationID, node string
+	fields := make([]slog.Attr, 0, len(h.attrs)+record.NumAttrs())
+	collect := func(attr slog.Attr) {
+		switch attr.Key {
+		case CorrelationIDKey:
+			correlationID = attr.Value.String()
+		case NodeKey:
+			node = attr.Value.String()
+		default:
+			fields = append(fields, attr)
+		}
+	}
+	for _, attr := range h.attrs {
+		collect(attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		attr.Key = h.prefix + attr.Key
+		collect(attr)
+		return true
+	})
+
+	var buf bytes.Buffer
+	t := record.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf.WriteString(t.Format(consoleTimeFormat))
+	fmt.Fprintf(&buf, " %-5s ", record.Level.String())
+	if correlationID != "" {
+		fmt.Fprintf(&buf, "[%s] ", correlationID)
+	}
+	if node != "" {
+		buf.WriteString(node)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(record.Message)
+	for _, attr := range fields {
+		fmt.Fprintf(&buf, " %s=%v", attr.Key, attr.Value.Resolve())
+	}
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(buf.Bytes())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		attr.Key = h.prefix + attr.Key
+		clone.attrs = append(clone.attrs, attr)
+	}
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
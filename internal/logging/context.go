@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 日志中的字段名
+const (
+	CorrelationIDKey = "correlation_id"
+	NodeKey          = "node"
+)
+
+// CorrelationIDHeader 服务之间传递关联ID的 HTTP 请求头
+const CorrelationIDHeader = "X-Correlation-ID"
+
+type correlationIDKey struct{}
+
+type nodeKey struct{}
+
+// WithCorrelationID 返回携带关联ID的上下文，id 为空时原样返回
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID 返回上下文中的关联ID，未设置时使用工作流上下文中的 workflow_id
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(correlationIDKey{}).(string); ok {
+		return id
+	}
+	id, _ := ctx.Value("workflow_id").(string)
+	return id
+}
+
+// EnsureCorrelationID 上下文中没有关联ID时生成一个
+func EnsureCorrelationID(ctx context.Context) context.Context {
+	if CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, uuid.New().String())
+}
+
+// WithNode 返回携带 LangGraph 节点名的上下文，节点内的日志据此标注所属节点
+func WithNode(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nodeKey{}, name)
+}
+
+// Node 返回上下文中的节点名
+func Node(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	name, _ := ctx.Value(nodeKey{}).(string)
+	return name
+}
+
+// Middleware 从 X-Correlation-ID 请求头读取关联ID，没有时生成一个，写入请求上下文并在响应头中返回
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := WithCorrelationID(c.Request.Context(), c.GetHeader(CorrelationIDHeader))
+		ctx = EnsureCorrelationID(ctx)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(CorrelationIDHeader, CorrelationID(ctx))
+		c.Next()
+	}
+}
+
+// SetHeader 将上下文中的关联ID写入发往其它服务的请求
+func SetHeader(ctx context.Context, req *http.Request) {
+	if id := CorrelationID(ctx); id != "" {
+		req.Header.Set(CorrelationIDHeader, id)
+	}
+}
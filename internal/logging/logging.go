@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"qng_agent/internal/config"
+)
+
+// 日志格式
+const (
+	// FormatConsole 人类可读的单行格式，默认值
+	FormatConsole = "console"
+	// FormatJSON 每行一个 JSON 对象，便于日志系统检索
+	FormatJSON = "json"
+	// FormatText slog 的 key=value 格式
+	FormatText = "text"
+)
+
+// Setup 按日志配置创建结构化日志并设为默认日志。log 包的输出同样经过该 handler，
+// 以 INFO 级别记录。输出到文件时返回的 io.Closer 用于关闭文件，否则为 nil
+func Setup(cfg config.LoggingConfig) (io.Closer, error) {
+	out, closer, err := openOutput(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: parseLevel(cfg.Level)}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, options)
+	case FormatText:
+		handler = slog.NewTextHandler(out, options)
+	case "", FormatConsole:
+		handler = newConsoleHandler(out, options)
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("unknown logging format %q, use console, json or text", cfg.Format)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{Handler: handler}))
+	return closer, nil
+}
+
+// openOutput 按 logging.output 选择输出：stdout（默认）、stderr 或 file（写入 logging.file）
+func openOutput(cfg config.LoggingConfig) (io.Writer, io.Closer, error) {
+	switch strings.ToLower(cfg.Output) {
+	case "", "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	case "file":
+		if cfg.File == "" {
+			return nil, nil, fmt.Errorf("logging.file is required when logging.output is file")
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		return file, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown logging output %q, use stdout, stderr or file", cfg.Output)
+	}
+}
+
+// parseLevel 解析日志级别，无法识别时使用 info
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler 从上下文读取关联ID与节点名并附加到每条日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		record.AddAttrs(slog.String(CorrelationIDKey, id))
+	}
+	if node := Node(ctx); node != "" {
+		record.AddAttrs(slog.String(NodeKey, node))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 6_000_000, time.Local)
+	tests := []struct {
+		name    string
+		handler func(h *consoleHandler) slog.Handler
+		level   slog.Level
+		attrs   []slog.Attr
+		want    string
+	}{
+		{
+			name:    "message with fields",
+			handler: func(h *consoleHandler) slog.Handler { return h },
+			level:   slog.LevelInfo,
+			attrs:   []slog.Attr{slog.String("tx_hash", "0xab"), slog.Int("count", 2)},
+			want:    "2026-01-02 03:04:05.006 INFO  ✅ 完成 tx_hash=0xab count=2\n",
+		},
+		{
+			name:    "correlation id and node",
+			handler: func(h *consoleHandler) slog.Handler { return h },
+			level:   slog.LevelWarn,
+			attrs:   []slog.Attr{slog.String(CorrelationIDKey, "wf_1"), slog.String(NodeKey, "swap_executor"), slog.Bool("retry", true)},
+			want:    "2026-01-02 03:04:05.006 WARN  [wf_1] swap_executor: ✅ 完成 retry=true\n",
+		},
+		{
+			name: "attrs and group",
+			handler: func(h *consoleHandler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("service", "chain")}).WithGroup("rpc").WithAttrs([]slog.Attr{slog.String("url", "http://node")})
+			},
+			level: slog.LevelError,
+			attrs: []slog.Attr{slog.String("method", "eth_call")},
+			want:  "2026-01-02 03:04:05.006 ERROR ✅ 完成 service=chain rpc.url=http://node rpc.method=eth_call\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := tt.handler(newConsoleHandler(&buf, &slog.HandlerOptions{}))
+			record := slog.NewRecord(at, tt.level, "✅ 完成", 0)
+			record.AddAttrs(tt.attrs...)
+			if err := handler.Handle(context.Background(), record); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsoleHandlerLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		level slog.Leveler
+		debug bool
+		info  bool
+	}{
+		{name: "default info", info: true},
+		{name: "debug", level: slog.LevelDebug, debug: true, info: true},
+		{name: "warn", level: slog.LevelWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newConsoleHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: tt.level})
+			if got := handler.Enabled(context.Background(), slog.LevelDebug); got != tt.debug {
+				t.Errorf("debug enabled = %v, want %v", got, tt.debug)
+			}
+			if got := handler.Enabled(context.Background(), slog.LevelInfo); got != tt.info {
+				t.Errorf("info enabled = %v, want %v", got, tt.info)
+			}
+		})
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]any
+	}{
+		{name: "no context values", ctx: context.Background(), want: map[string]any{}},
+		{
+			name: "correlation id and node",
+			ctx:  WithNode(WithCorrelationID(context.Background(), "req_1"), "task_decomposer"),
+			want: map[string]any{CorrelationIDKey: "req_1", NodeKey: "task_decomposer"},
+		},
+		{
+			// 没有关联ID时使用工作流上下文中的 workflow_id
+			name: "workflow id fallback",
+			ctx:  context.WithValue(context.Background(), "workflow_id", "wf_1"),
+			want: map[string]any{CorrelationIDKey: "wf_1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(&contextHandler{Handler: slog.NewJSONHandler(&buf, nil)}).With("service", "chain")
+			logger.InfoContext(tt.ctx, "message")
+
+			var fields map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+				t.Fatalf("decode %q: %v", buf.String(), err)
+			}
+			if fields["service"] != "chain" {
+				t.Errorf("service = %v, want chain", fields["service"])
+			}
+			for _, key := range []string{CorrelationIDKey, NodeKey} {
+				if got, want := fields[key], tt.want[key]; got != want {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{name: "header forwarded", header: "req_from_agent"},
+		{name: "generated"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			router := gin.New()
+			router.Use(Middleware())
+			router.GET("/", func(c *gin.Context) {
+				seen = CorrelationID(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(CorrelationIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if seen == "" {
+				t.Fatal("request context has no correlation id")
+			}
+			if tt.header != "" && seen != tt.header {
+				t.Errorf("correlation id = %q, want %q", seen, tt.header)
+			}
+			if got := rec.Header().Get(CorrelationIDHeader); got != seen {
+				t.Errorf("response header = %q, want %q", got, seen)
+			}
+
+			// 关联ID随请求转发给其它服务
+			outgoing := httptest.NewRequest(http.MethodGet, "/", nil)
+			SetHeader(WithCorrelationID(context.Background(), seen), outgoing)
+			if got := outgoing.Header.Get(CorrelationIDHeader); got != seen {
+				t.Errorf("forwarded header = %q, want %q", got, seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"qng_agent/internal/logging"
+	"qng_agent/internal/qng"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StatusCancelled 用户通过 cancel_workflow 取消的会话
+const StatusCancelled = "cancelled"
+
+// cancellableStatuses 可以取消的会话状态，已结束的会话不能取消
+var cancellableStatuses = map[string]bool{
+	"pending":             true,
+	"running":             true,
+	"waiting_signature":   true,
+	"confirmation_failed": true,
+	StatusInterrupted:     true,
+}
+
+// cancel 关闭会话的 CancelChan，重复调用（如取消后服务停止）不会再次关闭
+func (session *Session) cancel() {
+	session.cancelOnce.Do(func() {
+		close(session.CancelChan)
+	})
+}
+
+// workflowContext 创建工作流执行上下文，CancelChan 关闭时上下文随之取消
+func (s *QNGServer) workflowContext(session *Session) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-session.CancelChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ctx = context.WithValue(ctx, "workflow_id", session.WorkflowID)
+	ctx = context.WithValue(ctx, "session_id", session.ID)
+	ctx = logging.WithCorrelationID(ctx, session.WorkflowID)
+	return ctx, cancel
+}
+
+// abandoned 工作流上下文已被取消（用户取消或服务停止）时放弃执行结果，会话状态保持不变。
+// 取消前已广播的交易不会撤回：结果中的任务进度与仍在等待确认的交易记入会话，避免丢失交易哈希
+func (s *QNGServer) abandoned(ctx context.Context, session *Session, result *qng.ProcessResult, err error) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	log.Printf("🛑 工作流已取消，放弃执行结果: %s", session.ID)
+
+	if result != nil && result.WorkflowContext != nil {
+		if progress := qng.ExtractTaskProgress(result.WorkflowContext); progress != nil {
+			session.update(func() {
+				session.TaskProgress = progress
+			})
+			log.Printf("📋 已记录取消时的任务进度: 完成 %d/%d", len(progress.CompletedTasks), len(progress.Tasks))
+		}
+	}
+
+	var confirmationErr *qng.ConfirmationError
+	if !errors.As(err, &confirmationErr) || confirmationErr.TxHash == "" {
+		return true
+	}
+	// 服务端签名的批量交易以逗号分隔
+	txHashes := strings.Split(confirmationErr.TxHash, ",")
+	log.Printf("📋 记录取消时等待确认的交易: %v", txHashes)
+	now := time.Now().Format(time.RFC3339)
+	session.update(func() {
+		if session.Error != nil && session.Error.TxHash == "" {
+			session.Error.TxHash = txHashes[len(txHashes)-1]
+		}
+		for _, txHash := range txHashes {
+			if !slices.ContainsFunc(session.Transactions, func(tracked TrackedTransaction) bool { return tracked.TxHash == txHash }) {
+				session.Transactions = append(session.Transactions, TrackedTransaction{
+					TxHash:      txHash,
+					TaskID:      confirmationErr.TaskID,
+					SubmittedAt: now,
+				})
+			}
+		}
+	})
+	return true
+}
+
+// cancelWorkflow 取消会话：状态置为 cancelled 并关闭 CancelChan，进行中的 LLM 与 RPC 调用随之放弃。
+// 已广播的交易无法撤回，需要时使用 cancel_transaction
+func (s *QNGServer) cancelWorkflow(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("🛑 取消工作流")
+
+	sessionID, ok := params["session_id"].(string)
+	if !ok {
+		log.Printf("❌ 缺少session_id参数")
+		return nil, missingParam("session_id")
+	}
+
+	session, exists := s.getSession(sessionID)
+
+	if !exists {
+		log.Printf("❌ 会话不存在: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
+	}
+
+	status := session.currentStatus()
+	if !cancellableStatuses[status] {
+		log.Printf("❌ 会话状态不正确: %s", status)
+		return nil, invalidState(session, "session in %s status cannot be cancelled", status)
+	}
+
+	s.recordTaskProgress(session)
+	var progress *qng.TaskProgress
+	var inFlight string
+	session.update(func() {
+		// running 状态下最近提交的交易仍在等待确认，取消后可能上链
+		if status == "running" && len(session.Transactions) > 0 {
+			inFlight = session.Transactions[len(session.Transactions)-1].TxHash
+		}
+		session.SignatureRequest = nil
+		session.Replacement = nil
+		session.Error = &SessionError{
+			Type:    "cancelled",
+			Message: "工作流已被用户取消",
+			TxHash:  inFlight,
+		}
+		progress = session.TaskProgress
+	})
+	s.updateSessionStatus(session, StatusCancelled, "工作流已取消")
+	session.cancel()
+
+	log.Printf("✅ 会话已取消: %s", session.ID)
+
+	result := map[string]any{
+		"session_id":  session.ID,
+		"workflow_id": session.WorkflowID,
+		"status":      StatusCancelled,
+		"message":     "工作流已取消，已广播的交易不受影响",
+	}
+	if inFlight != "" {
+		result["tx_hash"] = inFlight
+	}
+	if progress != nil {
+		result["completed_tasks"] = progress.CompletedTasks
+		result["tx_hashes"] = progress.TxHashes
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+)
+
+// waitForSession 等待会话满足条件，超时则测试失败
+func waitForSession(t *testing.T, session *Session, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		session.mu.RLock()
+		ok := cond()
+		session.mu.RUnlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("session %s: timed out waiting for %s (status %s)", session.ID, what, session.currentStatus())
+}
+
+// TestCancelWorkflow 取消等待签名或确认中的工作流：会话置为 cancelled 并记录仍在等待确认的交易，
+// 之后被放弃的执行结果不覆盖会话状态
+func TestCancelWorkflow(t *testing.T) {
+	txHash := fmt.Sprintf("0x%064x", 0xca1)
+
+	tests := []struct {
+		name string
+		// sign 为 true 时先提交签名，在交易确认过程中取消
+		sign       bool
+		wantTxHash string
+	}{
+		{name: "waiting signature"},
+		{name: "running", sign: true, wantTxHash: txHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, node := newSequenceChain(t)
+			release := make(chan struct{})
+			node.On("eth_getTransactionReceipt", func(params []interface{}) (interface{}, *rpc.RPCError) {
+				<-release
+				return nil, nil
+			})
+			t.Cleanup(func() { close(release) })
+			server := NewQNGServerWithChain(config.QNGConfig{}, chain)
+			ctx := context.Background()
+
+			created, err := server.executeWorkflow(ctx, map[string]any{"message": "兑换1 MEER的MTK", "user_address": sequenceAddress})
+			if err != nil {
+				t.Fatalf("execute_workflow: %v", err)
+			}
+			session, _ := server.getSession(created.(map[string]any)["session_id"].(string))
+			waitForSession(t, session, "signature request", func() bool { return session.Status == "waiting_signature" })
+
+			if tt.sign {
+				if _, err := server.submitSignature(ctx, map[string]any{"session_id": session.ID, "signature": txHash}); err != nil {
+					t.Fatalf("submit_signature: %v", err)
+				}
+				waitForSession(t, session, "confirmation", func() bool { return session.Status == "running" })
+			}
+
+			result, err := server.cancelWorkflow(ctx, map[string]any{"session_id": session.ID})
+			if err != nil {
+				t.Fatalf("cancel_workflow: %v", err)
+			}
+			if got, _ := result.(map[string]any)["tx_hash"].(string); got != tt.wantTxHash {
+				t.Errorf("cancel result tx_hash = %q, want %q", got, tt.wantTxHash)
+			}
+
+			// 工作流在取消后退出，放弃的执行结果不能覆盖 cancelled 状态
+			time.Sleep(200 * time.Millisecond)
+			session.mu.RLock()
+			defer session.mu.RUnlock()
+			if session.Status != StatusCancelled || session.Error == nil || session.Error.Type != "cancelled" {
+				t.Fatalf("session after cancel = %s %+v, want cancelled", session.Status, session.Error)
+			}
+			if session.Error.TxHash != tt.wantTxHash {
+				t.Errorf("error tx_hash = %q, want %q", session.Error.TxHash, tt.wantTxHash)
+			}
+			if session.SignatureRequest != nil {
+				t.Error("signature request kept after cancel")
+			}
+		})
+	}
+}
+
+// TestAbandonedRecordsInFlightTransactions 服务端签名广播后被取消时，错误中的交易哈希记入会话
+func TestAbandonedRecordsInFlightTransactions(t *testing.T) {
+	tracked := fmt.Sprintf("0x%064x", 0xb1)
+	inFlight := fmt.Sprintf("0x%064x", 0xb2)
+	session := &Session{
+		ID:           "session_abandoned",
+		Status:       StatusCancelled,
+		Error:        &SessionError{Type: "cancelled"},
+		Transactions: []TrackedTransaction{{TxHash: tracked}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := fmt.Errorf("continue with signature failed: %w", &qng.ConfirmationError{
+		Kind:   qng.ConfirmationRPCError,
+		TxHash: tracked + "," + inFlight,
+		Err:    context.Canceled,
+	})
+	if !(&QNGServer{}).abandoned(ctx, session, nil, err) {
+		t.Fatal("abandoned = false for a cancelled context")
+	}
+
+	if session.Status != StatusCancelled {
+		t.Errorf("status = %q, want %s", session.Status, StatusCancelled)
+	}
+	if session.Error.TxHash != inFlight {
+		t.Errorf("error tx_hash = %q, want %q", session.Error.TxHash, inFlight)
+	}
+	var hashes []string
+	for _, tx := range session.Transactions {
+		hashes = append(hashes, tx.TxHash)
+	}
+	if want := []string{tracked, inFlight}; !slices.Equal(hashes, want) {
+		t.Errorf("transactions = %v, want %v", hashes, want)
+	}
+}
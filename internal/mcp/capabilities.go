@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"fmt"
+	"log"
+)
+
+// CapabilityErrorKey 客户端能力列表中标记服务查询失败的字段
+const CapabilityErrorKey = "error"
+
+// capabilitySource 单个服务的能力查询
+type capabilitySource struct {
+	name  string
+	fetch func() []Capability
+}
+
+// fetchCapabilities 查询单个服务的能力，服务内部 panic 时转为错误，不影响其它服务
+func fetchCapabilities(source capabilitySource) (capabilities []Capability, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("capabilities of %s panicked: %v", source.name, r)
+		}
+	}()
+	return source.fetch(), nil
+}
+
+// CapabilityReport 按服务汇总能力：Capabilities 只包含正常响应的服务，
+// Errors 记录已启用但初始化或查询失败的服务
+func (s *Server) CapabilityReport() (map[string][]Capability, map[string]string) {
+	capabilities := make(map[string][]Capability)
+	errs := make(map[string]string)
+
+	var sources []capabilitySource
+	if s.qngServer != nil {
+		sources = append(sources, capabilitySource{name: "qng", fetch: s.qngServer.GetCapabilities})
+	} else if s.config.QNG.Enabled && s.initErr != nil {
+		errs["qng"] = fmt.Sprintf("initialization failed: %v", s.initErr)
+	}
+	if s.metamaskServer != nil {
+		sources = append(sources, capabilitySource{name: "metamask", fetch: s.metamaskServer.GetCapabilities})
+	}
+
+	for _, source := range sources {
+		log.Printf("📋 获取%s服务能力", source.name)
+		caps, err := fetchCapabilities(source)
+		if err != nil {
+			log.Printf("❌ 获取%s服务能力失败: %v", source.name, err)
+			errs[source.name] = err.Error()
+			continue
+		}
+		capabilities[source.name] = caps
+	}
+
+	return capabilities, errs
+}
+
+// mergeCapabilityErrors 将服务端报告的失败服务加入能力列表，标记为带错误的空条目
+func mergeCapabilityErrors(capabilities map[string]interface{}, errs map[string]string) {
+	for server, message := range errs {
+		log.Printf("⚠️  MCP服务 %s 能力不可用: %s", server, message)
+		capabilities[server] = map[string]interface{}{
+			CapabilityErrorKey: message,
+			"capabilities":     []interface{}{},
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"qng_agent/internal/config"
+	"qng_agent/internal/logging"
 	"time"
 )
 
@@ -16,6 +17,7 @@ type HTTPClient struct {
 	baseURL    string
 	httpClient *http.Client
 	config     config.MCPConfig
+	retry      retryPolicy
 }
 
 // MCPRequest MCP 请求结构
@@ -25,10 +27,31 @@ type MCPRequest struct {
 	Params map[string]interface{} `json:"params"`
 }
 
-// MCPResponse MCP 响应结构
+// MCPResponse MCP 响应结构，失败时 Code 与 Details 描述结构化错误
 type MCPResponse struct {
-	Result interface{} `json:"result"`
-	Error  string      `json:"error,omitempty"`
+	Result  interface{}    `json:"result"`
+	Error   string         `json:"error,omitempty"`
+	Code    ErrorCode      `json:"code,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// responseError 从响应中还原结构化错误，旧版本服务端未返回错误码时按状态码推断
+func (r *MCPResponse) responseError(status int) *MCPError {
+	code := r.Code
+	if code == "" {
+		code = codeForStatus(status)
+	}
+	return &MCPError{Code: code, Message: r.Error, Details: r.Details}
+}
+
+// codeForStatus 按 HTTP 状态码推断错误码
+func codeForStatus(status int) ErrorCode {
+	for code, s := range httpStatus {
+		if s == status {
+			return code
+		}
+	}
+	return CodeInternal
 }
 
 // NewHTTPClient 创建新的 MCP HTTP 客户端
@@ -43,20 +66,28 @@ func NewHTTPClient(config config.MCPConfig) *HTTPClient {
 			Timeout: time.Duration(config.Timeout) * time.Second,
 		},
 		config: config,
+		retry:  newRetryPolicy(config.Retry),
 	}
 }
 
-// Call 调用 MCP 服务器方法
+// Call 调用 MCP 服务器方法，幂等方法在瞬时失败时按配置重试
 func (c *HTTPClient) Call(ctx context.Context, server, method string, params map[string]interface{}) (interface{}, error) {
 	log.Printf("🔄 MCP服务器调用")
 	log.Printf("🔧 服务: %s", server)
 	log.Printf("🛠️  方法: %s", method)
 	log.Printf("📋 参数: %v", params)
 	
+	return c.retry.do(ctx, server+"."+method, c.retry.attemptsFor(method), func() (any, error) {
+		return c.callOnce(ctx, server, method, params)
+	})
+}
+
+// callOnce 执行一次 MCP 调用，瞬时错误会被标记为可重试
+func (c *HTTPClient) callOnce(ctx context.Context, server, method string, params map[string]interface{}) (interface{}, error) {
 	// 检查服务器连接
 	if !c.isServerRunning() {
 		log.Printf("❌ MCP服务器未运行")
-		return nil, fmt.Errorf("MCP server is not running")
+		return nil, transient(fmt.Errorf("MCP server is not running"))
 	}
 	
 	// 构建请求
@@ -79,28 +110,42 @@ func (c *HTTPClient) Call(ctx context.Context, server, method string, params map
 	}
 	
 	req.Header.Set("Content-Type", "application/json")
+	logging.SetHeader(ctx, req)
 	
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		log.Printf("❌ HTTP请求失败: %v", err)
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		}
+		return nil, transient(fmt.Errorf("HTTP request failed: %w", err))
 	}
 	defer resp.Body.Close()
 	
+	// 解析响应，失败的响应体中携带错误码
+	var mcpResp MCPResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&mcpResp)
+	
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("❌ HTTP状态码错误: %d", resp.StatusCode)
-		return nil, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+		var err error = fmt.Errorf("HTTP error: %d", resp.StatusCode)
+		if decodeErr == nil && mcpResp.Error != "" {
+			log.Printf("❌ MCP错误 [%s]: %s", mcpResp.Code, mcpResp.Error)
+			err = mcpResp.responseError(resp.StatusCode)
+		}
+		if retryableStatus(resp.StatusCode) {
+			return nil, transient(err)
+		}
+		return nil, err
 	}
 	
-	// 解析响应
-	var mcpResp MCPResponse
-	if err := json.NewDecoder(resp.Body).Decode(&mcpResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
 	}
 	
 	if mcpResp.Error != "" {
-		log.Printf("❌ MCP错误: %s", mcpResp.Error)
-		return nil, fmt.Errorf("MCP error: %s", mcpResp.Error)
+		log.Printf("❌ MCP错误 [%s]: %s", mcpResp.Code, mcpResp.Error)
+		return nil, mcpResp.responseError(resp.StatusCode)
 	}
 	
 	log.Printf("✅ MCP调用成功")
@@ -119,39 +164,60 @@ func (c *HTTPClient) Stop() error {
 	return nil
 }
 
-// GetCapabilities 获取服务器能力
+// GetCapabilities 获取服务器能力，瞬时失败时按配置重试
 func (c *HTTPClient) GetCapabilities() map[string]interface{} {
 	ctx := context.Background()
 	
+	result, err := c.retry.do(ctx, "capabilities", c.retry.maxAttempts, func() (any, error) {
+		return c.fetchCapabilities(ctx)
+	})
+	if err != nil {
+		return make(map[string]interface{})
+	}
+	return result.(map[string]interface{})
+}
+
+// fetchCapabilities 执行一次能力查询
+func (c *HTTPClient) fetchCapabilities(ctx context.Context) (map[string]interface{}, error) {
 	url := c.baseURL + "/api/mcp/capabilities"
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Printf("❌ 创建能力查询请求失败: %v", err)
-		return make(map[string]interface{})
+		return nil, err
 	}
+	logging.SetHeader(ctx, req)
 	
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		log.Printf("❌ 能力查询请求失败: %v", err)
-		return make(map[string]interface{})
+		return nil, transient(err)
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("❌ 能力查询状态码错误: %d", resp.StatusCode)
-		return make(map[string]interface{})
+		err := fmt.Errorf("HTTP error: %d", resp.StatusCode)
+		if retryableStatus(resp.StatusCode) {
+			return nil, transient(err)
+		}
+		return nil, err
 	}
 	
 	var response struct {
 		Capabilities map[string]interface{} `json:"capabilities"`
+		Errors       map[string]string      `json:"errors"`
 	}
 	
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		log.Printf("❌ 解析能力响应失败: %v", err)
-		return make(map[string]interface{})
+		return nil, err
 	}
 	
-	return response.Capabilities
+	if response.Capabilities == nil {
+		response.Capabilities = make(map[string]interface{})
+	}
+	mergeCapabilityErrors(response.Capabilities, response.Errors)
+	return response.Capabilities, nil
 }
 
 // isServerRunning 检查服务器是否运行
@@ -165,6 +231,7 @@ func (c *HTTPClient) isServerRunning() bool {
 		log.Printf("❌ 创建健康检查请求失败: %v", err)
 		return false
 	}
+	logging.SetHeader(ctx, req)
 	
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorCode MCP 错误码，取值稳定，客户端据此区分错误类型而不必解析错误消息
+type ErrorCode string
+
+const (
+	// CodeNotFound 会话、工作流、代币等资源不存在
+	CodeNotFound ErrorCode = "not_found"
+	// CodeNotOwned 会话属于其它副本，请求需要路由到创建该会话的实例
+	CodeNotOwned ErrorCode = "not_owned"
+	// CodeInvalidState 资源状态不允许该操作，如会话未在等待签名
+	CodeInvalidState ErrorCode = "invalid_state"
+	// CodeValidation 参数缺失或无效
+	CodeValidation ErrorCode = "validation"
+	// CodeForbidden 方法不在服务的允许列表中
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeUnavailable 服务未运行或未启用
+	CodeUnavailable ErrorCode = "unavailable"
+	// CodeUpstreamRPC 链上 RPC 调用失败
+	CodeUpstreamRPC ErrorCode = "upstream_rpc"
+	// CodeInternal 未分类的内部错误
+	CodeInternal ErrorCode = "internal"
+)
+
+// httpStatus 错误码对应的 HTTP 状态码
+var httpStatus = map[ErrorCode]int{
+	CodeNotFound:     http.StatusNotFound,
+	CodeNotOwned:     http.StatusMisdirectedRequest,
+	CodeInvalidState: http.StatusConflict,
+	CodeValidation:   http.StatusBadRequest,
+	CodeForbidden:    http.StatusForbidden,
+	CodeUnavailable:  http.StatusServiceUnavailable,
+	CodeUpstreamRPC:  http.StatusBadGateway,
+	CodeInternal:     http.StatusInternalServerError,
+}
+
+// MCPError 带错误码的结构化错误，由 QNGServer.Call 与 HTTP 接口返回，HTTPClient 从响应中还原
+type MCPError struct {
+	Code    ErrorCode      `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+	// cause 原始错误，保留 errors.Is 对 ErrSessionNotOwned 等哨兵错误的判断
+	cause error
+}
+
+func (e *MCPError) Error() string {
+	return e.Message
+}
+
+func (e *MCPError) Unwrap() error {
+	return e.cause
+}
+
+// WithDetail 附加一项错误详情
+func (e *MCPError) WithDetail(key string, value any) *MCPError {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// HTTPStatus 错误码对应的 HTTP 状态码，未知错误码按内部错误处理
+func (e *MCPError) HTTPStatus() int {
+	if status, ok := httpStatus[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// newError 按格式化消息创建结构化错误，消息中以 %w 包装的错误可通过 errors.Is/As 判断
+func newError(code ErrorCode, format string, args ...any) *MCPError {
+	err := fmt.Errorf(format, args...)
+	return &MCPError{Code: code, Message: err.Error(), cause: errors.Unwrap(err)}
+}
+
+// missingParam 缺少必填参数
+func missingParam(name string) *MCPError {
+	return newError(CodeValidation, "%s parameter required", name).WithDetail("param", name)
+}
+
+// invalidState 会话状态不允许该操作
+func invalidState(session *Session, format string, args ...any) *MCPError {
+	return newError(CodeInvalidState, format, args...).WithDetail("status", session.currentStatus())
+}
+
+// upstreamError 链上 RPC 调用失败，已是结构化错误时原样返回
+func upstreamError(err error) error {
+	var mcpErr *MCPError
+	if errors.As(err, &mcpErr) {
+		return err
+	}
+	return newError(CodeUpstreamRPC, "%w", err)
+}
+
+// AsMCPError 将任意错误转换为结构化错误，未分类的错误使用 CodeInternal
+func AsMCPError(err error) *MCPError {
+	var mcpErr *MCPError
+	if errors.As(err, &mcpErr) {
+		if mcpErr.Message == err.Error() {
+			return mcpErr
+		}
+		// 外层包装了上下文时保留完整消息
+		return &MCPError{Code: mcpErr.Code, Message: err.Error(), Details: mcpErr.Details, cause: err}
+	}
+	return &MCPError{Code: CodeInternal, Message: err.Error(), cause: err}
+}
+
+// CodeOf 返回错误的错误码，nil 返回空字符串
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	return AsMCPError(err).Code
+}
+
+// ErrorResponse HTTP 接口的错误响应：按错误码选择状态码，error 字段保持为字符串以兼容旧客户端
+func ErrorResponse(err error) (int, map[string]any) {
+	mcpErr := AsMCPError(err)
+	body := map[string]any{
+		"error": mcpErr.Message,
+		"code":  mcpErr.Code,
+	}
+	if len(mcpErr.Details) > 0 {
+		body["details"] = mcpErr.Details
+	}
+	return mcpErr.HTTPStatus(), body
+}
@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InstanceEnv 未在配置中指定实例ID时读取的环境变量
+const InstanceEnv = "QNG_INSTANCE_ID"
+
+// ErrSessionNotOwned 会话属于其它副本，请求需要路由到创建该会话的实例
+var ErrSessionNotOwned = errors.New("session belongs to another instance")
+
+// resolveInstanceID 确定本副本的实例ID：配置 > 环境变量 > 主机名 > 随机值
+func resolveInstanceID(configured string) string {
+	for _, candidate := range []string{configured, os.Getenv(InstanceEnv), hostname()} {
+		if id := sanitizeInstanceID(candidate); id != "" {
+			return id
+		}
+	}
+	return uuid.NewString()[:8]
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// sanitizeInstanceID 只保留字母、数字与连字符，下划线用作ID各部分的分隔符
+func sanitizeInstanceID(id string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(id)), "-")
+}
+
+// newID 生成带实例命名空间的ID，格式为 <prefix>_<instance>_<unixnano>
+func (s *QNGServer) newID(prefix string) string {
+	return fmt.Sprintf("%s_%s_%d", prefix, s.instanceID, time.Now().UnixNano())
+}
+
+// InstanceFromID 返回会话或工作流ID所属的实例，旧格式的ID（无实例部分）返回空字符串
+func InstanceFromID(id string) string {
+	parts := strings.Split(id, "_")
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// sessionNotFound 会话不存在时的错误，ID 属于其它实例时返回 ErrSessionNotOwned 以便调用方重新路由
+func (s *QNGServer) sessionNotFound(id string) error {
+	if owner := InstanceFromID(id); owner != "" && owner != s.instanceID {
+		return newError(CodeNotOwned, "%w: %s is owned by instance %s", ErrSessionNotOwned, id, owner).WithDetail("instance_id", owner)
+	}
+	return newError(CodeNotFound, "session not found: %s", id).WithDetail("session_id", id)
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale 能力描述的源语言，目录中缺少的条目也回退到该语言
+const DefaultLocale = "zh"
+
+// capabilityCatalogs 能力描述的翻译目录，键为 服务.方法 或 服务.方法.参数。
+// 能力与参数名称保持不变，只翻译描述。
+var capabilityCatalogs = map[string]map[string]string{
+	"en": {
+		"qng.execute_workflow":                          "Execute a QNG workflow",
+		"qng.execute_workflow.message":                  "User message",
+		"qng.execute_workflow.dry_run":                  "Dry run: return the ordered planned transactions (with gas and value) without requesting signatures",
+		"qng.get_session_status":                        "Get session status",
+		"qng.get_session_status.session_id":             "Session ID",
+		"qng.submit_signature":                          "Submit the user's signature",
+		"qng.submit_signature.session_id":               "Session ID",
+		"qng.submit_signature.signature":                "User signature",
+		"qng.submit_signature.gas_price":                "gasPrice used to rebroadcast the transaction (wei, decimal or 0x hex)",
+		"qng.submit_signature.max_fee_per_gas":          "maxFeePerGas used to rebroadcast the transaction (wei)",
+		"qng.submit_signature.max_priority_fee_per_gas": "maxPriorityFeePerGas used to rebroadcast the transaction (wei)",
+		"qng.submit_signature.gas_limit":                "Gas limit used to rebroadcast the transaction",
+		"qng.resume_workflow":                           "Resume a failed workflow from its completed tasks",
+		"qng.resume_workflow.session_id":                "Session ID",
+		"qng.retry_confirmation":                        "Wait again for a transaction confirmation that timed out or hit an RPC failure",
+		"qng.retry_confirmation.session_id":             "Session ID",
+		"qng.cancel_workflow":                           "Cancel a running or signature-pending workflow; already broadcast transactions are not affected",
+		"qng.cancel_workflow.session_id":                "Session or workflow ID",
+		"qng.cancel_transaction":                        "Cancel a transaction whose confirmation timed out by sending a 0-value transaction with the same nonce and a higher fee",
+		"qng.cancel_transaction.session_id":             "Session ID",
+		"qng.speed_up_transaction":                      "Resend a transaction whose confirmation timed out with the same nonce and a higher fee",
+		"qng.speed_up_transaction.session_id":           "Session ID",
+		"qng.send_raw_transaction":                      "Broadcast a signed transaction",
+		"qng.send_raw_transaction.signed_tx":            "Hex-encoded signed transaction",
+		"qng.get_tokens":                                "Get supported tokens (decimals, contract address, whether native)",
+		"qng.get_tokens.symbol":                         "Token symbol; all tokens are returned when empty",
+		"qng.get_staking_position":                      "Get the staked amount and pending rewards of an address in a staking pool",
+		"qng.get_staking_position.address":              "User wallet address",
+		"qng.get_staking_position.pool":                 "Staking pool name or alias; the default pool is used when empty",
+		"qng.poll_session":                              "Long-poll for session updates",
+		"qng.poll_session.session_id":                   "Session ID",
+		"qng.poll_session.timeout":                      "Timeout in seconds",
+
+		"metamask.connect_wallet":                     "Connect a MetaMask wallet",
+		"metamask.connect_wallet.request_permissions": "Whether to request permissions",
+		"metamask.poll_connection":                    "Wait for the wallet to approve a pairing and return its status and approved account",
+		"metamask.poll_connection.topic":              "Pairing topic returned by connect_wallet",
+		"metamask.poll_connection.timeout":            "Maximum wait in seconds, up to 60",
+		"metamask.approve_connection":                 "Approve a pairing from the wallet with the account, chain ID and a personal_sign signature of the pairing message",
+		"metamask.approve_connection.topic":           "Pairing topic",
+		"metamask.approve_connection.account":         "Wallet account address",
+		"metamask.approve_connection.chain_id":        "Chain ID the wallet is on",
+		"metamask.approve_connection.signature":       "Signature of the challenge returned by connect_wallet",
+		"metamask.reject_connection":                  "Reject a pairing from the wallet",
+		"metamask.reject_connection.topic":            "Pairing topic",
+		"metamask.get_accounts":                       "List wallet accounts",
+		"metamask.sign_transaction":                   "Sign a transaction",
+		"metamask.sign_transaction.transaction":       "Transaction data",
+		"metamask.get_balance":                        "Get account balance",
+		"metamask.get_balance.account":                "Account address; defaults to the connected account or the read-only default account",
+		"metamask.get_network":                        "Get network information",
+	},
+}
+
+// NegotiateLocale 按 Accept-Language（支持 q 权重）选择支持的语言，没有匹配时返回 DefaultLocale
+func NegotiateLocale(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.q <= 0 {
+			continue
+		}
+		// 只比较主语言标签，如 en-US 匹配 en
+		primary, _, _ := strings.Cut(c.lang, "-")
+		if primary == DefaultLocale {
+			return DefaultLocale
+		}
+		if _, ok := capabilityCatalogs[primary]; ok {
+			return primary
+		}
+	}
+	return DefaultLocale
+}
+
+// LocalizeCapabilities 返回描述翻译为指定语言的能力副本，目录中缺少的描述保持原文
+func LocalizeCapabilities(capabilities map[string][]Capability, locale string) map[string][]Capability {
+	catalog, ok := capabilityCatalogs[locale]
+	if !ok {
+		return capabilities
+	}
+
+	localized := make(map[string][]Capability, len(capabilities))
+	for server, caps := range capabilities {
+		translated := make([]Capability, len(caps))
+		for i, capability := range caps {
+			key := server + "." + capability.Name
+			if description, ok := catalog[key]; ok {
+				capability.Description = description
+			}
+
+			params := make([]Parameter, len(capability.Parameters))
+			for j, param := range capability.Parameters {
+				if description, ok := catalog[key+"."+param.Name]; ok {
+					param.Description = description
+				}
+				params[j] = param
+			}
+			capability.Parameters = params
+			translated[i] = capability
+		}
+		localized[server] = translated
+	}
+	return localized
+}
@@ -0,0 +1,133 @@
+// Package mcptest 提供 mcp.ServerInterface 的测试替身，供依赖 MCP 服务的包在测试中使用
+package mcptest
+
+import (
+	"context"
+	"fmt"
+	"qng_agent/internal/mcp"
+	"sync"
+)
+
+// MockCall 记录的一次 MockMCPServer 调用
+type MockCall struct {
+	Server string
+	Method string
+	Params map[string]interface{}
+}
+
+// MockHandler 动态生成调用结果
+type MockHandler func(ctx context.Context, params map[string]interface{}) (interface{}, error)
+
+// MockMCPServer 可编程的 mcp.ServerInterface 测试替身，按 server.method 返回预设结果并记录所有调用
+type MockMCPServer struct {
+	mu           sync.Mutex
+	handlers     map[string]MockHandler
+	capabilities map[string]interface{}
+	calls        []MockCall
+	started      bool
+}
+
+// NewMockMCPServer 创建测试替身
+func NewMockMCPServer() *MockMCPServer {
+	return &MockMCPServer{
+		handlers:     make(map[string]MockHandler),
+		capabilities: make(map[string]interface{}),
+	}
+}
+
+// On 设置方法的固定返回值
+func (m *MockMCPServer) On(server, method string, result interface{}, err error) *MockMCPServer {
+	return m.OnFunc(server, method, func(context.Context, map[string]interface{}) (interface{}, error) {
+		return result, err
+	})
+}
+
+// OnFunc 设置方法的动态处理函数
+func (m *MockMCPServer) OnFunc(server, method string, handler MockHandler) *MockMCPServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[server+"."+method] = handler
+	return m
+}
+
+// SetCapabilities 设置 GetCapabilities 的返回值
+func (m *MockMCPServer) SetCapabilities(capabilities map[string]interface{}) *MockMCPServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.capabilities = capabilities
+	return m
+}
+
+// Call 记录调用并返回预设结果，未设置的方法返回错误
+func (m *MockMCPServer) Call(ctx context.Context, server, method string, params map[string]interface{}) (interface{}, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, MockCall{Server: server, Method: method, Params: params})
+	handler, ok := m.handlers[server+"."+method]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown method: %s.%s", server, method)
+	}
+	return handler(ctx, params)
+}
+
+// Start 标记为已启动
+func (m *MockMCPServer) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = true
+	return nil
+}
+
+// Stop 标记为已停止
+func (m *MockMCPServer) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = false
+	return nil
+}
+
+// Started 返回是否已启动
+func (m *MockMCPServer) Started() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started
+}
+
+// GetCapabilities 返回预设的能力信息
+func (m *MockMCPServer) GetCapabilities() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.capabilities
+}
+
+// Calls 返回所有已记录的调用
+func (m *MockMCPServer) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+// CallsTo 返回指定方法的调用记录
+func (m *MockMCPServer) CallsTo(server, method string) []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []MockCall
+	for _, call := range m.calls {
+		if call.Server == server && call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset 清空调用记录
+func (m *MockMCPServer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+// 确保 MockMCPServer 实现 mcp.ServerInterface
+var _ mcp.ServerInterface = (*MockMCPServer)(nil)
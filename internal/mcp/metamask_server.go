@@ -7,21 +7,26 @@ import (
 	"fmt"
 	"log"
 	"qng_agent/internal/config"
+	"sync"
 	"time"
 )
 
 type MetaMaskServer struct {
 	config config.MetaMaskConfig
-	// 模拟钱包连接状态
+	// 钱包连接状态，由 mu 保护；模拟模式下连接固定账户，否则为最近批准配对的账户
 	connected bool
 	accounts  []string
 	network   string
+	// sessions 按配对主题保存的钱包配对会话
+	sessions map[string]*WalletSession
+	mu       sync.Mutex
 }
 
 func NewMetaMaskServer(config config.MetaMaskConfig) *MetaMaskServer {
 	return &MetaMaskServer{
 		config: config,
 		network: config.Network,
+		sessions: make(map[string]*WalletSession),
 	}
 }
 
@@ -51,14 +56,47 @@ func (s *MetaMaskServer) Call(ctx context.Context, method string, params map[str
 		return s.getBalance(ctx, params)
 	case "get_network":
 		return s.getNetwork(ctx, params)
+	case "poll_connection":
+		return s.pollConnection(ctx, params)
+	case "approve_connection":
+		return s.approveConnection(ctx, params)
+	case "reject_connection":
+		return s.rejectConnection(ctx, params)
 	default:
 		log.Printf("❌ 未知方法: %s", method)
 		return nil, fmt.Errorf("unknown method: %s", method)
 	}
 }
 
+// connectWallet 创建钱包配对，返回配对URI与需要钱包签名的消息；钱包批准前 connected 为 false，
+// 调用方通过 poll_connection 等待。开启 mock 时直接连接模拟账户
 func (s *MetaMaskServer) connectWallet(ctx context.Context, params map[string]any) (any, error) {
-	log.Printf("🔗 连接MetaMask钱包")
+	if s.config.Mock {
+		return s.connectMockWallet(ctx, params)
+	}
+	
+	log.Printf("🔗 创建钱包配对")
+	session, err := s.createPairing()
+	if err != nil {
+		log.Printf("❌ 创建钱包配对失败: %v", err)
+		return nil, err
+	}
+	
+	log.Printf("✅ 钱包配对已创建: %s，有效期至 %s", session.Topic, session.ExpiresAt.Format(time.RFC3339))
+	return map[string]any{
+		"connected":  false,
+		"status":     session.Status,
+		"topic":      session.Topic,
+		"uri":        session.URI,
+		"challenge":  session.Challenge,
+		"chain_id":   s.config.ChainID,
+		"expires_at": session.ExpiresAt,
+	}, nil
+}
+
+// connectMockWallet 模拟钱包连接，用于离线开发
+func (s *MetaMaskServer) connectMockWallet(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("🔗 连接模拟MetaMask钱包")
 	
 	// 模拟连接过程
 	time.Sleep(1 * time.Second)
@@ -69,8 +107,10 @@ func (s *MetaMaskServer) connectWallet(ctx context.Context, params map[string]an
 		"0x1234567890123456789012345678901234567890",
 	}
 	
+	s.mu.Lock()
 	s.connected = true
 	s.accounts = accounts
+	s.mu.Unlock()
 	
 	log.Printf("✅ 钱包连接成功")
 	log.Printf("📋 账户: %v", accounts)
@@ -86,19 +126,27 @@ func (s *MetaMaskServer) connectWallet(ctx context.Context, params map[string]an
 func (s *MetaMaskServer) getAccounts(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("📋 获取账户列表")
 	
-	if !s.connected {
+	accounts, connected := s.connectedAccounts()
+	if !connected {
 		log.Printf("❌ 钱包未连接")
 		return nil, fmt.Errorf("wallet not connected")
 	}
 	
-	log.Printf("✅ 返回账户列表: %v", s.accounts)
-	return s.accounts, nil
+	log.Printf("✅ 返回账户列表: %v", accounts)
+	return accounts, nil
+}
+
+// connectedAccounts 返回已连接的账户与连接状态
+func (s *MetaMaskServer) connectedAccounts() ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.accounts...), s.connected
 }
 
 func (s *MetaMaskServer) signTransaction(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("✍️  签名交易")
 	
-	if !s.connected {
+	if _, connected := s.connectedAccounts(); !connected {
 		log.Printf("❌ 钱包未连接")
 		return nil, fmt.Errorf("wallet not connected")
 	}
@@ -131,15 +179,11 @@ func (s *MetaMaskServer) signTransaction(ctx context.Context, params map[string]
 func (s *MetaMaskServer) getBalance(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("💰 获取余额")
 	
-	if !s.connected {
-		log.Printf("❌ 钱包未连接")
-		return nil, fmt.Errorf("wallet not connected")
-	}
-	
-	account, ok := params["account"].(string)
-	if !ok {
-		log.Printf("❌ 缺少账户地址")
-		return nil, fmt.Errorf("account address required")
+	// 余额查询为只读操作，不要求钱包已连接
+	account, err := s.readAccount(params)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return nil, err
 	}
 	
 	log.Printf("📋 查询账户: %s", account)
@@ -156,10 +200,25 @@ func (s *MetaMaskServer) getBalance(ctx context.Context, params map[string]any)
 	return balances, nil
 }
 
+// readAccount 确定只读查询使用的账户：优先使用参数中的地址，其次是已连接的账户，最后是配置的默认账户
+func (s *MetaMaskServer) readAccount(params map[string]any) (string, error) {
+	if account, ok := params["account"].(string); ok && account != "" {
+		return account, nil
+	}
+	if accounts, connected := s.connectedAccounts(); connected && len(accounts) > 0 {
+		return accounts[0], nil
+	}
+	if s.config.DefaultAccount != "" {
+		log.Printf("📋 钱包未连接，使用默认只读账户: %s", s.config.DefaultAccount)
+		return s.config.DefaultAccount, nil
+	}
+	return "", fmt.Errorf("account address required: wallet not connected and no default account configured")
+}
+
 func (s *MetaMaskServer) getNetwork(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("🌐 获取网络信息")
 	
-	if !s.connected {
+	if _, connected := s.connectedAccounts(); !connected {
 		log.Printf("❌ 钱包未连接")
 		return nil, fmt.Errorf("wallet not connected")
 	}
@@ -197,6 +256,66 @@ func (s *MetaMaskServer) GetCapabilities() []Capability {
 				},
 			},
 		},
+		{
+			Name:        "poll_connection",
+			Description: "等待钱包批准配对，返回配对状态与已批准的账户",
+			Parameters: []Parameter{
+				{
+					Name:        "topic",
+					Type:        "string",
+					Description: "connect_wallet 返回的配对主题",
+					Required:    true,
+				},
+				{
+					Name:        "timeout",
+					Type:        "int",
+					Description: "最长等待时间（秒），最多60秒",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "approve_connection",
+			Description: "钱包批准配对，提交账户、链ID与对配对消息的 personal_sign 签名",
+			Parameters: []Parameter{
+				{
+					Name:        "topic",
+					Type:        "string",
+					Description: "配对主题",
+					Required:    true,
+				},
+				{
+					Name:        "account",
+					Type:        "string",
+					Description: "钱包账户地址",
+					Required:    true,
+				},
+				{
+					Name:        "chain_id",
+					Type:        "string",
+					Description: "钱包当前链ID",
+					Required:    false,
+				},
+				{
+					Name:        "signature",
+					Type:        "string",
+					Description: "对 connect_wallet 返回的 challenge 的签名",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "reject_connection",
+			Description: "钱包拒绝配对",
+			Parameters: []Parameter{
+				{
+					Name:        "topic",
+					Type:        "string",
+					Description: "配对主题",
+					Required:    true,
+				},
+			},
+		},
 		{
 			Name:        "get_accounts",
 			Description: "获取钱包账户列表",
@@ -221,8 +340,8 @@ func (s *MetaMaskServer) GetCapabilities() []Capability {
 				{
 					Name:        "account",
 					Type:        "string",
-					Description: "账户地址",
-					Required:    true,
+					Description: "账户地址，缺省时使用已连接账户或默认只读账户",
+					Required:    false,
 				},
 			},
 		},
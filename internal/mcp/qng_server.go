@@ -2,10 +2,17 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"qng_agent/internal/config"
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/logging"
+	"qng_agent/internal/metrics"
 	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,24 +22,42 @@ type QNGServer struct {
 	chain      *qng.Chain
 	sessions   map[string]*Session
 	sessionsMu sync.RWMutex
+	// instanceID 本副本的实例ID，作为会话与工作流ID的命名空间
+	instanceID string
+	// store 会话持久化存储，未启用时为 nil
+	store *SessionStore
+	// stopSweep 关闭时停止过期会话清理
+	stopSweep chan struct{}
+	// readAccount 未提供用户地址时解析只读查询账户（已连接的钱包或默认账户），由 MetaMask 服务提供，可为 nil
+	readAccount func(params map[string]any) (string, error)
 }
 
 // Session和SessionUpdate类型已在types.go中定义
 
-func NewQNGServer(config config.QNGConfig) *QNGServer {
-	chain := qng.NewChain(config)
+// NewQNGServer 创建QNG MCP服务器，QNG Chain 初始化失败时返回错误
+func NewQNGServer(config config.QNGConfig) (*QNGServer, error) {
+	chain, err := qng.NewChain(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create QNG chain: %w", err)
+	}
 	
-	server := &QNGServer{
+	return NewQNGServerWithChain(config, chain), nil
+}
+
+// NewQNGServerWithChain 使用已创建的 QNG Chain 创建服务器，独立部署的 chain 服务据此提供相同的会话与长轮询。
+// Start/Stop 会同时启动和停止该 Chain
+func NewQNGServerWithChain(config config.QNGConfig, chain *qng.Chain) *QNGServer {
+	return &QNGServer{
 		config:   config,
 		chain:    chain,
 		sessions: make(map[string]*Session),
+		instanceID: resolveInstanceID(config.InstanceID),
+		stopSweep:  make(chan struct{}),
 	}
-	
-	return server
 }
 
 func (s *QNGServer) Start() error {
-	log.Printf("🚀 QNG MCP服务器启动")
+	log.Printf("🚀 QNG MCP服务器启动 (实例: %s)", s.instanceID)
 	
 	// 启动QNG Chain
 	if err := s.chain.Start(); err != nil {
@@ -40,20 +65,34 @@ func (s *QNGServer) Start() error {
 		return err
 	}
 	
+	// 定期清理已结束的过期会话
+	go s.sweepSessions()
+	
 	log.Printf("✅ QNG MCP服务器启动成功")
 	return nil
 }
 
 func (s *QNGServer) Stop() error {
 	log.Printf("🛑 QNG MCP服务器停止")
+	close(s.stopSweep)
 	
-	// 停止所有会话
+	// 停止所有会话，会话同时以 sessionID 和 workflowID 索引，每个只关闭一次
 	s.sessionsMu.Lock()
+	closed := make(map[*Session]bool)
 	for _, session := range s.sessions {
-		close(session.CancelChan)
+		if !closed[session] {
+			closed[session] = true
+			session.cancel()
+		}
 	}
 	s.sessionsMu.Unlock()
 	
+	if s.store != nil {
+		if err := s.store.Close(); err != nil {
+			log.Printf("⚠️  关闭会话存储失败: %v", err)
+		}
+	}
+	
 	// 停止QNG Chain
 	if err := s.chain.Stop(); err != nil {
 		log.Printf("❌ 停止QNG Chain失败: %v", err)
@@ -65,9 +104,9 @@ func (s *QNGServer) Stop() error {
 }
 
 func (s *QNGServer) Call(ctx context.Context, method string, params map[string]any) (any, error) {
-	log.Printf("🔄 QNG MCP服务器调用")
-	log.Printf("🛠️  方法: %s", method)
-	log.Printf("📋 参数: %+v", params)
+	ctx = s.correlate(ctx, params)
+	slog.InfoContext(ctx, "🔄 QNG MCP服务器调用", "method", method)
+	slog.DebugContext(ctx, "📋 调用参数", "params", params)
 	
 	switch method {
 	case "execute_workflow":
@@ -78,32 +117,83 @@ func (s *QNGServer) Call(ctx context.Context, method string, params map[string]a
 		return s.submitSignature(ctx, params)
 	case "poll_session":
 		return s.pollSession(ctx, params)
+	case "resume_workflow":
+		return s.resumeWorkflow(ctx, params)
+	case "retry_confirmation":
+		return s.retryConfirmation(ctx, params)
+	case "cancel_workflow":
+		return s.cancelWorkflow(ctx, params)
+	case "cancel_transaction":
+		return s.replaceTransaction(ctx, params, contracts.ReplaceCancel)
+	case "speed_up_transaction":
+		return s.replaceTransaction(ctx, params, contracts.ReplaceSpeedUp)
+	case "send_raw_transaction":
+		return s.sendRawTransaction(ctx, params)
+	case "get_tokens":
+		return s.getTokens(ctx, params)
+	case "get_staking_position":
+		return s.getStakingPosition(ctx, params)
 	default:
 		log.Printf("❌ 未知方法: %s", method)
-		return nil, fmt.Errorf("unknown method: %s", method)
+		return nil, newError(CodeNotFound, "unknown method: %s", method).WithDetail("method", method)
 	}
 }
 
+// correlate 针对已有会话的调用使用会话的工作流ID作为关联ID，与异步执行的工作流日志对应；
+// 请求自带的关联ID记录为 request_id
+func (s *QNGServer) correlate(ctx context.Context, params map[string]any) context.Context {
+	sessionID, _ := params["session_id"].(string)
+	if sessionID == "" {
+		return ctx
+	}
+	session, exists := s.getSession(sessionID)
+	if !exists || session.WorkflowID == logging.CorrelationID(ctx) {
+		return ctx
+	}
+	correlated := logging.WithCorrelationID(ctx, session.WorkflowID)
+	if requestID := logging.CorrelationID(ctx); requestID != "" {
+		slog.DebugContext(correlated, "🔗 请求关联到工作流", "request_id", requestID)
+	}
+	return correlated
+}
+
 func (s *QNGServer) executeWorkflow(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("🔄 执行工作流")
 	
 	message, ok := params["message"].(string)
 	if !ok {
 		log.Printf("❌ 缺少message参数")
-		return nil, fmt.Errorf("message parameter required")
+		return nil, missingParam("message")
 	}
 	
 	log.Printf("📝 用户消息: %s", message)
 	
+	// 可选的用户ID，仅随会话记录，不参与支出限额匹配
+	userID, _ := params["user_id"].(string)
+	// 可选的用户钱包地址，用于在构建交易前检查链上余额
+	userAddress, _ := params["user_address"].(string)
+	manualConfirmation, _ := params["manual_confirmation"].(bool)
+	// 可选的最近对话，用于解析“它”、“这些MTK”等指代
+	history := qng.ParseConversationHistory(params["history"])
+	
+	// 预演模式同步返回交易计划，不创建会话
+	if dryRun, _ := params["dry_run"].(bool); dryRun {
+		return s.simulateWorkflow(ctx, message, userID, userAddress, history)
+	}
+	
 	// 创建新会话
-	sessionID := generateSessionID()
-	workflowID := generateWorkflowID()
+	sessionID := s.newID("session")
+	workflowID := s.newID("workflow")
 	
 	session := &Session{
 		ID:          sessionID,
 		WorkflowID:   workflowID,
 		Status:       "pending",
 		Message:      message,
+		UserID:       userID,
+		UserAddress:  userAddress,
+		History:      history,
+		ManualConfirmation: manualConfirmation,
 		CreatedAt:    time.Now().Format(time.RFC3339),
 		UpdatedAt:    time.Now().Format(time.RFC3339),
 		PollingChan:  make(chan *SessionUpdate, 10),
@@ -115,9 +205,10 @@ func (s *QNGServer) executeWorkflow(ctx context.Context, params map[string]any)
 	s.sessions[sessionID] = session
 	s.sessions[workflowID] = session  // 允许通过 workflowID 查询
 	s.sessionsMu.Unlock()
+	s.persistSession(session)
 	
-	log.Printf("✅ 创建会话: %s", sessionID)
-	log.Printf("📋 工作流ID: %s", workflowID)
+	// 请求的关联ID与工作流ID对应，之后的工作流日志使用工作流ID
+	slog.InfoContext(ctx, "✅ 创建会话", "session_id", sessionID, "workflow_id", workflowID)
 	
 	// 异步执行工作流
 	go s.executeWorkflowAsync(session, message)
@@ -127,9 +218,55 @@ func (s *QNGServer) executeWorkflow(ctx context.Context, params map[string]any)
 		"workflow_id": workflowID,
 		"status":      "pending",
 		"message":     "工作流已提交，正在处理中...",
+		"instance_id": s.instanceID,
 	}, nil
 }
 
+// withReadAccount 设置工作流的账户：有用户地址时作为 user_address，否则解析只读账户作为 read_account，
+// 后者只用于余额、报价与授权额度查询
+func (s *QNGServer) withReadAccount(ctx context.Context, userAddress string) context.Context {
+	if userAddress != "" {
+		return context.WithValue(ctx, "user_address", userAddress)
+	}
+	if s.readAccount == nil {
+		return ctx
+	}
+	account, err := s.readAccount(map[string]any{})
+	if err != nil {
+		log.Printf("⚠️  没有可用的只读账户，跳过余额与授权额度查询: %v", err)
+		return ctx
+	}
+	return context.WithValue(ctx, "read_account", account)
+}
+
+// simulateWorkflow 以预演模式执行工作流：运行任务分解与执行节点，返回按顺序排列的计划交易，不请求签名
+func (s *QNGServer) simulateWorkflow(ctx context.Context, message, userID, userAddress string, history []qng.ConversationTurn) (any, error) {
+	log.Printf("📝 预演工作流: %s", message)
+	
+	ctx = context.WithValue(ctx, "dry_run", true)
+	ctx = context.WithValue(ctx, "workflow_id", s.newID("dryrun"))
+	if userID != "" {
+		ctx = context.WithValue(ctx, "user_id", userID)
+	}
+	ctx = s.withReadAccount(ctx, userAddress)
+	if len(history) > 0 {
+		ctx = context.WithValue(ctx, "conversation_history", history)
+	}
+	
+	result, err := s.chain.ProcessMessage(ctx, message)
+	if err != nil {
+		log.Printf("❌ 预演失败: %v", err)
+		return nil, fmt.Errorf("dry run failed: %w", err)
+	}
+	if result.NeedSignature {
+		// 执行节点在预演模式下不会请求签名
+		return nil, fmt.Errorf("dry run unexpectedly requested a signature")
+	}
+	
+	log.Printf("✅ 预演完成")
+	return result.FinalResult, nil
+}
+
 func (s *QNGServer) executeWorkflowAsync(session *Session, message string) {
 	log.Printf("🔄 异步执行工作流")
 	log.Printf("📋 会话ID: %s", session.ID)
@@ -138,105 +275,33 @@ func (s *QNGServer) executeWorkflowAsync(session *Session, message string) {
 	// 更新状态为运行中
 	s.updateSessionStatus(session, "running", "正在执行工作流...")
 	
-	// 创建上下文
-	ctx := context.WithValue(context.Background(), "workflow_id", session.WorkflowID)
-	ctx = context.WithValue(ctx, "session_id", session.ID)
+	// 创建上下文，会话被取消时进行中的 LLM 与 RPC 调用随之放弃
+	ctx, cancel := s.workflowContext(session)
+	defer cancel()
+	ctx = s.withConfirmationProgress(ctx, session)
+	if session.UserID != "" {
+		ctx = context.WithValue(ctx, "user_id", session.UserID)
+	}
+	ctx = s.withReadAccount(ctx, session.UserAddress)
+	if session.ManualConfirmation {
+		ctx = context.WithValue(ctx, "manual_confirmation", true)
+	}
+	if len(session.History) > 0 {
+		ctx = context.WithValue(ctx, "conversation_history", session.History)
+	}
 	
 	// 执行工作流
 	result, err := s.chain.ProcessMessage(ctx, message)
-	if err != nil {
-		log.Printf("❌ 工作流执行失败: %v", err)
-		s.updateSessionStatus(session, "failed", fmt.Sprintf("执行失败: %v", err))
+	if s.abandoned(ctx, session, result, err) {
 		return
 	}
-	
-	// 检查是否需要签名
-	if result.NeedSignature {
-		log.Printf("✍️  需要用户签名")
-		session.Context = result.WorkflowContext
-		
-		// 将签名请求转换为正确的类型并保存
-		if sigReq, ok := result.SignatureRequest.(map[string]interface{}); ok {
-			signatureRequest := &SignatureRequest{}
-			if action, exists := sigReq["action"]; exists {
-				if actionStr, ok := action.(string); ok {
-					signatureRequest.Action = actionStr
-				}
-			}
-			if fromToken, exists := sigReq["from_token"]; exists {
-				if fromTokenStr, ok := fromToken.(string); ok {
-					signatureRequest.FromToken = fromTokenStr
-				}
-			}
-			if toToken, exists := sigReq["to_token"]; exists {
-				if toTokenStr, ok := toToken.(string); ok {
-					signatureRequest.ToToken = toTokenStr
-				}
-			}
-			if amount, exists := sigReq["amount"]; exists {
-				if amountStr, ok := amount.(string); ok {
-					signatureRequest.Amount = amountStr
-				}
-			}
-			if gasFee, exists := sigReq["gas_fee"]; exists {
-				if gasFeeStr, ok := gasFee.(string); ok {
-					signatureRequest.GasFee = gasFeeStr
-				}
-			}
-			if slippage, exists := sigReq["slippage"]; exists {
-				if slippageStr, ok := slippage.(string); ok {
-					signatureRequest.Slippage = slippageStr
-				}
-			}
-			// 添加区块链交易必需字段
-			if toAddress, exists := sigReq["to_address"]; exists {
-				if toAddressStr, ok := toAddress.(string); ok {
-					signatureRequest.ToAddress = toAddressStr
-				}
-			}
-			if value, exists := sigReq["value"]; exists {
-				if valueStr, ok := value.(string); ok {
-					signatureRequest.Value = valueStr
-				}
-			}
-			if data, exists := sigReq["data"]; exists {
-				if dataStr, ok := data.(string); ok {
-					signatureRequest.Data = dataStr
-				}
-			}
-			if gasLimit, exists := sigReq["gas_limit"]; exists {
-				if gasLimitStr, ok := gasLimit.(string); ok {
-					signatureRequest.GasLimit = gasLimitStr
-				}
-			}
-			if gasPrice, exists := sigReq["gas_price"]; exists {
-				if gasPriceStr, ok := gasPrice.(string); ok {
-					signatureRequest.GasPrice = gasPriceStr
-				}
-			}
-			session.SignatureRequest = signatureRequest
-			
-			log.Printf("✅ 签名请求已保存到会话")
-			log.Printf("📋 签名请求详情: action=%s, from=%s->%s, amount=%s", 
-				signatureRequest.Action, signatureRequest.FromToken, signatureRequest.ToToken, signatureRequest.Amount)
-			log.Printf("📋 交易数据: to=%s, value=%s, data=%s", 
-				signatureRequest.ToAddress, signatureRequest.Value, signatureRequest.Data)
-		}
-		
-		s.updateSessionStatus(session, "waiting_signature", "等待用户签名授权")
-		
-		// 发送签名请求
-		s.sendSessionUpdate(session, "signature_request", result.SignatureRequest)
+	if err != nil {
+		log.Printf("❌ 工作流执行失败: %v", err)
+		s.failSession(session, err, "执行失败")
 		return
 	}
 	
-	// 工作流完成
-	log.Printf("✅ 工作流执行完成")
-	session.Result = result.FinalResult
-	s.updateSessionStatus(session, "completed", "工作流执行完成")
-	
-	// 发送结果
-	s.sendSessionUpdate(session, "result", result.FinalResult)
+	s.handleResult(session, result)
 }
 
 func (s *QNGServer) getSessionStatus(ctx context.Context, params map[string]any) (any, error) {
@@ -245,20 +310,26 @@ func (s *QNGServer) getSessionStatus(ctx context.Context, params map[string]any)
 	sessionID, ok := params["session_id"].(string)
 	if !ok {
 		log.Printf("❌ 缺少session_id参数")
-		return nil, fmt.Errorf("session_id parameter required")
+		return nil, missingParam("session_id")
 	}
 	
-	s.sessionsMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionsMu.RUnlock()
+	session, exists := s.getSession(sessionID)
 	
 	if !exists {
 		log.Printf("❌ 会话不存在: %s", sessionID)
-		return nil, fmt.Errorf("session not found: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
 	}
 	
-	log.Printf("✅ 返回会话状态: %s", session.Status)
-	
+	var result map[string]any
+	session.read(func() {
+		result = sessionStatusResult(session)
+	})
+	log.Printf("✅ 返回会话状态: %s", result["status"])
+	return result, nil
+}
+
+// sessionStatusResult 会话状态查询的响应，调用方需持有会话读锁
+func sessionStatusResult(session *Session) map[string]any {
 	result := map[string]any{
 		"session_id":  session.ID,
 		"workflow_id": session.WorkflowID,
@@ -274,7 +345,30 @@ func (s *QNGServer) getSessionStatus(ctx context.Context, params map[string]any)
 		result["signature_request"] = session.SignatureRequest
 	}
 	
-	return result, nil
+	// 已提交的交易及声明的 gas 参数
+	if len(session.Transactions) > 0 {
+		result["transactions"] = session.Transactions
+	}
+	
+	// 添加任务进度，失败的会话可据此恢复
+	if session.TaskProgress != nil {
+		result["tasks"] = session.TaskProgress.Tasks
+		result["completed_tasks"] = session.TaskProgress.CompletedTasks
+		result["tx_hashes"] = session.TaskProgress.TxHashes
+		result["resumable"] = session.Status == "failed" || session.Status == "confirmation_failed" || session.Status == StatusInterrupted
+	}
+	
+	if session.Error != nil {
+		result["error"] = session.Error.Message
+		result["error_type"] = session.Error.Type
+		result["retryable"] = session.Error.Retryable
+		result["error_detail"] = session.Error
+		if session.Error.Report != nil {
+			result["failure_report"] = session.Error.Report
+		}
+	}
+	
+	return result
 }
 
 func (s *QNGServer) submitSignature(ctx context.Context, params map[string]any) (any, error) {
@@ -283,29 +377,52 @@ func (s *QNGServer) submitSignature(ctx context.Context, params map[string]any)
 	sessionID, ok := params["session_id"].(string)
 	if !ok {
 		log.Printf("❌ 缺少session_id参数")
-		return nil, fmt.Errorf("session_id parameter required")
+		return nil, missingParam("session_id")
 	}
 	
 	signature, ok := params["signature"].(string)
 	if !ok {
 		log.Printf("❌ 缺少signature参数")
-		return nil, fmt.Errorf("signature parameter required")
+		return nil, missingParam("signature")
 	}
 	
 	log.Printf("🔐 签名长度: %d", len(signature))
 	
-	s.sessionsMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionsMu.RUnlock()
+	session, exists := s.getSession(sessionID)
 	
 	if !exists {
 		log.Printf("❌ 会话不存在: %s", sessionID)
-		return nil, fmt.Errorf("session not found: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
 	}
 	
-	if session.Status != "waiting_signature" {
-		log.Printf("❌ 会话状态不正确: %s", session.Status)
-		return nil, fmt.Errorf("session not in waiting_signature status")
+	if status := session.currentStatus(); status != "waiting_signature" {
+		log.Printf("❌ 会话状态不正确: %s", status)
+		return nil, invalidState(session, "session not in waiting_signature status")
+	}
+	
+	// 可选的 gas 参数，用户提高 gas 重新广播交易时提交
+	gas, err := qng.ParseGasOverride(params)
+	if err != nil {
+		log.Printf("❌ gas 参数无效: %v", err)
+		return nil, newError(CodeValidation, "%w", err)
+	}
+	s.trackTransactions(session, signature, gas)
+	
+	// 取消交易不属于任何任务，确认后结束工作流；加速交易替代原交易继续工作流
+	var replacement *qng.Replacement
+	session.update(func() {
+		replacement, session.Replacement = session.Replacement, nil
+	})
+	if replacement != nil {
+		if replacement.Kind == contracts.ReplaceCancel {
+			s.updateSessionStatus(session, "running", "正在等待取消交易确认...")
+			go s.confirmCancellation(session, replacement.OriginalTxHash, signature)
+			return map[string]any{
+				"session_id": session.ID,
+				"status":     "processing",
+				"message":    "取消交易已提交，正在等待确认...",
+			}, nil
+		}
 	}
 	
 	log.Printf("✅ 验证签名并继续工作流")
@@ -314,7 +431,7 @@ func (s *QNGServer) submitSignature(ctx context.Context, params map[string]any)
 	s.updateSessionStatus(session, "running", "正在处理签名...")
 	
 	// 异步继续工作流
-	go s.continueWorkflowWithSignature(session, signature)
+	go s.continueWorkflowWithSignature(session, signature, gas)
 	
 	return map[string]any{
 		"session_id": session.ID,
@@ -323,104 +440,575 @@ func (s *QNGServer) submitSignature(ctx context.Context, params map[string]any)
 	}, nil
 }
 
-func (s *QNGServer) continueWorkflowWithSignature(session *Session, signature string) {
+// trackTransactions 记录本轮签名提交的交易及声明的 gas 参数，批量签名按请求顺序对应任务
+func (s *QNGServer) trackTransactions(session *Session, signature string, gas *qng.GasOverride) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	
+	var requests []*SignatureRequest
+	if session.SignatureRequest != nil {
+		requests = session.SignatureRequest.Requests
+		if len(requests) == 0 {
+			requests = []*SignatureRequest{session.SignatureRequest}
+		}
+	}
+	
+	now := time.Now().Format(time.RFC3339)
+	for i, txHash := range strings.Split(signature, ",") {
+		tracked := TrackedTransaction{
+			TxHash:      strings.TrimSpace(txHash),
+			Gas:         gas,
+			SubmittedAt: now,
+		}
+		if i < len(requests) && requests[i] != nil {
+			tracked.TaskID = requests[i].TaskID
+		}
+		session.Transactions = append(session.Transactions, tracked)
+	}
+}
+
+// trackedGas 返回交易提交时声明的 gas 参数，同一交易多次提交时以最后一次为准
+func (s *QNGServer) trackedGas(session *Session, txHash string) *qng.GasOverride {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	
+	for i := len(session.Transactions) - 1; i >= 0; i-- {
+		if strings.EqualFold(session.Transactions[i].TxHash, txHash) {
+			return session.Transactions[i].Gas
+		}
+	}
+	return nil
+}
+
+func (s *QNGServer) continueWorkflowWithSignature(session *Session, signature string, gas *qng.GasOverride) {
 	log.Printf("🔄 使用签名继续工作流")
 	log.Printf("📋 会话ID: %s", session.ID)
 	
-	// 创建上下文
-	ctx := context.WithValue(context.Background(), "workflow_id", session.WorkflowID)
-	ctx = context.WithValue(ctx, "session_id", session.ID)
+	// 创建上下文，会话被取消时进行中的 LLM 与 RPC 调用随之放弃
+	ctx, cancel := s.workflowContext(session)
+	defer cancel()
+	ctx = s.withConfirmationProgress(ctx, session)
+	ctx = qng.WithGasOverride(ctx, gas)
 	
 	// 继续工作流
-	result, err := s.chain.ContinueWithSignature(ctx, session.Context, signature)
+	var workflowContext any
+	session.read(func() {
+		workflowContext = session.Context
+	})
+	result, err := s.chain.ContinueWithSignature(ctx, workflowContext, signature)
+	if s.abandoned(ctx, session, result, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("❌ 继续工作流失败: %v", err)
-		s.updateSessionStatus(session, "failed", fmt.Sprintf("继续执行失败: %v", err))
+		// 记录失败前已完成的任务，以便后续恢复
+		s.recordTaskProgress(session)
+		s.failSession(session, err, "继续执行失败")
 		return
 	}
 	
+	s.handleResult(session, result)
+}
+
+// handleResult 处理工作流首次执行、签名后或恢复后继续执行的结果，
+// 结果经 StatusFromResult 转换后写入会话并推送更新
+func (s *QNGServer) handleResult(session *Session, result *qng.ProcessResult) {
+	status := StatusFromResult(result)
+	
 	// 检查是否需要新的签名请求
-	if result.NeedSignature {
+	if status.NeedSignature {
 		log.Printf("🔔 检测到新的签名请求")
 		
 		// 保存工作流上下文
-		session.Context = result.WorkflowContext
+		session.update(func() {
+			session.Context = result.WorkflowContext
+		})
+		s.recordTaskProgress(session)
+		if err := s.countSignatureRound(session); err != nil {
+			s.failSession(session, err, "签名轮数超出上限")
+			return
+		}
 		
-		// 处理签名请求
-		if sigReq, ok := result.SignatureRequest.(map[string]any); ok {
-			signatureRequest := &SignatureRequest{}
-			if action, exists := sigReq["action"]; exists {
-				if actionStr, ok := action.(string); ok {
-					signatureRequest.Action = actionStr
-				}
-			}
-			if token, exists := sigReq["token"]; exists {
-				if tokenStr, ok := token.(string); ok {
-					signatureRequest.ToToken = tokenStr
-				}
-			}
-			if amount, exists := sigReq["amount"]; exists {
-				if amountStr, ok := amount.(string); ok {
-					signatureRequest.Amount = amountStr
-				}
-			}
-			if toAddress, exists := sigReq["to_address"]; exists {
-				if addressStr, ok := toAddress.(string); ok {
-					signatureRequest.ToAddress = addressStr
-				}
-			}
-			if value, exists := sigReq["value"]; exists {
-				if valueStr, ok := value.(string); ok {
-					signatureRequest.Value = valueStr
-				}
-			}
-			if data, exists := sigReq["data"]; exists {
-				if dataStr, ok := data.(string); ok {
-					signatureRequest.Data = dataStr
-				}
-			}
-			if gasLimit, exists := sigReq["gas_limit"]; exists {
-				if gasLimitStr, ok := gasLimit.(string); ok {
-					signatureRequest.GasLimit = gasLimitStr
-				}
-			}
-			if gasPrice, exists := sigReq["gas_price"]; exists {
-				if gasPriceStr, ok := gasPrice.(string); ok {
-					signatureRequest.GasPrice = gasPriceStr
-				}
-			}
-			session.SignatureRequest = signatureRequest
+		if signatureRequest := status.SignatureRequest; signatureRequest != nil {
+			session.update(func() {
+				signatureRequest.ManualConfirmation = session.ManualConfirmation
+				session.SignatureRequest = signatureRequest
+			})
 			
-			log.Printf("✅ 新签名请求已保存到会话")
-			log.Printf("📋 签名请求详情: action=%s, token=%s, amount=%s", 
-				signatureRequest.Action, signatureRequest.ToToken, signatureRequest.Amount)
+			log.Printf("✅ 签名请求已保存到会话")
+			log.Printf("📋 签名请求详情: action=%s, from=%s->%s, amount=%s", 
+				signatureRequest.Action, signatureRequest.FromToken, signatureRequest.ToToken, signatureRequest.Amount)
 			log.Printf("📋 交易数据: to=%s, value=%s, data=%s", 
 				signatureRequest.ToAddress, signatureRequest.Value, signatureRequest.Data)
 		}
 		
-		s.updateSessionStatus(session, "waiting_signature", "等待用户签名授权")
+		s.updateSessionStatus(session, status.Status, status.Message)
 		
 		// 发送签名请求
 		s.sendSessionUpdate(session, "signature_request", result.SignatureRequest)
 		return
 	}
 	
+	// 没有可执行任务，不标记为完成
+	if status.Status == qng.StatusNoTasks {
+		log.Printf("⚠️  未识别到可执行的任务")
+		session.update(func() {
+			session.Result = status.Result
+		})
+		s.updateSessionStatus(session, status.Status, status.Message)
+		s.sendSessionUpdate(session, "result", status.Result)
+		return
+	}
+	
 	// 工作流完成
 	log.Printf("✅ 工作流执行完成")
-	session.Result = result.FinalResult
-	s.updateSessionStatus(session, "completed", "工作流执行完成")
+	s.recordTaskProgress(session)
+	session.update(func() {
+		session.Result = result.FinalResult
+	})
+	s.updateSessionStatus(session, status.Status, status.Message)
 	
 	// 发送结果
 	s.sendSessionUpdate(session, "result", result.FinalResult)
 }
 
+func (s *QNGServer) resumeWorkflow(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("🔄 恢复工作流")
+	
+	sessionID, ok := params["session_id"].(string)
+	if !ok {
+		log.Printf("❌ 缺少session_id参数")
+		return nil, missingParam("session_id")
+	}
+	
+	session, exists := s.getSession(sessionID)
+	
+	if !exists {
+		log.Printf("❌ 会话不存在: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
+	}
+	
+	var status string
+	var progress *qng.TaskProgress
+	session.read(func() {
+		status, progress = session.Status, session.TaskProgress
+	})
+	if status != "failed" && status != "confirmation_failed" && status != StatusInterrupted {
+		log.Printf("❌ 会话状态不正确: %s", status)
+		return nil, invalidState(session, "session not in failed status")
+	}
+	
+	if progress == nil {
+		log.Printf("❌ 会话没有可恢复的任务进度")
+		return nil, invalidState(session, "session has no recorded task progress")
+	}
+	
+	log.Printf("📋 已完成任务: %v", progress.CompletedTasks)
+	
+	session.update(func() {
+		session.Error = nil
+	})
+	s.updateSessionStatus(session, "running", "正在恢复未完成的任务...")
+	
+	// 异步恢复工作流
+	go s.resumeWorkflowAsync(session)
+	
+	return map[string]any{
+		"session_id":      session.ID,
+		"status":          "processing",
+		"completed_tasks": progress.CompletedTasks,
+		"message":         "工作流正在从未完成的任务恢复...",
+	}, nil
+}
+
+func (s *QNGServer) resumeWorkflowAsync(session *Session) {
+	log.Printf("🔄 异步恢复工作流")
+	log.Printf("📋 会话ID: %s", session.ID)
+	
+	// 创建上下文，会话被取消时进行中的 LLM 与 RPC 调用随之放弃
+	ctx, cancel := s.workflowContext(session)
+	defer cancel()
+	ctx = s.withConfirmationProgress(ctx, session)
+	
+	var progress *qng.TaskProgress
+	session.read(func() {
+		progress = session.TaskProgress
+	})
+	result, err := s.chain.ResumeWorkflow(ctx, progress)
+	if s.abandoned(ctx, session, result, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 恢复工作流失败: %v", err)
+		s.failSession(session, err, "恢复执行失败")
+		return
+	}
+	
+	s.handleResult(session, result)
+}
+
+func (s *QNGServer) retryConfirmation(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("🔄 重试交易确认")
+	
+	sessionID, ok := params["session_id"].(string)
+	if !ok {
+		log.Printf("❌ 缺少session_id参数")
+		return nil, missingParam("session_id")
+	}
+	
+	session, exists := s.getSession(sessionID)
+	
+	if !exists {
+		log.Printf("❌ 会话不存在: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
+	}
+	
+	var status string
+	var sessionErr *SessionError
+	session.read(func() {
+		status, sessionErr = session.Status, session.Error
+	})
+	if status != "confirmation_failed" || sessionErr == nil {
+		log.Printf("❌ 会话状态不正确: %s", status)
+		return nil, invalidState(session, "session not in confirmation_failed status")
+	}
+	
+	if !sessionErr.Retryable {
+		log.Printf("❌ 交易确认失败不可重试: %s", sessionErr.Type)
+		return nil, invalidState(session, "confirmation failure %s is not retryable", sessionErr.Type)
+	}
+	
+	txHash := sessionErr.TxHash
+	log.Printf("⏳ 重新等待交易确认: %s", txHash)
+	
+	session.update(func() {
+		session.Error = nil
+	})
+	s.updateSessionStatus(session, "running", "正在重新等待交易确认...")
+	
+	// 使用同一交易哈希重新进入签名验证节点，仅重新等待确认
+	go s.continueWorkflowWithSignature(session, txHash, s.trackedGas(session, txHash))
+	
+	return map[string]any{
+		"session_id": session.ID,
+		"status":     "processing",
+		"tx_hash":    txHash,
+		"message":    "正在重新等待交易确认...",
+	}, nil
+}
+
+// replaceTransaction 为确认失败的会话中卡住的交易构建取消或加速交易，需要用户签名时会话回到 waiting_signature
+func (s *QNGServer) replaceTransaction(ctx context.Context, params map[string]any, kind string) (any, error) {
+	log.Printf("🔄 替换卡住的交易: %s", kind)
+	
+	sessionID, ok := params["session_id"].(string)
+	if !ok {
+		log.Printf("❌ 缺少session_id参数")
+		return nil, missingParam("session_id")
+	}
+	
+	session, exists := s.getSession(sessionID)
+	
+	if !exists {
+		log.Printf("❌ 会话不存在: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
+	}
+	
+	var status string
+	var sessionErr *SessionError
+	session.read(func() {
+		status, sessionErr = session.Status, session.Error
+	})
+	if status != "confirmation_failed" || sessionErr == nil || sessionErr.TxHash == "" {
+		log.Printf("❌ 会话状态不正确: %s", status)
+		return nil, invalidState(session, "session not in confirmation_failed status")
+	}
+	
+	txHash := sessionErr.TxHash
+	replacement, err := s.chain.ReplaceTransaction(ctx, txHash, kind)
+	if err != nil {
+		log.Printf("❌ 构建替换交易失败: %v", err)
+		return nil, upstreamError(err)
+	}
+	session.update(func() {
+		session.Error = nil
+	})
+	
+	// 服务端签名器已广播替换交易，直接按提交签名的流程继续
+	if replacement.TxHash != "" {
+		s.trackTransactions(session, replacement.TxHash, nil)
+		if kind == contracts.ReplaceCancel {
+			s.updateSessionStatus(session, "running", "正在等待取消交易确认...")
+			go s.confirmCancellation(session, txHash, replacement.TxHash)
+		} else {
+			s.updateSessionStatus(session, "running", "正在等待加速交易确认...")
+			go s.continueWorkflowWithSignature(session, replacement.TxHash, nil)
+		}
+		return map[string]any{
+			"session_id": session.ID,
+			"status":     "processing",
+			"replaces":   txHash,
+			"tx_hash":    replacement.TxHash,
+		}, nil
+	}
+	
+	signatureRequest := SignatureRequestFromMap(replacement.SignatureRequest)
+	session.update(func() {
+		signatureRequest.ManualConfirmation = session.ManualConfirmation
+		session.Replacement = replacement
+		session.SignatureRequest = signatureRequest
+	})
+	message := "等待用户签名加速交易"
+	if kind == contracts.ReplaceCancel {
+		message = "等待用户签名取消交易"
+	}
+	s.updateSessionStatus(session, "waiting_signature", message)
+	s.sendSessionUpdate(session, "signature_request", replacement.SignatureRequest)
+	
+	return map[string]any{
+		"session_id":        session.ID,
+		"status":            "waiting_signature",
+		"replaces":          txHash,
+		"signature_request": signatureRequest,
+	}, nil
+}
+
+// confirmCancellation 等待取消交易确认。原交易被取消后工作流以 cancelled 结束，可通过 resume_workflow 重新执行未完成的任务；
+// 取消交易未确认时会话回到 confirmation_failed，错误中记录原交易哈希
+func (s *QNGServer) confirmCancellation(session *Session, originalTxHash, txHash string) {
+	log.Printf("⏳ 等待取消交易确认: %s (替换 %s)", txHash, originalTxHash)
+	
+	ctx := context.WithValue(context.Background(), "session_id", session.ID)
+	s.recordTaskProgress(session)
+	
+	if err := s.chain.ConfirmTransaction(ctx, txHash); err != nil {
+		log.Printf("❌ 取消交易确认失败: %v", err)
+		// 取消未生效，原交易仍可能上链。会话回到 confirmation_failed 并保留原交易哈希，
+		// 可以再次取消、加速或重新等待原交易确认
+		kind := qng.ConfirmationRPCError
+		var confirmErr *qng.ConfirmationError
+		if errors.As(err, &confirmErr) {
+			kind = confirmErr.Kind
+		}
+		message := fmt.Sprintf("取消交易 %s 未确认，原交易 %s 仍可能上链，请在钱包中核对: %v", txHash, originalTxHash, err)
+		s.setSessionError(session, &SessionError{
+			Type:      kind,
+			Message:   message,
+			TxHash:    originalTxHash,
+			Retryable: true,
+		}, "confirmation_failed", message)
+		return
+	}
+	
+	message := fmt.Sprintf("交易 %s 已被取消交易 %s 替换", originalTxHash, txHash)
+	s.setSessionError(session, &SessionError{
+		Type:    "cancelled",
+		Message: message,
+		TxHash:  txHash,
+	}, "failed", message)
+}
+
+func (s *QNGServer) sendRawTransaction(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("📤 广播已签名交易")
+	
+	signedTx, ok := params["signed_tx"].(string)
+	if !ok || signedTx == "" {
+		log.Printf("❌ 缺少signed_tx参数")
+		return nil, missingParam("signed_tx")
+	}
+	
+	txHash, err := s.chain.SendRawTransaction(ctx, signedTx)
+	if err != nil {
+		log.Printf("❌ 广播交易失败: %v", err)
+		return nil, upstreamError(err)
+	}
+	
+	return map[string]any{
+		"tx_hash": txHash,
+		"status":  "broadcast",
+	}, nil
+}
+
+// getTokens 返回代币注册表（精度、地址、是否原生代币），可按 symbol 过滤
+func (s *QNGServer) getTokens(ctx context.Context, params map[string]any) (any, error) {
+	tokens, err := s.chain.GetTokens()
+	if err != nil {
+		log.Printf("❌ 获取代币信息失败: %v", err)
+		return nil, err
+	}
+	
+	if symbol, ok := params["symbol"].(string); ok && symbol != "" {
+		for _, token := range tokens {
+			if strings.EqualFold(token.Symbol, symbol) {
+				return map[string]any{"tokens": []any{token}}, nil
+			}
+		}
+		return nil, newError(CodeNotFound, "unknown token: %s", symbol).WithDetail("symbol", symbol)
+	}
+	
+	return map[string]any{
+		"tokens": tokens,
+	}, nil
+}
+
+// getStakingPosition 查询地址在质押池中的质押数量与待领取奖励
+func (s *QNGServer) getStakingPosition(ctx context.Context, params map[string]any) (any, error) {
+	address, ok := params["address"].(string)
+	if !ok || address == "" {
+		log.Printf("❌ 缺少address参数")
+		return nil, missingParam("address")
+	}
+	pool, _ := params["pool"].(string)
+	
+	position, err := s.chain.GetStakingPosition(ctx, pool, address)
+	if err != nil {
+		log.Printf("❌ 查询质押仓位失败: %v", err)
+		return nil, upstreamError(err)
+	}
+	
+	return map[string]any{
+		"position": position,
+	}, nil
+}
+
+// signatureStatusMessage 等待签名时的状态消息，附带签名请求的说明（含格式化的数量）与警告
+func signatureStatusMessage(signatureRequest any) string {
+	message := "等待用户签名授权"
+	request, ok := signatureRequest.(map[string]any)
+	if !ok {
+		return message
+	}
+	if description, ok := request["description"].(string); ok && description != "" {
+		message += ": " + description
+	}
+	// 不可信合约、待确认交易等警告需要在签名前让用户看到
+	if warning, ok := request["warning"].(string); ok && warning != "" {
+		message += "\n⚠️ " + warning
+	}
+	return message
+}
+
+// completionMessage 使用结果中的完成消息（含浏览器链接），没有时使用默认消息
+func completionMessage(finalResult any) string {
+	if final, ok := finalResult.(map[string]any); ok {
+		if message, ok := final["message"].(string); ok && message != "" {
+			return message
+		}
+	}
+	return "工作流执行完成"
+}
+
+// failSession 将会话标记为失败，交易确认失败时记录具体原因并进入 confirmation_failed 状态
+func (s *QNGServer) failSession(session *Session, err error, prefix string) {
+	message := fmt.Sprintf("%s: %v", prefix, err)
+	
+	// 节点失败时附带补偿报告，任务进度以失败时的状态为准
+	var report *qng.FailureReport
+	statusMessage := message
+	var failure *qng.WorkflowFailure
+	if errors.As(err, &failure) {
+		report = failure.Report
+		if failure.Progress != nil {
+			session.update(func() {
+				session.TaskProgress = failure.Progress
+			})
+		}
+		if report != nil {
+			statusMessage += report.Summary()
+		}
+	}
+	
+	var confirmErr *qng.ConfirmationError
+	if errors.As(err, &confirmErr) {
+		s.setSessionError(session, &SessionError{
+			Type:      confirmErr.Kind,
+			Message:   message,
+			TxHash:    confirmErr.TxHash,
+			Retryable: confirmErr.Retryable(),
+			Report:    report,
+		}, "confirmation_failed", statusMessage)
+		return
+	}
+	
+	var limitErr *qng.SpendingLimitError
+	if errors.As(err, &limitErr) {
+		s.setSessionError(session, &SessionError{
+			Type:    "spending_limit",
+			Message: message,
+			Report:  report,
+		}, "limit_exceeded", statusMessage)
+		return
+	}
+	
+	var balanceErr *contracts.InsufficientBalanceError
+	if errors.As(err, &balanceErr) {
+		s.setSessionError(session, &SessionError{
+			Type:    "insufficient_balance",
+			Message: message,
+			Balance: balanceErr,
+			Report:  report,
+		}, "failed", statusMessage)
+		return
+	}
+	
+	errorType := "execution"
+	if errors.Is(err, qng.ErrNodeTimeout) {
+		errorType = "timeout"
+	} else if errors.Is(err, qng.ErrTooManySignatureRounds) {
+		errorType = "signature_rounds"
+	}
+	s.setSessionError(session, &SessionError{
+		Type:      errorType,
+		Message:   message,
+		Retryable: errorType == "timeout",
+		Report:    report,
+	}, "failed", statusMessage)
+}
+
+// setSessionError 记录会话错误、更新状态并推送错误更新
+func (s *QNGServer) setSessionError(session *Session, sessionErr *SessionError, status, message string) {
+	session.update(func() {
+		session.Error = sessionErr
+	})
+	s.updateSessionStatus(session, status, message)
+	s.sendSessionUpdate(session, "error", sessionErr)
+}
+
+// countSignatureRound 记录一轮新的签名请求，超过 max_signature_rounds 时返回错误
+func (s *QNGServer) countSignatureRound(session *Session) error {
+	var rounds int
+	session.update(func() {
+		session.SignatureRounds++
+		rounds = session.SignatureRounds
+	})
+	if err := qng.CheckSignatureRounds(rounds, s.config.MaxSignatureRounds); err != nil {
+		log.Printf("🛑 会话 %s 的签名轮数超出上限: %v", session.ID, err)
+		return err
+	}
+	log.Printf("🔢 签名轮数: %d/%d", rounds, qng.MaxSignatureRounds(s.config.MaxSignatureRounds))
+	return nil
+}
+
+// recordTaskProgress 从工作流上下文中记录已完成任务和交易哈希
+func (s *QNGServer) recordTaskProgress(session *Session) {
+	session.mu.Lock()
+	progress := qng.ExtractTaskProgress(session.Context)
+	if progress != nil {
+		session.TaskProgress = progress
+	}
+	session.mu.Unlock()
+	if progress != nil {
+		log.Printf("📋 已记录任务进度: 完成 %d/%d", len(progress.CompletedTasks), len(progress.Tasks))
+	}
+}
+
 func (s *QNGServer) pollSession(ctx context.Context, params map[string]any) (any, error) {
 	log.Printf("🔄 Long Polling会话")
 	
 	sessionID, ok := params["session_id"].(string)
 	if !ok {
 		log.Printf("❌ 缺少session_id参数")
-		return nil, fmt.Errorf("session_id parameter required")
+		return nil, missingParam("session_id")
 	}
 	
 	timeout, ok := params["timeout"].(int)
@@ -428,13 +1016,11 @@ func (s *QNGServer) pollSession(ctx context.Context, params map[string]any) (any
 		timeout = 30 // 默认30秒
 	}
 	
-	s.sessionsMu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.sessionsMu.RUnlock()
+	session, exists := s.getSession(sessionID)
 	
 	if !exists {
 		log.Printf("❌ 会话不存在: %s", sessionID)
-		return nil, fmt.Errorf("session not found: %s", sessionID)
+		return nil, s.sessionNotFound(sessionID)
 	}
 	
 	log.Printf("⏰ 等待会话更新，超时时间: %d秒", timeout)
@@ -465,16 +1051,63 @@ func (s *QNGServer) pollSession(ctx context.Context, params map[string]any) (any
 }
 
 func (s *QNGServer) updateSessionStatus(session *Session, status, message string) {
+	session.mu.Lock()
+	// 已取消的会话不再被仍在退出的工作流覆盖状态
+	if session.Status == StatusCancelled && status != StatusCancelled {
+		session.mu.Unlock()
+		log.Printf("⚠️  会话已取消，忽略状态更新: %s", status)
+		return
+	}
 	log.Printf("🔄 更新会话状态: %s -> %s", session.Status, status)
 	
+	if finishedStatuses[status] && !finishedStatuses[session.Status] {
+		observeWorkflow(session, status)
+	}
 	session.Status = status
 	session.Message = message
 	session.UpdatedAt = time.Now().Format(time.RFC3339)
-	session.CreatedAt = time.Now().Format(time.RFC3339)
+	session.mu.Unlock()
+	s.persistSession(session)
 	
 	log.Printf("✅ 会话状态已更新")
 }
 
+// finishedStatuses 工作流结束的会话状态，进入这些状态时记录工作流指标
+var finishedStatuses = map[string]bool{
+	"completed":       true,
+	"failed":          true,
+	"limit_exceeded":  true,
+	qng.StatusNoTasks: true,
+	StatusCancelled:   true,
+}
+
+// observeWorkflow 按结束状态记录工作流数量与从创建到结束的耗时
+func observeWorkflow(session *Session, status string) {
+	var duration time.Duration
+	if created, err := time.Parse(time.RFC3339, session.CreatedAt); err == nil {
+		duration = time.Since(created)
+	}
+	metrics.ObserveWorkflow(status, duration)
+}
+
+// withConfirmationProgress 将签名验证节点的确认进度写入会话状态，并推送到会话的更新通道
+func (s *QNGServer) withConfirmationProgress(ctx context.Context, session *Session) context.Context {
+	return qng.WithConfirmationProgress(ctx, func(progress qng.ConfirmationProgress) {
+		message := fmt.Sprintf("等待交易确认: %d/%d", progress.Confirmations, progress.Required)
+		switch {
+		case !progress.Mined:
+			message = "等待交易被打包..."
+		case progress.Strategy == rpc.StrategyFinalized && !progress.Finalized:
+			message = fmt.Sprintf("等待区块最终确定，已有 %d 个确认", progress.Confirmations)
+		}
+		if progress.TaskID != "" {
+			message = fmt.Sprintf("%s (任务 %s)", message, progress.TaskID)
+		}
+		s.updateSessionStatus(session, "running", message)
+		s.sendSessionUpdate(session, "confirmation_progress", progress)
+	})
+}
+
 func (s *QNGServer) sendSessionUpdate(session *Session, updateType string, data any) {
 	log.Printf("📤 发送会话更新: %s", updateType)
 	
@@ -505,6 +1138,12 @@ func (s *QNGServer) GetCapabilities() []Capability {
 					Description: "用户消息",
 					Required:    true,
 				},
+				{
+					Name:        "dry_run",
+					Type:        "boolean",
+					Description: "预演模式：只返回按顺序排列的计划交易（含 gas 与 value），不请求签名",
+					Required:    false,
+				},
 			},
 		},
 		{
@@ -535,6 +1174,132 @@ func (s *QNGServer) GetCapabilities() []Capability {
 					Description: "用户签名",
 					Required:    true,
 				},
+				{
+					Name:        "gas_price",
+					Type:        "string",
+					Description: "重新广播交易使用的 gasPrice（wei，十进制或0x十六进制）",
+					Required:    false,
+				},
+				{
+					Name:        "max_fee_per_gas",
+					Type:        "string",
+					Description: "重新广播交易使用的 maxFeePerGas（wei）",
+					Required:    false,
+				},
+				{
+					Name:        "max_priority_fee_per_gas",
+					Type:        "string",
+					Description: "重新广播交易使用的 maxPriorityFeePerGas（wei）",
+					Required:    false,
+				},
+				{
+					Name:        "gas_limit",
+					Type:        "string",
+					Description: "重新广播交易使用的 gas 上限",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "resume_workflow",
+			Description: "从已完成的任务恢复失败的工作流",
+			Parameters: []Parameter{
+				{
+					Name:        "session_id",
+					Type:        "string",
+					Description: "会话ID",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "retry_confirmation",
+			Description: "重新等待超时或RPC失败的交易确认",
+			Parameters: []Parameter{
+				{
+					Name:        "session_id",
+					Type:        "string",
+					Description: "会话ID",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "cancel_workflow",
+			Description: "取消进行中或等待签名的工作流，已广播的交易不受影响",
+			Parameters: []Parameter{
+				{
+					Name:        "session_id",
+					Type:        "string",
+					Description: "会话ID或工作流ID",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "cancel_transaction",
+			Description: "以相同nonce、更高费用发送0值交易，取消确认超时的交易",
+			Parameters: []Parameter{
+				{
+					Name:        "session_id",
+					Type:        "string",
+					Description: "会话ID",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "speed_up_transaction",
+			Description: "以相同nonce、更高费用重新发送确认超时的交易",
+			Parameters: []Parameter{
+				{
+					Name:        "session_id",
+					Type:        "string",
+					Description: "会话ID",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "send_raw_transaction",
+			Description: "广播已签名的交易",
+			Parameters: []Parameter{
+				{
+					Name:        "signed_tx",
+					Type:        "string",
+					Description: "已签名交易的十六进制编码",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "get_tokens",
+			Description: "获取支持的代币信息（精度、合约地址、是否原生代币）",
+			Parameters: []Parameter{
+				{
+					Name:        "symbol",
+					Type:        "string",
+					Description: "代币符号，为空时返回全部代币",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "get_staking_position",
+			Description: "查询地址在质押池中的质押数量与待领取奖励",
+			Parameters: []Parameter{
+				{
+					Name:        "address",
+					Type:        "string",
+					Description: "用户钱包地址",
+					Required:    true,
+				},
+				{
+					Name:        "pool",
+					Type:        "string",
+					Description: "质押池名称或别名，为空时使用默认池",
+					Required:    false,
+				},
 			},
 		},
 		{
@@ -558,10 +1323,3 @@ func (s *QNGServer) GetCapabilities() []Capability {
 	}
 }
 
-func generateSessionID() string {
-	return fmt.Sprintf("session_%d", time.Now().UnixNano())
-}
-
-func generateWorkflowID() string {
-	return fmt.Sprintf("workflow_%d", time.Now().UnixNano())
-}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"fmt"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+)
+
+// TestConfirmCancellation 取消交易确认后会话以 cancelled 结束；未确认时原交易仍可能上链，
+// 会话回到 confirmation_failed 并保留原交易哈希以便再次替换
+func TestConfirmCancellation(t *testing.T) {
+	originalTx := fmt.Sprintf("0x%064x", 0xa1)
+	cancelTx := fmt.Sprintf("0x%064x", 0xc1)
+
+	tests := []struct {
+		name          string
+		receiptStatus string
+		wantStatus    string
+		wantType      string
+		wantTxHash    string
+		wantRetryable bool
+	}{
+		{name: "confirmed", receiptStatus: "0x1", wantStatus: "failed", wantType: "cancelled", wantTxHash: cancelTx},
+		{name: "not confirmed", receiptStatus: "0x0", wantStatus: "confirmation_failed", wantType: qng.ConfirmationReverted, wantTxHash: originalTx, wantRetryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, node := newSequenceChain(t)
+			node.On("eth_getTransactionReceipt", func(params []interface{}) (interface{}, *rpc.RPCError) {
+				return map[string]interface{}{
+					"transactionHash": params[0],
+					"blockNumber":     "0x1",
+					"status":          tt.receiptStatus,
+					"gasUsed":         "0x5208",
+				}, nil
+			})
+			server := NewQNGServerWithChain(config.QNGConfig{}, chain)
+			session := &Session{ID: "session_cancel", Status: "running"}
+
+			server.confirmCancellation(session, originalTx, cancelTx)
+
+			if session.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", session.Status, tt.wantStatus)
+			}
+			if session.Error == nil {
+				t.Fatal("session error not recorded")
+			}
+			if session.Error.Type != tt.wantType || session.Error.TxHash != tt.wantTxHash || session.Error.Retryable != tt.wantRetryable {
+				t.Errorf("error = %+v, want type %q tx %s retryable %v", session.Error, tt.wantType, tt.wantTxHash, tt.wantRetryable)
+			}
+		})
+	}
+}
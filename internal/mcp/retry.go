@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"qng_agent/internal/config"
+	"time"
+)
+
+// defaultIdempotentMethods 只读方法，重复调用不会产生副作用
+var defaultIdempotentMethods = []string{
+	"get_session_status",
+	"poll_session",
+	"get_tokens",
+	"get_staking_position",
+	"get_accounts",
+	"get_balance",
+	"get_network",
+}
+
+// retryableError 可重试的瞬时错误（网络错误、5xx、429），错误信息保持不变
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// retryPolicy HTTP 调用重试策略
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	idempotent  map[string]bool
+}
+
+func newRetryPolicy(cfg config.MCPRetryConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     time.Duration(cfg.Backoff) * time.Millisecond,
+		maxBackoff:  time.Duration(cfg.MaxBackoff) * time.Millisecond,
+		idempotent:  make(map[string]bool),
+	}
+	if policy.maxAttempts < 1 {
+		policy.maxAttempts = 1
+	}
+	if policy.backoff <= 0 {
+		policy.backoff = 500 * time.Millisecond
+	}
+	if policy.maxBackoff < policy.backoff {
+		policy.maxBackoff = policy.backoff
+	}
+
+	methods := cfg.IdempotentMethods
+	if len(methods) == 0 {
+		methods = defaultIdempotentMethods
+	}
+	for _, method := range methods {
+		policy.idempotent[method] = true
+	}
+	return policy
+}
+
+// attemptsFor 返回方法允许的尝试次数，非幂等方法只尝试一次
+func (p retryPolicy) attemptsFor(method string) int {
+	if !p.idempotent[method] {
+		return 1
+	}
+	return p.maxAttempts
+}
+
+// do 按策略执行调用，仅在返回可重试错误时按指数退避重试
+func (p retryPolicy) do(ctx context.Context, name string, attempts int, fn func() (any, error)) (any, error) {
+	delay := p.backoff
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		var retryErr *retryableError
+		if err == nil || !errors.As(err, &retryErr) || attempt >= attempts {
+			return result, err
+		}
+
+		log.Printf("🔁 %s 第 %d/%d 次尝试失败，%v 后重试: %v", name, attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > p.maxBackoff {
+			delay = p.maxBackoff
+		}
+	}
+}
+
+// retryableStatus 判断 HTTP 状态码是否为瞬时错误
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// transient 将错误标记为可重试
+func transient(err error) error {
+	return &retryableError{err: err}
+}
@@ -2,16 +2,23 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"qng_agent/internal/config"
 	"sync"
 )
 
+// ErrMethodNotPermitted 方法不在服务的允许列表中
+var ErrMethodNotPermitted = errors.New("method not permitted")
+
 type Server struct {
 	config      config.MCPConfig
 	qngServer   *QNGServer
 	metamaskServer *MetaMaskServer
+	// initErr 子服务初始化失败的错误，Start 时返回以拒绝启动
+	initErr     error
 	mu          sync.RWMutex
 	running     bool
 }
@@ -28,8 +35,14 @@ func NewServer(config config.MCPConfig) *Server {
 	// 初始化QNG服务器
 	if config.QNG.Enabled {
 		log.Printf("🔧 初始化QNG MCP服务器")
-		server.qngServer = NewQNGServer(config.QNG)
-		log.Printf("✅ QNG服务器初始化完成")
+		qngServer, err := NewQNGServer(config.QNG)
+		if err != nil {
+			log.Printf("❌ QNG服务器初始化失败: %v", err)
+			server.initErr = err
+		} else {
+			server.qngServer = qngServer
+			log.Printf("✅ QNG服务器初始化完成")
+		}
 	} else {
 		log.Printf("⚠️  QNG服务未启用")
 	}
@@ -39,6 +52,10 @@ func NewServer(config config.MCPConfig) *Server {
 		log.Printf("🔧 初始化MetaMask MCP服务器")
 		server.metamaskServer = NewMetaMaskServer(config.MetaMask)
 		log.Printf("✅ MetaMask服务器初始化完成")
+		// 工作流的只读查询与 get_balance 使用相同的账户解析
+		if server.qngServer != nil {
+			server.qngServer.readAccount = server.metamaskServer.readAccount
+		}
 	} else {
 		log.Printf("⚠️  MetaMask服务未启用")
 	}
@@ -49,6 +66,10 @@ func NewServer(config config.MCPConfig) *Server {
 func (s *Server) Start() error {
 	log.Printf("🚀 MCP服务器启动")
 	
+	if s.initErr != nil {
+		return fmt.Errorf("failed to initialize QNG server: %w", s.initErr)
+	}
+	
 	s.mu.Lock()
 	s.running = true
 	s.mu.Unlock()
@@ -77,6 +98,28 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// EnableSessionStore 按数据库配置启用QNG会话持久化，目前只支持 SQLite
+func (s *Server) EnableSessionStore(cfg config.DatabaseConfig) error {
+	if s.qngServer == nil {
+		return nil
+	}
+	if cfg.Driver != "sqlite" {
+		log.Printf("⚠️  会话持久化只支持 sqlite，当前驱动为 %q，会话仅保存在内存中", cfg.Driver)
+		return nil
+	}
+
+	store, err := OpenSessionStore(cfg.SQLite.Path)
+	if err != nil {
+		return err
+	}
+	if err := s.qngServer.UseSessionStore(store); err != nil {
+		store.Close()
+		return err
+	}
+	log.Printf("💾 QNG会话持久化已启用: %s", cfg.SQLite.Path)
+	return nil
+}
+
 func (s *Server) Stop() error {
 	log.Printf("🛑 MCP服务器停止")
 	
@@ -108,25 +151,28 @@ func (s *Server) Stop() error {
 	return nil
 }
 
+// Call 调用服务方法。所有 HTTP 路由都经由 Call 分发，因此在这里按允许列表检查方法
 func (s *Server) Call(ctx context.Context, service string, method string, params map[string]any) (any, error) {
-	log.Printf("🔄 MCP服务器调用")
-	log.Printf("🔧 服务: %s", service)
-	log.Printf("🛠️  方法: %s", method)
-	log.Printf("📋 参数: %+v", params)
+	slog.InfoContext(ctx, "🔄 MCP服务器调用", "service", service, "method", method)
+	slog.DebugContext(ctx, "📋 调用参数", "params", params)
 	
 	s.mu.RLock()
 	if !s.running {
 		s.mu.RUnlock()
 		log.Printf("❌ MCP服务器未运行")
-		return nil, fmt.Errorf("MCP server is not running")
+		return nil, newError(CodeUnavailable, "MCP server is not running")
 	}
 	s.mu.RUnlock()
 	
+	if err := s.CheckMethodAllowed(service, method); err != nil {
+		return nil, err
+	}
+	
 	switch service {
 	case "qng":
 		if s.qngServer == nil {
 			log.Printf("❌ QNG服务未启用")
-			return nil, fmt.Errorf("QNG service not enabled")
+			return nil, newError(CodeUnavailable, "QNG service not enabled")
 		}
 		log.Printf("🔄 调用QNG服务")
 		return s.qngServer.Call(ctx, method, params)
@@ -134,34 +180,47 @@ func (s *Server) Call(ctx context.Context, service string, method string, params
 	case "metamask":
 		if s.metamaskServer == nil {
 			log.Printf("❌ MetaMask服务未启用")
-			return nil, fmt.Errorf("MetaMask service not enabled")
+			return nil, newError(CodeUnavailable, "MetaMask service not enabled")
 		}
 		log.Printf("🔄 调用MetaMask服务")
 		return s.metamaskServer.Call(ctx, method, params)
 		
 	default:
 		log.Printf("❌ 未知服务: %s", service)
-		return nil, fmt.Errorf("unknown service: %s", service)
+		return nil, newError(CodeNotFound, "unknown service: %s", service).WithDetail("service", service)
 	}
 }
 
-func (s *Server) GetCapabilities() map[string][]Capability {
-	log.Printf("📋 获取MCP服务器能力")
-	
-	capabilities := make(map[string][]Capability)
+// CheckMethodAllowed 检查方法是否在服务配置的允许列表中，未配置允许列表时允许所有方法
+func (s *Server) CheckMethodAllowed(service, method string) error {
+	var allowed []string
+	switch service {
+	case "qng":
+		allowed = s.config.QNG.AllowedMethods
+	case "metamask":
+		allowed = s.config.MetaMask.AllowedMethods
+	}
 	
-	// QNG服务能力
-	if s.qngServer != nil {
-		log.Printf("📋 获取QNG服务能力")
-		capabilities["qng"] = s.qngServer.GetCapabilities()
+	if len(allowed) == 0 {
+		return nil
 	}
 	
-	// MetaMask服务能力
-	if s.metamaskServer != nil {
-		log.Printf("📋 获取MetaMask服务能力")
-		capabilities["metamask"] = s.metamaskServer.GetCapabilities()
+	for _, m := range allowed {
+		if m == method {
+			return nil
+		}
 	}
 	
+	log.Printf("❌ 方法不允许调用: %s.%s", service, method)
+	return newError(CodeForbidden, "%w: %s.%s", ErrMethodNotPermitted, service, method)
+}
+
+// GetCapabilities 返回正常响应的服务能力，单个服务失败不影响其它服务，失败详情见 CapabilityReport
+func (s *Server) GetCapabilities() map[string][]Capability {
+	log.Printf("📋 获取MCP服务器能力")
+	
+	capabilities, _ := s.CapabilityReport()
+	
 	log.Printf("✅ 返回 %d 个服务的能力", len(capabilities))
 	return capabilities
 }
@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"qng_agent/internal/config"
+)
+
+func TestServerCallEnforcesAllowedMethods(t *testing.T) {
+	server := NewServer(config.MCPConfig{
+		MetaMask: config.MetaMaskConfig{
+			Enabled:        true,
+			Mock:           true,
+			AllowedMethods: []string{"get_network"},
+		},
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer server.Stop()
+
+	tests := []struct {
+		method    string
+		forbidden bool
+	}{
+		{method: "get_network"},
+		{method: "connect_wallet", forbidden: true},
+		{method: "sign_transaction", forbidden: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			_, err := server.Call(context.Background(), "metamask", tt.method, map[string]any{})
+			if got := errors.Is(err, ErrMethodNotPermitted); got != tt.forbidden {
+				t.Fatalf("Call(%s) error = %v, forbidden = %v, want %v", tt.method, err, got, tt.forbidden)
+			}
+			if tt.forbidden {
+				if status, _ := ErrorResponse(err); status != 403 {
+					t.Errorf("status = %d, want 403", status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"qng_agent/internal/qng"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrSessionNotStored 会话存储中没有该会话
+var ErrSessionNotStored = errors.New("session not stored")
+
+// SessionStore 基于 SQLite 的会话持久化存储，服务重启后可重新加载等待签名等未完成的会话
+type SessionStore struct {
+	db *sql.DB
+}
+
+// OpenSessionStore 打开（必要时创建）SQLite 数据库与会话表
+func OpenSessionStore(path string) (*SessionStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path not configured")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite 只允许单个写连接，避免并发写入时的 database is locked 错误
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		id          TEXT PRIMARY KEY,
+		workflow_id TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		data        TEXT NOT NULL,
+		updated_at  TEXT NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sessions table: %w", err)
+	}
+
+	return &SessionStore{db: db}, nil
+}
+
+// Close 关闭数据库
+func (st *SessionStore) Close() error {
+	return st.db.Close()
+}
+
+// SaveSession 保存会话（状态、消息、工作流上下文、签名请求等），已存在时覆盖。
+// 在会话锁内编码，保存的是某一时刻一致的快照
+func (st *SessionStore) SaveSession(session *Session) error {
+	session.mu.RLock()
+	data, err := json.Marshal(session)
+	status := session.Status
+	session.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode session %s: %w", session.ID, err)
+	}
+
+	_, err = st.db.Exec(`INSERT INTO sessions (id, workflow_id, status, data, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET workflow_id = excluded.workflow_id, status = excluded.status,
+		data = excluded.data, updated_at = excluded.updated_at`,
+		session.ID, session.WorkflowID, status, string(data), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+// DeleteSession 删除会话记录
+func (st *SessionStore) DeleteSession(id string) error {
+	if _, err := st.db.Exec(`DELETE FROM sessions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	return nil
+}
+
+// LoadSession 按会话ID或工作流ID加载会话，不存在时返回 ErrSessionNotStored
+func (st *SessionStore) LoadSession(id string) (*Session, error) {
+	var data string
+	err := st.db.QueryRow(`SELECT data FROM sessions WHERE id = ? OR workflow_id = ?`, id, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotStored, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", id, err)
+	}
+	return decodeSession(data)
+}
+
+// LoadSessions 加载全部会话
+func (st *SessionStore) LoadSessions() ([]*Session, error) {
+	rows, err := st.db.Query(`SELECT data FROM sessions`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read session row: %w", err)
+		}
+		session, err := decodeSession(data)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// decodeSession 解码会话并重建无法持久化的通道
+func decodeSession(data string) (*Session, error) {
+	var session Session
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	session.PollingChan = make(chan *SessionUpdate, 10)
+	session.CancelChan = make(chan bool, 1)
+	return &session, nil
+}
+
+// StatusInterrupted 服务重启时仍在执行的会话，需要客户端重新提交或恢复
+const StatusInterrupted = "interrupted"
+
+// UseSessionStore 启用会话持久化，并重新加载上次运行留下的会话。
+// 重启时仍在执行的会话标记为 interrupted；等待签名的会话恢复工作流上下文后可继续提交签名。
+func (s *QNGServer) UseSessionStore(store *SessionStore) error {
+	sessions, err := store.LoadSessions()
+	if err != nil {
+		return err
+	}
+
+	s.sessionsMu.Lock()
+	s.store = store
+	for _, session := range sessions {
+		s.sessions[session.ID] = session
+		s.sessions[session.WorkflowID] = session
+	}
+	s.sessionsMu.Unlock()
+
+	interrupted := 0
+	for _, session := range sessions {
+		switch session.Status {
+		case "pending", "running":
+			interrupted++
+			s.updateSessionStatus(session, StatusInterrupted, "服务重启时工作流仍在执行，请重新提交或恢复工作流")
+		case "waiting_signature":
+			restored, err := qng.RestoreWorkflowContext(session.Context)
+			if err != nil {
+				log.Printf("⚠️  会话 %s 的工作流上下文无法恢复: %v", session.ID, err)
+				interrupted++
+				s.updateSessionStatus(session, StatusInterrupted, "服务重启后无法继续等待签名，请重新提交或恢复工作流")
+				continue
+			}
+			session.Context = restored
+		}
+	}
+
+	log.Printf("💾 已从会话存储加载 %d 个会话，其中 %d 个标记为中断", len(sessions), interrupted)
+	return nil
+}
+
+// getSession 按会话ID或工作流ID查找会话，内存中不存在时尝试从会话存储加载
+func (s *QNGServer) getSession(id string) (*Session, bool) {
+	s.sessionsMu.RLock()
+	session, exists := s.sessions[id]
+	store := s.store
+	s.sessionsMu.RUnlock()
+	if exists || store == nil {
+		return session, exists
+	}
+
+	session, err := store.LoadSession(id)
+	if err != nil {
+		if !errors.Is(err, ErrSessionNotStored) {
+			log.Printf("⚠️  从会话存储加载会话失败: %v", err)
+		}
+		return nil, false
+	}
+	if session.Context != nil {
+		if restored, err := qng.RestoreWorkflowContext(session.Context); err == nil {
+			session.Context = restored
+		}
+	}
+
+	s.sessionsMu.Lock()
+	s.sessions[session.ID] = session
+	s.sessions[session.WorkflowID] = session
+	s.sessionsMu.Unlock()
+	return session, true
+}
+
+// persistSession 将会话写入存储，失败时只记录警告
+func (s *QNGServer) persistSession(session *Session) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.SaveSession(session); err != nil {
+		log.Printf("⚠️  保存会话失败: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"log"
+	"qng_agent/internal/qng"
+	"time"
+)
+
+// sessionSweepInterval 过期会话的清理周期
+const sessionSweepInterval = time.Minute
+
+// defaultSessionTTL 未配置 session_ttl 时已结束会话的保留时间
+const defaultSessionTTL = 30 * time.Minute
+
+// expirableStatuses 可以被清理的会话状态。已结束与中断的会话在保留时间内仍可查询或恢复，
+// 等待签名或确认失败（可重试确认、替换交易）的会话不会过期
+var expirableStatuses = map[string]bool{
+	"completed":       true,
+	"failed":          true,
+	"limit_exceeded":  true,
+	qng.StatusNoTasks: true,
+	StatusInterrupted: true,
+	StatusCancelled:   true,
+}
+
+// sessionTTL 已结束会话的保留时间
+func (s *QNGServer) sessionTTL() time.Duration {
+	if s.config.SessionTTL > 0 {
+		return time.Duration(s.config.SessionTTL) * time.Minute
+	}
+	return defaultSessionTTL
+}
+
+// sweepSessions 每分钟清理一次过期会话，直到服务停止
+func (s *QNGServer) sweepSessions() {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSweep:
+			return
+		case now := <-ticker.C:
+			s.removeExpiredSessions(now)
+		}
+	}
+}
+
+// removeExpiredSessions 删除 UpdatedAt 早于保留时间的已结束会话，
+// 会话同时以 sessionID 和 workflowID 索引，两个键在同一次加锁中一起删除
+func (s *QNGServer) removeExpiredSessions(now time.Time) int {
+	cutoff := now.Add(-s.sessionTTL())
+
+	s.sessionsMu.Lock()
+	expired := make(map[*Session]bool)
+	for _, session := range s.sessions {
+		if expired[session] {
+			continue
+		}
+		var status, updated string
+		session.read(func() {
+			status, updated = session.Status, session.UpdatedAt
+		})
+		if !expirableStatuses[status] {
+			continue
+		}
+		updatedAt, err := time.Parse(time.RFC3339, updated)
+		if err != nil || updatedAt.After(cutoff) {
+			continue
+		}
+		expired[session] = true
+	}
+	for session := range expired {
+		delete(s.sessions, session.ID)
+		delete(s.sessions, session.WorkflowID)
+	}
+	store := s.store
+	s.sessionsMu.Unlock()
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	// 持久化的记录一并删除，否则重启后会重新加载
+	if store != nil {
+		for session := range expired {
+			if err := store.DeleteSession(session.ID); err != nil {
+				log.Printf("⚠️  删除过期会话失败: %v", err)
+			}
+		}
+	}
+
+	log.Printf("🧹 已清理 %d 个过期会话", len(expired))
+	return len(expired)
+}
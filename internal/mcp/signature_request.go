@@ -0,0 +1,47 @@
+package mcp
+
+// SignatureRequestFromMap 将工作流节点生成的签名请求转换为 SignatureRequest，
+// 质押与转账请求使用 token 字段表示代币
+func SignatureRequestFromMap(fields map[string]any) *SignatureRequest {
+	request := &SignatureRequest{}
+	request.Type, _ = fields["type"].(string)
+	request.TaskID, _ = fields["task_id"].(string)
+	request.Action, _ = fields["action"].(string)
+	request.FromToken, _ = fields["from_token"].(string)
+	request.ToToken, _ = fields["to_token"].(string)
+	if request.ToToken == "" {
+		request.ToToken, _ = fields["token"].(string)
+	}
+	request.Amount, _ = fields["amount"].(string)
+	request.AmountDisplay, _ = fields["amount_display"].(string)
+	request.ToAddress, _ = fields["to_address"].(string)
+	request.Value, _ = fields["value"].(string)
+	request.Data, _ = fields["data"].(string)
+	request.GasLimit, _ = fields["gas_limit"].(string)
+	request.GasPrice, _ = fields["gas_price"].(string)
+	request.MaxFeePerGas, _ = fields["max_fee_per_gas"].(string)
+	request.MaxPriorityFeePerGas, _ = fields["max_priority_fee_per_gas"].(string)
+	request.GasFee, _ = fields["gas_fee"].(string)
+	request.Slippage, _ = fields["slippage"].(string)
+	request.Nonce, _ = fields["nonce"].(string)
+	request.Replaces, _ = fields["replaces"].(string)
+	request.ManualConfirmation, _ = fields["manual_confirmation"].(bool)
+	request.Requests = BatchSignatureRequests(fields)
+	return request
+}
+
+// BatchSignatureRequests 转换批量签名请求中需要依次签名的交易，非批量请求返回 nil
+func BatchSignatureRequests(fields map[string]any) []*SignatureRequest {
+	var requests []*SignatureRequest
+	switch items := fields["requests"].(type) {
+	case []any:
+		for _, item := range items {
+			if itemFields, ok := item.(map[string]any); ok {
+				requests = append(requests, SignatureRequestFromMap(itemFields))
+			}
+		}
+	case []*SignatureRequest:
+		requests = items
+	}
+	return requests
+}
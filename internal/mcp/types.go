@@ -1,6 +1,11 @@
 package mcp
 
-import "time"
+import (
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/qng"
+	"sync"
+	"time"
+)
 
 // Capability 表示MCP服务器的能力
 type Capability struct {
@@ -27,6 +32,11 @@ type WorkflowStatus struct {
 	SignatureRequest *SignatureRequest  `json:"signature_request,omitempty"`
 	Result       map[string]interface{} `json:"result,omitempty"`
 	Error        string                 `json:"error,omitempty"`
+	CompletedTasks []string             `json:"completed_tasks,omitempty"`
+	ErrorType    string                 `json:"error_type,omitempty"`
+	Retryable    bool                   `json:"retryable,omitempty"`
+	// FailureReport 工作流失败时的补偿报告
+	FailureReport map[string]interface{} `json:"failure_report,omitempty"`
 	CreatedAt    time.Time              `json:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at"`
 }
@@ -37,28 +47,103 @@ type SignatureRequest struct {
 	FromToken   string `json:"from_token"`
 	ToToken     string `json:"to_token"`
 	Amount      string `json:"amount"`
+	// AmountDisplay 按代币精度格式化并带代币符号的数量，如 "1,000 MTK"
+	AmountDisplay string `json:"amount_display,omitempty"`
 	ToAddress   string `json:"to_address"`
 	Value       string `json:"value"`
 	Data        string `json:"data"`
 	GasLimit    string `json:"gas_limit"`
 	GasPrice    string `json:"gas_price"`
+	// MaxFeePerGas 与 MaxPriorityFeePerGas 仅在 EIP-1559 网络上设置，此时 GasPrice 为空
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
 	GasFee      string `json:"gas_fee"`
 	Slippage    string `json:"slippage"`
+	// ManualConfirmation 请求被标记为可疑，前端应展示完整交易详情并要求用户逐项核对
+	ManualConfirmation bool `json:"manual_confirmation,omitempty"`
+	// Type 为 batch_transaction_signature 时 Requests 列出互不依赖、需要依次签名的交易，
+	// 每笔交易的 TaskID 标明所属任务
+	Type     string              `json:"type,omitempty"`
+	TaskID   string              `json:"task_id,omitempty"`
+	Requests []*SignatureRequest `json:"requests,omitempty"`
+	// Nonce 与 Replaces 仅在取消或加速交易时设置，钱包必须使用该 nonce 发送交易以替换 Replaces
+	Nonce    string `json:"nonce,omitempty"`
+	Replaces string `json:"replaces,omitempty"`
 }
 
 // Session 表示会话信息
 type Session struct {
 	ID               string                 `json:"id"`
 	WorkflowID       string                 `json:"workflow_id"`
-	Status           string                 `json:"status"` // pending, running, waiting_signature, completed, failed
+	Status           string                 `json:"status"` // pending, running, waiting_signature, completed, failed, confirmation_failed, limit_exceeded, no_tasks, interrupted, cancelled
 	Message          string                 `json:"message"`
+	UserID           string                 `json:"user_id,omitempty"`
+	UserAddress      string                 `json:"user_address,omitempty"`
+	// History 调用方传入的最近对话，供任务分解解析指代
+	History []qng.ConversationTurn `json:"history,omitempty"`
+	// ManualConfirmation 可疑请求需要用户在钱包中逐笔确认，禁止服务端自动签名
+	ManualConfirmation bool                 `json:"manual_confirmation,omitempty"`
+	// SignatureRounds 工作流已请求的签名轮数，超过 max_signature_rounds 时工作流失败
+	SignatureRounds int `json:"signature_rounds,omitempty"`
 	Result           any                    `json:"result,omitempty"`
 	Context          any                    `json:"context,omitempty"`
 	SignatureRequest *SignatureRequest      `json:"signature_request,omitempty"`
+	TaskProgress     *qng.TaskProgress      `json:"task_progress,omitempty"` // 已完成任务及交易哈希，用于失败后恢复
+	// Replacement 等待用户签名的取消或加速交易
+	Replacement *qng.Replacement `json:"replacement,omitempty"`
+	// Transactions 用户提交与取消时仍在等待确认的交易及声明的 gas 参数，按提交顺序排列
+	Transactions []TrackedTransaction `json:"transactions,omitempty"`
+	Error            *SessionError          `json:"error,omitempty"`
 	CreatedAt        string                 `json:"created_at"`
 	UpdatedAt        string                 `json:"updated_at"`
 	PollingChan      chan *SessionUpdate    `json:"-"`
+	// CancelChan 会话被取消或服务停止时关闭，等待中的轮询与执行中的工作流据此退出
 	CancelChan       chan bool              `json:"-"`
+	cancelOnce       sync.Once
+	// mu 保护会话字段：工作流协程写入的同时，状态查询、过期清理与持久化会并发读取
+	mu sync.RWMutex
+}
+
+// update 在会话锁内修改会话字段
+func (session *Session) update(fn func()) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	fn()
+}
+
+// read 在会话锁内读取会话字段，fn 中不能再调用 update 或 updateSessionStatus
+func (session *Session) read(fn func()) {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	fn()
+}
+
+// currentStatus 返回会话当前状态
+func (session *Session) currentStatus() string {
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	return session.Status
+}
+
+// SessionError 会话失败的结构化信息
+type SessionError struct {
+	Type      string `json:"type"` // timeout, reverted, rpc_error, spending_limit, insufficient_balance, signature_rounds, cancelled, execution
+	Message   string `json:"message"`
+	TxHash    string `json:"tx_hash,omitempty"`
+	Retryable bool   `json:"retryable"`
+	// Balance 余额不足时所需、可用与差额的数量
+	Balance *contracts.InsufficientBalanceError `json:"balance,omitempty"`
+	// Report 失败前已完成与失败的任务及建议的恢复操作
+	Report *qng.FailureReport `json:"report,omitempty"`
+}
+
+// TrackedTransaction 用户通过 submit_signature 提交的交易，以及工作流取消时仍在等待确认的交易
+type TrackedTransaction struct {
+	TxHash string `json:"tx_hash"`
+	TaskID string `json:"task_id,omitempty"`
+	// Gas 提交时声明的 gas 参数，未声明时为空
+	Gas         *qng.GasOverride `json:"gas,omitempty"`
+	SubmittedAt string           `json:"submitted_at"`
 }
 
 // SessionUpdate 表示会话更新
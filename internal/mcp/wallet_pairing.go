@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"qng_agent/internal/qng"
+)
+
+// 钱包配对状态
+const (
+	PairingPending  = "pending"
+	PairingApproved = "approved"
+	PairingRejected = "rejected"
+	PairingExpired  = "expired"
+)
+
+var (
+	// ErrPairingNotFound 配对主题不存在或已被清理
+	ErrPairingNotFound = errors.New("wallet pairing not found")
+	// ErrPairingClosed 配对已批准、拒绝或过期，不能再次处理
+	ErrPairingClosed = errors.New("wallet pairing is no longer pending")
+)
+
+// maxPairingPoll poll_connection 单次等待的最长时间
+const maxPairingPoll = 60 * time.Second
+
+// WalletSession 一次钱包配对。这不是 WalletConnect：没有中继与加密通道，
+// 钱包（或前端）取得 Topic 与 Challenge 后用 personal_sign 签名 Challenge，
+// 直接调用 approve_connection 提交账户、链ID与签名，签名者必须与账户一致
+type WalletSession struct {
+	Topic     string    `json:"topic"`
+	URI       string    `json:"uri"`
+	Challenge string    `json:"challenge"`
+	Status    string    `json:"status"`
+	Account   string    `json:"account,omitempty"`
+	ChainID   string    `json:"chain_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// done 配对批准或拒绝时关闭，唤醒 poll_connection
+	done chan struct{}
+}
+
+// pairingURI 生成本服务自定义的配对URI，供前端展示二维码或深链接；
+// 不使用 wc: 格式，WalletConnect 钱包无法通过中继完成这种配对
+func pairingURI(topic, chainID string, expiresAt time.Time) string {
+	return fmt.Sprintf("qng-pair:%s?chain_id=%s&expiry=%d", topic, url.QueryEscape(chainID), expiresAt.Unix())
+}
+
+// pairingChallenge 钱包批准配对时需要签名的消息
+func pairingChallenge(topic, chainID string) string {
+	return fmt.Sprintf("QNG Agent wallet pairing\nTopic: %s\nChain ID: %s", topic, chainID)
+}
+
+// randomHex 生成 n 字节随机数的十六进制编码
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// createPairing 创建待批准的配对会话，同时清理已过期的配对
+func (s *MetaMaskServer) createPairing() (*WalletSession, error) {
+	topic, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate pairing topic: %w", err)
+	}
+
+	ttl := time.Duration(s.config.PairingTTL) * time.Second
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	now := time.Now()
+	session := &WalletSession{
+		Topic:     topic,
+		URI:       pairingURI(topic, s.config.ChainID, now.Add(ttl)),
+		Challenge: pairingChallenge(topic, s.config.ChainID),
+		Status:    PairingPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		done:      make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, existing := range s.sessions {
+		if existing.Status != PairingApproved && now.After(existing.ExpiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[topic] = session
+	return session, nil
+}
+
+// snapshot 返回配对会话的副本，待批准的配对过期时标记为 expired
+func (s *MetaMaskServer) snapshot(session *WalletSession) WalletSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session.Status == PairingPending && time.Now().After(session.ExpiresAt) {
+		session.Status = PairingExpired
+	}
+	return *session
+}
+
+// lookupPairing 按主题查找配对会话
+func (s *MetaMaskServer) lookupPairing(params map[string]any) (*WalletSession, error) {
+	topic, ok := params["topic"].(string)
+	if !ok || topic == "" {
+		return nil, fmt.Errorf("topic parameter required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, exists := s.sessions[topic]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPairingNotFound, topic)
+	}
+	return session, nil
+}
+
+// approveConnection 钱包批准配对：校验 personal_sign 签名者与账户一致后记录账户与链ID
+func (s *MetaMaskServer) approveConnection(ctx context.Context, params map[string]any) (any, error) {
+	log.Printf("🔗 钱包批准配对")
+
+	session, err := s.lookupPairing(params)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return nil, err
+	}
+	account, _ := params["account"].(string)
+	signature, _ := params["signature"].(string)
+	if account == "" || signature == "" {
+		return nil, fmt.Errorf("account and signature parameters required")
+	}
+	chainID, _ := params["chain_id"].(string)
+	if chainID == "" {
+		chainID = s.config.ChainID
+	}
+	if chainID != s.config.ChainID {
+		return nil, fmt.Errorf("wallet is on chain %s, expected %s", chainID, s.config.ChainID)
+	}
+
+	signer, err := qng.RecoverPersonalSigner(session.Challenge, signature)
+	if err != nil {
+		log.Printf("❌ 配对签名无效: %v", err)
+		return nil, err
+	}
+	if !strings.EqualFold(signer, account) {
+		log.Printf("❌ 配对签名者 %s 与账户 %s 不一致", signer, account)
+		return nil, fmt.Errorf("%w: pairing was signed by %s, not %s", qng.ErrSignerMismatch, signer, account)
+	}
+
+	s.mu.Lock()
+	if session.Status != PairingPending || time.Now().After(session.ExpiresAt) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrPairingClosed, session.Topic)
+	}
+	session.Status = PairingApproved
+	session.Account = account
+	session.ChainID = chainID
+	close(session.done)
+	s.connected = true
+	s.accounts = []string{account}
+	s.mu.Unlock()
+
+	log.Printf("✅ 钱包配对成功: %s (chain %s)", account, chainID)
+	return s.snapshot(session), nil
+}
+
+// rejectConnection 钱包拒绝配对
+func (s *MetaMaskServer) rejectConnection(ctx context.Context, params map[string]any) (any, error) {
+	session, err := s.lookupPairing(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if session.Status != PairingPending {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrPairingClosed, session.Topic)
+	}
+	session.Status = PairingRejected
+	close(session.done)
+	s.mu.Unlock()
+
+	log.Printf("🚫 钱包拒绝配对: %s", session.Topic)
+	return s.snapshot(session), nil
+}
+
+// pollConnection 等待钱包批准或拒绝配对，超时或配对过期时返回当前状态
+func (s *MetaMaskServer) pollConnection(ctx context.Context, params map[string]any) (any, error) {
+	session, err := s.lookupPairing(params)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := 30 * time.Second
+	switch v := params["timeout"].(type) {
+	case int:
+		timeout = time.Duration(v) * time.Second
+	case float64:
+		timeout = time.Duration(v) * time.Second
+	}
+	timeout = min(timeout, maxPairingPoll)
+	if remaining := time.Until(session.ExpiresAt); remaining < timeout {
+		timeout = max(remaining, 0)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-session.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.snapshot(session), nil
+}
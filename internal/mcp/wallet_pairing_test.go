@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/qng"
+)
+
+// pairingKey web3.js 文档中的示例私钥，对应地址 0x2c7536E3605D9C16a7a3D7b1898e529396a65c23
+const (
+	pairingKey     = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	pairingAccount = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+)
+
+// personalSign 按 personal_sign 生成 r || s || v 签名，v 为 27/28
+func personalSign(t *testing.T, keyHex, message string) string {
+	t.Helper()
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha3.NewLegacyKeccak256()
+	fmt.Fprintf(h, "\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	compact := ecdsa.SignCompact(secp256k1.PrivKeyFromBytes(keyBytes), h.Sum(nil), false)
+	sig := append(append([]byte{}, compact[1:]...), compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func newPairingServer() *MetaMaskServer {
+	return NewMetaMaskServer(config.MetaMaskConfig{ChainID: "813", PairingTTL: 60})
+}
+
+func TestConnectWalletReturnsCustomPairingURI(t *testing.T) {
+	s := newPairingServer()
+	result, err := s.Call(context.Background(), "connect_wallet", map[string]any{})
+	if err != nil {
+		t.Fatalf("connect_wallet: %v", err)
+	}
+	fields := result.(map[string]any)
+	topic, _ := fields["topic"].(string)
+	uri, _ := fields["uri"].(string)
+
+	if strings.HasPrefix(uri, "wc:") || strings.Contains(uri, "symKey") {
+		t.Errorf("uri advertises WalletConnect: %s", uri)
+	}
+	if !strings.HasPrefix(uri, "qng-pair:"+topic+"?chain_id=813&expiry=") {
+		t.Errorf("uri = %s, want qng-pair:%s?chain_id=813&expiry=...", uri, topic)
+	}
+	if fields["connected"] != false {
+		t.Error("wallet should not be connected before approval")
+	}
+}
+
+func TestApproveConnection(t *testing.T) {
+	tests := []struct {
+		name    string
+		account string
+		chainID string
+		// message 不为空时签名该消息而不是配对的 challenge
+		message string
+		wantErr error
+	}{
+		{name: "approved", account: pairingAccount},
+		{name: "lowercase account", account: strings.ToLower(pairingAccount)},
+		{
+			name:    "signed by another key",
+			account: "0x00000000000000000000000000000000000000e5",
+			wantErr: qng.ErrSignerMismatch,
+		},
+		{
+			name:    "signature over a different message",
+			account: pairingAccount,
+			message: "QNG Agent wallet pairing\nTopic: other",
+			wantErr: qng.ErrSignerMismatch,
+		},
+		{name: "wrong chain", account: pairingAccount, chainID: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPairingServer()
+			created, err := s.createPairing()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			message := created.Challenge
+			if tt.message != "" {
+				message = tt.message
+			}
+			signature := personalSign(t, pairingKey, message)
+			params := map[string]any{"topic": created.Topic, "account": tt.account, "signature": signature}
+			if tt.chainID != "" {
+				params["chain_id"] = tt.chainID
+			}
+
+			_, err = s.Call(context.Background(), "approve_connection", params)
+			switch {
+			case tt.chainID != "":
+				if err == nil {
+					t.Fatal("expected chain mismatch error")
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("approve_connection error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("approve_connection: %v", err)
+			}
+
+			polled, err := s.Call(context.Background(), "poll_connection", map[string]any{"topic": created.Topic, "timeout": 1})
+			if err != nil {
+				t.Fatalf("poll_connection: %v", err)
+			}
+			session := polled.(WalletSession)
+			if session.Status != PairingApproved || session.Account != tt.account {
+				t.Errorf("pairing = %s %s, want approved %s", session.Status, session.Account, tt.account)
+			}
+			if accounts, connected := s.connectedAccounts(); !connected || len(accounts) != 1 {
+				t.Errorf("connected accounts = %v (%v)", accounts, connected)
+			}
+		})
+	}
+}
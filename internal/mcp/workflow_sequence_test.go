@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/qng"
+	"qng_agent/internal/rpc"
+)
+
+// sequenceAddress 模拟用户地址，用于覆盖余额检查
+const sequenceAddress = "0x00000000000000000000000000000000000000aa"
+
+// newSequenceChain 使用仓库配置、模拟LLM与模拟RPC节点构建工作流链
+func newSequenceChain(t *testing.T) (*qng.Chain, *rpc.MockNode) {
+	t.Helper()
+	// 合约配置按仓库根目录的相对路径加载
+	t.Chdir("../..")
+
+	cfg := config.LoadConfig("config/config.yaml")
+	if cfg == nil {
+		t.Fatal("failed to load config/config.yaml")
+	}
+
+	node := rpc.NewMockNode()
+	t.Cleanup(node.Close)
+
+	qngConfig := cfg.MCP.QNG
+	qngConfig.Chain.LLM = config.LLMConfig{Provider: llm.ProviderMock}
+	qngConfig.Chain.RPCURL = node.URL()
+	qngConfig.Chain.Signer.Enabled = false
+	qngConfig.Chain.Transaction = config.TransactionConfig{
+		ConfirmationTimeout:   10,
+		PollingInterval:       1,
+		RequiredConfirmations: 1,
+		ConfirmationStrategy:  rpc.StrategyConfirmations,
+		GasBufferPercent:      qngConfig.Chain.Transaction.GasBufferPercent,
+		// 模拟节点的交易哈希没有对应的签名数据，无法恢复签名者
+		PermissiveSignatures: true,
+	}
+
+	chain, err := qng.NewChain(qngConfig)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	if err := chain.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { chain.Stop() })
+	return chain, node
+}
+
+// TestSwapApproveStakeSequence 驱动"兑换后质押"工作流，依次校验 swap → approve → stake 三个签名请求、
+// 每笔交易确认后的任务状态以及最终的聚合结果
+func TestSwapApproveStakeSequence(t *testing.T) {
+	chain, node := newSequenceChain(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	ctx = context.WithValue(ctx, "workflow_id", "sequence_test")
+	ctx = context.WithValue(ctx, "session_id", "sequence_test")
+	ctx = context.WithValue(ctx, "user_address", sequenceAddress)
+
+	result, err := chain.ProcessMessage(ctx, "兑换1 MEER的MTK，然后质押")
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	var swapTask, stakeTask string
+	var txHashes []string
+	for i, action := range []string{"swap", "approve", "stake"} {
+		if result == nil || !result.NeedSignature {
+			t.Fatalf("step %d: workflow did not request a %s signature", i+1, action)
+		}
+
+		// 经规范状态转换后签名请求不丢失字段，经 JSON 往返（HTTP 调用）后仍一致
+		status := StatusFromResult(result)
+		checkSignatureStatus(t, status, action)
+		checkSignatureStatus(t, roundTripStatus(t, status), action)
+
+		data := result.WorkflowContext.Data
+		if i == 0 {
+			swapTask, stakeTask = workflowTaskIDs(t, data)
+		}
+		completed, _ := data["completed_tasks"].([]string)
+		currentStep, _ := data[stakeTask+"_current_step"].(string)
+		approved, _ := data[stakeTask+"_approve_completed"].(bool)
+
+		// 上一笔交易确认后记录的任务状态
+		switch action {
+		case "swap":
+			if len(completed) != 0 {
+				t.Errorf("completed_tasks before any signature = %v, want none", completed)
+			}
+		case "approve":
+			if !reflect.DeepEqual(completed, []string{swapTask}) {
+				t.Errorf("completed_tasks after swap = %v, want [%s]", completed, swapTask)
+			}
+			if hash, _ := data[swapTask+"_tx_hash"].(string); hash != txHashes[0] {
+				t.Errorf("%s_tx_hash = %q, want %q", swapTask, hash, txHashes[0])
+			}
+			if currentStep != "approve" || approved {
+				t.Errorf("stake task current_step = %q approve_completed = %v, want approve and false", currentStep, approved)
+			}
+		case "stake":
+			if !reflect.DeepEqual(completed, []string{swapTask}) {
+				t.Errorf("completed_tasks after approve = %v, want [%s]", completed, swapTask)
+			}
+			if !approved {
+				t.Errorf("%s_approve_completed not recorded", stakeTask)
+			}
+			if hash, _ := data[stakeTask+"_approve_tx_hash"].(string); hash != txHashes[1] {
+				t.Errorf("%s_approve_tx_hash = %q, want %q", stakeTask, hash, txHashes[1])
+			}
+			if currentStep == "approve" {
+				t.Error("stake task still at the approve step after approval was confirmed")
+			}
+		}
+
+		txHash := fmt.Sprintf("0x%064x", 0x5e1f7e57+i)
+		txHashes = append(txHashes, txHash)
+		if result, err = chain.ContinueWithSignature(ctx, result.WorkflowContext, txHash); err != nil {
+			t.Fatalf("ContinueWithSignature(%s): %v", action, err)
+		}
+	}
+
+	// 三次签名后不应再有签名请求
+	if result == nil || result.NeedSignature {
+		t.Fatalf("workflow still requests a signature after swap, approve and stake: %+v", result)
+	}
+
+	finalResult, _ := result.FinalResult.(map[string]any)
+	if status, _ := finalResult["status"].(string); status != "completed" {
+		t.Errorf("final status = %q, want completed", status)
+	}
+	if success, _ := finalResult["success"].(bool); !success {
+		t.Error("final result is not successful")
+	}
+
+	wantTasks := []string{swapTask, stakeTask}
+	if completed, _ := finalResult["completed_tasks"].([]string); !reflect.DeepEqual(completed, wantTasks) {
+		t.Errorf("final completed_tasks = %v, want %v", completed, wantTasks)
+	}
+	finalStatus := roundTripStatus(t, StatusFromResult(result))
+	if finalStatus.Status != "completed" {
+		t.Errorf("workflow status = %q, want completed", finalStatus.Status)
+	}
+	if !reflect.DeepEqual(finalStatus.CompletedTasks, wantTasks) {
+		t.Errorf("workflow status completed_tasks = %v, want %v", finalStatus.CompletedTasks, wantTasks)
+	}
+
+	hashes, _ := finalResult["tx_hashes"].(map[string]string)
+	wantHashes := map[string]string{
+		swapTask + "_tx_hash":          txHashes[0],
+		stakeTask + "_approve_tx_hash": txHashes[1],
+		stakeTask + "_tx_hash":         txHashes[2],
+	}
+	if !reflect.DeepEqual(hashes, wantHashes) {
+		t.Errorf("tx_hashes = %v, want %v", hashes, wantHashes)
+	}
+
+	if receipts := node.CallsTo("eth_getTransactionReceipt"); receipts < len(txHashes) {
+		t.Errorf("eth_getTransactionReceipt called %d times, want at least %d", receipts, len(txHashes))
+	}
+}
+
+// checkSignatureStatus 检查工作流处于等待签名状态且签名请求为期望的操作
+func checkSignatureStatus(t *testing.T, status *WorkflowStatus, action string) {
+	t.Helper()
+	if status.Status != "waiting_signature" {
+		t.Fatalf("status = %q, want waiting_signature", status.Status)
+	}
+	if status.SignatureRequest == nil {
+		t.Fatalf("%s: missing signature request", action)
+	}
+	if status.SignatureRequest.Action != action {
+		t.Fatalf("signature request action = %q, want %q", status.SignatureRequest.Action, action)
+	}
+	if status.SignatureRequest.ToAddress == "" || status.SignatureRequest.Data == "" {
+		t.Errorf("%s signature request is missing transaction fields: %+v", action, status.SignatureRequest)
+	}
+}
+
+// roundTripStatus 按 get_session_status 经 HTTP 返回的形式编码后重新转换工作流状态
+func roundTripStatus(t *testing.T, status *WorkflowStatus) *WorkflowStatus {
+	t.Helper()
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("marshal status: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	return WorkflowStatusFromMap(fields)
+}
+
+// workflowTaskIDs 从工作流数据中找出兑换与质押任务
+func workflowTaskIDs(t *testing.T, data map[string]any) (swapTask, stakeTask string) {
+	t.Helper()
+	tasks, _ := data["tasks"].([]map[string]any)
+	for _, task := range tasks {
+		id, _ := task["id"].(string)
+		switch task["type"] {
+		case "swap":
+			swapTask = id
+		case "stake":
+			stakeTask = id
+		}
+	}
+	if len(tasks) != 2 || swapTask == "" || stakeTask == "" {
+		t.Fatalf("decomposed tasks = %v, want one swap and one stake", tasks)
+	}
+	return swapTask, stakeTask
+}
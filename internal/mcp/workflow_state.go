@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"qng_agent/internal/qng"
+	"time"
+)
+
+// WorkflowStatus 是工作流状态的规范表示。Chain 的 ProcessResult、get_session_status 的响应与
+// poll_session 的更新都通过本文件中的函数转换为 WorkflowStatus，agent 再从它构建自己的视图，
+// 各层不再各自逐字段映射。
+
+// StatusFromResult 将 Chain 的执行结果转换为工作流状态：需要签名时为 waiting_signature，
+// 没有可执行任务时为 no_tasks，否则为 completed
+func StatusFromResult(result *qng.ProcessResult) *WorkflowStatus {
+	now := time.Now()
+	status := &WorkflowStatus{UpdatedAt: now}
+
+	if result.NeedSignature {
+		status.Status = "waiting_signature"
+		status.NeedSignature = true
+		status.SignatureRequest = SignatureRequestFromAny(result.SignatureRequest)
+		status.Message = signatureStatusMessage(result.SignatureRequest)
+		return status
+	}
+
+	final, _ := result.FinalResult.(map[string]any)
+	status.Result = final
+	if final["status"] == qng.StatusNoTasks {
+		status.Status = qng.StatusNoTasks
+		status.Message, _ = final["message"].(string)
+		return status
+	}
+
+	status.Status = "completed"
+	status.Progress = 100
+	status.Message = completionMessage(result.FinalResult)
+	status.CompletedTasks = stringList(final["completed_tasks"])
+	return status
+}
+
+// WorkflowStatusFromMap 将 get_session_status 的响应转换为工作流状态。
+// 本地调用时字段为原始类型，经 HTTP 调用时为 JSON 解码后的类型，两者都支持
+func WorkflowStatusFromMap(fields map[string]any) *WorkflowStatus {
+	status := &WorkflowStatus{}
+	status.Status, _ = fields["status"].(string)
+	status.Message, _ = fields["message"].(string)
+	status.SessionID, _ = fields["session_id"].(string)
+	status.Progress = intField(fields["progress"])
+	status.NeedSignature, _ = fields["need_signature"].(bool)
+	status.SignatureRequest = SignatureRequestFromAny(fields["signature_request"])
+	status.Result = mapField(fields["result"])
+	status.Error, _ = fields["error"].(string)
+	status.ErrorType, _ = fields["error_type"].(string)
+	status.Retryable, _ = fields["retryable"].(bool)
+	status.FailureReport = mapField(fields["failure_report"])
+	status.CompletedTasks = stringList(fields["completed_tasks"])
+	status.CreatedAt = timeField(fields["created_at"])
+	status.UpdatedAt = timeField(fields["updated_at"])
+	return status
+}
+
+// WorkflowStatusFromPoll 将 poll_session 的结果转换为工作流状态。轮询超时返回 timeout 状态，
+// 会话取消返回 cancelled 状态
+func WorkflowStatusFromPoll(sessionID string, poll map[string]any) (*WorkflowStatus, error) {
+	status := &WorkflowStatus{SessionID: sessionID, UpdatedAt: time.Now()}
+
+	if timeout, _ := poll["timeout"].(bool); timeout {
+		status.Status = "timeout"
+		status.Message = "轮询超时，请重试"
+		return status, nil
+	}
+	if cancelled, _ := poll["cancelled"].(bool); cancelled {
+		status.Status = StatusCancelled
+		status.Message = "会话已取消"
+		return status, nil
+	}
+
+	// 本地调用时为 *SessionUpdate，经 HTTP 调用时为解码后的 map
+	var updateType string
+	var data any
+	switch update := poll["update"].(type) {
+	case *SessionUpdate:
+		updateType, data = update.Type, update.Data
+	case map[string]any:
+		updateType, _ = update["type"].(string)
+		data = update["data"]
+	default:
+		return nil, fmt.Errorf("missing update")
+	}
+	if updateType == "" {
+		return nil, fmt.Errorf("missing update type")
+	}
+
+	switch updateType {
+	case "signature_request":
+		status.Status = "waiting_signature"
+		status.NeedSignature = true
+		status.SignatureRequest = SignatureRequestFromAny(data)
+		status.Message = signatureStatusMessage(mapField(data))
+	case "result":
+		status.Result = mapField(data)
+		if status.Result["status"] == qng.StatusNoTasks {
+			status.Status = qng.StatusNoTasks
+			status.Message, _ = status.Result["message"].(string)
+		} else {
+			status.Status = "completed"
+			status.Progress = 100
+			status.Message = completionMessage(data)
+			status.CompletedTasks = stringList(status.Result["completed_tasks"])
+		}
+	case "error":
+		detail := mapField(data)
+		status.Status = "failed"
+		status.Error, _ = detail["message"].(string)
+		status.ErrorType, _ = detail["type"].(string)
+		status.Retryable, _ = detail["retryable"].(bool)
+		status.FailureReport = mapField(detail["report"])
+		status.Message = status.Error
+	default:
+		status.Status = "unknown"
+		status.Message = fmt.Sprintf("未知更新类型: %s", updateType)
+	}
+	return status, nil
+}
+
+// SignatureRequestFromAny 转换节点生成的 map、已转换的 *SignatureRequest 或 JSON 解码后的签名请求，
+// 为空时返回 nil
+func SignatureRequestFromAny(value any) *SignatureRequest {
+	switch request := value.(type) {
+	case nil:
+		return nil
+	case *SignatureRequest:
+		return request
+	case map[string]any:
+		return SignatureRequestFromMap(request)
+	default:
+		if fields := mapField(request); fields != nil {
+			return SignatureRequestFromMap(fields)
+		}
+		return nil
+	}
+}
+
+// mapField 返回 map 字段，结构体等其它类型按 JSON 转换，无法转换时返回 nil
+func mapField(value any) map[string]any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// intField 读取整数字段，JSON 解码后的数字为 float64
+func intField(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// stringList 读取字符串列表字段
+func stringList(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// timeField 读取 RFC3339 时间字段，缺失或格式错误时返回零值
+func timeField(value any) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,283 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"qng_agent/internal/qng"
+)
+
+// swapRequestFields 节点生成的兑换签名请求
+func swapRequestFields() map[string]any {
+	return map[string]any{
+		"action":      "swap",
+		"from_token":  "MEER",
+		"to_token":    "MTK",
+		"amount":      "1",
+		"to_address":  "0x00000000000000000000000000000000000000aa",
+		"data":        "0xabcdef",
+		"gas_limit":   "0x5208",
+		"description": "兑换 1 MEER 为 MTK",
+		"warning":     "合约不在可信列表中",
+	}
+}
+
+var swapRequest = &SignatureRequest{
+	Action:    "swap",
+	FromToken: "MEER",
+	ToToken:   "MTK",
+	Amount:    "1",
+	ToAddress: "0x00000000000000000000000000000000000000aa",
+	Data:      "0xabcdef",
+	GasLimit:  "0x5208",
+}
+
+// withoutTimes 清除转换时写入的当前时间，便于比较其余字段
+func withoutTimes(status *WorkflowStatus) *WorkflowStatus {
+	if status == nil {
+		return nil
+	}
+	copied := *status
+	copied.CreatedAt, copied.UpdatedAt = time.Time{}, time.Time{}
+	return &copied
+}
+
+func TestStatusFromResult(t *testing.T) {
+	completed := map[string]any{
+		"status":          "completed",
+		"message":         "✅ 工作流执行完成",
+		"completed_tasks": []string{"task_1", "task_2"},
+	}
+	noTasks := map[string]any{"status": qng.StatusNoTasks, "message": "没有可执行的任务"}
+
+	tests := []struct {
+		name   string
+		result *qng.ProcessResult
+		want   *WorkflowStatus
+	}{
+		{
+			name:   "waiting signature",
+			result: &qng.ProcessResult{NeedSignature: true, SignatureRequest: swapRequestFields()},
+			want: &WorkflowStatus{
+				Status:           "waiting_signature",
+				NeedSignature:    true,
+				SignatureRequest: swapRequest,
+				Message:          "等待用户签名授权: 兑换 1 MEER 为 MTK\n⚠️ 合约不在可信列表中",
+			},
+		},
+		{
+			name:   "no tasks",
+			result: &qng.ProcessResult{FinalResult: noTasks},
+			want:   &WorkflowStatus{Status: qng.StatusNoTasks, Message: "没有可执行的任务", Result: noTasks},
+		},
+		{
+			name:   "completed",
+			result: &qng.ProcessResult{FinalResult: completed},
+			want: &WorkflowStatus{
+				Status:         "completed",
+				Progress:       100,
+				Message:        "✅ 工作流执行完成",
+				Result:         completed,
+				CompletedTasks: []string{"task_1", "task_2"},
+			},
+		},
+		{
+			name:   "completed without message",
+			result: &qng.ProcessResult{},
+			want:   &WorkflowStatus{Status: "completed", Progress: 100, Message: "工作流执行完成"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutTimes(StatusFromResult(tt.result)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StatusFromResult =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowStatusFromMap(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	local := map[string]any{
+		"status":            "failed",
+		"message":           "交易失败",
+		"session_id":        "wf_1",
+		"progress":          50,
+		"need_signature":    false,
+		"signature_request": swapRequest,
+		"result":            map[string]any{"status": "failed"},
+		"error":             "reverted",
+		"error_type":        "transaction_reverted",
+		"retryable":         true,
+		"failure_report":    map[string]any{"completed": []any{"task_1"}},
+		"completed_tasks":   []string{"task_1"},
+		"created_at":        created,
+		"updated_at":        created,
+	}
+	want := &WorkflowStatus{
+		Status:           "failed",
+		Message:          "交易失败",
+		SessionID:        "wf_1",
+		Progress:         50,
+		SignatureRequest: swapRequest,
+		Result:           map[string]any{"status": "failed"},
+		Error:            "reverted",
+		ErrorType:        "transaction_reverted",
+		Retryable:        true,
+		FailureReport:    map[string]any{"completed": []any{"task_1"}},
+		CompletedTasks:   []string{"task_1"},
+		CreatedAt:        created,
+		UpdatedAt:        created,
+	}
+
+	// 经 HTTP 调用时字段为 JSON 解码后的类型
+	data, err := json.Marshal(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]any
+	}{
+		{name: "local call", fields: local},
+		{name: "JSON decoded", fields: decoded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WorkflowStatusFromMap(tt.fields); !reflect.DeepEqual(got, want) {
+				t.Errorf("WorkflowStatusFromMap =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkflowStatusFromPoll(t *testing.T) {
+	result := map[string]any{"message": "✅ 完成", "completed_tasks": []any{"task_1"}}
+
+	tests := []struct {
+		name    string
+		poll    map[string]any
+		want    *WorkflowStatus
+		wantErr bool
+	}{
+		{
+			name: "timeout",
+			poll: map[string]any{"timeout": true},
+			want: &WorkflowStatus{SessionID: "wf_1", Status: "timeout", Message: "轮询超时，请重试"},
+		},
+		{
+			name: "cancelled",
+			poll: map[string]any{"cancelled": true},
+			want: &WorkflowStatus{SessionID: "wf_1", Status: StatusCancelled, Message: "会话已取消"},
+		},
+		{
+			name: "local signature request",
+			poll: map[string]any{"update": &SessionUpdate{Type: "signature_request", Data: swapRequestFields()}},
+			want: &WorkflowStatus{
+				SessionID:        "wf_1",
+				Status:           "waiting_signature",
+				NeedSignature:    true,
+				SignatureRequest: swapRequest,
+				Message:          "等待用户签名授权: 兑换 1 MEER 为 MTK\n⚠️ 合约不在可信列表中",
+			},
+		},
+		{
+			name: "decoded result",
+			poll: map[string]any{"update": map[string]any{"type": "result", "data": result}},
+			want: &WorkflowStatus{
+				SessionID:      "wf_1",
+				Status:         "completed",
+				Progress:       100,
+				Message:        "✅ 完成",
+				Result:         result,
+				CompletedTasks: []string{"task_1"},
+			},
+		},
+		{
+			name: "no tasks result",
+			poll: map[string]any{"update": &SessionUpdate{Type: "result", Data: map[string]any{"status": qng.StatusNoTasks, "message": "没有任务"}}},
+			want: &WorkflowStatus{
+				SessionID: "wf_1",
+				Status:    qng.StatusNoTasks,
+				Message:   "没有任务",
+				Result:    map[string]any{"status": qng.StatusNoTasks, "message": "没有任务"},
+			},
+		},
+		{
+			name: "error",
+			poll: map[string]any{"update": &SessionUpdate{Type: "error", Data: map[string]any{
+				"message": "gas 不足", "type": "insufficient_gas", "retryable": true, "report": map[string]any{"failed": "task_2"},
+			}}},
+			want: &WorkflowStatus{
+				SessionID:     "wf_1",
+				Status:        "failed",
+				Message:       "gas 不足",
+				Error:         "gas 不足",
+				ErrorType:     "insufficient_gas",
+				Retryable:     true,
+				FailureReport: map[string]any{"failed": "task_2"},
+			},
+		},
+		{
+			name: "unknown update",
+			poll: map[string]any{"update": map[string]any{"type": "heartbeat"}},
+			want: &WorkflowStatus{SessionID: "wf_1", Status: "unknown", Message: "未知更新类型: heartbeat"},
+		},
+		{name: "missing update", poll: map[string]any{}, wantErr: true},
+		{name: "missing update type", poll: map[string]any{"update": map[string]any{"data": result}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WorkflowStatusFromPoll("wf_1", tt.poll)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WorkflowStatusFromPoll: %v", err)
+			}
+			if got := withoutTimes(got); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WorkflowStatusFromPoll =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureRequestFromAny(t *testing.T) {
+	type nodeRequest struct {
+		Action    string `json:"action"`
+		ToAddress string `json:"to_address"`
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  *SignatureRequest
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "converted request", value: swapRequest, want: swapRequest},
+		{name: "node map", value: swapRequestFields(), want: swapRequest},
+		{name: "struct", value: nodeRequest{Action: "approve", ToAddress: "0xbb"}, want: &SignatureRequest{Action: "approve", ToAddress: "0xbb"}},
+		{name: "not convertible", value: "swap", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignatureRequestFromAny(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SignatureRequestFromAny = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"qng_agent/internal/config"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace 所有指标名的前缀
+const namespace = "qng_agent"
+
+// Registry 本进程导出的指标，与 prometheus 默认注册表分开，避免依赖库注册的指标混入
+var Registry = prometheus.NewRegistry()
+
+var (
+	workflowsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "workflows_total",
+		Help:      "按结束状态统计的工作流数量",
+	}, []string{"status"})
+
+	workflowDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "workflow_duration_seconds",
+		Help:      "工作流从创建到结束的耗时，包含等待用户签名的时间",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"status"})
+
+	llmRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "LLM提供商HTTP请求耗时，流式请求只统计到收到响应头",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"provider", "code"})
+
+	rpcRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "链上JSON-RPC请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	websocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "当前活跃的WebSocket连接数",
+	})
+)
+
+// RPC 请求结果
+const (
+	RPCStatusOK = "ok"
+	// RPCStatusError 请求未发出或响应无法解析
+	RPCStatusError = "error"
+	// RPCStatusRPCError 节点返回了 JSON-RPC 错误
+	RPCStatusRPCError = "rpc_error"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		workflowsTotal,
+		workflowDuration,
+		llmRequestDuration,
+		rpcRequestDuration,
+		websocketConnections,
+	)
+}
+
+// ObserveWorkflow 记录一个结束的工作流及其耗时
+func ObserveWorkflow(status string, duration time.Duration) {
+	workflowsTotal.WithLabelValues(status).Inc()
+	workflowDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// LLMTransport 返回记录请求耗时的 http.RoundTripper，按提供商与响应状态码分类；next 为 nil 时使用默认传输
+func LLMTransport(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	observer := llmRequestDuration.MustCurryWith(prometheus.Labels{"provider": provider})
+	return promhttp.InstrumentRoundTripperDuration(observer, next)
+}
+
+// ObserveRPC 记录一次 JSON-RPC 请求的耗时与结果
+func ObserveRPC(method, status string, start time.Time) {
+	rpcRequestDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+}
+
+// WebSocketConnected 活跃连接数加一，连接关闭时必须调用 WebSocketDisconnected
+func WebSocketConnected() {
+	websocketConnections.Inc()
+}
+
+// WebSocketDisconnected 活跃连接数减一
+func WebSocketDisconnected() {
+	websocketConnections.Dec()
+}
+
+// Handler 以 Prometheus 文本格式导出 Registry 中的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve 在 monitoring.metrics.port 的 /metrics 上导出指标，监控或指标未启用时返回 nil。
+// 端口被占用等监听失败只记录日志，不影响服务本身
+func Serve(cfg config.MonitoringConfig) *http.Server {
+	if !cfg.Enabled || !cfg.Metrics.Enabled {
+		log.Printf("📊 指标导出未启用")
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Metrics.Port),
+		Handler: mux,
+	}
+
+	go func() {
+		log.Printf("📊 指标导出在端口: %d (/metrics)", cfg.Metrics.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("❌ 指标服务启动失败: %v", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,70 @@
+package qng
+
+import (
+	"context"
+	"log"
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/rpc"
+)
+
+// readAddress 返回只读查询（余额、报价、授权额度）使用的账户：优先使用用户钱包地址，
+// 其次是 MCP 服务按已连接钱包或 metamask.default_account 解析出的只读账户 read_account。
+// read_account 只用于查询，不参与签名者校验与支出限额
+func readAddress(data map[string]any) string {
+	if address, _ := data["user_address"].(string); address != "" {
+		return address
+	}
+	address, _ := data["read_account"].(string)
+	return address
+}
+
+// accountCaller 以指定账户身份执行 eth_call，用于依赖调用者的报价函数
+type accountCaller struct {
+	client *rpc.Client
+	from   string
+}
+
+func (c accountCaller) Call(ctx context.Context, to, data string) (string, error) {
+	return c.client.CallFrom(ctx, c.from, to, data)
+}
+
+// checkAmountBounds 校验数量不超过代币配置的最大数量，以及（已知查询账户时）链上余额。
+// 余额读取失败时只记录警告，交由钱包在签名时拒绝；预演模式不检查余额。
+func checkAmountBounds(ctx context.Context, contractManager *contracts.ContractManager, rpcClient *rpc.Client, data map[string]any, token, amount string) error {
+	if err := contractManager.CheckMaxAmount(token, amount); err != nil {
+		return err
+	}
+
+	// 预演时前序任务尚未上链，余额不能反映执行到该任务时的状态
+	address := readAddress(data)
+	if address == "" || rpcClient == nil || isDryRun(data) {
+		return nil
+	}
+
+	balance, err := contractManager.GetBalance(ctx, rpcClient, token, address)
+	if err != nil {
+		log.Printf("⚠️  读取 %s 余额失败，跳过余额检查: %v", token, err)
+		return nil
+	}
+	return contractManager.CheckBalance(token, amount, address, balance)
+}
+
+// checkUnstakeBounds 校验取消质押的数量：代币从质押池取回，按地址在质押池中的质押数量而不是钱包余额检查
+func checkUnstakeBounds(ctx context.Context, contractManager *contracts.ContractManager, rpcClient *rpc.Client, data map[string]any, pool, token, amount string) error {
+	if err := contractManager.CheckMaxAmount(token, amount); err != nil {
+		return err
+	}
+
+	// 预演时前序任务尚未上链，质押数量不能反映执行到该任务时的状态
+	address := readAddress(data)
+	if address == "" || rpcClient == nil || isDryRun(data) {
+		return nil
+	}
+
+	staked, err := contractManager.GetStakedBalance(ctx, rpcClient, pool, address)
+	if err != nil {
+		log.Printf("⚠️  读取质押池 %s 的质押数量失败，跳过检查: %v", pool, err)
+		return nil
+	}
+	return contractManager.CheckBalance(token, amount, address, staked)
+}
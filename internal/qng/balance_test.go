@@ -0,0 +1,134 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/rpc"
+)
+
+const (
+	testUserAddress = "0x00000000000000000000000000000000000000c1"
+	testReadAccount = "0x00000000000000000000000000000000000000d2"
+)
+
+func TestReadAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]any
+		want string
+	}{
+		{name: "none", data: map[string]any{}, want: ""},
+		{name: "nil values from context", data: map[string]any{"user_address": nil, "read_account": nil}, want: ""},
+		{name: "read account", data: map[string]any{"read_account": testReadAccount}, want: testReadAccount},
+		{name: "user address preferred", data: map[string]any{"user_address": testUserAddress, "read_account": testReadAccount}, want: testUserAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readAddress(tt.data); got != tt.want {
+				t.Errorf("readAddress = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAmountBoundsUsesReadAccount(t *testing.T) {
+	cm, err := contracts.NewContractManager("../../config/contracts.json")
+	if err != nil {
+		t.Fatalf("NewContractManager: %v", err)
+	}
+
+	node := rpc.NewMockNode()
+	defer node.Close()
+	var queried []string
+	node.On("eth_getBalance", func(params []interface{}) (interface{}, *rpc.RPCError) {
+		address, _ := params[0].(string)
+		queried = append(queried, address)
+		return "0xde0b6b3a7640000", nil // 1 MEER
+	})
+	client := rpc.NewClient(node.URL())
+
+	tests := []struct {
+		name    string
+		data    map[string]any
+		amount  string
+		queried string
+		wantErr bool
+	}{
+		{name: "no account skips balance", data: map[string]any{}, amount: "5"},
+		{name: "read account within balance", data: map[string]any{"read_account": testReadAccount}, amount: "0.5", queried: testReadAccount},
+		{name: "read account insufficient", data: map[string]any{"read_account": testReadAccount}, amount: "5", queried: testReadAccount, wantErr: true},
+		{name: "user address checked first", data: map[string]any{"user_address": testUserAddress, "read_account": testReadAccount}, amount: "5", queried: testUserAddress, wantErr: true},
+		{name: "dry run skips balance", data: map[string]any{"read_account": testReadAccount, "dry_run": true}, amount: "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried = nil
+			err := checkAmountBounds(context.Background(), cm, client, tt.data, "MEER", tt.amount)
+			if got := errors.Is(err, contracts.ErrAmountTooLarge); got != tt.wantErr {
+				t.Fatalf("checkAmountBounds error = %v, want too large = %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.queried == "" && len(queried) > 0:
+				t.Errorf("balance queried for %v, want no query", queried)
+			case tt.queried != "" && (len(queried) != 1 || queried[0] != tt.queried):
+				t.Errorf("balance queried for %v, want %s", queried, tt.queried)
+			}
+		})
+	}
+}
+
+func TestCheckUnstakeBoundsUsesStakedBalance(t *testing.T) {
+	cm, err := contracts.NewContractManager("../../config/contracts.json")
+	if err != nil {
+		t.Fatalf("NewContractManager: %v", err)
+	}
+
+	node := rpc.NewMockNode()
+	defer node.Close()
+	// 质押池中质押了 2 MTK，钱包余额为 0
+	node.On("eth_call", func([]interface{}) (interface{}, *rpc.RPCError) {
+		return "0x" + strings.Repeat("0", 48) + "1bc16d674ec80000", nil
+	})
+	node.On("eth_getBalance", func([]interface{}) (interface{}, *rpc.RPCError) {
+		return "0x0", nil
+	})
+	client := rpc.NewClient(node.URL())
+
+	tests := []struct {
+		name      string
+		data      map[string]any
+		amount    string
+		wantCalls bool
+		wantErr   bool
+	}{
+		{name: "within staked amount", data: map[string]any{"user_address": testUserAddress}, amount: "1", wantCalls: true},
+		{name: "read account", data: map[string]any{"read_account": testReadAccount}, amount: "2", wantCalls: true},
+		{name: "exceeds staked amount", data: map[string]any{"user_address": testUserAddress}, amount: "5", wantCalls: true, wantErr: true},
+		{name: "no account", data: map[string]any{}, amount: "5"},
+		{name: "dry run", data: map[string]any{"user_address": testUserAddress, "dry_run": true}, amount: "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := node.CallsTo("eth_call")
+			err := checkUnstakeBounds(context.Background(), cm, client, tt.data, "", "MTK", tt.amount)
+			if got := errors.Is(err, contracts.ErrAmountTooLarge); got != tt.wantErr {
+				t.Fatalf("checkUnstakeBounds error = %v, want too large = %v", err, tt.wantErr)
+			}
+			if got := node.CallsTo("eth_call") > calls; got != tt.wantCalls {
+				t.Errorf("staked balance queried = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+
+	// 取消质押不读取钱包余额
+	if n := node.CallsTo("eth_getBalance"); n != 0 {
+		t.Errorf("eth_getBalance called %d times, want none", n)
+	}
+}
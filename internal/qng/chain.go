@@ -2,11 +2,14 @@ package qng
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"qng_agent/internal/config"
-	"qng_agent/internal/llm"
 	"qng_agent/internal/contracts"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/logging"
 	"qng_agent/internal/rpc"
 	"sync"
 )
@@ -17,36 +20,41 @@ type Chain struct {
 	contractManager *contracts.ContractManager
 	rpcClient       *rpc.Client
 	langGraph       *LangGraph
+	signer          *Signer
 	mu              sync.RWMutex
 	running         bool
+	stopRates       context.CancelFunc
 }
 
 type ProcessResult struct {
-	NeedSignature    bool `json:"need_signature"`
-	SignatureRequest any  `json:"signature_request,omitempty"`
-	WorkflowContext  any  `json:"workflow_context,omitempty"`
-	FinalResult      any  `json:"final_result,omitempty"`
+	NeedSignature    bool             `json:"need_signature"`
+	SignatureRequest any              `json:"signature_request,omitempty"`
+	WorkflowContext  *WorkflowContext `json:"workflow_context,omitempty"`
+	FinalResult      any              `json:"final_result,omitempty"`
 }
 
-func NewChain(config config.QNGConfig) *Chain {
+// NewChain 创建QNG Chain。LLM客户端、合约管理器、工作流图或已启用的服务端签名器
+// 无法初始化时返回错误，调用方应拒绝启动。
+func NewChain(config config.QNGConfig) (*Chain, error) {
 	// 创建LLM客户端
 	var llmClient llm.Client
 	var err error
-	
-	// 从配置中获取LLM配置
-	if config.Chain.LLM.Provider != "" {
-		llmClient, err = llm.NewClient(config.Chain.LLM)
+
+	// 从配置中获取LLM配置，chain.llm 与顶层 llm 都未配置提供商时使用规则分解
+	llmConfig := resolveLLMConfig(config)
+	if llmConfig.Provider != "" || llmConfig.Strict {
+		llmClient, err = llm.NewClient(llmConfig)
 		if err != nil {
-			log.Printf("⚠️  无法创建LLM客户端: %v", err)
-			llmClient = nil
+			log.Printf("❌ 无法创建LLM客户端: %v", err)
+			return nil, fmt.Errorf("failed to create LLM client: %w", err)
 		}
 	}
 
 	// 创建合约管理器
 	contractManager, err := contracts.NewContractManager("config/contracts.json")
 	if err != nil {
-		log.Printf("⚠️  无法创建合约管理器: %v", err)
-		contractManager = nil
+		log.Printf("❌ 无法创建合约管理器: %v", err)
+		return nil, fmt.Errorf("failed to create contract manager: %w", err)
 	}
 
 	// 创建RPC客户端
@@ -58,8 +66,25 @@ func NewChain(config config.QNGConfig) *Chain {
 		log.Printf("⚠️  未配置RPC URL，使用模拟确认")
 	}
 
+	// 创建服务端签名器（默认关闭）
+	var signer *Signer
+	var signerAddress string
+	if config.Chain.Signer.Enabled {
+		signer, err = NewSigner(config.Chain.Signer, contractManager.ChainID())
+		if err != nil {
+			log.Printf("❌ 服务端签名器初始化失败: %v", err)
+			return nil, fmt.Errorf("failed to create signer: %w", err)
+		}
+		signerAddress = signer.Address()
+	}
+
 	// 创建LangGraph
-	langGraph := NewLangGraph(llmClient, contractManager, rpcClient, config.Chain.Transaction)
+	spendingGuard := NewSpendingGuard(config.Chain.SpendingLimits, contractManager, signerAddress, config.Chain.Transaction.PermissiveSignatures)
+	langGraph, err := NewLangGraph(llmClient, contractManager, rpcClient, config.Chain.Transaction, config.Chain.LangGraph, spendingGuard)
+	if err != nil {
+		log.Printf("❌ 无法创建LangGraph: %v", err)
+		return nil, err
+	}
 
 	chain := &Chain{
 		config:          config,
@@ -67,32 +92,62 @@ func NewChain(config config.QNGConfig) *Chain {
 		contractManager: contractManager,
 		rpcClient:       rpcClient,
 		langGraph:       langGraph,
+		signer:          signer,
+	}
+
+	return chain, nil
+}
+
+// resolveLLMConfig 返回任务分解使用的LLM配置：chain.llm 配置了提供商时使用它，
+// 否则回退到顶层 llm 配置，避免对话使用真实LLM而任务分解退化为规则分解
+func resolveLLMConfig(config config.QNGConfig) config.LLMConfig {
+	if config.Chain.LLM.Provider != "" || config.DefaultLLM.Provider == "" {
+		return config.Chain.LLM
 	}
 
-	return chain
+	log.Printf("🔧 未配置 chain.llm 提供商，任务分解使用顶层LLM配置: %s", config.DefaultLLM.Provider)
+	fallback := config.DefaultLLM
+	fallback.Strict = fallback.Strict || config.Chain.LLM.Strict
+	return fallback
 }
 
 func (c *Chain) Start() error {
 	log.Printf("🚀 QNG Chain启动")
 	c.running = true
+
+	// 启动链上汇率刷新（未启用时为空操作）
+	if c.contractManager != nil && c.rpcClient != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stopRates = cancel
+		c.contractManager.StartRateRefresher(ctx, c.rpcClient)
+	}
 	return nil
 }
 
 func (c *Chain) Stop() error {
 	log.Printf("🛑 QNG Chain停止")
 	c.running = false
+	if c.stopRates != nil {
+		c.stopRates()
+		c.stopRates = nil
+	}
 	return nil
 }
 
 func (c *Chain) ProcessMessage(ctx context.Context, message string) (*ProcessResult, error) {
-	log.Printf("🔄 QNG Chain开始处理消息")
-	log.Printf("📝 消息内容: %s", message)
-	
+	ctx = logging.EnsureCorrelationID(ctx)
+	slog.InfoContext(ctx, "🔄 QNG Chain开始处理消息", "message", message)
+
 	if !c.running {
 		log.Printf("❌ Chain未运行")
 		return nil, fmt.Errorf("chain is not running")
 	}
 
+	// 服务端签名时按签名账户的余额进行检查
+	if c.signer != nil && ctx.Value("user_address") == nil {
+		ctx = context.WithValue(ctx, "user_address", c.signer.Address())
+	}
+
 	// 使用LangGraph执行工作流
 	result, err := c.langGraph.ExecuteWorkflow(ctx, message)
 	if err != nil {
@@ -101,13 +156,13 @@ func (c *Chain) ProcessMessage(ctx context.Context, message string) (*ProcessRes
 	}
 
 	log.Printf("✅ LangGraph执行成功")
-	return result, nil
+	return c.autoSign(ctx, result)
 }
 
 func (c *Chain) ContinueWithSignature(ctx context.Context, workflowContext any, signature string) (*ProcessResult, error) {
-	log.Printf("🔄 QNG Chain使用签名继续工作流")
-	log.Printf("🔐 签名长度: %d", len(signature))
-	
+	ctx = logging.EnsureCorrelationID(ctx)
+	slog.InfoContext(ctx, "🔄 QNG Chain使用签名继续工作流", "signature_length", len(signature))
+
 	result, err := c.langGraph.ContinueWithSignature(ctx, workflowContext, signature)
 	if err != nil {
 		log.Printf("❌ 继续执行失败: %v", err)
@@ -115,5 +170,115 @@ func (c *Chain) ContinueWithSignature(ctx context.Context, workflowContext any,
 	}
 
 	log.Printf("✅ 继续执行成功")
+	return c.autoSign(ctx, result)
+}
+
+func (c *Chain) ResumeWorkflow(ctx context.Context, progress *TaskProgress) (*ProcessResult, error) {
+	ctx = logging.EnsureCorrelationID(ctx)
+	slog.InfoContext(ctx, "🔄 QNG Chain恢复工作流")
+
+	if !c.running {
+		log.Printf("❌ Chain未运行")
+		return nil, fmt.Errorf("chain is not running")
+	}
+
+	result, err := c.langGraph.ResumeWorkflow(ctx, progress)
+	if err != nil {
+		log.Printf("❌ 恢复执行失败: %v", err)
+		return nil, fmt.Errorf("resume workflow failed: %w", err)
+	}
+
+	log.Printf("✅ 恢复执行成功")
+	return c.autoSign(ctx, result)
+}
+
+// Graph 导出工作流图的节点与边
+func (c *Chain) Graph() GraphExport {
+	return c.langGraph.Export()
+}
+
+// Nodes 返回工作流图中的节点及其启用状态
+func (c *Chain) Nodes() []NodeInfo {
+	return c.langGraph.Nodes()
+}
+
+// GetTokens 返回合约管理器中配置的代币信息
+func (c *Chain) GetTokens() ([]contracts.TokenConfig, error) {
+	if c.contractManager == nil {
+		return nil, fmt.Errorf("contract manager not initialized")
+	}
+	return c.contractManager.GetTokens(), nil
+}
+
+// GetStakingPosition 读取地址在质押池中的质押数量与待领取奖励，池名称为空时使用默认池
+func (c *Chain) GetStakingPosition(ctx context.Context, pool, address string) (*contracts.StakingPosition, error) {
+	if c.contractManager == nil {
+		return nil, fmt.Errorf("contract manager not initialized")
+	}
+	if c.rpcClient == nil {
+		return nil, fmt.Errorf("rpc client not configured")
+	}
+	return c.contractManager.GetStakingPosition(ctx, c.rpcClient, pool, address)
+}
+
+// SendRawTransaction 广播已签名的交易
+func (c *Chain) SendRawTransaction(ctx context.Context, signedHex string) (string, error) {
+	if c.rpcClient == nil {
+		return "", fmt.Errorf("rpc client not configured")
+	}
+	return c.rpcClient.SendRawTransaction(ctx, signedHex)
+}
+
+// requiresManualConfirmation 检查工作流是否被标记为需要用户手动确认
+func requiresManualConfirmation(result *ProcessResult) bool {
+	if result.WorkflowContext == nil {
+		return false
+	}
+	manual, _ := result.WorkflowContext.Data["manual_confirmation"].(bool)
+	return manual
+}
+
+// autoSign 启用服务端签名时，直接签名并广播待签名交易，跳过 waiting_signature 步骤
+func (c *Chain) autoSign(ctx context.Context, result *ProcessResult) (*ProcessResult, error) {
+	if result != nil && result.NeedSignature && requiresManualConfirmation(result) {
+		if request, ok := result.SignatureRequest.(map[string]any); ok {
+			request["manual_confirmation"] = true
+		}
+		if c.signer != nil {
+			log.Printf("🚨 工作流需要手动确认，跳过服务端签名")
+		}
+		return result, nil
+	}
+
+	for round := 1; c.signer != nil && result != nil && result.NeedSignature; round++ {
+		if err := CheckSignatureRounds(round, c.config.MaxSignatureRounds); err != nil {
+			log.Printf("🛑 服务端签名轮数超出上限: %v", err)
+			return nil, err
+		}
+		log.Printf("✍️  使用服务端签名器签名交易")
+
+		var txHash string
+		var err error
+		if request, ok := result.SignatureRequest.(map[string]any); ok && request["type"] == batchSignatureType {
+			txHash, err = c.signer.signBatch(ctx, c.rpcClient, request)
+		} else {
+			txHash, err = c.signer.SignAndSend(ctx, c.rpcClient, result.SignatureRequest)
+		}
+		if err != nil {
+			log.Printf("❌ 服务端签名广播失败: %v", err)
+			return nil, fmt.Errorf("server-side signing failed: %w", err)
+		}
+
+		result, err = c.langGraph.ContinueWithSignature(ctx, result.WorkflowContext, txHash)
+		if err != nil {
+			log.Printf("❌ 继续执行失败: %v", err)
+			// 交易已广播但工作流被取消，返回交易哈希，调用方据此记录仍在等待确认的交易
+			var confirmationErr *ConfirmationError
+			if ctx.Err() != nil && !errors.As(err, &confirmationErr) {
+				err = newConfirmationError(txHash, err)
+			}
+			return nil, fmt.Errorf("continue with signature failed: %w", err)
+		}
+	}
 	return result, nil
 }
@@ -0,0 +1,194 @@
+package qng
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+	"qng_agent/internal/rpc"
+)
+
+// newTestChain 使用仓库配置、模拟LLM与模拟RPC节点构建工作流链，signer 为 true 时启用服务端签名
+func newTestChain(t *testing.T, signer bool) (*Chain, *rpc.MockNode) {
+	t.Helper()
+	// 合约配置按仓库根目录的相对路径加载
+	t.Chdir("../..")
+
+	node := rpc.NewMockNode()
+	t.Cleanup(node.Close)
+
+	cfg := config.QNGConfig{}
+	cfg.Chain.LLM = config.LLMConfig{Provider: llm.ProviderMock}
+	cfg.Chain.RPCURL = node.URL()
+	cfg.Chain.Transaction = config.TransactionConfig{
+		ConfirmationTimeout:   10,
+		PollingInterval:       1,
+		RequiredConfirmations: 1,
+		ConfirmationStrategy:  rpc.StrategyConfirmations,
+		// 模拟节点的交易哈希没有对应的签名数据，无法恢复签名者
+		PermissiveSignatures: true,
+	}
+	// 与部署配置一致，节点在独立的超时上下文中执行
+	cfg.Chain.LangGraph.NodeTimeout = 60
+	if signer {
+		t.Setenv("QNG_SIGNER_PRIVATE_KEY", hex.EncodeToString(eip155Key))
+		cfg.Chain.Signer = config.SignerConfig{Enabled: true}
+	}
+
+	chain, err := NewChain(cfg)
+	if err != nil {
+		t.Fatalf("NewChain: %v", err)
+	}
+	if err := chain.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { chain.Stop() })
+	return chain, node
+}
+
+// TestProcessMessageManualConfirmationSkipsAutoSign 被标记为需要手动确认的工作流即使启用了服务端签名也不得自动签名
+func TestProcessMessageManualConfirmationSkipsAutoSign(t *testing.T) {
+	chain, node := newTestChain(t, true)
+
+	ctx := context.WithValue(context.Background(), "manual_confirmation", true)
+	result, err := chain.ProcessMessage(ctx, "兑换1 MEER的MTK")
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+
+	if sent := node.CallsTo("eth_sendRawTransaction"); sent != 0 {
+		t.Errorf("server signer broadcast %d transactions, want none", sent)
+	}
+	if result == nil || !result.NeedSignature {
+		t.Fatalf("result = %+v, want a signature request for the user", result)
+	}
+	if manual, _ := result.WorkflowContext.Data["manual_confirmation"].(bool); !manual {
+		t.Errorf("workflow data manual_confirmation = %v, want true", result.WorkflowContext.Data["manual_confirmation"])
+	}
+	request, _ := result.SignatureRequest.(map[string]any)
+	if manual, _ := request["manual_confirmation"].(bool); !manual {
+		t.Errorf("signature request manual_confirmation = %v, want true", request["manual_confirmation"])
+	}
+}
+
+// TestProcessMessageCancelledAfterBroadcast 服务端签名广播后工作流被取消，错误中带有已广播的交易哈希
+func TestProcessMessageCancelledAfterBroadcast(t *testing.T) {
+	chain, node := newTestChain(t, true)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "user_address", testUserAddress))
+	defer cancel()
+	node.On("eth_getTransactionReceipt", func(params []interface{}) (interface{}, *rpc.RPCError) {
+		cancel()
+		return nil, nil
+	})
+
+	_, err := chain.ProcessMessage(ctx, "兑换1 MEER的MTK")
+	if node.CallsTo("eth_sendRawTransaction") != 1 {
+		t.Fatalf("eth_sendRawTransaction called %d times, want 1", node.CallsTo("eth_sendRawTransaction"))
+	}
+	var confirmationErr *ConfirmationError
+	if want := fmt.Sprintf("0x%064x", 1); !errors.As(err, &confirmationErr) || confirmationErr.TxHash != want {
+		t.Errorf("error = %v, want a ConfirmationError for the broadcast transaction %s", err, want)
+	}
+}
+
+// TestSwapSignatureRequestEstimatesOutput 兑换函数没有 minAmountOut 参数时签名请求不声称最少输出
+func TestSwapSignatureRequestEstimatesOutput(t *testing.T) {
+	chain, _ := newTestChain(t, false)
+
+	result, err := chain.ProcessMessage(context.Background(), "兑换1 MEER的MTK")
+	if err != nil {
+		t.Fatalf("ProcessMessage: %v", err)
+	}
+	request, _ := result.SignatureRequest.(map[string]any)
+	if request["action"] != "swap" {
+		t.Fatalf("signature request = %v, want a swap", request)
+	}
+	for _, field := range []string{"min_output", "min_output_display", "slippage"} {
+		if value, exists := request[field]; exists {
+			t.Errorf("%s = %v, want omitted", field, value)
+		}
+	}
+	if estimated, _ := request["output_estimated"].(bool); !estimated {
+		t.Error("output_estimated not set")
+	}
+	if description, _ := request["description"].(string); strings.Contains(description, "最少") && !strings.Contains(description, "不保证") {
+		t.Errorf("description %q promises a minimum output", description)
+	}
+}
+
+// TestProcessMessageChecksReadAccountBalance 只读账户经工作流图传到执行节点，用于余额检查
+func TestProcessMessageChecksReadAccountBalance(t *testing.T) {
+	chain, node := newTestChain(t, false)
+
+	var mu sync.Mutex
+	var queried []string
+	node.On("eth_getBalance", func(params []interface{}) (interface{}, *rpc.RPCError) {
+		address, _ := params[0].(string)
+		mu.Lock()
+		queried = append(queried, address)
+		mu.Unlock()
+		return "0xde0b6b3a7640000", nil // 1 MEER
+	})
+
+	ctx := context.WithValue(context.Background(), "read_account", testReadAccount)
+	result, err := chain.ProcessMessage(ctx, "兑换5 MEER的MTK")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(queried, testReadAccount) {
+		t.Fatalf("balance queried for %v, want %s", queried, testReadAccount)
+	}
+	// 只读账户余额不足时不应生成签名请求
+	if err == nil && result != nil && result.NeedSignature {
+		t.Errorf("workflow requested a signature for 5 MEER with a 1 MEER read account balance")
+	}
+}
+
+// TestConcurrentWorkflowsStartAtDecomposer 签名后恢复执行的工作流不应改变其他工作流的入口节点
+func TestConcurrentWorkflowsStartAtDecomposer(t *testing.T) {
+	chain, _ := newTestChain(t, false)
+
+	ctx := context.WithValue(context.Background(), "user_address", testUserAddress)
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 3 {
+				result, err := chain.ProcessMessage(ctx, "兑换1 MEER的MTK")
+				if err != nil {
+					t.Errorf("ProcessMessage: %v", err)
+					return
+				}
+				request, _ := result.SignatureRequest.(map[string]any)
+				if !result.NeedSignature || request["action"] != "swap" {
+					t.Errorf("new workflow did not start at the task decomposer: %+v", result)
+					return
+				}
+				txHash := fmt.Sprintf("0x%064x", i*10+j+1)
+				if _, err := chain.ContinueWithSignature(ctx, result.WorkflowContext, txHash); err != nil {
+					t.Errorf("ContinueWithSignature: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContinueWithSignatureUnknownNode(t *testing.T) {
+	chain, _ := newTestChain(t, false)
+
+	wc := &WorkflowContext{CurrentNode: "swap_executor", NextNodes: []string{"missing_node"}, Data: map[string]any{}}
+	if _, err := chain.ContinueWithSignature(context.Background(), wc, "0x01"); err == nil {
+		t.Fatal("expected error for an unknown next node")
+	}
+}
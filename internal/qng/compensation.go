@@ -0,0 +1,263 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"qng_agent/internal/contracts"
+)
+
+// TaskOutcome 工作流失败时单个任务的执行情况
+type TaskOutcome struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Summary string `json:"summary"`
+	TxHash  string `json:"tx_hash,omitempty"`
+	// ExplorerURL 区块浏览器中的交易链接，未配置浏览器时为空
+	ExplorerURL string `json:"explorer_url,omitempty"`
+	// Error 失败原因，仅失败的任务设置
+	Error string `json:"error,omitempty"`
+}
+
+// FailureReport 工作流失败时的补偿报告：哪些任务已经上链、哪些失败或尚未执行，以及建议的恢复操作
+type FailureReport struct {
+	FailedNode       string        `json:"failed_node"`
+	Error            string        `json:"error"`
+	CompletedTasks   []TaskOutcome `json:"completed_tasks"`
+	FailedTasks      []TaskOutcome `json:"failed_tasks"`
+	PendingTasks     []TaskOutcome `json:"pending_tasks,omitempty"`
+	SuggestedActions []string      `json:"suggested_actions,omitempty"`
+}
+
+// Summary 生成面向用户的简要说明，列出已完成的交易与建议操作
+func (r *FailureReport) Summary() string {
+	var b strings.Builder
+	for _, task := range r.CompletedTasks {
+		fmt.Fprintf(&b, "\n✅ 已完成: %s", task.Summary)
+		if task.TxHash != "" {
+			fmt.Fprintf(&b, " (%s)", task.TxHash)
+		}
+	}
+	for _, task := range r.FailedTasks {
+		fmt.Fprintf(&b, "\n❌ 失败: %s", task.Summary)
+	}
+	for _, action := range r.SuggestedActions {
+		fmt.Fprintf(&b, "\n💡 %s", action)
+	}
+	return b.String()
+}
+
+// WorkflowFailure 节点执行失败的错误，携带补偿报告与失败时的任务进度。
+// 错误信息与 Unwrap 均保持原始错误，调用方仍可按原有错误类型判断
+type WorkflowFailure struct {
+	Report   *FailureReport
+	Progress *TaskProgress
+	Err      error
+}
+
+func (e *WorkflowFailure) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WorkflowFailure) Unwrap() error {
+	return e.Err
+}
+
+// compensate 节点失败时由结果聚合节点生成补偿报告，附加到错误上返回；还没有分解出任务时原样返回错误
+func (lg *LangGraph) compensate(nodeName string, data map[string]any, err error) error {
+	if tasks, _ := data["tasks"].([]map[string]any); len(tasks) == 0 {
+		return err
+	}
+
+	aggregator, ok := lg.nodes["result_aggregator"].(*ResultAggregatorNode)
+	if !ok {
+		aggregator = NewResultAggregatorNode(lg.contractManager)
+	}
+	report := aggregator.FailureReport(data, nodeName, err)
+	log.Printf("🧾 补偿报告: 完成 %d 个任务，失败 %d 个，未执行 %d 个",
+		len(report.CompletedTasks), len(report.FailedTasks), len(report.PendingTasks))
+
+	return &WorkflowFailure{
+		Report:   report,
+		Progress: ExtractTaskProgress(&WorkflowContext{CurrentNode: nodeName, Data: data}),
+		Err:      err,
+	}
+}
+
+// FailureReport 根据节点共享数据整理失败时各任务的状态，并给出恢复建议
+func (n *ResultAggregatorNode) FailureReport(data map[string]any, failedNode string, err error) *FailureReport {
+	report := &FailureReport{
+		FailedNode:     failedNode,
+		Error:          err.Error(),
+		CompletedTasks: []TaskOutcome{},
+		FailedTasks:    []TaskOutcome{},
+	}
+
+	tasks, _ := data["tasks"].([]map[string]any)
+	completed := make(map[string]bool)
+	if completedTasks, ok := data["completed_tasks"].([]string); ok {
+		for _, taskID := range completedTasks {
+			completed[taskID] = true
+		}
+	}
+	failed := failedTaskIDs(failedNode, data, completed)
+
+	var confirmErr *ConfirmationError
+	hasConfirmErr := errors.As(err, &confirmErr)
+
+	for _, task := range tasks {
+		taskID, _ := task["id"].(string)
+		outcome := TaskOutcome{
+			ID:      taskID,
+			Summary: taskSummary(n.contractManager, data, task),
+		}
+		outcome.Type, _ = task["type"].(string)
+
+		switch {
+		case completed[taskID]:
+			outcome.TxHash, _ = data[taskID+"_tx_hash"].(string)
+			outcome.ExplorerURL = n.explorerURL(outcome.TxHash)
+			report.CompletedTasks = append(report.CompletedTasks, outcome)
+		case failed[taskID]:
+			outcome.Error = err.Error()
+			if hasConfirmErr {
+				outcome.TxHash = confirmErr.TxHash
+				outcome.ExplorerURL = n.explorerURL(outcome.TxHash)
+			}
+			report.FailedTasks = append(report.FailedTasks, outcome)
+		default:
+			report.PendingTasks = append(report.PendingTasks, outcome)
+		}
+	}
+
+	report.SuggestedActions = suggestedActions(n.contractManager, data, tasks, completed, err)
+	return report
+}
+
+// explorerURL 返回交易的浏览器链接
+func (n *ResultAggregatorNode) explorerURL(txHash string) string {
+	if txHash == "" || n.contractManager == nil {
+		return ""
+	}
+	return n.contractManager.ExplorerTxURL(txHash)
+}
+
+// failedTaskIDs 确定失败的任务：批量签名中未完成的任务、执行节点记录的当前任务，
+// 或失败节点负责的第一个可执行任务
+func failedTaskIDs(failedNode string, data map[string]any, completed map[string]bool) map[string]bool {
+	failed := make(map[string]bool)
+	for _, taskID := range batchTaskIDs(data) {
+		if !completed[taskID] {
+			failed[taskID] = true
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+
+	if taskID, _ := data["current_task_id"].(string); taskID != "" && !completed[taskID] {
+		failed[taskID] = true
+		return failed
+	}
+
+	for _, task := range readyTasks(data) {
+		if failedNode == "parallel_executor" || executorForTask(task) == failedNode {
+			taskID, _ := task["id"].(string)
+			failed[taskID] = true
+			if failedNode != "parallel_executor" {
+				break
+			}
+		}
+	}
+	return failed
+}
+
+// taskSummary 任务的简要描述，数量按代币精度格式化；all_from_previous 在依赖任务已完成时显示其输出数量
+func taskSummary(cm *contracts.ContractManager, data map[string]any, task map[string]any) string {
+	amount := func(token any) string {
+		symbol := fmt.Sprint(token)
+		value, _ := task["amount"].(string)
+		if value == contracts.AmountFromPrevious {
+			output, err := dependencyOutputAmount(task, data)
+			if err != nil {
+				return "上一步获得的全部 " + symbol
+			}
+			value = output
+		}
+		return displayAmount(cm, symbol, value)
+	}
+
+	taskType, _ := task["type"].(string)
+	switch taskType {
+	case "swap":
+		return fmt.Sprintf("兑换 %s 为 %v", amount(task["from_token"]), task["to_token"])
+	case "stake":
+		if pool, _ := task["pool"].(string); pool != "" {
+			return fmt.Sprintf("质押 %s 到 %s 池", amount(task["token"]), pool)
+		}
+		return fmt.Sprintf("质押 %s", amount(task["token"]))
+	case "transfer":
+		return fmt.Sprintf("向 %v 转账 %s", task["to_address"], amount(task["token"]))
+	}
+	return fmt.Sprintf("%s 任务 %v", taskType, task["id"])
+}
+
+// displayAmount 面向用户显示的代币数量（见 ContractManager.FormatAmount），合约管理器未初始化时原样附加代币符号
+func displayAmount(cm *contracts.ContractManager, symbol, amount string) string {
+	if cm == nil {
+		return strings.TrimSpace(amount + " " + symbol)
+	}
+	return cm.FormatAmount(symbol, amount)
+}
+
+// suggestedActions 按失败原因与已完成的任务生成恢复建议
+func suggestedActions(cm *contracts.ContractManager, data map[string]any, tasks []map[string]any, completed map[string]bool, err error) []string {
+	var actions []string
+
+	var confirmErr *ConfirmationError
+	var limitErr *SpendingLimitError
+	var balanceErr *contracts.InsufficientBalanceError
+	switch {
+	case errors.As(err, &confirmErr) && confirmErr.Retryable():
+		actions = append(actions, fmt.Sprintf("交易 %s 尚未确认，可调用 retry_confirmation 重新等待确认，不要重复签名；交易卡住时可调用 speed_up_transaction 或 cancel_transaction", confirmErr.TxHash))
+	case errors.As(err, &confirmErr):
+		actions = append(actions, fmt.Sprintf("交易 %s 已回滚，请检查余额与授权后调用 resume_workflow 重新执行失败的任务", confirmErr.TxHash))
+	case errors.As(err, &balanceErr):
+		actions = append(actions, fmt.Sprintf("%s 余额不足：需要 %s，可用 %s，还差 %s %s；请充值或减少数量后调用 resume_workflow 重新执行",
+			balanceErr.Token, balanceErr.Required, balanceErr.Available, balanceErr.Missing, balanceErr.Token))
+	case errors.As(err, &limitErr):
+		actions = append(actions, "请调整数量或支出限额后重新提交剩余任务")
+	default:
+		actions = append(actions, "可调用 resume_workflow 从失败的任务继续执行，已完成的任务不会重复执行")
+	}
+
+	// 已完成的任务无法自动撤销，被依赖的任务失败时提示用户手动处理
+	for _, task := range tasks {
+		taskID, _ := task["id"].(string)
+		depID, _ := task["dependency_tx_id"].(string)
+		if completed[taskID] || depID == "" || !completed[depID] {
+			continue
+		}
+		for _, dependency := range tasks {
+			if id, _ := dependency["id"].(string); id != depID {
+				continue
+			}
+			if dependency["type"] == "swap" {
+				actions = append(actions, fmt.Sprintf("兑换得到的 %v 仍在钱包中；如不再继续，可手动将其兑换回 %v",
+					dependency["to_token"], dependency["from_token"]))
+			}
+		}
+	}
+	for _, task := range tasks {
+		taskID, _ := task["id"].(string)
+		if approved, _ := data[taskID+"_approve_completed"].(bool); approved && !completed[taskID] {
+			token, _ := task["token"].(string)
+			amount, _ := task["amount"].(string)
+			actions = append(actions, fmt.Sprintf("质押合约仍持有 %s 的授权额度；如不再继续，可将授权额度重置为 0",
+				displayAmount(cm, token, amount)))
+		}
+	}
+	return actions
+}
@@ -0,0 +1,83 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"qng_agent/internal/config"
+	"qng_agent/internal/rpc"
+)
+
+// 交易确认失败类型
+const (
+	ConfirmationTimeout  = "timeout"
+	ConfirmationReverted = "reverted"
+	ConfirmationRPCError = "rpc_error"
+)
+
+// ConfirmationError 交易确认失败的结构化错误
+type ConfirmationError struct {
+	Kind   string
+	TxHash string
+	// TaskID 交易所属的任务，确认前失败时为空
+	TaskID string
+	Err    error
+}
+
+func (e *ConfirmationError) Error() string {
+	return fmt.Sprintf("transaction %s confirmation failed (%s): %v", e.TxHash, e.Kind, e.Err)
+}
+
+func (e *ConfirmationError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 交易被回滚时重试无意义，超时和RPC错误可以重新等待确认
+func (e *ConfirmationError) Retryable() bool {
+	return e.Kind != ConfirmationReverted
+}
+
+// newConfirmationError 根据RPC错误归类确认失败类型
+func newConfirmationError(txHash string, err error) *ConfirmationError {
+	kind := ConfirmationRPCError
+	switch {
+	case errors.Is(err, rpc.ErrTransactionReverted):
+		kind = ConfirmationReverted
+	case errors.Is(err, rpc.ErrConfirmationTimeout):
+		kind = ConfirmationTimeout
+	}
+	return &ConfirmationError{
+		Kind:   kind,
+		TxHash: txHash,
+		Err:    err,
+	}
+}
+
+// transactionAction 返回任务当前交易的类型：授权步骤为 approve，否则为任务类型
+func transactionAction(data map[string]any, taskID string) string {
+	if step, _ := data[taskID+"_current_step"].(string); step == "approve" {
+		return "approve"
+	}
+	if tasks, ok := data["tasks"].([]map[string]any); ok {
+		for _, task := range tasks {
+			if id, _ := task["id"].(string); id == taskID {
+				action, _ := task["type"].(string)
+				return action
+			}
+		}
+	}
+	return ""
+}
+
+// requiredConfirmations 返回交易类型所需的确认数，未单独配置的类型使用 required_confirmations
+func requiredConfirmations(txConfig config.TransactionConfig, action string) int {
+	if confirmations, ok := txConfig.ActionConfirmations[action]; ok && confirmations > 0 {
+		log.Printf("🎚️  %s 交易使用确认数 %d", action, confirmations)
+		return confirmations
+	}
+	if action == "" {
+		action = "未知类型"
+	}
+	log.Printf("🎚️  %s 交易使用默认确认数 %d", action, txConfig.RequiredConfirmations)
+	return txConfig.RequiredConfirmations
+}
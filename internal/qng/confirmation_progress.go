@@ -0,0 +1,50 @@
+package qng
+
+import (
+	"context"
+	"qng_agent/internal/rpc"
+	"time"
+)
+
+// ConfirmationProgress 签名验证节点等待交易确认时推送的进度
+type ConfirmationProgress struct {
+	// TaskID 交易所属的任务，批量签名时用于区分各笔交易
+	TaskID string `json:"task_id,omitempty"`
+	// Gas 用户提交签名时声明的 gas 参数
+	Gas *GasOverride `json:"gas,omitempty"`
+	rpc.ConfirmationProgress
+}
+
+type confirmationProgressKey struct{}
+
+// WithConfirmationProgress 返回携带确认进度回调的上下文，工作流执行期间签名验证节点会通过回调推送确认进度
+func WithConfirmationProgress(ctx context.Context, fn func(ConfirmationProgress)) context.Context {
+	return context.WithValue(ctx, confirmationProgressKey{}, fn)
+}
+
+// confirmationProgressFunc 返回上下文中的确认进度回调，未设置时返回 nil
+func confirmationProgressFunc(ctx context.Context) func(ConfirmationProgress) {
+	fn, _ := ctx.Value(confirmationProgressKey{}).(func(ConfirmationProgress))
+	return fn
+}
+
+// progressReporter 为任务的交易生成 RPC 轮询的进度回调，按 progress_interval 限制推送频率，
+// 达到所需确认时总是推送。上下文中没有进度回调时返回 nil
+func (n *SignatureValidatorNode) progressReporter(ctx context.Context, taskID string) func(rpc.ConfirmationProgress) {
+	notify := confirmationProgressFunc(ctx)
+	if notify == nil {
+		return nil
+	}
+
+	gas := gasOverrideFrom(ctx)
+	interval := time.Duration(n.txConfig.ProgressInterval) * time.Second
+	var last time.Time
+	return func(p rpc.ConfirmationProgress) {
+		done := p.Finalized || (p.Strategy != rpc.StrategyFinalized && p.Mined && p.Confirmations >= int64(p.Required))
+		if !done && !last.IsZero() && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		notify(ConfirmationProgress{TaskID: taskID, Gas: gas, ConfirmationProgress: p})
+	}
+}
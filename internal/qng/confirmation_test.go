@@ -0,0 +1,49 @@
+package qng
+
+import (
+	"testing"
+
+	"qng_agent/internal/config"
+)
+
+func TestTransactionConfirmations(t *testing.T) {
+	data := map[string]any{
+		"tasks": []map[string]any{
+			{"id": "task_1", "type": "swap"},
+			{"id": "task_2", "type": "stake"},
+			{"id": "task_3", "type": "transfer"},
+		},
+		"task_2_current_step": "approve",
+	}
+	txConfig := config.TransactionConfig{
+		RequiredConfirmations: 2,
+		ActionConfirmations:   map[string]int{"approve": 1, "stake": 6, "transfer": 0},
+	}
+
+	tests := []struct {
+		name          string
+		taskID        string
+		step          string
+		wantAction    string
+		confirmations int
+	}{
+		{name: "swap uses default", taskID: "task_1", wantAction: "swap", confirmations: 2},
+		{name: "stake approve step", taskID: "task_2", step: "approve", wantAction: "approve", confirmations: 1},
+		{name: "stake after approval", taskID: "task_2", wantAction: "stake", confirmations: 6},
+		{name: "zero override uses default", taskID: "task_3", wantAction: "transfer", confirmations: 2},
+		{name: "unknown task", taskID: "task_9", wantAction: "", confirmations: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data["task_2_current_step"] = tt.step
+			action := transactionAction(data, tt.taskID)
+			if action != tt.wantAction {
+				t.Errorf("transactionAction = %q, want %q", action, tt.wantAction)
+			}
+			if got := requiredConfirmations(txConfig, action); got != tt.confirmations {
+				t.Errorf("requiredConfirmations(%q) = %d, want %d", action, got, tt.confirmations)
+			}
+		})
+	}
+}
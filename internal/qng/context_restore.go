@@ -0,0 +1,162 @@
+package qng
+
+import (
+	"encoding/json"
+	"fmt"
+	"qng_agent/internal/contracts"
+)
+
+// WorkflowContext 工作流等待签名时的可序列化上下文，记录暂停的节点、签名后继续执行的节点
+// 以及节点共享数据，可随会话持久化或经 HTTP 传递后由 ContinueWithSignature 重建执行状态
+type WorkflowContext struct {
+	CurrentNode string         `json:"current_node"`
+	NextNodes   []string       `json:"next_nodes"`
+	Data        map[string]any `json:"data"`
+	Context     map[string]any `json:"context,omitempty"`
+}
+
+// UnmarshalJSON 解码工作流上下文，并将共享数据中的字段恢复为节点使用的具体类型
+func (wc *WorkflowContext) UnmarshalJSON(b []byte) error {
+	type plain WorkflowContext
+	var decoded plain
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	if decoded.Data != nil {
+		if err := restoreDataTypes(decoded.Data); err != nil {
+			return err
+		}
+	}
+	*wc = WorkflowContext(decoded)
+	return nil
+}
+
+// legacyWorkflowContext 旧版本保存的工作流上下文格式，节点输出与输入整体嵌套保存
+type legacyWorkflowContext struct {
+	CurrentNode string      `json:"current_node"`
+	NodeOutput  *NodeOutput `json:"node_output"`
+	Input       NodeInput   `json:"input"`
+}
+
+// RestoreWorkflowContext 将任意形式的工作流上下文（内存中的上下文、JSON 解码得到的 map、
+// 原始 JSON 或旧版本保存的格式）恢复为 WorkflowContext
+func RestoreWorkflowContext(raw any) (*WorkflowContext, error) {
+	var encoded []byte
+	switch v := raw.(type) {
+	case *WorkflowContext:
+		if v == nil {
+			return nil, fmt.Errorf("workflow context is nil")
+		}
+		return v, nil
+	case WorkflowContext:
+		return &v, nil
+	case json.RawMessage:
+		encoded = v
+	case []byte:
+		encoded = v
+	case string:
+		encoded = []byte(v)
+	case map[string]any:
+		var err error
+		if encoded, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("failed to encode workflow context: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid workflow context type: %T", raw)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &probe); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow context: %w", err)
+	}
+
+	var wc WorkflowContext
+	if _, legacy := probe["node_output"]; legacy {
+		var old legacyWorkflowContext
+		if err := json.Unmarshal(encoded, &old); err != nil {
+			return nil, fmt.Errorf("failed to decode workflow context: %w", err)
+		}
+		if old.NodeOutput == nil || old.NodeOutput.Data == nil {
+			return nil, fmt.Errorf("incomplete workflow context")
+		}
+		if err := restoreDataTypes(old.NodeOutput.Data); err != nil {
+			return nil, err
+		}
+		wc = WorkflowContext{
+			CurrentNode: old.CurrentNode,
+			NextNodes:   old.NodeOutput.NextNodes,
+			Data:        old.NodeOutput.Data,
+			Context:     old.Input.Context,
+		}
+	} else if err := json.Unmarshal(encoded, &wc); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow context: %w", err)
+	}
+
+	if wc.CurrentNode == "" || wc.Data == nil {
+		return nil, fmt.Errorf("incomplete workflow context")
+	}
+	return &wc, nil
+}
+
+// restoreDataTypes 将节点共享数据中经 JSON 往返后变为通用类型的字段恢复为节点使用的具体类型
+func restoreDataTypes(data map[string]any) error {
+	restore := func(key string, target any) error {
+		value, exists := data[key]
+		if !exists || value == nil {
+			return nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		if err := json.Unmarshal(encoded, target); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", key, err)
+		}
+		return nil
+	}
+
+	var tasks []map[string]any
+	var completed []string
+	var batch []string
+	var spent map[string]float64
+	var routes map[string][]contracts.SwapHop
+	var history []ConversationTurn
+	if err := restore("tasks", &tasks); err != nil {
+		return err
+	}
+	if err := restore("completed_tasks", &completed); err != nil {
+		return err
+	}
+	if err := restore(batchTasksKey, &batch); err != nil {
+		return err
+	}
+	if err := restore("spent_amounts", &spent); err != nil {
+		return err
+	}
+	if err := restore("conversation_history", &history); err != nil {
+		return err
+	}
+	if err := restore(swapRoutesKey, &routes); err != nil {
+		return err
+	}
+
+	if tasks != nil {
+		data["tasks"] = tasks
+	}
+	if completed != nil {
+		data["completed_tasks"] = completed
+	}
+	if batch != nil {
+		data[batchTasksKey] = batch
+	}
+	if spent != nil {
+		data["spent_amounts"] = spent
+	}
+	if history != nil {
+		data["conversation_history"] = history
+	}
+	if routes != nil {
+		data[swapRoutesKey] = routes
+	}
+	return nil
+}
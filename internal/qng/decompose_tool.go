@@ -0,0 +1,107 @@
+package qng
+
+import (
+	"context"
+	"log"
+	"qng_agent/internal/llm"
+)
+
+// decomposeTasksTool 任务分解使用的函数名称
+const decomposeTasksTool = "decompose_tasks"
+
+// nullable 返回允许为 null 的字段 schema，严格模式下所有字段必填，不适用的字段以 null 表示
+func nullable(fieldType, description string) map[string]any {
+	return map[string]any{
+		"type":        []string{fieldType, "null"},
+		"description": description,
+	}
+}
+
+// decomposeTasksToolDef 任务分解的函数定义，严格模式下模型返回的参数必须符合该 schema
+var decomposeTasksToolDef = llm.Tool{
+	Name:        decomposeTasksTool,
+	Description: "将用户的DeFi请求分解为按顺序执行的任务",
+	Strict:      true,
+	Parameters: map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"tasks"},
+		"properties": map[string]any{
+			"tasks": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"additionalProperties": false,
+					"required": []string{
+						"id", "type", "amount", "from_token", "to_token", "token",
+						"pool", "to_address", "dependency_tx_id", "description",
+					},
+					"properties": map[string]any{
+						"id":               map[string]any{"type": "string", "description": "唯一的任务ID，如 task_1"},
+						"type":             map[string]any{"type": "string", "enum": []string{"swap", "stake", "transfer"}},
+						"amount":           map[string]any{"type": "string", "description": "数量，或 all_from_previous 表示使用依赖任务的全部输出"},
+						"from_token":       nullable("string", "兑换卖出的代币，仅 swap"),
+						"to_token":         nullable("string", "兑换买入的代币，仅 swap"),
+						"token":            nullable("string", "质押或转账的代币，仅 stake/transfer"),
+						"pool":             nullable("string", "质押池名称，仅 stake，用户未指定时为 null"),
+						"to_address":       nullable("string", "接收地址，仅 transfer"),
+						"dependency_tx_id": nullable("string", "依赖的任务ID，独立任务为 null"),
+						"description":      map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+	},
+}
+
+// decomposeWithLLM 调用LLM分解任务。客户端支持函数调用时使用 decompose_tasks 获取结构化结果，
+// 否则或模型没有调用函数时从文本回复中解析；无法解析时按 parse_failure 策略处理
+func (n *TaskDecomposerNode) decomposeWithLLM(ctx context.Context, prompt, userMessage string) ([]map[string]any, error) {
+	messages := []llm.Message{{Role: "user", Content: prompt}}
+
+	toolClient, ok := n.llmClient.(llm.ToolClient)
+	if !ok {
+		response, err := n.llmClient.Chat(ctx, messages, n.chatOptions...)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("✅ LLM响应成功")
+		log.Printf("📄 LLM响应: %s", response)
+		if tasks, ok := n.parseTasksFromResponse(ctx, response); ok {
+			return tasks, nil
+		}
+		return n.handleParseFailure(ctx, messages, response, userMessage)
+	}
+
+	response, err := toolClient.ChatWithTools(ctx, messages, []llm.Tool{decomposeTasksToolDef}, n.chatOptions...)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("✅ LLM响应成功")
+
+	if call := response.ToolCallByName(decomposeTasksTool); call != nil {
+		log.Printf("🔧 函数调用参数: %s", call.Arguments)
+		if tasks, ok := n.tasksFromJSON(ctx, call.Arguments); ok {
+			for _, task := range tasks {
+				dropNullFields(task)
+			}
+			return tasks, nil
+		}
+		return n.handleParseFailure(ctx, messages, call.Arguments, userMessage)
+	}
+
+	log.Printf("⚠️  模型没有调用 %s，从文本回复中解析", decomposeTasksTool)
+	if tasks, ok := n.parseTasksFromResponse(ctx, response.Content); ok {
+		return tasks, nil
+	}
+	return n.handleParseFailure(ctx, messages, response.Content, userMessage)
+}
+
+// dropNullFields 删除严格模式下以 null 填充的不适用字段，保持与文本解析结果一致
+func dropNullFields(task map[string]any) {
+	for key, value := range task {
+		if value == nil {
+			delete(task, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package qng
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// plannedTransactionsKey 预演模式下记录计划交易的工作流数据键
+const plannedTransactionsKey = "planned_transactions"
+
+// StatusPlanned 预演模式的结果状态：只生成交易计划，不请求签名
+const StatusPlanned = "planned"
+
+// PlannedTransaction 预演模式下执行节点构建的一笔交易，按执行顺序排列
+type PlannedTransaction struct {
+	Step                 int    `json:"step"`
+	TaskID               string `json:"task_id"`
+	Action               string `json:"action"`
+	Description          string `json:"description,omitempty"`
+	To                   string `json:"to"`
+	Value                string `json:"value"`
+	Data                 string `json:"data"`
+	GasLimit             string `json:"gas_limit"`
+	GasPrice             string `json:"gas_price,omitempty"`
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
+	GasFee               string `json:"gas_fee,omitempty"`
+	Warning              string `json:"warning,omitempty"`
+}
+
+// isDryRun 检查工作流是否为预演模式（execute_workflow 的 dry_run 参数）
+func isDryRun(data map[string]any) bool {
+	dryRun, _ := data["dry_run"].(bool)
+	return dryRun
+}
+
+// requestSignature 返回等待用户签名的执行节点输出。预演模式下不请求签名：
+// 记录计划交易、按已签名处理当前步骤，然后直接进入后续任务
+func requestSignature(data map[string]any, authRequest map[string]any) *NodeOutput {
+	if !isDryRun(data) {
+		return &NodeOutput{
+			Data:         data,
+			NextNodes:    []string{"signature_validator"},
+			NeedUserAuth: true,
+			AuthRequest:  authRequest,
+			Completed:    false,
+		}
+	}
+
+	taskID, _ := data["current_task_id"].(string)
+	planned, _ := data[plannedTransactionsKey].([]PlannedTransaction)
+	tx := plannedTransaction(len(planned)+1, taskID, authRequest)
+	data[plannedTransactionsKey] = append(planned, tx)
+	log.Printf("📝 预演模式: 记录第 %d 笔交易 %s -> %s", tx.Step, tx.Action, tx.To)
+
+	completeTaskStep(data, taskID, "")
+	delete(data, "current_task_id")
+
+	return &NodeOutput{
+		Data:      data,
+		NextNodes: readyTaskNodes(data),
+		Completed: false,
+	}
+}
+
+// plannedTransaction 从签名请求中提取计划交易
+func plannedTransaction(step int, taskID string, authRequest map[string]any) PlannedTransaction {
+	field := func(key string) string {
+		value, _ := authRequest[key].(string)
+		return value
+	}
+	description := field("description")
+	if description == "" {
+		description = field("title")
+	}
+	return PlannedTransaction{
+		Step:                 step,
+		TaskID:               taskID,
+		Action:               field("action"),
+		Description:          description,
+		To:                   field("to_address"),
+		Value:                field("value"),
+		Data:                 field("data"),
+		GasLimit:             field("gas_limit"),
+		GasPrice:             field("gas_price"),
+		MaxFeePerGas:         field("max_fee_per_gas"),
+		MaxPriorityFeePerGas: field("max_priority_fee_per_gas"),
+		GasFee:               field("gas_fee"),
+		Warning:              field("warning"),
+	}
+}
+
+// dryRunResult 预演模式的最终结果：任务列表与按顺序排列的计划交易
+func dryRunResult(input NodeInput) map[string]any {
+	planned, _ := input.Data[plannedTransactionsKey].([]PlannedTransaction)
+	if planned == nil {
+		planned = []PlannedTransaction{}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📝 预演完成，共 %d 笔交易（未请求签名）:", len(planned))
+	for _, tx := range planned {
+		fmt.Fprintf(&b, "\n%d. %s -> %s", tx.Step, tx.Action, tx.To)
+		if tx.GasFee != "" {
+			fmt.Fprintf(&b, "（Gas费 %s）", tx.GasFee)
+		}
+	}
+
+	return map[string]any{
+		"status":       StatusPlanned,
+		"success":      true,
+		"dry_run":      true,
+		"workflow_id":  input.Context["workflow_id"],
+		"tasks":        input.Data["tasks"],
+		"transactions": planned,
+		"message":      b.String(),
+		"user_message": input.Data["user_message"],
+	}
+}
@@ -0,0 +1,29 @@
+package qng
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/rpc"
+)
+
+// estimateGasLimit 通过 eth_estimateGas 估算 gas 上限并加上安全余量（百分比），
+// 估算失败时保留构建器设置的默认值
+func estimateGasLimit(ctx context.Context, rpcClient *rpc.Client, tx *contracts.TransactionData, data map[string]any, bufferPercent int) {
+	if rpcClient == nil {
+		return
+	}
+
+	// 没有钱包地址时以只读账户估算，零地址调用会因余额与授权不足而回滚
+	from := readAddress(data)
+	estimate, err := rpcClient.EstimateGas(ctx, rpc.CallMsg{From: from, To: tx.To, Value: tx.Value, Data: tx.Data})
+	if err != nil {
+		log.Printf("⚠️  gas估算失败，使用默认gas上限 %s: %v", tx.GasLimit, err)
+		return
+	}
+
+	limit := estimate + estimate*uint64(max(bufferPercent, 0))/100
+	tx.GasLimit = fmt.Sprintf("0x%x", limit)
+	log.Printf("⛽ gas估算: %d，加 %d%% 余量后上限 %d", estimate, bufferPercent, limit)
+}
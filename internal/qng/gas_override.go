@@ -0,0 +1,174 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"qng_agent/internal/rpc"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidGasOverride 提交签名时附带的 gas 参数无法解析
+var ErrInvalidGasOverride = errors.New("invalid gas override")
+
+// GasOverride 用户提交签名时声明的 gas 参数，用于提高 gas 重新广播的交易。
+// 数值统一记录为十进制字符串（wei），未设置的字段为空
+type GasOverride struct {
+	GasPrice             string `json:"gas_price,omitempty"`
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
+	GasLimit             string `json:"gas_limit,omitempty"`
+}
+
+// fields 参数名到字段的映射，按参数名顺序解析
+func (g *GasOverride) fields() []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"gas_price", &g.GasPrice},
+		{"max_fee_per_gas", &g.MaxFeePerGas},
+		{"max_priority_fee_per_gas", &g.MaxPriorityFeePerGas},
+		{"gas_limit", &g.GasLimit},
+	}
+}
+
+// CopyGasParams 把 gas 中的 gas 参数（gas_price、max_fee_per_gas、max_priority_fee_per_gas、gas_limit）复制到请求参数，
+// 其他键被忽略，调用方传入的 gas 不能覆盖 session_id、signature 等字段
+func CopyGasParams(params, gas map[string]any) {
+	for _, field := range (&GasOverride{}).fields() {
+		if value, ok := gas[field.name]; ok {
+			params[field.name] = value
+		}
+	}
+}
+
+// ParseGasOverride 从请求参数中解析可选的 gas 参数，支持十进制、0x 十六进制字符串与数字。
+// 没有任何 gas 参数时返回 nil
+func ParseGasOverride(params map[string]any) (*GasOverride, error) {
+	override := &GasOverride{}
+	found := false
+	for _, field := range override.fields() {
+		raw, ok := params[field.name]
+		if !ok || raw == nil {
+			continue
+		}
+		value, err := parseGasValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidGasOverride, field.name, err)
+		}
+		*field.value = value.String()
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	if override.GasPrice != "" && (override.MaxFeePerGas != "" || override.MaxPriorityFeePerGas != "") {
+		return nil, fmt.Errorf("%w: gas_price cannot be combined with EIP-1559 fee fields", ErrInvalidGasOverride)
+	}
+	return override, nil
+}
+
+// parseGasValue 解析单个非负的 gas 数值
+func parseGasValue(raw any) (*big.Int, error) {
+	var text string
+	switch v := raw.(type) {
+	case string:
+		text = strings.TrimSpace(v)
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		text = strconv.FormatInt(int64(v), 10)
+	case int:
+		text = strconv.Itoa(v)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", raw)
+	}
+
+	base := 10
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		text, base = text[2:], 16
+	}
+	n, ok := new(big.Int).SetString(text, base)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not a non-negative integer", raw)
+	}
+	return n, nil
+}
+
+type gasOverrideKey struct{}
+
+// WithGasOverride 返回携带用户声明 gas 参数的上下文，签名验证节点据此记录并核对交易的 gas 参数
+func WithGasOverride(ctx context.Context, override *GasOverride) context.Context {
+	if override == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, gasOverrideKey{}, override)
+}
+
+// gasOverrideFrom 返回上下文中的 gas 参数，未设置时返回 nil
+func gasOverrideFrom(ctx context.Context) *GasOverride {
+	override, _ := ctx.Value(gasOverrideKey{}).(*GasOverride)
+	return override
+}
+
+// gasOverrideKeyFor 任务交易的 gas 参数在共享数据中的键
+func gasOverrideKeyFor(taskID string) string {
+	return taskID + "_gas_override"
+}
+
+// mismatches 比较链上交易的 gas 参数与声明值，返回不一致的字段说明
+func (g *GasOverride) mismatches(tx *rpc.Transaction) []string {
+	actual := map[string]string{
+		"gas_price":                tx.GasPrice,
+		"max_fee_per_gas":          tx.MaxFeePerGas,
+		"max_priority_fee_per_gas": tx.MaxPriorityFeePerGas,
+		"gas_limit":                tx.Gas,
+	}
+
+	var diffs []string
+	for _, field := range g.fields() {
+		if *field.value == "" {
+			continue
+		}
+		onChain, err := hexBig(actual[field.name])
+		if err != nil || onChain.String() != *field.value {
+			diffs = append(diffs, fmt.Sprintf("%s 声明 %s，链上 %s", field.name, *field.value, actual[field.name]))
+		}
+	}
+	return diffs
+}
+
+// trackGasOverride 记录任务交易声明的 gas 参数，并在可以查询交易时核对链上交易。
+// 不一致只记录警告：交易以链上确认结果为准
+func (n *SignatureValidatorNode) trackGasOverride(ctx context.Context, data map[string]any, taskID, txHash string) {
+	override := gasOverrideFrom(ctx)
+	if override == nil {
+		return
+	}
+	if taskID != "" {
+		data[gasOverrideKeyFor(taskID)] = override
+	}
+	log.Printf("⛽ 交易 %s 使用用户声明的 gas 参数: %+v", txHash, *override)
+
+	if n.rpcClient == nil || !txHashPattern.MatchString(txHash) {
+		return
+	}
+	tx, err := n.rpcClient.GetTransactionByHash(ctx, txHash)
+	if err != nil || tx == nil {
+		log.Printf("⚠️  无法核对交易 %s 的 gas 参数: %v", txHash, err)
+		return
+	}
+	for _, diff := range override.mismatches(tx) {
+		log.Printf("⚠️  交易 %s 的 gas 参数与声明不一致: %s", txHash, diff)
+	}
+}
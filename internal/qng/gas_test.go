@@ -0,0 +1,44 @@
+package qng
+
+import (
+	"context"
+	"testing"
+
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/rpc"
+)
+
+func TestEstimateGasLimitFrom(t *testing.T) {
+	node := rpc.NewMockNode()
+	defer node.Close()
+	var from string
+	node.On("eth_estimateGas", func(params []interface{}) (interface{}, *rpc.RPCError) {
+		msg, _ := params[0].(map[string]interface{})
+		from, _ = msg["from"].(string)
+		return "0x5208", nil // 21000
+	})
+	client := rpc.NewClient(node.URL())
+
+	tests := []struct {
+		name string
+		data map[string]any
+		want string
+	}{
+		{name: "user address", data: map[string]any{"user_address": testUserAddress, "read_account": testReadAccount}, want: testUserAddress},
+		{name: "read account", data: map[string]any{"read_account": testReadAccount}, want: testReadAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from = ""
+			tx := &contracts.TransactionData{To: testUserAddress, Value: "0x0", Data: "0x", GasLimit: "0x186A0"}
+			estimateGasLimit(context.Background(), client, tx, tt.data, 20)
+			if from != tt.want {
+				t.Errorf("estimate from = %q, want %q", from, tt.want)
+			}
+			if tx.GasLimit != "0x6270" { // 21000 + 20%
+				t.Errorf("gas limit = %s, want 0x6270", tx.GasLimit)
+			}
+		})
+	}
+}
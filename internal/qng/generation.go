@@ -0,0 +1,46 @@
+package qng
+
+import (
+	"log"
+	"qng_agent/internal/config"
+	"qng_agent/internal/llm"
+)
+
+// zeroTemperature 任务分解需要确定性的输出
+var zeroTemperature = 0.0
+
+// defaultNodeGeneration 未配置 langgraph.generation 时各节点调用LLM的生成参数
+var defaultNodeGeneration = map[string]config.NodeGenerationConfig{
+	"task_decomposer": {Temperature: &zeroTemperature},
+}
+
+// chatOptions 返回节点调用LLM时的生成参数，配置按节点整体覆盖默认值
+func (lg *LangGraph) chatOptions(name string) []llm.ChatOption {
+	generation, exists := lg.graphConfig.Generation[name]
+	if !exists {
+		generation, exists = defaultNodeGeneration[name]
+	}
+	if !exists {
+		return nil
+	}
+
+	var opts []llm.ChatOption
+	if generation.Temperature != nil {
+		opts = append(opts, llm.WithTemperature(*generation.Temperature))
+	}
+	if generation.MaxTokens > 0 {
+		opts = append(opts, llm.WithMaxTokens(generation.MaxTokens))
+	}
+	if len(opts) > 0 {
+		log.Printf("🎛️  节点 %s 的生成参数: temperature=%v, max_tokens=%d", name, formatTemperature(generation.Temperature), generation.MaxTokens)
+	}
+	return opts
+}
+
+// formatTemperature 未设置的温度显示为 default
+func formatTemperature(temperature *float64) any {
+	if temperature == nil {
+		return "default"
+	}
+	return *temperature
+}
@@ -0,0 +1,66 @@
+package qng
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Qitmeer/qng/graph"
+)
+
+// GraphExport 工作流图结构，用于文档与调试
+type GraphExport struct {
+	EntryPoint string      `json:"entry_point"`
+	Nodes      []NodeInfo  `json:"nodes"`
+	Edges      []GraphEdge `json:"edges"`
+}
+
+// GraphEdge 一条可能的跳转。图中的边都是条件边，运行时由节点输出决定实际走向
+type GraphEdge struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Conditional bool   `json:"conditional"`
+}
+
+// Export 导出已注册的节点与它们之间的条件边，指向未注册节点的边不会导出
+func (lg *LangGraph) Export() GraphExport {
+	export := GraphExport{EntryPoint: lg.entryPoint}
+	for _, info := range lg.nodeInfos {
+		if info.Enabled {
+			export.Nodes = append(export.Nodes, info)
+		}
+	}
+
+	for _, info := range export.Nodes {
+		for _, to := range lg.edges[info.Name] {
+			if _, exists := lg.nodes[to]; !exists && to != graph.END {
+				continue
+			}
+			export.Edges = append(export.Edges, GraphEdge{From: info.Name, To: to, Conditional: true})
+		}
+	}
+	return export
+}
+
+// DOT 将图结构输出为 Graphviz DOT 格式，条件边以虚线表示
+func (e GraphExport) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  START [shape=circle];\n")
+	fmt.Fprintf(&b, "  %s [shape=doublecircle];\n", graph.END)
+	for _, node := range e.Nodes {
+		fmt.Fprintf(&b, "  %q [shape=box, label=\"%s\\n(%s)\"];\n", node.Name, node.Name, node.Type)
+	}
+	if e.EntryPoint != "" {
+		fmt.Fprintf(&b, "  START -> %q;\n", e.EntryPoint)
+	}
+	for _, edge := range e.Edges {
+		style := ""
+		if edge.Conditional {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "  %q -> %q%s;\n", edge.From, edge.To, style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Qitmeer/qng/graph"
+)
+
+// ErrInvalidGraph 工作流图结构无效
+var ErrInvalidGraph = errors.New("invalid workflow graph")
+
+// validateGraph 校验图结构：入口节点已注册、配置的节点均存在、
+// 每条边的起点和所有可能的后继节点均已注册、每个节点都有出边。
+// 返回的错误列出全部问题。
+func (lg *LangGraph) validateGraph() error {
+	var problems []string
+
+	if _, exists := lg.nodes[lg.entryPoint]; !exists {
+		problems = append(problems, fmt.Sprintf("entry point %q is not a registered node", lg.entryPoint))
+	}
+
+	for _, name := range lg.graphConfig.Nodes {
+		if _, exists := lg.edges[name]; !exists {
+			problems = append(problems, fmt.Sprintf("configured node %q is unknown", name))
+		}
+	}
+
+	for _, from := range sortedKeys(lg.edges) {
+		_, registered := lg.nodes[from]
+		for _, to := range lg.edges[from] {
+			if to == graph.END {
+				continue
+			}
+			if _, exists := lg.nodes[to]; !exists && registered {
+				problems = append(problems, fmt.Sprintf("node %q may route to unregistered node %q", from, to))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(lg.nodes) {
+		if _, exists := lg.edges[name]; !exists {
+			problems = append(problems, fmt.Sprintf("node %q has no outgoing edge", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidGraph, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// sortedKeys 返回按名称排序的键，保证错误信息稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
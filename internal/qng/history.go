@@ -0,0 +1,70 @@
+package qng
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxHistoryTurns 传给任务分解节点的对话条数上限，防止调用方传入过长历史
+const maxHistoryTurns = 20
+
+// maxHistoryTurnBytes 单条历史消息写入提示的最大字节数
+const maxHistoryTurnBytes = 500
+
+// ConversationTurn 一条历史对话，用于任务分解时解析“它”、“这些MTK”等指代
+type ConversationTurn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ParseConversationHistory 解析 execute_workflow 的 history 参数，
+// 兼容进程内调用传入的切片与 HTTP 调用解析得到的 []any，只保留最近的 maxHistoryTurns 条
+func ParseConversationHistory(raw any) []ConversationTurn {
+	var turns []ConversationTurn
+	switch history := raw.(type) {
+	case []ConversationTurn:
+		turns = append(turns, history...)
+	case []map[string]any:
+		for _, entry := range history {
+			turns = appendTurn(turns, entry)
+		}
+	case []any:
+		for _, entry := range history {
+			if m, ok := entry.(map[string]any); ok {
+				turns = appendTurn(turns, m)
+			}
+		}
+	}
+
+	if len(turns) > maxHistoryTurns {
+		turns = turns[len(turns)-maxHistoryTurns:]
+	}
+	return turns
+}
+
+func appendTurn(turns []ConversationTurn, entry map[string]any) []ConversationTurn {
+	role, _ := entry["role"].(string)
+	content, _ := entry["content"].(string)
+	if role == "" || strings.TrimSpace(content) == "" {
+		return turns
+	}
+	return append(turns, ConversationTurn{Role: role, Content: content})
+}
+
+// formatHistory 将历史对话格式化为提示中的一段文本，没有历史时返回空字符串
+func formatHistory(turns []ConversationTurn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("最近的对话（仅用于理解用户请求中的指代，不要为其中已完成的操作生成任务）：\n")
+	for _, turn := range turns {
+		speaker := "用户"
+		if turn.Role == "assistant" {
+			speaker = "助手"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", speaker, truncateUTF8(strings.TrimSpace(turn.Content), maxHistoryTurnBytes))
+	}
+	return b.String()
+}
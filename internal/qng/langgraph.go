@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"github.com/Qitmeer/qng/graph"
 	"log"
+	"log/slog"
 	"qng_agent/internal/config"
 	"qng_agent/internal/contracts"
 	"qng_agent/internal/llm"
+	"qng_agent/internal/logging"
 	"qng_agent/internal/rpc"
 	"time"
 )
@@ -19,9 +21,27 @@ type LangGraph struct {
 	contractManager *contracts.ContractManager
 	rpcClient       *rpc.Client
 	txConfig        config.TransactionConfig
+	graphConfig     config.LangGraphConfig
+	spendingGuard   *SpendingGuard
+
+	// nodeFuncs 各节点的图节点函数，edgeFunc 所有节点共用的条件边
+	nodeFuncs map[string]graph.NodeFunction
+	edgeFunc  graph.EdgeFunction
+	// runnables 以每个节点为入口编译的图。签名后从中间节点恢复执行时使用对应入口的图，
+	// 不修改共享图的入口，并发的工作流互不影响
+	runnables map[string]*graph.Runnable
+	// edges 各节点可能跳转到的后继节点，用于启动时校验图结构
+	edges      map[string][]string
+	entryPoint string
+	// nodeInfos 所有内置节点的描述，按注册顺序排列
+	nodeInfos []NodeInfo
+}
 
-	g *graph.Graph
-	r *graph.Runnable
+// NodeInfo 节点描述，Enabled 为 false 表示节点未在 langgraph.nodes 中启用
+type NodeInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
 }
 
 // Node 节点接口
@@ -47,67 +67,109 @@ type NodeOutput struct {
 }
 
 // NewLangGraph 创建LangGraph实例
-func NewLangGraph(llmClient llm.Client, contractManager *contracts.ContractManager, rpcClient *rpc.Client, txConfig config.TransactionConfig) *LangGraph {
+func NewLangGraph(llmClient llm.Client, contractManager *contracts.ContractManager, rpcClient *rpc.Client, txConfig config.TransactionConfig, graphConfig config.LangGraphConfig, spendingGuard *SpendingGuard) (*LangGraph, error) {
 	lg := &LangGraph{
 		nodes:           make(map[string]Node),
 		llm:             llmClient,
 		contractManager: contractManager,
 		rpcClient:       rpcClient,
 		txConfig:        txConfig,
+		graphConfig:     graphConfig,
+		spendingGuard:   spendingGuard,
+		nodeFuncs:       make(map[string]graph.NodeFunction),
 	}
-	lg.g = graph.NewGraph()
 	// 注册节点
 	lg.registerNodes()
 
 	// 构建图结构
-	lg.buildGraph()
+	if err := lg.buildGraph(); err != nil {
+		return nil, err
+	}
 
-	return lg
+	return lg, nil
 }
 
 // registerNodes 注册所有节点
 func (lg *LangGraph) registerNodes() {
+	for name := range lg.graphConfig.Retries {
+		if nonRetryableNodes[name] {
+			log.Printf("⚠️  节点 %s 不可重试，忽略其重试配置", name)
+		}
+	}
+
+	trusted := NewTrustedAddresses(lg.txConfig.TrustedAddresses, lg.contractManager)
 	nodes := []Node{
-		NewTaskDecomposerNode(lg.llm),                        // 任务分解节点
-		NewSwapExecutorNode(lg.contractManager),              // 交易执行节点
-		NewStakeExecutorNode(lg.contractManager),             // 质押执行节点
-		NewSignatureValidatorNode(lg.rpcClient, lg.txConfig), // 签名验证节点
-		NewResultAggregatorNode(),                            // 结果聚合节点
+		NewTaskDecomposerNode(lg.llm, lg.contractManager, lg.graphConfig.ParseFailure, lg.chatOptions("task_decomposer")...), // 任务分解节点
+		NewSwapExecutorNode(lg.contractManager, lg.rpcClient, lg.spendingGuard, trusted, lg.txConfig.GasBufferPercent),       // 交易执行节点
+		NewStakeExecutorNode(lg.contractManager, lg.rpcClient, lg.spendingGuard, trusted, lg.txConfig.GasBufferPercent),      // 质押执行节点
+		NewTransferExecutorNode(lg.contractManager, lg.rpcClient, lg.spendingGuard, trusted, lg.txConfig.GasBufferPercent),   // 转账执行节点
+		NewSignatureValidatorNode(lg.rpcClient, lg.contractManager, lg.txConfig),                                             // 签名验证节点
+		NewResultAggregatorNode(lg.contractManager),                                                                          // 结果聚合节点
+		NewParallelExecutorNode(lg.nodes), // 并行执行节点
+	}
+
+	// 配置了节点集合时只注册列出的节点
+	if len(lg.graphConfig.Nodes) > 0 {
+		enabled := make(map[string]bool, len(lg.graphConfig.Nodes))
+		for _, name := range lg.graphConfig.Nodes {
+			enabled[name] = true
+		}
+		selected := make([]Node, 0, len(nodes))
+		for _, node := range nodes {
+			lg.nodeInfos = append(lg.nodeInfos, NodeInfo{Name: node.GetName(), Type: node.GetType(), Enabled: enabled[node.GetName()]})
+			if enabled[node.GetName()] {
+				selected = append(selected, node)
+			}
+		}
+		nodes = selected
+	} else {
+		for _, node := range nodes {
+			lg.nodeInfos = append(lg.nodeInfos, NodeInfo{Name: node.GetName(), Type: node.GetType(), Enabled: true})
+		}
 	}
 
 	for _, node := range nodes {
-		lg.g.AddNode(node.GetName(), func(ctx context.Context, name string, state graph.State) (graph.State, error) {
-			log.Printf("🔄 执行节点: %s (类型: %s)", node.GetName(), node.GetType())
+		lg.nodes[node.GetName()] = node
+		lg.nodeFuncs[node.GetName()] = func(ctx context.Context, name string, state graph.State) (graph.State, error) {
+			// 节点内的日志标注所属节点，与工作流的关联ID一起定位
+			ctx = logging.WithNode(ctx, node.GetName())
+			slog.InfoContext(ctx, "🔄 执行节点", "type", node.GetType())
 			input := state["input"].(*NodeInput)
-			// 执行节点
-			output, err := node.Execute(ctx, *input)
+			// 执行节点，超过节点超时时间时失败，临时性失败按节点重试策略重试
+			output, err := lg.executeWithRetry(ctx, node, *input)
 			if err != nil {
-				log.Printf("❌ 节点执行失败: %v", err)
-				return nil, fmt.Errorf("node %s execution failed: %w", node.GetName(), err)
+				slog.ErrorContext(ctx, "❌ 节点执行失败", "error", err)
+				// 附加补偿报告，说明失败前哪些任务已经上链
+				return nil, lg.compensate(node.GetName(), input.Data, fmt.Errorf("node %s execution failed: %w", node.GetName(), err))
 			}
-			log.Printf("✅ 节点执行成功")
-			log.Printf("📊 输出数据: %+v", output.Data)
+			slog.InfoContext(ctx, "✅ 节点执行成功")
+			slog.DebugContext(ctx, "📊 输出数据", "data", output.Data)
 
 			state["output"] = output
 			state[node.GetName()] = node
 
 			return state, nil
-		})
+		}
 	}
 }
 
-// buildGraph 构建图结构
-func (lg *LangGraph) buildGraph() {
-	edgeFunc := func(ctx context.Context, name string, state graph.State) string {
+// Nodes 返回所有内置节点及其启用状态
+func (lg *LangGraph) Nodes() []NodeInfo {
+	return append([]NodeInfo(nil), lg.nodeInfos...)
+}
+
+// buildGraph 构建图结构，编译失败时返回错误
+func (lg *LangGraph) buildGraph() error {
+	lg.edgeFunc = func(ctx context.Context, name string, state graph.State) string {
 		input := state["input"].(*NodeInput)
 		output := state["output"].(*NodeOutput)
 
 		if name == "task_decomposer" {
 			n := state["task_decomposer"].(*TaskDecomposerNode)
-			output.NextNodes = n.determineNextNodes(output.Data["tasks"].([]map[string]any))
+			output.NextNodes = n.determineNextNodes(ctx, output.Data["tasks"].([]map[string]any))
 		} else if name == "signature_validator" {
 			n := state["signature_validator"].(*SignatureValidatorNode)
-			output.NextNodes = n.checkDependentTasks(input.Data, output.Data["transaction_hash"].(string))
+			output.NextNodes = n.checkDependentTasks(ctx, input.Data, output.Data["transaction_hash"].(string))
 		}
 
 		log.Printf("➡️  下一个节点: %v", output.NextNodes)
@@ -122,10 +184,11 @@ func (lg *LangGraph) buildGraph() {
 			state["result"] = &ProcessResult{
 				NeedSignature:    true,
 				SignatureRequest: output.AuthRequest,
-				WorkflowContext: map[string]any{
-					"current_node": name,
-					"node_output":  output,
-					"input":        *input,
+				WorkflowContext: &WorkflowContext{
+					CurrentNode: name,
+					NextNodes:   output.NextNodes,
+					Data:        output.Data,
+					Context:     input.Context,
 				},
 			}
 			return graph.END
@@ -140,9 +203,14 @@ func (lg *LangGraph) buildGraph() {
 			return graph.END
 		}
 
-		// 继续执行下一个节点
+		// 继续执行下一个节点，多个互不依赖的任务可执行时由并行执行节点合并为一次批量签名；
+		// 依赖未完成的任务不会出现在后继节点中，因此依赖顺序不受影响
 		if len(output.NextNodes) > 0 {
-			nextNode := output.NextNodes[0] // 简化处理，取第一个
+			nextNode := output.NextNodes[0]
+			// 预演模式不请求签名，无需合并批量签名
+			if _, parallel := lg.nodes["parallel_executor"]; parallel && len(output.NextNodes) > 1 && !isDryRun(output.Data) {
+				nextNode = "parallel_executor"
+			}
 			log.Printf("➡️  继续执行下一个节点: %s", nextNode)
 
 			nextInput := &NodeInput{
@@ -161,19 +229,72 @@ func (lg *LangGraph) buildGraph() {
 		return graph.END
 	}
 
-	lg.g.AddConditionalEdge("task_decomposer", edgeFunc)
-	lg.g.AddConditionalEdge("swap_executor", edgeFunc)
-	lg.g.AddConditionalEdge("stake_executor", edgeFunc)
-	lg.g.AddConditionalEdge("signature_validator", edgeFunc)
-	lg.g.AddEdge("result_aggregator", graph.END)
+	lg.edges = make(map[string][]string)
+	addEdge := func(from string, targets ...string) {
+		lg.edges[from] = targets
+	}
+	taskTargets := []string{"swap_executor", "stake_executor", "transfer_executor", "result_aggregator"}
+	// 并行执行节点未启用时多个可执行任务依次执行
+	if _, parallel := lg.nodes["parallel_executor"]; parallel {
+		taskTargets = append(taskTargets, "parallel_executor")
+	}
+	addEdge("task_decomposer", taskTargets...)
+	// 预演模式下执行节点不请求签名，直接进入后续任务
+	executorTargets := append([]string{"signature_validator"}, taskTargets...)
+	addEdge("swap_executor", executorTargets...)
+	addEdge("stake_executor", executorTargets...)
+	addEdge("transfer_executor", executorTargets...)
+	addEdge("parallel_executor", "signature_validator")
+	addEdge("signature_validator", taskTargets...)
+	addEdge("result_aggregator", graph.END)
+
+	lg.entryPoint = "task_decomposer"
+
+	if err := lg.validateGraph(); err != nil {
+		log.Printf("❌ 工作流图校验失败: %v", err)
+		return err
+	}
 
-	lg.g.SetEntryPoint("task_decomposer")
+	lg.runnables = make(map[string]*graph.Runnable, len(lg.nodes))
+	for _, entry := range sortedKeys(lg.nodes) {
+		r, err := lg.compile(entry)
+		if err != nil {
+			log.Printf("❌ 工作流图编译失败: %v", err)
+			return fmt.Errorf("failed to compile workflow graph from %s: %w", entry, err)
+		}
+		lg.runnables[entry] = r
+	}
+	return nil
+}
 
-	r, err := lg.g.Compile()
+// compile 以指定节点为入口编译工作流图
+func (lg *LangGraph) compile(entry string) (*graph.Runnable, error) {
+	g := graph.NewGraph()
+	for name, fn := range lg.nodeFuncs {
+		g.AddNode(name, fn)
+	}
+	for from := range lg.edges {
+		g.AddConditionalEdge(from, lg.edgeFunc)
+	}
+	g.SetEntryPoint(entry)
+	return g.Compile()
+}
+
+// invoke 从指定节点开始执行工作流，返回边函数写入的处理结果
+func (lg *LangGraph) invoke(ctx context.Context, entry string, input *NodeInput) (*ProcessResult, error) {
+	r, exists := lg.runnables[entry]
+	if !exists {
+		return nil, fmt.Errorf("unknown workflow node: %s", entry)
+	}
+	state, err := r.Invoke(ctx, map[string]interface{}{"input": input})
 	if err != nil {
-		log.Printf("❌ compile error: %v", err)
+		return nil, err
 	}
-	lg.r = r
+	result, ok := state["result"].(*ProcessResult)
+	if !ok {
+		return nil, fmt.Errorf("workflow from %s ended without a result", entry)
+	}
+	return result, nil
 }
 
 // ExecuteWorkflow 执行工作流
@@ -186,6 +307,17 @@ func (lg *LangGraph) ExecuteWorkflow(ctx context.Context, message string) (*Proc
 		Data: map[string]any{
 			"user_message": message,
 			"timestamp":    time.Now(),
+			"user_id":      ctx.Value("user_id"),
+			// 用户钱包地址，用于检查链上余额
+			"user_address": ctx.Value("user_address"),
+			// 未提供钱包地址时只读查询使用的账户
+			"read_account": ctx.Value("read_account"),
+			// 可疑请求需要逐笔手动确认，禁止服务端自动签名
+			"manual_confirmation": ctx.Value("manual_confirmation") == true,
+			// 预演模式：只生成交易计划，不请求签名
+			"dry_run": ctx.Value("dry_run") == true,
+			// 最近的对话，供任务分解节点解析指代
+			"conversation_history": ParseConversationHistory(ctx.Value("conversation_history")),
 		},
 		Context: map[string]any{
 			"workflow_id": ctx.Value("workflow_id"),
@@ -193,11 +325,7 @@ func (lg *LangGraph) ExecuteWorkflow(ctx context.Context, message string) (*Proc
 		},
 	}
 
-	state, err := lg.r.Invoke(ctx, map[string]interface{}{"input": input})
-	if err != nil {
-		return nil, err
-	}
-	return state["result"].(*ProcessResult), nil
+	return lg.invoke(ctx, lg.entryPoint, input)
 }
 
 // ContinueWithSignature 使用签名继续工作流
@@ -205,60 +333,40 @@ func (lg *LangGraph) ContinueWithSignature(ctx context.Context, workflowContext
 	log.Printf("🔄 使用签名继续工作流")
 	log.Printf("🔐 签名长度: %d", len(signature))
 
-	// 从工作流上下文恢复执行状态
-	contextMap, ok := workflowContext.(map[string]any)
-	if !ok {
-		log.Printf("❌ 无效的工作流上下文类型")
-		return nil, fmt.Errorf("invalid workflow context")
-	}
-
-	currentNode, ok := contextMap["current_node"].(string)
-	if !ok {
-		log.Printf("❌ 上下文中缺少当前节点信息")
-		return nil, fmt.Errorf("invalid current node in context")
-	}
-
-	log.Printf("🔄 从节点恢复: %s", currentNode)
-
-	nodeOutput, ok := contextMap["node_output"].(*NodeOutput)
-	if !ok {
-		log.Printf("❌ 上下文中缺少节点输出信息")
-		return nil, fmt.Errorf("invalid node output in context")
+	// 从可序列化的工作流上下文重建执行状态
+	wc, err := RestoreWorkflowContext(workflowContext)
+	if err != nil {
+		log.Printf("❌ 无效的工作流上下文: %v", err)
+		return nil, fmt.Errorf("invalid workflow context: %w", err)
 	}
 
-	nodeInput, ok := contextMap["input"].(NodeInput)
-	if !ok {
-		log.Printf("❌ 上下文中缺少节点输入信息")
-		return nil, fmt.Errorf("invalid node input in context")
-	}
+	log.Printf("🔄 从节点恢复: %s", wc.CurrentNode)
 
 	// 将签名添加到数据中
 	log.Printf("🔐 将签名添加到节点数据中")
-	nodeOutput.Data["signature"] = signature
-	nodeOutput.NeedUserAuth = false
+	wc.Data["signature"] = signature
 
 	// 继续执行下一个节点
-	if len(nodeOutput.NextNodes) > 0 {
-		nextNode := nodeOutput.NextNodes[0]
+	if len(wc.NextNodes) > 0 {
+		nextNode := wc.NextNodes[0]
 		log.Printf("➡️  继续执行下一个节点: %s", nextNode)
 
 		nextInput := &NodeInput{
-			Data:    nodeOutput.Data,
-			Context: nodeInput.Context,
+			Data:    wc.Data,
+			Context: wc.Context,
 		}
-		lg.g.SetEntryPoint(nextNode)
-		state, err := lg.r.Invoke(ctx, map[string]interface{}{"input": nextInput})
+		result, err := lg.invoke(ctx, nextNode, nextInput)
 		if err != nil {
 			log.Printf("❌ 继续执行失败: %v", err)
 			return nil, err
 		}
 		log.Printf("✅ 继续执行成功")
 		// 返回完整的 ProcessResult，而不是只返回 FinalResult
-		return state["result"].(*ProcessResult), nil
+		return result, nil
 	}
 
 	log.Printf("✅ 没有下一个节点，返回当前数据")
 	return &ProcessResult{
-		FinalResult: nodeOutput.Data,
+		FinalResult: wc.Data,
 	}, nil
 }
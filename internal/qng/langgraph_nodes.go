@@ -3,25 +3,94 @@ package qng
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"qng_agent/internal/config"
 	"qng_agent/internal/contracts"
 	"qng_agent/internal/llm"
 	"qng_agent/internal/rpc"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// fallbackSwapAmountPatterns 备用文本解析中提取兑换数量的模式
+var fallbackSwapAmountPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`兑换\s*(\d+(?:\.\d+)?)\s*meer.*mtk`),      // "兑换10MEER的MTK"
+	regexp.MustCompile(`兑换\s*(\d+(?:\.\d+)?).*meer.*mtk`),       // "兑换10 MEER为MTK"
+	regexp.MustCompile(`兑换\s*(\d+(?:\.\d+)?)\s*(?:meer|mtk)`),   // "兑换5 MTK"
+	regexp.MustCompile(`swap\s+(\d+(?:\.\d+)?)\s*(?:meer|mtk)`), // "swap 10 meer"
+}
+
+// fallbackStakeAmountPatterns 备用文本解析中提取独立质押数量的模式
+var fallbackStakeAmountPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`质押\s*(\d+(?:\.\d+)?)\s*mtk`),    // "质押100MTK"
+	regexp.MustCompile(`(\d+(?:\.\d+)?)\s*mtk.*质押`),     // "把100 MTK质押"
+	regexp.MustCompile(`stake\s+(\d+(?:\.\d+)?)\s*mtk`), // "stake 100 mtk"
+}
+
+// extractAmount 按顺序尝试模式提取数量，均未匹配时返回默认值
+func extractAmount(ctx context.Context, lowerMessage string, patterns []*regexp.Regexp, defaultAmount string) string {
+	for _, re := range patterns {
+		if matches := re.FindStringSubmatch(lowerMessage); len(matches) > 1 {
+			slog.DebugContext(ctx, "📋 提取数量", "amount", matches[1])
+			return matches[1]
+		}
+	}
+	return defaultAmount
+}
+
+// normalizeTaskAmounts 规范化分解出的任务数量，作为后续执行和展示的唯一数量来源
+func normalizeTaskAmounts(tasks []map[string]any) error {
+	for _, task := range tasks {
+		raw, exists := task["amount"]
+		if !exists {
+			continue
+		}
+		amount, ok := raw.(string)
+		if !ok {
+			// LLM 可能返回数字类型
+			amount = fmt.Sprint(raw)
+		}
+		normalized, err := contracts.NormalizeAmount(amount)
+		if err != nil {
+			return fmt.Errorf("task %v: %w", task["id"], err)
+		}
+		task["amount"] = normalized
+	}
+	return nil
+}
+
+// truncateUTF8 按字节截断字符串，不截断多字节字符
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
 // TaskDecomposerNode 任务分解节点
 type TaskDecomposerNode struct {
-	llmClient llm.Client
+	llmClient       llm.Client
+	contractManager *contracts.ContractManager
+	// parseFailure LLM回复无法解析为任务时的处理策略
+	parseFailure string
+	// chatOptions 调用LLM时的生成参数
+	chatOptions []llm.ChatOption
 }
 
-func NewTaskDecomposerNode(llmClient llm.Client) *TaskDecomposerNode {
+func NewTaskDecomposerNode(llmClient llm.Client, contractManager *contracts.ContractManager, parseFailure string, chatOptions ...llm.ChatOption) *TaskDecomposerNode {
 	return &TaskDecomposerNode{
-		llmClient: llmClient,
+		llmClient:       llmClient,
+		contractManager: contractManager,
+		parseFailure:    parseFailureStrategy(parseFailure),
+		chatOptions:     chatOptions,
 	}
 }
 
@@ -34,28 +103,36 @@ func (n *TaskDecomposerNode) GetType() string {
 }
 
 func (n *TaskDecomposerNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
-	log.Printf("🔄 任务分解节点开始执行")
+	slog.InfoContext(ctx, "🔄 任务分解节点开始执行")
 
 	userMessage, ok := input.Data["user_message"].(string)
 	if !ok {
-		log.Printf("❌ 输入中缺少user_message")
+		slog.ErrorContext(ctx, "❌ 输入中缺少user_message")
 		return nil, fmt.Errorf("user_message not found in input")
 	}
 
-	log.Printf("📝 用户消息: %s", userMessage)
+	slog.InfoContext(ctx, "📝 用户消息", "message", userMessage)
+
+	// 最近的对话用于解析指代，没有历史时该段为空
+	history, _ := input.Data["conversation_history"].([]ConversationTurn)
+	if len(history) > 0 {
+		slog.DebugContext(ctx, "💬 附带最近的对话", "turns", len(history))
+	}
 
 	// 构建LLM提示
+	tokensSection := swapPairsPrompt(n.contractManager)
+	poolsSection, defaultPool := stakingPoolsPrompt(n.contractManager)
 	prompt := fmt.Sprintf(`
 你是一个区块链DeFi操作分析助手。请仔细分析用户的中文请求，并分解为具体的执行步骤。
 
 支持的操作类型：
-1. swap: 代币兑换（支持 MEER ↔ MTK）
+1. swap: 代币兑换（支持下方列出的兑换对）
 2. stake: 代币质押（支持 MTK 质押获得奖励）
+3. transfer: 代币转账（支持下方列出的代币，需要 token、amount 与接收地址 to_address）
 
-支持的代币：
-- MEER: 原生代币
-- MTK: ERC20代币 
-
+%s
+%s
+%s
 用户请求: %s
 
 请根据用户的实际请求内容，准确识别代币名称和数量，按以下格式返回分解结果：
@@ -76,7 +153,7 @@ func (n *TaskDecomposerNode) Execute(ctx context.Context, input NodeInput) (*Nod
       "type": "stake",
       "token": "MTK",
       "amount": "all_from_previous",
-      "pool": "compound",
+      "pool": "%s",
       "dependency_tx_id": "task_1",
       "description": "将兑换得到的MTK进行质押"
     }
@@ -84,67 +161,102 @@ func (n *TaskDecomposerNode) Execute(ctx context.Context, input NodeInput) (*Nod
 }
 
 重要规则：
-1. 仔细阅读用户请求，准确提取代币名称（MEER/MTK）和数量
-2. 支持的兑换对只有 MEER ↔ MTK，不要使用其他代币如USDT、BTC等
+1. 仔细阅读用户请求，准确提取代币名称和数量
+2. 只使用上面列出的代币，不要使用未列出的代币如USDT、BTC等；没有直接兑换对的两种代币会自动经过中间代币兑换，swap 任务直接填写起止代币
 3. 如果用户说"兑换X MEER的MTK"，意思是用X个MEER兑换MTK
 4. 如果用户说"质押MTK"，使用stake类型
 5. 如果是连续操作（先兑换后质押），第二个任务要设置dependency_tx_id
 6. 每个任务必须有唯一的id（task_1, task_2...）
 7. amount可以设置为"all_from_previous"表示使用前一个任务的全部输出
 8. 独立任务的dependency_tx_id设置为null
+9. 如果用户请求中用"它"、"这些MTK"等指代代币或数量，结合最近的对话确定具体的代币和数量
+10. 转账任务格式为 {"id": "task_1", "type": "transfer", "token": "MTK", "amount": "5", "to_address": "0x...", "dependency_tx_id": null}，用户没有给出完整的接收地址时不要生成转账任务
+11. 用户指定了质押池（如"质押 100 MTK 到 30天锁定池"）时，在 stake 任务的 pool 中填写该池
 
 只返回JSON格式，不要其他文字。
-`, userMessage)
+`, tokensSection, poolsSection, formatHistory(history), userMessage, defaultPool)
 
-	log.Printf("📋 构建LLM提示完成")
-	log.Printf("📝 提示长度: %d", len(prompt))
+	slog.DebugContext(ctx, "📋 构建LLM提示完成", "prompt_length", len(prompt))
 
 	// 调用LLM进行任务分解
 	if n.llmClient != nil {
-		log.Printf("🤖 调用LLM进行任务分解...")
-		response, err := n.llmClient.Chat(ctx, []llm.Message{
-			{Role: "user", Content: prompt},
-		})
+		slog.InfoContext(ctx, "🤖 调用LLM进行任务分解")
+		tasks, err := n.decomposeWithLLM(ctx, prompt, userMessage)
+		if errors.Is(err, errNeedsClarification) {
+			return &NodeOutput{
+				Data: withData(input.Data, map[string]any{
+					"tasks":         []map[string]any{},
+					"clarification": clarificationMessage,
+					"decomposed_at": input.Data["timestamp"],
+				}),
+				Completed: false,
+			}, nil
+		}
 		if err != nil {
-			log.Printf("❌ LLM调用失败: %v", err)
-			return nil, fmt.Errorf("LLM call failed: %w", err)
+			slog.ErrorContext(ctx, "❌ LLM调用失败", "error", err)
+			return nil, transient(fmt.Errorf("LLM call failed: %w", err))
 		}
+		slog.InfoContext(ctx, "📋 解析出任务", "count", len(tasks))
 
-		log.Printf("✅ LLM响应成功")
-		log.Printf("📄 LLM响应: %s", response)
-
-		// 解析LLM响应
-		log.Printf("🔄 解析LLM响应...")
-		tasks := n.parseTasksFromResponse(response, userMessage)
-		log.Printf("📋 解析出 %d 个任务", len(tasks))
+		if err := normalizeTaskAmounts(tasks); err != nil {
+			slog.WarnContext(ctx, "❌ 任务数量无效", "error", err)
+			return nil, err
+		}
+		if err := normalizeTaskPools(n.contractManager, tasks); err != nil {
+			slog.WarnContext(ctx, "❌ 质押池无效", "error", err)
+			return nil, err
+		}
 
 		return &NodeOutput{
-			Data: map[string]any{
+			Data: withData(input.Data, map[string]any{
 				"tasks":         tasks,
-				"user_message":  userMessage,
 				"decomposed_at": input.Data["timestamp"],
-			},
+				"dry_run":       isDryRun(input.Data),
+			}),
 			Completed: false,
 		}, nil
 	}
 
 	// 如果没有LLM客户端，使用简单的规则分解
-	log.Printf("⚠️  没有LLM客户端，使用简单规则分解")
-	tasks := n.simpleTaskDecomposition(userMessage)
-	log.Printf("📋 简单分解出 %d 个任务", len(tasks))
+	slog.WarnContext(ctx, "⚠️  没有LLM客户端，使用简单规则分解")
+	tasks := n.simpleTaskDecomposition(ctx, userMessage)
+	slog.InfoContext(ctx, "📋 简单分解出任务", "count", len(tasks))
+
+	if err := normalizeTaskAmounts(tasks); err != nil {
+		slog.WarnContext(ctx, "❌ 任务数量无效", "error", err)
+		return nil, err
+	}
+	if err := normalizeTaskPools(n.contractManager, tasks); err != nil {
+		slog.WarnContext(ctx, "❌ 质押池无效", "error", err)
+		return nil, err
+	}
 
 	return &NodeOutput{
-		Data: map[string]any{
-			"tasks":        tasks,
-			"user_message": userMessage,
-		},
+		Data: withData(input.Data, map[string]any{
+			"tasks":   tasks,
+			"dry_run": isDryRun(input.Data),
+		}),
 		Completed: false,
 	}, nil
 }
 
-func (n *TaskDecomposerNode) parseTasksFromResponse(response string, originalUserMessage string) []map[string]any {
-	log.Printf("🔄 解析LLM响应中的任务")
-	log.Printf("📄 响应内容: %s", response)
+// withData 复制输入数据并覆盖新的字段。工作流初始数据（用户地址、只读账户、手动确认标记等）
+// 必须随任务一起传给后续节点，不能由节点重新构建的数据替换
+func withData(data map[string]any, fields map[string]any) map[string]any {
+	merged := make(map[string]any, len(data)+len(fields))
+	for key, value := range data {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return merged
+}
+
+// parseTasksFromResponse 从LLM文本回复中提取并解析任务JSON，没有可用的JSON或验证失败时返回 false
+func (n *TaskDecomposerNode) parseTasksFromResponse(ctx context.Context, response string) ([]map[string]any, bool) {
+	slog.DebugContext(ctx, "🔄 解析LLM响应中的任务")
+	slog.DebugContext(ctx, "📄 响应内容", "response", response)
 
 	// 尝试从JSON响应中解析任务
 	// 首先尝试提取JSON部分
@@ -153,59 +265,66 @@ func (n *TaskDecomposerNode) parseTasksFromResponse(response string, originalUse
 
 	if jsonStart >= 0 && jsonEnd > jsonStart {
 		jsonStr := response[jsonStart : jsonEnd+1]
-		log.Printf("📋 提取的JSON: %s", jsonStr)
-
-		// 尝试使用json.Unmarshal解析
-		var result map[string]any
-		if err := json.Unmarshal([]byte(jsonStr), &result); err == nil {
-			if tasks, ok := result["tasks"].([]any); ok {
-				log.Printf("✅ 成功解析JSON，找到 %d 个任务", len(tasks))
-
-				// 转换为所需格式并验证内容
-				taskList := make([]map[string]any, 0, len(tasks))
-				allTasksValid := true
-
-				for i, task := range tasks {
-					if taskMap, ok := task.(map[string]any); ok {
-						log.Printf("📋 任务[%d]: %+v", i, taskMap)
-
-						// 验证代币是否为支持的类型
-						if taskType, exists := taskMap["type"].(string); exists && taskType == "swap" {
-							fromToken, _ := taskMap["from_token"].(string)
-							toToken, _ := taskMap["to_token"].(string)
-
-							// 检查是否为支持的代币对
-							if !n.isSupportedTokenPair(fromToken, toToken) {
-								log.Printf("⚠️  检测到不支持的代币对: %s -> %s", fromToken, toToken)
-								allTasksValid = false
-								break
-							}
-						}
-
-						taskList = append(taskList, taskMap)
-					}
-				}
+		slog.DebugContext(ctx, "📋 提取的JSON", "json", jsonStr)
 
-				if allTasksValid {
-					log.Printf("✅ 所有任务验证通过")
-					return taskList
-				} else {
-					log.Printf("⚠️  任务内容验证失败，使用备用解析")
-				}
+		return n.tasksFromJSON(ctx, jsonStr)
+	}
+
+	slog.WarnContext(ctx, "⚠️  响应中没有JSON")
+	return nil, false
+}
+
+// tasksFromJSON 解析 {"tasks": [...]} 格式的JSON并验证代币对，解析或验证失败时返回 false
+func (n *TaskDecomposerNode) tasksFromJSON(ctx context.Context, jsonStr string) ([]map[string]any, bool) {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		slog.WarnContext(ctx, "⚠️  JSON解析失败", "error", err)
+		return nil, false
+	}
+	tasks, ok := result["tasks"].([]any)
+	if !ok {
+		return nil, false
+	}
+	slog.InfoContext(ctx, "✅ 成功解析JSON", "count", len(tasks))
+
+	// 转换为所需格式并验证内容
+	taskList := make([]map[string]any, 0, len(tasks))
+	for i, task := range tasks {
+		taskMap, ok := task.(map[string]any)
+		if !ok {
+			continue
+		}
+		slog.DebugContext(ctx, "📋 任务", "index", i, "task", taskMap)
+
+		// 验证代币是否为支持的类型
+		if taskType, exists := taskMap["type"].(string); exists && taskType == "swap" {
+			fromToken, _ := taskMap["from_token"].(string)
+			toToken, _ := taskMap["to_token"].(string)
+
+			// 检查是否为支持的代币对
+			if !n.isSupportedTokenPair(ctx, fromToken, toToken) {
+				slog.WarnContext(ctx, "⚠️  检测到不支持的代币对，任务内容验证失败，使用备用解析", "from_token", fromToken, "to_token", toToken)
+				return nil, false
 			}
-		} else {
-			log.Printf("⚠️  JSON解析失败: %v", err)
 		}
+
+		taskList = append(taskList, taskMap)
 	}
 
-	// 如果JSON解析失败或内容验证失败，使用原始用户输入进行文本解析
-	log.Printf("🔄 使用原始用户输入进行备用解析")
-	log.Printf("📝 原始用户输入: %s", originalUserMessage)
-	return n.fallbackParseFromText(originalUserMessage)
+	slog.DebugContext(ctx, "✅ 所有任务验证通过")
+	return taskList, true
 }
 
-// isSupportedTokenPair 检查是否为支持的代币对
-func (n *TaskDecomposerNode) isSupportedTokenPair(fromToken, toToken string) bool {
+// isSupportedTokenPair 检查是否为支持的代币对，配置的交换对能在跳数限制内连通即可
+func (n *TaskDecomposerNode) isSupportedTokenPair(ctx context.Context, fromToken, toToken string) bool {
+	if n.contractManager != nil {
+		if _, err := n.contractManager.FindSwapRoute(fromToken, toToken, contracts.MaxSwapHops); err != nil {
+			slog.DebugContext(ctx, "📋 当前代币对没有可用的兑换路由", "error", err)
+			return false
+		}
+		return true
+	}
+
 	supportedPairs := [][]string{
 		{"MEER", "MTK"},
 		{"MTK", "MEER"},
@@ -217,21 +336,27 @@ func (n *TaskDecomposerNode) isSupportedTokenPair(fromToken, toToken string) boo
 		}
 	}
 
-	log.Printf("📋 支持的代币对: MEER↔MTK，当前: %s->%s", fromToken, toToken)
+	slog.DebugContext(ctx, "📋 支持的代币对: MEER↔MTK", "from_token", fromToken, "to_token", toToken)
 	return false
 }
 
 // fallbackParseFromText 简单文本解析作为备用
-func (n *TaskDecomposerNode) fallbackParseFromText(userMessage string) []map[string]any {
-	log.Printf("🔄 使用文本解析备用方案")
-	log.Printf("📝 分析用户消息: %s", userMessage)
+func (n *TaskDecomposerNode) fallbackParseFromText(ctx context.Context, userMessage string) []map[string]any {
+	slog.InfoContext(ctx, "🔄 使用文本解析备用方案")
+	slog.DebugContext(ctx, "📝 分析用户消息", "message", userMessage)
+
+	// 限制参与匹配的输入长度
+	if len(userMessage) > contracts.MaxParseInputLength {
+		slog.WarnContext(ctx, "⚠️  用户消息过长，仅解析前一部分", "bytes", len(userMessage), "max_bytes", contracts.MaxParseInputLength)
+		userMessage = truncateUTF8(userMessage, contracts.MaxParseInputLength)
+	}
 
 	lowerMessage := strings.ToLower(userMessage)
 	tasks := make([]map[string]any, 0)
 
 	// 检测兑换任务
 	if strings.Contains(lowerMessage, "swap") || strings.Contains(lowerMessage, "兑换") {
-		log.Printf("✅ 检测到兑换任务")
+		slog.DebugContext(ctx, "✅ 检测到兑换任务")
 
 		// 默认值
 		fromToken := "MEER"
@@ -240,20 +365,7 @@ func (n *TaskDecomposerNode) fallbackParseFromText(userMessage string) []map[str
 
 		// 智能解析代币和数量
 		// 解析类似 "兑换10MEER的MTK" 或 "兑换10 MEER为MTK" 的模式
-		patterns := []string{
-			`兑换(\d+)meer.*mtk`,    // "兑换10MEER的MTK"
-			`兑换(\d+).*meer.*mtk`,  // "兑换10 MEER为MTK"
-			`swap\s+(\d+)\s+meer`, // "swap 10 meer"
-		}
-
-		for _, pattern := range patterns {
-			re := regexp.MustCompile(pattern)
-			if matches := re.FindStringSubmatch(lowerMessage); len(matches) > 1 {
-				amount = matches[1]
-				log.Printf("📋 提取数量: %s", amount)
-				break
-			}
-		}
+		amount = extractAmount(ctx, lowerMessage, fallbackSwapAmountPatterns, amount)
 
 		// 确认代币方向
 		if strings.Contains(lowerMessage, "meer") && strings.Contains(lowerMessage, "mtk") {
@@ -282,13 +394,13 @@ func (n *TaskDecomposerNode) fallbackParseFromText(userMessage string) []map[str
 			"description":      fmt.Sprintf("兑换%s %s为%s", amount, fromToken, toToken),
 		}
 
-		log.Printf("📋 构建兑换任务: %+v", task)
+		slog.DebugContext(ctx, "📋 构建兑换任务", "task", task)
 		tasks = append(tasks, task)
 	}
 
 	// 检测质押任务
 	if strings.Contains(lowerMessage, "stake") || strings.Contains(lowerMessage, "质押") {
-		log.Printf("✅ 检测到质押任务")
+		slog.DebugContext(ctx, "✅ 检测到质押任务")
 
 		// 检查是否是连续操作
 		hasSwap := strings.Contains(lowerMessage, "swap") || strings.Contains(lowerMessage, "兑换")
@@ -301,35 +413,35 @@ func (n *TaskDecomposerNode) fallbackParseFromText(userMessage string) []map[str
 				"type":             "stake",
 				"token":            "MTK",
 				"amount":           "all_from_previous",
-				"pool":             "compound",
+				"pool":             n.poolFromMessage(lowerMessage),
 				"dependency_tx_id": "task_1",
 				"description":      "将兑换得到的MTK进行质押",
 			}
-			log.Printf("🔗 连续操作：质押依赖兑换任务")
+			slog.DebugContext(ctx, "🔗 连续操作：质押依赖兑换任务")
 		} else {
 			// 独立质押操作
 			stakeTask = map[string]any{
 				"id":               "task_1",
 				"type":             "stake",
 				"token":            "MTK",
-				"amount":           "100",
-				"pool":             "compound",
+				"amount":           extractAmount(ctx, lowerMessage, fallbackStakeAmountPatterns, "100"),
+				"pool":             n.poolFromMessage(lowerMessage),
 				"dependency_tx_id": nil,
 				"description":      "质押MTK代币",
 			}
 		}
 
-		log.Printf("📋 构建质押任务: %+v", stakeTask)
+		slog.DebugContext(ctx, "📋 构建质押任务", "task", stakeTask)
 		tasks = append(tasks, stakeTask)
 	}
 
-	log.Printf("✅ 文本解析完成，共 %d 个任务", len(tasks))
+	slog.InfoContext(ctx, "✅ 文本解析完成", "count", len(tasks))
 	return tasks
 }
 
-func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[string]any {
-	log.Printf("🔄 使用简单规则分解任务")
-	log.Printf("📝 消息: %s", message)
+func (n *TaskDecomposerNode) simpleTaskDecomposition(ctx context.Context, message string) []map[string]any {
+	slog.InfoContext(ctx, "🔄 使用简单规则分解任务")
+	slog.DebugContext(ctx, "📝 消息", "message", message)
 
 	lowerMsg := strings.ToLower(message)
 	tasks := make([]map[string]any, 0)
@@ -339,7 +451,7 @@ func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[strin
 		(strings.Contains(lowerMsg, "质押") || strings.Contains(lowerMsg, "stake"))
 
 	if strings.Contains(lowerMsg, "兑换") || strings.Contains(lowerMsg, "swap") {
-		log.Printf("✅ 检测到兑换/swap任务")
+		slog.DebugContext(ctx, "✅ 检测到兑换/swap任务")
 
 		// 解析代币信息
 		fromToken := "MEER"
@@ -354,12 +466,8 @@ func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[strin
 			toToken = "MTK"
 		}
 
-		// 解析数量
-		if strings.Contains(lowerMsg, "10") {
-			amount = "10"
-		} else if strings.Contains(lowerMsg, "1") {
-			amount = "1"
-		}
+		// 解析数量（与备用文本解析使用相同规则）
+		amount = extractAmount(ctx, lowerMsg, fallbackSwapAmountPatterns, amount)
 
 		swapTask := map[string]any{
 			"id":               "task_1",
@@ -374,19 +482,19 @@ func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[strin
 	}
 
 	if strings.Contains(lowerMsg, "质押") || strings.Contains(lowerMsg, "stake") {
-		log.Printf("✅ 检测到质押/stake任务")
+		slog.DebugContext(ctx, "✅ 检测到质押/stake任务")
 
 		var stakeTask map[string]any
 
 		if hasSwapAndStake {
 			// 如果是连续操作，质押任务依赖兑换任务
-			log.Printf("🔗 检测到连续操作：兑换后质押")
+			slog.DebugContext(ctx, "🔗 检测到连续操作：兑换后质押")
 			stakeTask = map[string]any{
 				"id":               "task_2",
 				"type":             "stake",
 				"token":            "MTK",               // 使用兑换得到的代币
 				"amount":           "all_from_previous", // 使用前一个任务的全部输出
-				"pool":             "compound",
+				"pool":             n.poolFromMessage(lowerMsg),
 				"dependency_tx_id": "task_1", // 依赖兑换任务
 				"description":      "将兑换得到的MTK进行质押",
 			}
@@ -396,8 +504,8 @@ func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[strin
 				"id":               "task_1",
 				"type":             "stake",
 				"token":            "MTK",
-				"amount":           "100", // 默认数量
-				"pool":             "compound",
+				"amount":           extractAmount(ctx, lowerMsg, fallbackStakeAmountPatterns, "100"), // 默认数量
+				"pool":             n.poolFromMessage(lowerMsg),
 				"dependency_tx_id": nil,
 				"description":      "质押MTK代币",
 			}
@@ -406,71 +514,77 @@ func (n *TaskDecomposerNode) simpleTaskDecomposition(message string) []map[strin
 		tasks = append(tasks, stakeTask)
 	}
 
-	log.Printf("📋 简单分解完成，共 %d 个任务", len(tasks))
+	slog.InfoContext(ctx, "📋 简单分解完成", "count", len(tasks))
 	if hasSwapAndStake {
-		log.Printf("🔗 检测到依赖关系：task_2 依赖 task_1")
+		slog.DebugContext(ctx, "🔗 检测到依赖关系：task_2 依赖 task_1")
 	}
 	return tasks
 }
 
-func (n *TaskDecomposerNode) determineNextNodes(tasks []map[string]any) []string {
-	log.Printf("🔄 确定下一个执行节点")
-	log.Printf("📋 任务数量: %d", len(tasks))
+func (n *TaskDecomposerNode) determineNextNodes(ctx context.Context, tasks []map[string]any) []string {
+	slog.DebugContext(ctx, "🔄 确定下一个执行节点", "tasks", len(tasks))
 
 	if len(tasks) == 0 {
-		log.Printf("➡️  没有任务，选择result_aggregator节点")
+		slog.WarnContext(ctx, "⚠️  未分解出可执行任务，选择result_aggregator节点报告")
 		return []string{"result_aggregator"}
 	}
 
-	// 找到没有依赖的第一个任务（即可以立即执行的任务）
+	// 找到所有没有依赖的任务（即可以立即执行的任务），多个时由图并行执行
+	var nextNodes []string
 	for i, task := range tasks {
-		log.Printf("📋 任务[%d]: %+v", i, task)
+		slog.DebugContext(ctx, "📋 任务", "index", i, "task", task)
 
 		dependencyTxID := task["dependency_tx_id"]
 		if dependencyTxID == nil {
 			// 没有依赖，可以立即执行
-			if taskType, ok := task["type"].(string); ok {
-				log.Printf("🔄 任务类型: %s (无依赖)", taskType)
-				switch taskType {
-				case "swap":
-					log.Printf("➡️  选择swap_executor节点")
-					return []string{"swap_executor"}
-				case "stake":
-					log.Printf("➡️  选择stake_executor节点")
-					return []string{"stake_executor"}
-				}
+			if node := executorForTask(task); node != "" {
+				slog.InfoContext(ctx, "🔄 选择无依赖任务的执行节点", "type", task["type"], "next", node)
+				nextNodes = append(nextNodes, node)
 			}
 		} else {
-			log.Printf("🔗 任务[%d]依赖于: %v", i, dependencyTxID)
+			slog.DebugContext(ctx, "🔗 任务存在依赖", "index", i, "depends_on", dependencyTxID)
 		}
 	}
+	if len(nextNodes) > 0 {
+		return nextNodes
+	}
 
 	// 如果所有任务都有依赖，说明可能有问题，先执行第一个任务
 	if len(tasks) > 0 {
 		firstTask := tasks[0]
 		if taskType, ok := firstTask["type"].(string); ok {
-			log.Printf("⚠️  所有任务都有依赖，强制执行第一个任务: %s", taskType)
+			slog.WarnContext(ctx, "⚠️  所有任务都有依赖，强制执行第一个任务", "type", taskType)
 			switch taskType {
 			case "swap":
 				return []string{"swap_executor"}
 			case "stake":
 				return []string{"stake_executor"}
+			case "transfer":
+				return []string{"transfer_executor"}
 			}
 		}
 	}
 
-	log.Printf("➡️  默认选择result_aggregator节点")
+	slog.InfoContext(ctx, "➡️  默认选择result_aggregator节点")
 	return []string{"result_aggregator"}
 }
 
 // SwapExecutorNode 交易执行节点
 type SwapExecutorNode struct {
 	contractManager *contracts.ContractManager
+	rpcClient       *rpc.Client
+	spendingGuard   *SpendingGuard
+	trusted         *TrustedAddresses
+	gasBuffer       int
 }
 
-func NewSwapExecutorNode(contractManager *contracts.ContractManager) *SwapExecutorNode {
+func NewSwapExecutorNode(contractManager *contracts.ContractManager, rpcClient *rpc.Client, spendingGuard *SpendingGuard, trusted *TrustedAddresses, gasBufferPercent int) *SwapExecutorNode {
 	return &SwapExecutorNode{
 		contractManager: contractManager,
+		rpcClient:       rpcClient,
+		spendingGuard:   spendingGuard,
+		trusted:         trusted,
+		gasBuffer:       gasBufferPercent,
 	}
 }
 
@@ -483,67 +597,127 @@ func (n *SwapExecutorNode) GetType() string {
 }
 
 func (n *SwapExecutorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
-	log.Printf("🔄 交易执行节点开始执行")
+	slog.InfoContext(ctx, "🔄 交易执行节点开始执行")
 
 	// 查找当前需要执行的swap任务
 	currentTask, err := n.findCurrentSwapTask(input.Data)
 	if err != nil {
-		log.Printf("❌ 查找当前swap任务失败: %v", err)
+		slog.ErrorContext(ctx, "❌ 查找当前swap任务失败", "error", err)
 		return nil, err
 	}
 
-	log.Printf("📋 当前执行任务: %+v", currentTask)
+	slog.InfoContext(ctx, "📋 当前执行任务", "task", currentTask)
 
 	// 使用合约管理器构建兑换请求
 	if n.contractManager == nil {
-		log.Printf("❌ 合约管理器未初始化")
+		slog.ErrorContext(ctx, "❌ 合约管理器未初始化")
 		return nil, fmt.Errorf("contract manager not initialized")
 	}
 
-	swapRequest, err := n.buildSwapRequestFromTask(currentTask, input.Data)
+	swapRequest, err := n.buildSwapRequestFromTask(ctx, currentTask, input.Data)
 	if err != nil {
-		log.Printf("❌ 构建兑换请求失败: %v", err)
+		slog.ErrorContext(ctx, "❌ 构建兑换请求失败", "error", err)
 		return nil, err
 	}
 
-	log.Printf("✅ 构建兑换请求成功: %s %s -> %s", swapRequest.Amount, swapRequest.FromToken, swapRequest.ToToken)
+	slog.InfoContext(ctx, "✅ 构建兑换请求成功", "amount", swapRequest.Amount, "from_token", swapRequest.FromToken, "to_token", swapRequest.ToToken)
 
-	// 构建交易数据
-	txData, err := n.contractManager.BuildSwapTransaction(swapRequest)
-	if err != nil {
-		log.Printf("❌ 构建交易数据失败: %v", err)
-		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	// 兑换路由在首跳时构建一次，后续各跳沿用首跳确定的数量
+	taskID, _ := currentTask["id"].(string)
+	hopIndex := completedSwapHops(input.Data, taskID)
+	hops := swapRoute(input.Data, taskID)
+	if hopIndex == 0 || hops == nil {
+		// 配置了报价函数的交换对先读取链上汇率，预计输出与最少输出按当前价格计算
+		if n.rpcClient != nil {
+			n.contractManager.RefreshRouteRates(ctx, accountCaller{client: n.rpcClient, from: readAddress(input.Data)}, swapRequest.FromToken, swapRequest.ToToken)
+		}
+
+		// 没有直接交换对时经过中间代币，每一跳单独签名
+		hops, err = n.contractManager.BuildSwapRoute(swapRequest, contracts.MaxSwapHops)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ 构建交易数据失败", "error", err)
+			return nil, fmt.Errorf("failed to build transaction: %w", err)
+		}
+		saveSwapRoute(input.Data, taskID, hops)
+	}
+
+	// 记录当前执行的任务，供签名验证后标记完成
+	if hopIndex >= len(hops) {
+		return nil, fmt.Errorf("swap task %s has no remaining hops", taskID)
+	}
+	hop := hops[hopIndex]
+
+	// 检查数量上限与账户余额
+	if err := checkAmountBounds(ctx, n.contractManager, n.rpcClient, input.Data, hop.FromToken, hop.Amount); err != nil {
+		slog.WarnContext(ctx, "❌ 数量检查未通过", "error", err)
+		return nil, err
+	}
+
+	input.Data["current_task_id"] = taskID
+
+	// 检查支出限额
+	if err := n.spendingGuard.Check(input.Data, taskID, swapRequest.FromToken, swapRequest.Amount); err != nil {
+		slog.WarnContext(ctx, "❌ 支出限额检查未通过", "error", err)
+		return nil, err
 	}
 
-	log.Printf("✅ 交易数据构建成功")
+	txData := hop.Transaction
+	estimateGasLimit(ctx, n.rpcClient, txData, input.Data, n.gasBuffer)
+
+	// 中间跳签名确认后需要回到本节点继续下一跳；最后一跳记录预计输出，供依赖任务的 all_from_previous 使用
+	if hopIndex < len(hops)-1 {
+		input.Data[taskID+"_current_step"] = stepSwapHop
+	} else {
+		input.Data[outputAmountKey(taskID)] = hop.Output
+	}
+
+	slog.InfoContext(ctx, "✅ 交易数据构建成功")
 
 	// 需要用户签名授权交易
-	log.Printf("✍️  需要用户签名授权交易")
+	slog.InfoContext(ctx, "✍️  需要用户签名授权交易")
+	amountDisplay := n.contractManager.FormatAmount(hop.FromToken, hop.Amount)
+	outputDisplay := n.contractManager.FormatAmount(hop.ToToken, hop.Output)
 	authRequest := map[string]any{
 		"type":       "transaction_signature",
 		"action":     "swap",
-		"from_token": swapRequest.FromToken,
-		"to_token":   swapRequest.ToToken,
-		"amount":     swapRequest.Amount,
-		"gas_fee":    "0.001 ETH",
-		"slippage":   "0.5%",
+		"from_token": hop.FromToken,
+		"to_token":   hop.ToToken,
+		"amount":     hop.Amount,
+		"gas_fee":    n.contractManager.FormatGasFee(txData),
+		// 按代币精度格式化的数量，供界面与对话展示
+		"amount_display": amountDisplay,
+		"output_display": outputDisplay,
 		// 使用合约管理器生成的真实交易数据
 		"to_address": txData.To,
 		"value":      txData.Value,
 		"data":       txData.Data,
 		"gas_limit":  txData.GasLimit,
 		"gas_price":  txData.GasPrice,
+		// EIP-1559 网络上设置，此时 gas_price 为空
+		"max_fee_per_gas":          txData.MaxFeePerGas,
+		"max_priority_fee_per_gas": txData.MaxPriorityFeePerGas,
 	}
+	if hop.MinOutputEnforced {
+		minOutputDisplay := n.contractManager.FormatAmount(hop.ToToken, hop.MinOutput)
+		authRequest["slippage"] = fmt.Sprintf("%g%%", contracts.SwapSlippage*100)
+		authRequest["min_output"] = hop.MinOutput
+		authRequest["min_output_display"] = minOutputDisplay
+		authRequest["description"] = fmt.Sprintf("兑换 %s，预计获得 %s（最少 %s）", amountDisplay, outputDisplay, minOutputDisplay)
+	} else {
+		// 兑换函数没有 minAmountOut 参数，链上成交数量不受保护，只能展示估算值
+		authRequest["output_estimated"] = true
+		authRequest["description"] = fmt.Sprintf("兑换 %s，预计获得约 %s（估算值，合约不保证最少输出）", amountDisplay, outputDisplay)
+	}
+	if len(hops) > 1 {
+		authRequest["route"] = formatSwapRoute(hops)
+		authRequest["step_info"] = fmt.Sprintf("步骤 %d/%d: 兑换 %s 为 %s", hopIndex+1, len(hops), hop.FromToken, hop.ToToken)
+	}
+	n.trusted.applyTrustCheck(authRequest, txData)
+	applyPendingNonceCheck(ctx, n.rpcClient, input.Data, authRequest)
 
-	log.Printf("📋 授权请求: %+v", authRequest)
+	slog.DebugContext(ctx, "📋 授权请求", "request", authRequest)
 
-	return &NodeOutput{
-		Data:         input.Data,
-		NextNodes:    []string{"signature_validator"},
-		NeedUserAuth: true,
-		AuthRequest:  authRequest,
-		Completed:    false,
-	}, nil
+	return requestSignature(input.Data, authRequest), nil
 }
 
 // findCurrentSwapTask 查找当前需要执行的swap任务
@@ -572,7 +746,8 @@ func (n *SwapExecutorNode) findCurrentSwapTask(data map[string]any) (map[string]
 					break
 				}
 			}
-			if alreadyCompleted {
+			// 已完成或已在当前批次中等待签名的任务跳过
+			if alreadyCompleted || isBatchedTask(data, taskID) {
 				continue
 			}
 
@@ -601,7 +776,7 @@ func (n *SwapExecutorNode) findCurrentSwapTask(data map[string]any) (map[string]
 }
 
 // buildSwapRequestFromTask 从任务构建兑换请求
-func (n *SwapExecutorNode) buildSwapRequestFromTask(task map[string]any, data map[string]any) (*contracts.SwapRequest, error) {
+func (n *SwapExecutorNode) buildSwapRequestFromTask(ctx context.Context, task map[string]any, data map[string]any) (*contracts.SwapRequest, error) {
 	fromToken, ok := task["from_token"].(string)
 	if !ok {
 		return nil, fmt.Errorf("from_token not found in task")
@@ -617,12 +792,14 @@ func (n *SwapExecutorNode) buildSwapRequestFromTask(task map[string]any, data ma
 		return nil, fmt.Errorf("amount not found in task")
 	}
 
-	// 处理特殊的金额类型
-	if amount == "all_from_previous" {
-		// 从前一个任务获取金额
-		// 这里可以根据前一个任务的类型和结果来计算
-		amount = "10" // 默认值，实际应该从前一个任务的交易结果中获取
-		log.Printf("🔄 使用前一个任务的输出金额: %s", amount)
+	// 使用依赖任务记录的输出数量，没有记录时报错而不是猜测数量
+	if amount == contracts.AmountFromPrevious {
+		previous, err := dependencyOutputAmount(task, data)
+		if err != nil {
+			return nil, err
+		}
+		amount = previous
+		slog.InfoContext(ctx, "🔄 使用前一个任务的输出金额", "amount", amount)
 	}
 
 	return &contracts.SwapRequest{
@@ -635,11 +812,19 @@ func (n *SwapExecutorNode) buildSwapRequestFromTask(task map[string]any, data ma
 // StakeExecutorNode 质押执行节点
 type StakeExecutorNode struct {
 	contractManager *contracts.ContractManager
+	rpcClient       *rpc.Client
+	spendingGuard   *SpendingGuard
+	trusted         *TrustedAddresses
+	gasBuffer       int
 }
 
-func NewStakeExecutorNode(contractManager *contracts.ContractManager) *StakeExecutorNode {
+func NewStakeExecutorNode(contractManager *contracts.ContractManager, rpcClient *rpc.Client, spendingGuard *SpendingGuard, trusted *TrustedAddresses, gasBufferPercent int) *StakeExecutorNode {
 	return &StakeExecutorNode{
 		contractManager: contractManager,
+		rpcClient:       rpcClient,
+		spendingGuard:   spendingGuard,
+		trusted:         trusted,
+		gasBuffer:       gasBufferPercent,
 	}
 }
 
@@ -652,120 +837,187 @@ func (n *StakeExecutorNode) GetType() string {
 }
 
 func (n *StakeExecutorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
-	log.Printf("🔄 质押执行节点开始执行")
+	slog.InfoContext(ctx, "🔄 质押执行节点开始执行")
 
 	// 查找当前需要执行的stake任务
 	currentTask, err := n.findCurrentStakeTask(input.Data)
 	if err != nil {
-		log.Printf("❌ 查找当前stake任务失败: %v", err)
+		slog.ErrorContext(ctx, "❌ 查找当前stake任务失败", "error", err)
 		return nil, err
 	}
 
-	log.Printf("📋 当前执行任务: %+v", currentTask)
+	slog.InfoContext(ctx, "📋 当前执行任务", "task", currentTask)
 
 	// 使用合约管理器构建质押请求
 	if n.contractManager == nil {
-		log.Printf("❌ 合约管理器未初始化")
+		slog.ErrorContext(ctx, "❌ 合约管理器未初始化")
 		return nil, fmt.Errorf("contract manager not initialized")
 	}
 
-	stakeRequest, err := n.buildStakeRequestFromTask(currentTask, input.Data)
+	stakeRequest, err := n.buildStakeRequestFromTask(ctx, currentTask, input.Data)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ 构建质押请求失败", "error", err)
+		return nil, err
+	}
+
+	pool, err := n.contractManager.ResolvePool(stakeRequest.Pool)
 	if err != nil {
-		log.Printf("❌ 构建质押请求失败: %v", err)
+		slog.WarnContext(ctx, "❌ 质押池无效", "error", err)
 		return nil, err
 	}
 
-	log.Printf("✅ 构建质押请求成功: %s %s %s", stakeRequest.Action, stakeRequest.Amount, stakeRequest.Token)
+	slog.InfoContext(ctx, "✅ 构建质押请求成功", "action", stakeRequest.Action, "amount", stakeRequest.Amount, "token", stakeRequest.Token, "pool", pool.Name)
+	amountDisplay := n.contractManager.FormatAmount(stakeRequest.Token, stakeRequest.Amount)
+
+	// 检查数量上限与账户余额，取消质押检查质押池中的质押数量
+	if stakeRequest.Action == "unstake" {
+		err = checkUnstakeBounds(ctx, n.contractManager, n.rpcClient, input.Data, pool.Name, stakeRequest.Token, stakeRequest.Amount)
+	} else {
+		err = checkAmountBounds(ctx, n.contractManager, n.rpcClient, input.Data, stakeRequest.Token, stakeRequest.Amount)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "❌ 数量检查未通过", "error", err)
+		return nil, err
+	}
 
 	// 检查是否已经执行了授权步骤
 	taskID, _ := currentTask["id"].(string)
+	input.Data["current_task_id"] = taskID
 	approveKey := taskID + "_approve_completed"
 
+	// 检查支出限额
+	if err := n.spendingGuard.Check(input.Data, taskID, stakeRequest.Token, stakeRequest.Amount); err != nil {
+		slog.WarnContext(ctx, "❌ 支出限额检查未通过", "error", err)
+		return nil, err
+	}
+
 	if _, approveCompleted := input.Data[approveKey]; !approveCompleted {
 		// 还没有授权，先构建授权交易
-		log.Printf("🔐 需要先授权MTK代币给质押合约")
+		slog.InfoContext(ctx, "🔐 需要先授权MTK代币给质押合约")
 
 		approveData, err := n.contractManager.BuildApproveTransaction(stakeRequest)
 		if err != nil {
-			log.Printf("❌ 构建授权交易失败: %v", err)
+			slog.ErrorContext(ctx, "❌ 构建授权交易失败", "error", err)
 			return nil, fmt.Errorf("failed to build approve transaction: %w", err)
 		}
+		estimateGasLimit(ctx, n.rpcClient, approveData, input.Data, n.gasBuffer)
 
-		log.Printf("✅ 授权交易数据构建成功")
+		slog.InfoContext(ctx, "✅ 授权交易数据构建成功")
+
+		// 从交易数据解码实际的授权对象，便于用户在钱包中核对
+		spender, err := n.contractManager.CheckApproveSpender(approveData.Data, pool.Contract)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ 解码授权对象失败", "error", err)
+			return nil, fmt.Errorf("failed to decode approve spender: %w", err)
+		}
+		if spender.Warning != "" {
+			slog.WarnContext(ctx, "⚠️  "+spender.Warning)
+		}
+		allowance := n.currentAllowance(ctx, input.Data, stakeRequest, spender.Address)
 
 		// 标记当前是授权步骤
 		input.Data[taskID+"_current_step"] = "approve"
 
 		authRequest := map[string]any{
-			"type":        "transaction_signature",
-			"action":      "approve",
-			"token":       stakeRequest.Token,
-			"amount":      stakeRequest.Amount,
-			"spender":     "MTK质押合约",
-			"gas_fee":     "0.001 ETH",
-			"title":       "MTK代币授权 - 质押准备",
-			"description": fmt.Sprintf("授权质押合约使用您的 %s %s 代币，这是质押操作的必要步骤", stakeRequest.Amount, stakeRequest.Token),
-			"step_info":   "步骤 1/2: 授权代币使用权限",
+			"type":           "transaction_signature",
+			"action":         "approve",
+			"token":          stakeRequest.Token,
+			"amount":         stakeRequest.Amount,
+			"spender":        pool.Contract,
+			"pool":           pool.Name,
+			"gas_fee":        n.contractManager.FormatGasFee(approveData),
+			"title":          "MTK代币授权 - 质押准备",
+			"description":    fmt.Sprintf("授权质押合约 %s 使用您的 %s 代币，这是质押操作的必要步骤", spender.Address, amountDisplay),
+			"amount_display": amountDisplay,
+			"step_info":      "步骤 1/2: 授权代币使用权限",
+			// 从交易数据解码出的实际授权地址及核验结果
+			"spender_address":  spender.Address,
+			"spender_verified": spender.Known && spender.Warning == "",
+			"spender_check":    spender,
 			// 使用合约管理器生成的真实交易数据
 			"to_address": approveData.To,
 			"value":      approveData.Value,
 			"data":       approveData.Data,
 			"gas_limit":  approveData.GasLimit,
 			"gas_price":  approveData.GasPrice,
+			// EIP-1559 网络上设置，此时 gas_price 为空
+			"max_fee_per_gas":          approveData.MaxFeePerGas,
+			"max_priority_fee_per_gas": approveData.MaxPriorityFeePerGas,
+		}
+		if spender.Warning != "" {
+			addWarning(authRequest, spender.Warning)
+		}
+		if allowance != "" {
+			authRequest["current_allowance"] = allowance
 		}
+		n.trusted.applyTrustCheck(authRequest, approveData)
+		applyPendingNonceCheck(ctx, n.rpcClient, input.Data, authRequest)
 
-		log.Printf("📋 授权请求: %+v", authRequest)
+		slog.DebugContext(ctx, "📋 授权请求", "request", authRequest)
 
-		return &NodeOutput{
-			Data:         input.Data,
-			NextNodes:    []string{"signature_validator"},
-			NeedUserAuth: true,
-			AuthRequest:  authRequest,
-			Completed:    false,
-		}, nil
+		return requestSignature(input.Data, authRequest), nil
 	}
 
 	// 授权已完成，现在构建质押交易
-	log.Printf("✅ 授权已完成，构建质押交易")
+	slog.InfoContext(ctx, "✅ 授权已完成，构建质押交易")
 
 	txData, err := n.contractManager.BuildStakeTransaction(stakeRequest)
 	if err != nil {
-		log.Printf("❌ 构建质押交易数据失败: %v", err)
+		slog.ErrorContext(ctx, "❌ 构建质押交易数据失败", "error", err)
 		return nil, fmt.Errorf("failed to build stake transaction: %w", err)
 	}
+	estimateGasLimit(ctx, n.rpcClient, txData, input.Data, n.gasBuffer)
 
-	log.Printf("✅ 质押交易数据构建成功")
+	slog.InfoContext(ctx, "✅ 质押交易数据构建成功")
 
 	// 需要用户签名授权质押交易
-	log.Printf("✍️  需要用户签名授权质押交易")
+	slog.InfoContext(ctx, "✍️  需要用户签名授权质押交易")
 	authRequest := map[string]any{
-		"type":        "transaction_signature",
-		"action":      "stake",
-		"token":       stakeRequest.Token,
-		"amount":      stakeRequest.Amount,
-		"pool":        "compound",
-		"gas_fee":     "0.001 ETH",
-		"apy":         "8.5%",
-		"title":       "MTK代币质押 - 开始赚取奖励",
-		"description": fmt.Sprintf("将 %s %s 代币质押到合约中，预计年化收益率 8.5%%", stakeRequest.Amount, stakeRequest.Token),
-		"step_info":   "步骤 2/2: 执行质押操作",
+		"type":           "transaction_signature",
+		"action":         "stake",
+		"token":          stakeRequest.Token,
+		"amount":         stakeRequest.Amount,
+		"pool":           pool.Name,
+		"gas_fee":        n.contractManager.FormatGasFee(txData),
+		"apy":            pool.APY,
+		"title":          "MTK代币质押 - 开始赚取奖励",
+		"description":    stakeDescription(amountDisplay, pool),
+		"amount_display": amountDisplay,
+		"step_info":      "步骤 2/2: 执行质押操作",
 		// 使用合约管理器生成的真实交易数据
 		"to_address": txData.To,
 		"value":      txData.Value,
 		"data":       txData.Data,
 		"gas_limit":  txData.GasLimit,
 		"gas_price":  txData.GasPrice,
+		// EIP-1559 网络上设置，此时 gas_price 为空
+		"max_fee_per_gas":          txData.MaxFeePerGas,
+		"max_priority_fee_per_gas": txData.MaxPriorityFeePerGas,
 	}
+	n.trusted.applyTrustCheck(authRequest, txData)
+	applyPendingNonceCheck(ctx, n.rpcClient, input.Data, authRequest)
 
-	log.Printf("📋 授权请求: %+v", authRequest)
+	slog.DebugContext(ctx, "📋 授权请求", "request", authRequest)
 
-	return &NodeOutput{
-		Data:         input.Data,
-		NextNodes:    []string{"signature_validator"},
-		NeedUserAuth: true,
-		AuthRequest:  authRequest,
-		Completed:    false,
-	}, nil
+	return requestSignature(input.Data, authRequest), nil
+}
+
+// currentAllowance 读取查询账户当前授权给质押合约的额度，便于用户判断是否需要重新授权；
+// 没有查询账户或读取失败时返回空字符串
+func (n *StakeExecutorNode) currentAllowance(ctx context.Context, data map[string]any, stakeRequest *contracts.StakeRequest, spender string) string {
+	owner := readAddress(data)
+	if owner == "" || n.rpcClient == nil || isDryRun(data) {
+		return ""
+	}
+
+	allowance, err := n.contractManager.GetAllowance(ctx, accountCaller{client: n.rpcClient, from: owner}, stakeRequest.Token, owner, spender)
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️  读取授权额度失败", "token", stakeRequest.Token, "error", err)
+		return ""
+	}
+	display := n.contractManager.FormatUnits(stakeRequest.Token, allowance)
+	slog.InfoContext(ctx, "📋 当前授权额度", "owner", owner, "allowance", display)
+	return display
 }
 
 // findCurrentStakeTask 查找当前需要执行的stake任务
@@ -794,7 +1046,8 @@ func (n *StakeExecutorNode) findCurrentStakeTask(data map[string]any) (map[strin
 					break
 				}
 			}
-			if alreadyCompleted {
+			// 已完成或已在当前批次中等待签名的任务跳过
+			if alreadyCompleted || isBatchedTask(data, taskID) {
 				continue
 			}
 
@@ -823,7 +1076,7 @@ func (n *StakeExecutorNode) findCurrentStakeTask(data map[string]any) (map[strin
 }
 
 // buildStakeRequestFromTask 从任务构建质押请求
-func (n *StakeExecutorNode) buildStakeRequestFromTask(task map[string]any, data map[string]any) (*contracts.StakeRequest, error) {
+func (n *StakeExecutorNode) buildStakeRequestFromTask(ctx context.Context, task map[string]any, data map[string]any) (*contracts.StakeRequest, error) {
 	token, ok := task["token"].(string)
 	if !ok {
 		return nil, fmt.Errorf("token not found in task")
@@ -834,32 +1087,271 @@ func (n *StakeExecutorNode) buildStakeRequestFromTask(task map[string]any, data
 		return nil, fmt.Errorf("amount not found in task")
 	}
 
-	// 处理特殊的金额类型
-	if amount == "all_from_previous" {
-		// 从前一个任务获取金额
-		// 根据兑换汇率计算：1 MEER = 1000 MTK
-		// 这里可以根据前一个任务的实际结果来计算
-		amount = "1000" // 假设前一个任务兑换了1 MEER，得到1000 MTK
-		log.Printf("🔄 使用前一个任务的输出金额: %s", amount)
+	// 使用依赖任务记录的输出数量，没有记录时报错而不是猜测数量
+	if amount == contracts.AmountFromPrevious {
+		previous, err := dependencyOutputAmount(task, data)
+		if err != nil {
+			return nil, err
+		}
+		amount = previous
+		slog.InfoContext(ctx, "🔄 使用前一个任务的输出金额", "amount", amount)
 	}
 
+	pool, _ := task["pool"].(string)
+
 	return &contracts.StakeRequest{
 		Token:  token,
 		Amount: amount,
 		Action: "stake", // 默认为质押操作
+		Pool:   pool,
+	}, nil
+}
+
+// TransferExecutorNode 转账执行节点，支持原生代币与ERC20代币
+type TransferExecutorNode struct {
+	contractManager *contracts.ContractManager
+	rpcClient       *rpc.Client
+	spendingGuard   *SpendingGuard
+	trusted         *TrustedAddresses
+	gasBuffer       int
+}
+
+func NewTransferExecutorNode(contractManager *contracts.ContractManager, rpcClient *rpc.Client, spendingGuard *SpendingGuard, trusted *TrustedAddresses, gasBufferPercent int) *TransferExecutorNode {
+	return &TransferExecutorNode{
+		contractManager: contractManager,
+		rpcClient:       rpcClient,
+		spendingGuard:   spendingGuard,
+		trusted:         trusted,
+		gasBuffer:       gasBufferPercent,
+	}
+}
+
+func (n *TransferExecutorNode) GetName() string {
+	return "transfer_executor"
+}
+
+func (n *TransferExecutorNode) GetType() string {
+	return "transaction_executor"
+}
+
+func (n *TransferExecutorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
+	slog.InfoContext(ctx, "🔄 转账执行节点开始执行")
+
+	// 查找当前需要执行的transfer任务
+	currentTask, err := n.findCurrentTransferTask(input.Data)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ 查找当前transfer任务失败", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "📋 当前执行任务", "task", currentTask)
+
+	if n.contractManager == nil {
+		slog.ErrorContext(ctx, "❌ 合约管理器未初始化")
+		return nil, fmt.Errorf("contract manager not initialized")
+	}
+
+	token, _ := currentTask["token"].(string)
+	amount, _ := currentTask["amount"].(string)
+	toAddress, _ := currentTask["to_address"].(string)
+	if token == "" || amount == "" || toAddress == "" {
+		return nil, fmt.Errorf("transfer task requires token, amount and to_address")
+	}
+
+	// 使用依赖任务记录的输出数量，没有记录时报错而不是猜测数量
+	if amount == contracts.AmountFromPrevious {
+		previous, err := dependencyOutputAmount(currentTask, input.Data)
+		if err != nil {
+			return nil, err
+		}
+		amount = previous
+		slog.InfoContext(ctx, "🔄 使用前一个任务的输出金额", "amount", amount)
+	}
+
+	// 检查数量上限与账户余额
+	if err := checkAmountBounds(ctx, n.contractManager, n.rpcClient, input.Data, token, amount); err != nil {
+		slog.WarnContext(ctx, "❌ 数量检查未通过", "error", err)
+		return nil, err
+	}
+
+	// 记录当前执行的任务，供签名验证后标记完成
+	taskID, _ := currentTask["id"].(string)
+	input.Data["current_task_id"] = taskID
+
+	// 检查支出限额
+	if err := n.spendingGuard.Check(input.Data, taskID, token, amount); err != nil {
+		slog.WarnContext(ctx, "❌ 支出限额检查未通过", "error", err)
+		return nil, err
+	}
+
+	txData, err := n.contractManager.BuildTransferTransaction(token, toAddress, amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ 构建转账交易失败", "error", err)
+		return nil, fmt.Errorf("failed to build transfer transaction: %w", err)
+	}
+	estimateGasLimit(ctx, n.rpcClient, txData, input.Data, n.gasBuffer)
+
+	slog.InfoContext(ctx, "✅ 转账交易数据构建成功")
+	amountDisplay := n.contractManager.FormatAmount(token, amount)
+
+	// 需要用户签名授权转账
+	slog.InfoContext(ctx, "✍️  需要用户签名授权转账")
+	authRequest := map[string]any{
+		"type":           "transaction_signature",
+		"action":         "transfer",
+		"token":          token,
+		"amount":         amount,
+		"recipient":      toAddress,
+		"gas_fee":        n.contractManager.FormatGasFee(txData),
+		"title":          fmt.Sprintf("%s 转账", token),
+		"description":    fmt.Sprintf("向 %s 转账 %s", toAddress, amountDisplay),
+		"amount_display": amountDisplay,
+		// 使用合约管理器生成的真实交易数据
+		"to_address": txData.To,
+		"value":      txData.Value,
+		"data":       txData.Data,
+		"gas_limit":  txData.GasLimit,
+		"gas_price":  txData.GasPrice,
+		// EIP-1559 网络上设置，此时 gas_price 为空
+		"max_fee_per_gas":          txData.MaxFeePerGas,
+		"max_priority_fee_per_gas": txData.MaxPriorityFeePerGas,
+	}
+	n.trusted.applyTrustCheck(authRequest, txData)
+	applyPendingNonceCheck(ctx, n.rpcClient, input.Data, authRequest)
+
+	slog.DebugContext(ctx, "📋 授权请求", "request", authRequest)
+
+	return requestSignature(input.Data, authRequest), nil
+}
+
+// findCurrentTransferTask 查找当前需要执行的transfer任务：未完成且依赖已完成
+func (n *TransferExecutorNode) findCurrentTransferTask(data map[string]any) (map[string]any, error) {
+	tasks, ok := data["tasks"].([]map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("tasks not found in input data")
+	}
+
+	completed := make(map[string]bool)
+	if completedTasks, exists := data["completed_tasks"].([]string); exists {
+		for _, taskID := range completedTasks {
+			completed[taskID] = true
+		}
+	}
+
+	for _, task := range tasks {
+		if taskType, _ := task["type"].(string); taskType != "transfer" {
+			continue
+		}
+		taskID, _ := task["id"].(string)
+		if completed[taskID] || isBatchedTask(data, taskID) {
+			continue
+		}
+		depID, hasDependency := task["dependency_tx_id"].(string)
+		if !hasDependency || completed[depID] {
+			return task, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no executable transfer task found")
+}
+
+// ParallelExecutorNode 并行执行节点：为多个互不依赖的可执行任务分别构建交易，
+// 合并为一个批量签名请求，由用户一次确认
+type ParallelExecutorNode struct {
+	executors map[string]Node
+}
+
+func NewParallelExecutorNode(executors map[string]Node) *ParallelExecutorNode {
+	return &ParallelExecutorNode{
+		executors: executors,
+	}
+}
+
+func (n *ParallelExecutorNode) GetName() string {
+	return "parallel_executor"
+}
+
+func (n *ParallelExecutorNode) GetType() string {
+	return "batch_executor"
+}
+
+func (n *ParallelExecutorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
+	slog.InfoContext(ctx, "🔄 并行执行节点开始执行")
+
+	tasks := readyTasks(input.Data)
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no executable task found")
+	}
+	slog.InfoContext(ctx, "📋 可并行执行的任务", "count", len(tasks))
+
+	requests := make([]any, 0, len(tasks))
+	batch := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskID, _ := task["id"].(string)
+		executor, exists := n.executors[executorForTask(task)]
+		if !exists {
+			return nil, fmt.Errorf("no executor registered for task %s", taskID)
+		}
+
+		// 执行节点跳过已在批次中的任务，因此按任务顺序依次取到当前任务
+		output, err := executor.Execute(ctx, input)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ 任务构建交易失败", "task_id", taskID, "error", err)
+			return nil, fmt.Errorf("task %s: %w", taskID, err)
+		}
+		if currentTaskID, _ := input.Data["current_task_id"].(string); currentTaskID != taskID {
+			return nil, fmt.Errorf("executor %s picked task %s instead of %s", executor.GetName(), currentTaskID, taskID)
+		}
+		request, ok := output.AuthRequest.(map[string]any)
+		if !output.NeedUserAuth || !ok {
+			return nil, fmt.Errorf("executor %s returned no signature request for task %s", executor.GetName(), taskID)
+		}
+
+		request["task_id"] = taskID
+		requests = append(requests, request)
+		batch = append(batch, taskID)
+		input.Data[batchTasksKey] = batch
+	}
+	delete(input.Data, "current_task_id")
+
+	authRequest := map[string]any{
+		"type":        batchSignatureType,
+		"title":       fmt.Sprintf("批量签名 - %d 笔交易", len(requests)),
+		"description": "以下交易互不依赖，请依次签名，并按顺序以逗号分隔提交交易哈希",
+		"requests":    requests,
+	}
+
+	// 各笔交易读取到的是同一账户的 nonce，在批量请求上给出一次警告即可
+	if first, ok := requests[0].(map[string]any); ok {
+		if gap, ok := first["nonce_gap"].(*rpc.NonceGap); ok {
+			authRequest["nonce_gap"] = gap
+			addWarning(authRequest, pendingNonceWarning(gap))
+		}
+	}
+
+	slog.InfoContext(ctx, "📋 批量授权请求", "count", len(requests), "tasks", batch)
+
+	return &NodeOutput{
+		Data:         input.Data,
+		NextNodes:    []string{"signature_validator"},
+		NeedUserAuth: true,
+		AuthRequest:  authRequest,
+		Completed:    false,
 	}, nil
 }
 
 // SignatureValidatorNode 签名验证节点
 type SignatureValidatorNode struct {
-	rpcClient *rpc.Client
-	txConfig  config.TransactionConfig
+	rpcClient       *rpc.Client
+	contractManager *contracts.ContractManager
+	txConfig        config.TransactionConfig
 }
 
-func NewSignatureValidatorNode(rpcClient *rpc.Client, txConfig config.TransactionConfig) *SignatureValidatorNode {
+func NewSignatureValidatorNode(rpcClient *rpc.Client, contractManager *contracts.ContractManager, txConfig config.TransactionConfig) *SignatureValidatorNode {
 	return &SignatureValidatorNode{
-		rpcClient: rpcClient,
-		txConfig:  txConfig,
+		rpcClient:       rpcClient,
+		contractManager: contractManager,
+		txConfig:        txConfig,
 	}
 }
 
@@ -872,109 +1364,181 @@ func (n *SignatureValidatorNode) GetType() string {
 }
 
 func (n *SignatureValidatorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
-	log.Printf("🔄 签名验证节点开始执行")
+	slog.InfoContext(ctx, "🔄 签名验证节点开始执行")
 
 	signature, ok := input.Data["signature"].(string)
 	if !ok || signature == "" {
-		log.Printf("❌ 输入中缺少签名")
+		slog.ErrorContext(ctx, "❌ 输入中缺少签名")
 		return nil, fmt.Errorf("signature not found in input")
 	}
 
-	log.Printf("🔐 收到签名，长度: %d", len(signature))
-	log.Printf("🔐 签名内容: %s", signature[:llm.Min(len(signature), 50)])
+	slog.InfoContext(ctx, "🔐 收到签名", "length", len(signature))
+	slog.DebugContext(ctx, "🔐 签名内容", "signature", signature[:llm.Min(len(signature), 50)])
+
+	// 批量签名逐笔确认，每个任务单独记录完成情况
+	if batch := batchTaskIDs(input.Data); len(batch) > 0 {
+		return n.executeBatch(ctx, input, batch, signature)
+	}
 
-	// 验证签名（简化处理）
-	if len(signature) < 10 {
-		log.Printf("❌ 签名长度不足: %d", len(signature))
+	// 宽松模式只做格式检查，用于本地模拟流程
+	if n.txConfig.PermissiveSignatures && len(signature) < 10 {
+		slog.WarnContext(ctx, "❌ 签名长度不足", "length", len(signature))
 		return nil, fmt.Errorf("invalid signature")
 	}
 
-	log.Printf("✅ 签名验证成功")
+	transactionHash := signature // 钱包签名广播后返回的交易哈希
+	input.Data["transaction_hash"] = transactionHash
+
+	taskID, _ := input.Data["current_task_id"].(string)
+	if err := n.confirmTransaction(ctx, input.Data, taskID, transactionHash); err != nil {
+		return nil, err
+	}
 
-	// 签名验证成功，继续下一步
 	input.Data["signature_verified"] = true
-	transactionHash := signature // 使用完整的交易哈希
-	input.Data["transaction_hash"] = transactionHash
+	slog.InfoContext(ctx, "✅ 签名验证成功")
+
+	return &NodeOutput{
+		Data:      input.Data,
+		Completed: false,
+	}, nil
+}
 
-	log.Printf("📊 更新数据:")
-	log.Printf("  - signature_verified: true")
-	log.Printf("  - transaction_hash: %s", transactionHash)
+// executeBatch 按批次顺序确认每笔交易并记录对应任务的完成情况，全部确认后才进入后续任务
+func (n *SignatureValidatorNode) executeBatch(ctx context.Context, input NodeInput, batch []string, signature string) (*NodeOutput, error) {
+	hashes := splitBatchSignature(signature)
+	if len(hashes) != len(batch) {
+		slog.WarnContext(ctx, "❌ 批量签名数量不匹配", "want", len(batch), "got", len(hashes))
+		return nil, fmt.Errorf("batch signature requires %d transaction hashes, got %d", len(batch), len(hashes))
+	}
 
-	// 等待交易确认
-	log.Printf("⏳ 等待交易确认...")
-	err := n.waitForTransactionConfirmation(ctx, transactionHash)
-	if err != nil {
-		log.Printf("❌ 交易确认失败: %v", err)
-		return nil, fmt.Errorf("transaction confirmation failed: %w", err)
+	slog.InfoContext(ctx, "🔐 收到批量签名", "count", len(hashes))
+	for i, taskID := range batch {
+		slog.InfoContext(ctx, "⏳ 确认任务的交易", "task_id", taskID, "index", i+1, "total", len(batch))
+		if err := n.confirmTransaction(ctx, input.Data, taskID, hashes[i]); err != nil {
+			return nil, fmt.Errorf("task %s: %w", taskID, err)
+		}
+		completeTaskStep(input.Data, taskID, hashes[i])
 	}
 
+	input.Data["transaction_hash"] = hashes[len(hashes)-1]
+	input.Data["signature_verified"] = true
+	slog.InfoContext(ctx, "✅ 批量签名验证成功")
+
 	return &NodeOutput{
 		Data:      input.Data,
 		Completed: false,
 	}, nil
 }
 
-// waitForTransactionConfirmation 等待交易确认
-func (n *SignatureValidatorNode) waitForTransactionConfirmation(ctx context.Context, txHash string) error {
-	log.Printf("🔍 开始监控交易确认: %s", txHash)
+// confirmTransaction 等待交易确认，在未开启宽松模式时校验交易签名者，并记录兑换任务的实际到账数量
+func (n *SignatureValidatorNode) confirmTransaction(ctx context.Context, data map[string]any, taskID, transactionHash string) error {
+	// 没有用户地址时无法校验签名者，在等待确认之前拒绝
+	expected, _ := data["user_address"].(string)
+	if !n.txConfig.PermissiveSignatures && expected == "" {
+		slog.WarnContext(ctx, "❌ 工作流中没有用户地址，无法校验交易签名者", "tx_hash", transactionHash)
+		return fmt.Errorf("%w: transaction %s", ErrNoExpectedSigner, transactionHash)
+	}
+
+	// 等待交易确认
+	action := transactionAction(data, taskID)
+	slog.InfoContext(ctx, "⏳ 等待交易确认", "tx_hash", transactionHash, "action", action)
+	receipt, err := n.waitForTransactionConfirmation(ctx, transactionHash, action, n.progressReporter(ctx, taskID))
+	if err != nil {
+		var confirmationErr *ConfirmationError
+		if errors.As(err, &confirmationErr) {
+			confirmationErr.TaskID = taskID
+		}
+		slog.ErrorContext(ctx, "❌ 交易确认失败", "error", err)
+		return fmt.Errorf("transaction confirmation failed: %w", err)
+	}
+	n.trackGasOverride(ctx, data, taskID, transactionHash)
+
+	// 从交易签名恢复签名者，防止提交他人的交易哈希冒充本次签名
+	if n.txConfig.PermissiveSignatures {
+		slog.WarnContext(ctx, "⚠️  签名者校验已关闭 (permissive_signatures)，仅适用于本地模拟流程")
+	} else {
+		signer, err := verifyTransactionSigner(ctx, n.rpcClient, transactionHash, expected)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ 签名者校验失败", "error", err)
+			return err
+		}
+		data["signer_address"] = signer
+		slog.InfoContext(ctx, "✅ 交易签名者", "signer", signer)
+	}
+
+	recordReceivedAmount(n.contractManager, data, taskID, receipt)
+	return nil
+}
+
+// waitForTransactionConfirmation 等待交易确认并返回收据，所需确认数按交易类型 action 选择；
+// progress 不为 nil 时在轮询过程中推送确认进度；未配置RPC客户端时模拟确认，收据为 nil
+func (n *SignatureValidatorNode) waitForTransactionConfirmation(ctx context.Context, txHash, action string, progress func(rpc.ConfirmationProgress)) (*rpc.TransactionReceipt, error) {
+	slog.DebugContext(ctx, "🔍 开始监控交易确认", "tx_hash", txHash)
 
 	// 如果没有RPC客户端，使用模拟确认
 	if n.rpcClient == nil {
-		log.Printf("⚠️  未配置RPC客户端，使用模拟确认")
+		slog.WarnContext(ctx, "⚠️  未配置RPC客户端，使用模拟确认")
 		confirmationTime := 5
-		log.Printf("⏰ 模拟等待交易确认，预计 %d 秒...", confirmationTime)
+		slog.InfoContext(ctx, "⏰ 模拟等待交易确认", "seconds", confirmationTime)
 
 		for i := 1; i <= confirmationTime; i++ {
 			time.Sleep(1 * time.Second)
-			log.Printf("⏳ 模拟确认进度: %d/%d 秒", i, confirmationTime)
+			slog.DebugContext(ctx, "⏳ 模拟确认进度", "elapsed", i, "seconds", confirmationTime)
 		}
 
-		log.Printf("✅ 模拟交易确认完成: %s", txHash)
-		return nil
+		slog.InfoContext(ctx, "✅ 模拟交易确认完成", "tx_hash", txHash)
+		return nil, nil
 	}
 
 	// 使用真实的RPC客户端等待交易确认
-	log.Printf("🌐 使用RPC轮询等待交易确认...")
+	slog.DebugContext(ctx, "🌐 使用RPC轮询等待交易确认")
 
 	// 创建带超时的上下文
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Duration(n.txConfig.ConfirmationTimeout)*time.Second)
 	defer cancel()
 
 	pollingInterval := time.Duration(n.txConfig.PollingInterval) * time.Second
-	requiredConfirmations := n.txConfig.RequiredConfirmations
+	confirmations := requiredConfirmations(n.txConfig, action)
 
 	receipt, err := n.rpcClient.WaitForTransactionConfirmation(
 		ctxWithTimeout,
 		txHash,
-		requiredConfirmations,
+		n.txConfig.ConfirmationStrategy,
+		confirmations,
 		pollingInterval,
+		progress,
 	)
 
 	if err != nil {
-		log.Printf("❌ 交易确认失败: %v", err)
-		return fmt.Errorf("交易确认失败: %w", err)
+		slog.ErrorContext(ctx, "❌ 交易确认失败", "error", err)
+		return nil, newConfirmationError(txHash, err)
 	}
 
 	if !receipt.Success {
-		log.Printf("❌ 交易执行失败: %s", txHash)
-		return fmt.Errorf("交易执行失败")
+		slog.ErrorContext(ctx, "❌ 交易执行失败", "tx_hash", txHash)
+		return nil, newConfirmationError(txHash, fmt.Errorf("%w: %s", rpc.ErrTransactionReverted, txHash))
 	}
 
-	log.Printf("✅ 交易已确认并完成: %s (区块: %s)", txHash, receipt.BlockNumber)
-	log.Printf("🎯 现在可以安全执行依赖任务")
-	return nil
+	slog.InfoContext(ctx, "✅ 交易已确认并完成，可以安全执行依赖任务", "tx_hash", txHash, "block", receipt.BlockNumber)
+	return receipt, nil
 }
 
 // checkDependentTasks 检查是否有依赖当前任务的下一个任务
-func (n *SignatureValidatorNode) checkDependentTasks(data map[string]any, completedTxHash string) []string {
-	log.Printf("🔗 检查依赖任务")
+func (n *SignatureValidatorNode) checkDependentTasks(ctx context.Context, data map[string]any, completedTxHash string) []string {
+	slog.DebugContext(ctx, "🔗 检查依赖任务")
+
+	// 批量签名的每个任务已在验证时记录，直接进入下一轮可执行任务
+	if len(batchTaskIDs(data)) > 0 {
+		delete(data, batchTasksKey)
+		return readyTaskNodes(data)
+	}
 
 	// 检查是否是授权步骤完成
 	for _, task := range data["tasks"].([]map[string]any) {
 		if taskID, exists := task["id"].(string); exists {
 			currentStepKey := taskID + "_current_step"
 			if currentStep, stepExists := data[currentStepKey].(string); stepExists && currentStep == "approve" {
-				log.Printf("✅ 授权步骤完成，标记并返回质押执行节点")
+				slog.InfoContext(ctx, "✅ 授权步骤完成，标记并返回质押执行节点")
 				// 标记授权完成
 				data[taskID+"_approve_completed"] = true
 				data[taskID+"_approve_tx_hash"] = completedTxHash
@@ -983,13 +1547,21 @@ func (n *SignatureValidatorNode) checkDependentTasks(data map[string]any, comple
 				// 返回质押执行节点继续执行实际的质押交易
 				return []string{"stake_executor"}
 			}
+			if currentStep, stepExists := data[currentStepKey].(string); stepExists && currentStep == stepSwapHop {
+				hop := completedSwapHops(data, taskID)
+				slog.InfoContext(ctx, "✅ 多跳兑换一跳完成，返回兑换执行节点", "hop", hop+1)
+				data[swapHopTxKey(taskID, hop)] = completedTxHash
+				delete(data, currentStepKey)
+				delete(data, "current_task_id")
+				return []string{"swap_executor"}
+			}
 		}
 	}
 
 	// 获取任务列表
 	tasks, ok := data["tasks"].([]map[string]any)
 	if !ok {
-		log.Printf("⚠️  没有找到任务列表，转到结果聚合")
+		slog.WarnContext(ctx, "⚠️  没有找到任务列表，转到结果聚合")
 		return []string{"result_aggregator"}
 	}
 
@@ -1003,9 +1575,22 @@ func (n *SignatureValidatorNode) checkDependentTasks(data map[string]any, comple
 		completedTasks = make([]string, 0)
 	}
 
-	// 查找刚完成的任务ID
+	// 查找刚完成的任务ID，优先使用执行节点记录的当前任务
 	var completedTaskID string
+	if currentTaskID, ok := data["current_task_id"].(string); ok && currentTaskID != "" {
+		completedTaskID = currentTaskID
+		delete(data, "current_task_id")
+		for _, completed := range completedTasks {
+			if completed == currentTaskID {
+				completedTaskID = ""
+				break
+			}
+		}
+	}
 	for _, task := range tasks {
+		if completedTaskID != "" {
+			break
+		}
 		if taskID, exists := task["id"].(string); exists {
 			// 检查这个任务是否是刚完成的（没有依赖或依赖已完成）
 			dependencyTxID := task["dependency_tx_id"]
@@ -1031,41 +1616,31 @@ func (n *SignatureValidatorNode) checkDependentTasks(data map[string]any, comple
 		completedTasks = append(completedTasks, completedTaskID)
 		data["completed_tasks"] = completedTasks
 		data[completedTaskID+"_tx_hash"] = completedTxHash
-		log.Printf("✅ 任务 %s 完成，交易哈希: %s", completedTaskID, completedTxHash)
+		slog.InfoContext(ctx, "✅ 任务完成", "task_id", completedTaskID, "tx_hash", completedTxHash)
 	}
 
-	// 查找依赖刚完成任务的下一个任务
-	for _, task := range tasks {
-		if taskID, exists := task["id"].(string); exists {
-			dependencyTxID := task["dependency_tx_id"]
-			if dependencyTxID != nil && dependencyTxID == completedTaskID {
-				// 找到依赖任务
-				log.Printf("🔗 找到依赖任务: %s 依赖于 %s", taskID, completedTaskID)
-
-				// 检查任务类型
-				if taskType, typeExists := task["type"].(string); typeExists {
-					switch taskType {
-					case "swap":
-						log.Printf("➡️  执行依赖的swap任务: %s", taskID)
-						return []string{"swap_executor"}
-					case "stake":
-						log.Printf("➡️  执行依赖的stake任务: %s", taskID)
-						return []string{"stake_executor"}
-					}
-				}
-			}
-		}
+	// 依赖刚完成任务的任务与其余无依赖的任务都可以执行，多个时由图并行执行
+	nextNodes := readyTaskNodes(data)
+	if nextNodes[0] == "result_aggregator" {
+		slog.InfoContext(ctx, "✅ 没有更多依赖任务，转到结果聚合")
+	} else {
+		slog.InfoContext(ctx, "➡️  可执行的后续任务", "next", nextNodes)
 	}
-
-	log.Printf("✅ 没有更多依赖任务，转到结果聚合")
-	return []string{"result_aggregator"}
+	return nextNodes
 }
 
+// StatusNoTasks 任务分解没有得到可执行任务时的结果状态
+const StatusNoTasks = "no_tasks"
+
 // ResultAggregatorNode 结果聚合节点
-type ResultAggregatorNode struct{}
+type ResultAggregatorNode struct {
+	contractManager *contracts.ContractManager
+}
 
-func NewResultAggregatorNode() *ResultAggregatorNode {
-	return &ResultAggregatorNode{}
+func NewResultAggregatorNode(contractManager *contracts.ContractManager) *ResultAggregatorNode {
+	return &ResultAggregatorNode{
+		contractManager: contractManager,
+	}
 }
 
 func (n *ResultAggregatorNode) GetName() string {
@@ -1077,12 +1652,46 @@ func (n *ResultAggregatorNode) GetType() string {
 }
 
 func (n *ResultAggregatorNode) Execute(ctx context.Context, input NodeInput) (*NodeOutput, error) {
-	log.Printf("🔄 结果聚合节点开始执行")
-	log.Printf("📊 输入数据: %+v", input.Data)
+	slog.InfoContext(ctx, "🔄 结果聚合节点开始执行")
+	slog.DebugContext(ctx, "📊 输入数据", "data", input.Data)
+
+	// 没有可执行任务时明确报告，不作为成功的交易结果
+	if tasks, _ := input.Data["tasks"].([]map[string]any); len(tasks) == 0 {
+		slog.WarnContext(ctx, "⚠️  没有可执行的任务")
+		message := "未识别到可执行的任务，请说明要兑换或质押的代币和数量"
+		if clarification, ok := input.Data["clarification"].(string); ok && clarification != "" {
+			message = clarification
+		}
+		return &NodeOutput{
+			Data: map[string]any{
+				"status":       StatusNoTasks,
+				"success":      false,
+				"message":      message,
+				"timestamp":    time.Now(),
+				"workflow_id":  input.Context["workflow_id"],
+				"session_id":   input.Context["session_id"],
+				"tasks":        []map[string]any{},
+				"user_message": input.Data["user_message"],
+			},
+			NextNodes: []string{},
+			Completed: true,
+		}, nil
+	}
+
+	// 预演模式只返回计划交易
+	if isDryRun(input.Data) {
+		slog.InfoContext(ctx, "📝 预演模式，返回交易计划")
+		return &NodeOutput{
+			Data:      dryRunResult(input),
+			NextNodes: []string{},
+			Completed: true,
+		}, nil
+	}
 
 	// 聚合所有执行结果
 	result := map[string]any{
 		"status":       "completed",
+		"success":      true,
 		"timestamp":    time.Now(),
 		"workflow_id":  input.Context["workflow_id"],
 		"session_id":   input.Context["session_id"],
@@ -1092,18 +1701,38 @@ func (n *ResultAggregatorNode) Execute(ctx context.Context, input NodeInput) (*N
 
 	// 检查是否有签名验证结果
 	if signatureVerified, ok := input.Data["signature_verified"].(bool); ok && signatureVerified {
-		log.Printf("✅ 检测到签名验证成功")
+		slog.DebugContext(ctx, "✅ 检测到签名验证成功")
 		result["signature_verified"] = true
 		result["transaction_hash"] = input.Data["transaction_hash"]
 	}
 
 	// 检查是否有交易执行结果
 	if transactionHash, ok := input.Data["transaction_hash"].(string); ok {
-		log.Printf("✅ 检测到交易哈希: %s", transactionHash)
+		slog.DebugContext(ctx, "✅ 检测到交易哈希", "tx_hash", transactionHash)
 		result["transaction_hash"] = transactionHash
 	}
 
-	log.Printf("📊 聚合结果: %+v", result)
+	// 已完成任务与各步骤的交易哈希，签名循环结束后调用方不必再从工作流上下文中读取
+	completedTasks, _ := input.Data["completed_tasks"].([]string)
+	result["completed_tasks"] = append([]string{}, completedTasks...)
+	result["tx_hashes"] = taskTxHashes(input.Data)
+
+	// 任务摘要，数量按代币精度格式化
+	tasks, _ := input.Data["tasks"].([]map[string]any)
+	summaries := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		summaries = append(summaries, taskSummary(n.contractManager, input.Data, task))
+	}
+	result["summary"] = summaries
+
+	// 为每笔交易生成浏览器链接
+	links := n.explorerLinks(input.Data)
+	if len(links) > 0 {
+		result["explorer_links"] = links
+	}
+	result["message"] = n.completionMessage(summaries, links)
+
+	slog.InfoContext(ctx, "📊 聚合结果", "result", result)
 
 	return &NodeOutput{
 		Data:      result,
@@ -1111,3 +1740,57 @@ func (n *ResultAggregatorNode) Execute(ctx context.Context, input NodeInput) (*N
 		Completed: true,
 	}, nil
 }
+
+// explorerLinks 收集工作流中的交易哈希并生成浏览器链接，key 为交易哈希字段名
+func (n *ResultAggregatorNode) explorerLinks(data map[string]any) map[string]string {
+	if n.contractManager == nil {
+		return nil
+	}
+
+	links := make(map[string]string)
+	for key, value := range data {
+		if key != "transaction_hash" && !strings.HasSuffix(key, "_tx_hash") {
+			continue
+		}
+		txHash, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if url := n.contractManager.ExplorerTxURL(txHash); url != "" {
+			links[key] = url
+		}
+	}
+	return links
+}
+
+// completionMessage 生成包含任务摘要与浏览器链接的完成消息，链接按交易哈希去重
+func (n *ResultAggregatorNode) completionMessage(summaries []string, links map[string]string) string {
+	var b strings.Builder
+	b.WriteString("✅ 工作流执行完成")
+	for _, summary := range summaries {
+		b.WriteString("\n- ")
+		b.WriteString(summary)
+	}
+	if len(links) == 0 {
+		return b.String()
+	}
+
+	keys := make([]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("\n交易详情:")
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		url := links[key]
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		b.WriteString("\n- ")
+		b.WriteString(url)
+	}
+	return b.String()
+}
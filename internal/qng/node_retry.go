@@ -0,0 +1,99 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"qng_agent/internal/config"
+	"time"
+)
+
+// defaultNodeRetries 未配置重试策略时使用，只有任务分解节点重试临时性的LLM失败
+var defaultNodeRetries = map[string]config.NodeRetryConfig{
+	"task_decomposer": {MaxAttempts: 3, Backoff: 1000},
+}
+
+// nonRetryableNodes 非幂等节点：签名验证会消费用户签名并等待交易上链，
+// 交易执行节点生成待签名交易，重试可能导致重复提交
+var nonRetryableNodes = map[string]bool{
+	"swap_executor":       true,
+	"stake_executor":      true,
+	"transfer_executor":   true,
+	"parallel_executor":   true,
+	"signature_validator": true,
+}
+
+// maxNodeRetryBackoff 节点重试间隔上限
+const maxNodeRetryBackoff = 10 * time.Second
+
+// transientError 临时性失败，可按节点重试策略重试，错误信息保持不变
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// transient 将错误标记为可重试
+func transient(err error) error {
+	return &transientError{err: err}
+}
+
+// retryable 判断节点错误是否为临时性失败
+func retryable(err error) bool {
+	var transientErr *transientError
+	return errors.As(err, &transientErr) || errors.Is(err, ErrNodeTimeout)
+}
+
+// nodeRetryPolicy 返回节点的重试策略，非幂等节点始终只执行一次
+func (lg *LangGraph) nodeRetryPolicy(name string) (int, time.Duration) {
+	if nonRetryableNodes[name] {
+		return 1, 0
+	}
+
+	retries := lg.graphConfig.Retries
+	if retries == nil {
+		retries = defaultNodeRetries
+	}
+	policy, exists := retries[name]
+	if !exists || policy.MaxAttempts < 1 {
+		return 1, 0
+	}
+
+	backoff := time.Duration(policy.Backoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	return policy.MaxAttempts, backoff
+}
+
+// executeWithRetry 按节点超时执行节点，临时性失败按节点重试策略以指数退避重试
+func (lg *LangGraph) executeWithRetry(ctx context.Context, node Node, input NodeInput) (*NodeOutput, error) {
+	name := node.GetName()
+	attempts, delay := lg.nodeRetryPolicy(name)
+	timeout := lg.nodeTimeout(name)
+
+	for attempt := 1; ; attempt++ {
+		output, err := executeWithTimeout(ctx, node, input, timeout)
+		if err == nil || !retryable(err) || attempt >= attempts || ctx.Err() != nil {
+			return output, err
+		}
+
+		slog.WarnContext(ctx, "🔁 节点执行失败，稍后重试", "attempt", attempt, "max_attempts", attempts, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxNodeRetryBackoff {
+			delay = maxNodeRetryBackoff
+		}
+	}
+}
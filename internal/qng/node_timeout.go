@@ -0,0 +1,68 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrNodeTimeout 节点执行超过配置的超时时间
+var ErrNodeTimeout = errors.New("timed out")
+
+// confirmationTimeoutMargin 签名验证节点在交易确认超时之外预留的时间
+const confirmationTimeoutMargin = 10 * time.Second
+
+// nodeTimeout 返回节点的执行超时，优先使用按节点覆盖的配置，0 表示不限制。
+// 签名验证节点的超时不短于交易确认超时，避免在确认完成前被提前中断。
+func (lg *LangGraph) nodeTimeout(name string) time.Duration {
+	seconds := lg.graphConfig.NodeTimeout
+	if override, exists := lg.graphConfig.NodeTimeouts[name]; exists {
+		seconds = override
+	}
+	timeout := time.Duration(seconds) * time.Second
+
+	if name == "signature_validator" && timeout > 0 {
+		minimum := time.Duration(lg.txConfig.ConfirmationTimeout)*time.Second + confirmationTimeoutMargin
+		if timeout < minimum {
+			timeout = minimum
+		}
+	}
+	return timeout
+}
+
+// executeWithTimeout 在超时时间内执行节点。节点忽略 ctx 时也会按时返回超时错误，
+// 此时节点仍在后台运行直至结束，其结果被丢弃。
+func executeWithTimeout(ctx context.Context, node Node, input NodeInput, timeout time.Duration) (*NodeOutput, error) {
+	if timeout <= 0 {
+		return node.Execute(ctx, input)
+	}
+
+	nodeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		output *NodeOutput
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := node.Execute(nodeCtx, input)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() == nil && errors.Is(nodeCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("node %s %w after %s: %v", node.GetName(), ErrNodeTimeout, timeout, r.err)
+		}
+		return r.output, r.err
+	case <-nodeCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		slog.WarnContext(ctx, "⏰ 节点执行超时", "timeout", timeout)
+		return nil, fmt.Errorf("node %s %w after %s", node.GetName(), ErrNodeTimeout, timeout)
+	}
+}
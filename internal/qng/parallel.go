@@ -0,0 +1,135 @@
+package qng
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"qng_agent/internal/rpc"
+	"strings"
+)
+
+// batchSignatureType 批量签名请求类型，requests 中的每笔交易对应一个互不依赖的任务
+const batchSignatureType = "batch_transaction_signature"
+
+// batchTasksKey 当前批次中等待签名的任务ID，按签名请求顺序排列
+const batchTasksKey = "batch_tasks"
+
+// executorForTask 返回执行任务的节点名称，未知任务类型返回空字符串
+func executorForTask(task map[string]any) string {
+	taskType, _ := task["type"].(string)
+	switch taskType {
+	case "swap":
+		return "swap_executor"
+	case "stake":
+		return "stake_executor"
+	case "transfer":
+		return "transfer_executor"
+	}
+	return ""
+}
+
+// readyTasks 返回未完成、未在当前批次中且依赖已完成的任务，按任务顺序排列
+func readyTasks(data map[string]any) []map[string]any {
+	tasks, _ := data["tasks"].([]map[string]any)
+	completed := make(map[string]bool)
+	if completedTasks, ok := data["completed_tasks"].([]string); ok {
+		for _, taskID := range completedTasks {
+			completed[taskID] = true
+		}
+	}
+
+	var ready []map[string]any
+	for _, task := range tasks {
+		taskID, _ := task["id"].(string)
+		if completed[taskID] || isBatchedTask(data, taskID) {
+			continue
+		}
+		if depID, ok := task["dependency_tx_id"].(string); ok && !completed[depID] {
+			continue
+		}
+		ready = append(ready, task)
+	}
+	return ready
+}
+
+// readyTaskNodes 为每个可执行任务返回对应的执行节点，没有可执行任务时转到结果聚合
+func readyTaskNodes(data map[string]any) []string {
+	var nodes []string
+	for _, task := range readyTasks(data) {
+		if node := executorForTask(task); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return []string{"result_aggregator"}
+	}
+	return nodes
+}
+
+// batchTaskIDs 返回当前批次的任务ID
+func batchTaskIDs(data map[string]any) []string {
+	batch, _ := data[batchTasksKey].([]string)
+	return batch
+}
+
+// isBatchedTask 检查任务是否已在当前批次中等待签名，执行节点查找任务时跳过这些任务
+func isBatchedTask(data map[string]any, taskID string) bool {
+	for _, batched := range batchTaskIDs(data) {
+		if batched == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// splitBatchSignature 拆分批量签名提交的交易哈希，多个哈希以逗号分隔并按签名请求顺序排列
+func splitBatchSignature(signature string) []string {
+	var hashes []string
+	for _, hash := range strings.Split(signature, ",") {
+		if hash = strings.TrimSpace(hash); hash != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// completeTaskStep 记录任务当前步骤的交易哈希：授权或多跳兑换中间跳完成后任务继续执行，
+// 否则标记任务完成
+func completeTaskStep(data map[string]any, taskID, txHash string) {
+	stepKey := taskID + "_current_step"
+	switch step, _ := data[stepKey].(string); step {
+	case "approve":
+		data[taskID+"_approve_completed"] = true
+		data[taskID+"_approve_tx_hash"] = txHash
+		log.Printf("✅ 任务 %s 授权步骤完成", taskID)
+	case stepSwapHop:
+		hop := completedSwapHops(data, taskID)
+		data[swapHopTxKey(taskID, hop)] = txHash
+		log.Printf("✅ 任务 %s 多跳兑换第 %d 跳完成", taskID, hop+1)
+	default:
+		completed, _ := data["completed_tasks"].([]string)
+		data["completed_tasks"] = append(completed, taskID)
+		data[taskID+"_tx_hash"] = txHash
+		log.Printf("✅ 任务 %s 完成，交易哈希: %s", taskID, txHash)
+	}
+	delete(data, stepKey)
+}
+
+// signBatch 依次签名并广播批量请求中的每笔交易，返回逗号分隔的交易哈希。
+// 每笔交易按 pending nonce 签名，广播顺序即 nonce 顺序
+func (s *Signer) signBatch(ctx context.Context, rpcClient *rpc.Client, request map[string]any) (string, error) {
+	requests, _ := request["requests"].([]any)
+	if len(requests) == 0 {
+		return "", fmt.Errorf("batch signature request has no transactions")
+	}
+
+	hashes := make([]string, 0, len(requests))
+	for i, item := range requests {
+		txHash, err := s.SignAndSend(ctx, rpcClient, item)
+		if err != nil {
+			return "", fmt.Errorf("batch transaction %d: %w", i+1, err)
+		}
+		hashes = append(hashes, txHash)
+	}
+	return strings.Join(hashes, ","), nil
+}
@@ -0,0 +1,90 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"qng_agent/internal/llm"
+)
+
+// 任务分解结果无法解析时的处理策略
+const (
+	// ParseFailureFallback 使用规则从用户消息中解析任务
+	ParseFailureFallback = "fallback"
+	// ParseFailureReprompt 要求LLM重新只返回严格的JSON，仍无法解析时使用规则解析
+	ParseFailureReprompt = "reprompt"
+	// ParseFailureClarify 不猜测任务，返回澄清请求让用户补充说明
+	ParseFailureClarify = "clarify"
+)
+
+// errNeedsClarification LLM回复无法解析且策略为 clarify，任务分解节点据此返回澄清请求
+var errNeedsClarification = errors.New("task decomposition needs clarification")
+
+// clarificationMessage 请用户澄清请求时返回的消息
+const clarificationMessage = "未能理解您的请求，请说明要执行的操作（兑换、质押或转账）、代币和数量，例如\"兑换 10 MEER 为 MTK\""
+
+// maxCorrectiveRetries 回复无法解析时最多追加的纠正请求次数，纠正请求的回复仍无法解析时不再继续请求
+const maxCorrectiveRetries = 1
+
+// strictJSONPrompt 纠正请求的提示，要求LLM只返回任务JSON
+const strictJSONPrompt = `你上一次的回复不是有效的JSON。请只返回JSON，格式如下，不要包含解释、Markdown代码块或其它文字：
+{"tasks": [{"id": "task_1", "type": "swap", "from_token": "MEER", "to_token": "MTK", "amount": "10", "dependency_tx_id": null, "description": "兑换10 MEER为MTK"}]}`
+
+// parseFailureStrategy 规范化配置的解析失败策略，未配置或无法识别时使用 reprompt
+func parseFailureStrategy(strategy string) string {
+	switch strategy {
+	case ParseFailureFallback, ParseFailureReprompt, ParseFailureClarify:
+		return strategy
+	case "":
+		return ParseFailureReprompt
+	default:
+		log.Printf("⚠️  未知的任务解析失败策略 %q，使用 %s", strategy, ParseFailureReprompt)
+		return ParseFailureReprompt
+	}
+}
+
+// handleParseFailure 按策略处理无法解析的LLM回复：重新请求严格JSON、请用户澄清，或使用规则解析用户消息
+func (n *TaskDecomposerNode) handleParseFailure(ctx context.Context, messages []llm.Message, response, userMessage string) ([]map[string]any, error) {
+	switch n.parseFailure {
+	case ParseFailureClarify:
+		log.Printf("❓ LLM回复无法解析为任务，请用户澄清请求")
+		return nil, errNeedsClarification
+	case ParseFailureReprompt:
+		if tasks, ok := n.correctiveRetry(ctx, messages, response); ok {
+			return tasks, nil
+		}
+	}
+
+	log.Printf("🔄 使用原始用户输入进行备用解析")
+	log.Printf("📝 原始用户输入: %s", userMessage)
+	return n.fallbackParseFromText(ctx, userMessage), nil
+}
+
+// correctiveRetry 把无法解析的回复与纠正提示追加到对话中重新请求LLM，最多 maxCorrectiveRetries 次，
+// 纠正请求本身不会再触发解析失败处理，避免循环请求
+func (n *TaskDecomposerNode) correctiveRetry(ctx context.Context, messages []llm.Message, response string) ([]map[string]any, bool) {
+	conversation := append([]llm.Message(nil), messages...)
+	for attempt := 1; attempt <= maxCorrectiveRetries; attempt++ {
+		log.Printf("🔁 LLM回复不是有效的任务JSON，触发纠正请求 (%d/%d)", attempt, maxCorrectiveRetries)
+		conversation = append(conversation,
+			llm.Message{Role: "assistant", Content: response},
+			llm.Message{Role: "user", Content: strictJSONPrompt},
+		)
+
+		retried, err := n.llmClient.Chat(ctx, conversation, n.chatOptions...)
+		if err != nil {
+			log.Printf("⚠️  纠正请求失败: %v", err)
+			return nil, false
+		}
+		log.Printf("📄 纠正请求的LLM响应: %s", retried)
+		if tasks, ok := n.parseTasksFromResponse(ctx, retried); ok {
+			log.Printf("✅ 纠正请求得到有效的任务JSON")
+			return tasks, true
+		}
+		response = retried
+	}
+
+	log.Printf("⚠️  纠正请求后仍无法解析任务")
+	return nil, false
+}
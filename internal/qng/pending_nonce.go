@@ -0,0 +1,39 @@
+package qng
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"qng_agent/internal/rpc"
+)
+
+// applyPendingNonceCheck 检查用户账户是否有占用 nonce 的待确认交易。新交易的 nonce 排在这些交易之后，
+// 前面的交易卡住时新交易也无法上链，因此在签名请求中记录 nonce_gap 并追加警告。
+// 不知道用户地址或读取 nonce 失败时跳过检查
+func applyPendingNonceCheck(ctx context.Context, rpcClient *rpc.Client, data map[string]any, authRequest map[string]any) {
+	address, _ := data["user_address"].(string)
+	if address == "" || rpcClient == nil {
+		return
+	}
+
+	gap, err := rpcClient.GetNonceGap(ctx, address)
+	if err != nil {
+		log.Printf("⚠️  读取账户 nonce 失败，跳过待确认交易检查: %v", err)
+		return
+	}
+	if gap.PendingCount() == 0 {
+		return
+	}
+
+	log.Printf("⏳ 账户 %s 有 %d 笔待确认交易 (nonce %d-%d)", address, gap.PendingCount(), gap.Latest, gap.Pending-1)
+	authRequest["nonce_gap"] = gap
+	addWarning(authRequest, pendingNonceWarning(gap))
+}
+
+// pendingNonceWarning 生成待确认交易的警告文本
+func pendingNonceWarning(gap *rpc.NonceGap) string {
+	return fmt.Sprintf("账户有 %d 笔待确认交易（nonce %d-%d），本交易会排在其后上链；"+
+		"若待确认交易长时间未确认，请先等待或使用 speed_up_transaction/cancel_transaction 处理",
+		gap.PendingCount(), gap.Latest, gap.Pending-1)
+}
@@ -0,0 +1,119 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"qng_agent/internal/contracts"
+	"strings"
+	"time"
+)
+
+// ErrTransactionNotPending 交易已上链或不存在，无法取消或加速
+var ErrTransactionNotPending = errors.New("transaction is not pending")
+
+// Replacement 取消或加速待确认交易的替换交易
+type Replacement struct {
+	Kind           string `json:"kind"`
+	OriginalTxHash string `json:"original_tx_hash"`
+	// TxHash 替换交易的哈希，服务端签名器已签名广播时设置，否则等待用户签名
+	TxHash string `json:"tx_hash,omitempty"`
+	// SignatureRequest 需要用户签名的替换交易，格式与工作流节点的签名请求相同
+	SignatureRequest map[string]any `json:"-"`
+}
+
+// ReplaceTransaction 为卡住的交易构建相同 nonce、更高费用的替换交易。
+// 原交易由服务端签名器发送时直接签名并广播替换交易，否则返回签名请求
+func (c *Chain) ReplaceTransaction(ctx context.Context, txHash, kind string) (*Replacement, error) {
+	log.Printf("🔄 替换交易: %s (%s)", txHash, kind)
+
+	if c.rpcClient == nil {
+		return nil, fmt.Errorf("rpc client not configured")
+	}
+	if !txHashPattern.MatchString(txHash) {
+		return nil, fmt.Errorf("%w: %q is not a transaction hash", ErrInvalidSignature, txHash)
+	}
+
+	receipt, err := c.rpcClient.GetTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt for %s: %w", txHash, err)
+	}
+	if receipt != nil {
+		return nil, fmt.Errorf("%w: %s was mined in block %s", ErrTransactionNotPending, txHash, receipt.BlockNumber)
+	}
+	tx, err := c.rpcClient.GetTransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction %s: %w", txHash, err)
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("%w: %s not found", ErrTransactionNotPending, txHash)
+	}
+
+	txData, err := c.contractManager.BuildReplacementTransaction(kind, tx.From, &contracts.TransactionData{
+		To:                   tx.To,
+		Value:                tx.Value,
+		Data:                 tx.Input,
+		GasLimit:             tx.Gas,
+		GasPrice:             tx.GasPrice,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Nonce:                tx.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	title, description := "加速交易", fmt.Sprintf("以更高的费用重新发送交易 %s", txHash)
+	if kind == contracts.ReplaceCancel {
+		title, description = "取消交易", fmt.Sprintf("发送 0 值交易取消交易 %s", txHash)
+	}
+	replacement := &Replacement{
+		Kind:           kind,
+		OriginalTxHash: txHash,
+		SignatureRequest: map[string]any{
+			"type":        "transaction_signature",
+			"action":      kind,
+			"replaces":    txHash,
+			"gas_fee":     c.contractManager.FormatGasFee(txData),
+			"title":       title,
+			"description": description,
+			"to_address":  txData.To,
+			"value":       txData.Value,
+			"data":        txData.Data,
+			"gas_limit":   txData.GasLimit,
+			"gas_price":   txData.GasPrice,
+			"nonce":       txData.Nonce,
+			// EIP-1559 网络上设置，此时 gas_price 为空
+			"max_fee_per_gas":          txData.MaxFeePerGas,
+			"max_priority_fee_per_gas": txData.MaxPriorityFeePerGas,
+		},
+	}
+
+	if c.signer != nil && strings.EqualFold(c.signer.Address(), tx.From) {
+		log.Printf("✍️  使用服务端签名器签名替换交易")
+		replacement.TxHash, err = c.signer.SignAndSend(ctx, c.rpcClient, replacement.SignatureRequest)
+		if err != nil {
+			return nil, fmt.Errorf("server-side signing failed: %w", err)
+		}
+	}
+	return replacement, nil
+}
+
+// ConfirmTransaction 按交易配置等待不属于工作流任务的交易（如取消交易）确认
+func (c *Chain) ConfirmTransaction(ctx context.Context, txHash string) error {
+	if c.rpcClient == nil {
+		return fmt.Errorf("rpc client not configured")
+	}
+
+	txConfig := c.config.Chain.Transaction
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(txConfig.ConfirmationTimeout)*time.Second)
+	defer cancel()
+
+	_, err := c.rpcClient.WaitForTransactionConfirmation(ctx, txHash, txConfig.ConfirmationStrategy,
+		txConfig.RequiredConfirmations, time.Duration(txConfig.PollingInterval)*time.Second, nil)
+	if err != nil {
+		return newConfirmationError(txHash, err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package qng
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// TaskProgress 工作流任务执行进度，可随会话持久化并用于恢复执行
+type TaskProgress struct {
+	UserMessage    string            `json:"user_message,omitempty"`
+	Tasks          []map[string]any  `json:"tasks,omitempty"`
+	CompletedTasks []string          `json:"completed_tasks,omitempty"`
+	TxHashes       map[string]string `json:"tx_hashes,omitempty"`
+	// UserID 与 SpentAmounts 用于恢复后继续累计同一工作流的支出
+	UserID       string             `json:"user_id,omitempty"`
+	SpentAmounts map[string]float64 `json:"spent_amounts,omitempty"`
+	// UserAddress 用户钱包地址，恢复后继续用于余额检查
+	UserAddress string `json:"user_address,omitempty"`
+	// ReadAccount 未提供钱包地址时只读查询使用的账户
+	ReadAccount string `json:"read_account,omitempty"`
+	// ManualConfirmation 工作流被标记为需要手动确认，恢复后仍禁止自动签名
+	ManualConfirmation bool `json:"manual_confirmation,omitempty"`
+	// OutputAmounts 已记录的任务输出数量，恢复后依赖任务的 all_from_previous 仍可使用
+	OutputAmounts map[string]string `json:"output_amounts,omitempty"`
+}
+
+// ExtractTaskProgress 从工作流上下文中提取任务进度，上下文无效时返回nil
+func ExtractTaskProgress(workflowContext any) *TaskProgress {
+	wc, err := RestoreWorkflowContext(workflowContext)
+	if err != nil {
+		return nil
+	}
+
+	data := wc.Data
+	tasks, ok := data["tasks"].([]map[string]any)
+	if !ok || len(tasks) == 0 {
+		return nil
+	}
+
+	progress := &TaskProgress{
+		Tasks:    tasks,
+		TxHashes: make(map[string]string),
+	}
+	if userMessage, ok := data["user_message"].(string); ok {
+		progress.UserMessage = userMessage
+	}
+	if completed, ok := data["completed_tasks"].([]string); ok {
+		progress.CompletedTasks = append([]string(nil), completed...)
+	}
+	if userID, ok := data["user_id"].(string); ok {
+		progress.UserID = userID
+	}
+	if userAddress, ok := data["user_address"].(string); ok {
+		progress.UserAddress = userAddress
+	}
+	progress.ReadAccount, _ = data["read_account"].(string)
+	progress.ManualConfirmation, _ = data["manual_confirmation"].(bool)
+	if spent, ok := data["spent_amounts"].(map[string]float64); ok {
+		progress.SpentAmounts = make(map[string]float64, len(spent))
+		for token, amount := range spent {
+			progress.SpentAmounts[token] = amount
+		}
+	}
+	for key, value := range data {
+		if taskID, ok := strings.CutSuffix(key, "_output_amount"); ok {
+			if amount, ok := value.(string); ok && amount != "" {
+				if progress.OutputAmounts == nil {
+					progress.OutputAmounts = make(map[string]string)
+				}
+				progress.OutputAmounts[taskID] = amount
+			}
+			continue
+		}
+		if !strings.HasSuffix(key, "_tx_hash") {
+			continue
+		}
+		if txHash, ok := value.(string); ok {
+			progress.TxHashes[key] = txHash
+		}
+	}
+
+	return progress
+}
+
+// IsCompleted 检查任务是否已完成
+func (p *TaskProgress) IsCompleted(taskID string) bool {
+	for _, completed := range p.CompletedTasks {
+		if completed == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// nextNode 返回第一个未完成且依赖已满足的任务对应的执行节点
+func (p *TaskProgress) nextNode() (string, error) {
+	pending := 0
+	for _, task := range p.Tasks {
+		taskID, _ := task["id"].(string)
+		if p.IsCompleted(taskID) {
+			continue
+		}
+		pending++
+
+		if depID, ok := task["dependency_tx_id"].(string); ok && !p.IsCompleted(depID) {
+			continue
+		}
+
+		taskType, _ := task["type"].(string)
+		switch taskType {
+		case "swap":
+			return "swap_executor", nil
+		case "stake":
+			return "stake_executor", nil
+		case "transfer":
+			return "transfer_executor", nil
+		default:
+			return "", fmt.Errorf("unsupported task type %q for task %s", taskType, taskID)
+		}
+	}
+
+	if pending == 0 {
+		return "result_aggregator", nil
+	}
+	return "", fmt.Errorf("no resumable task found, %d task(s) blocked by dependencies", pending)
+}
+
+// ResumeWorkflow 从已记录的任务进度恢复工作流，仅执行未完成的任务
+func (lg *LangGraph) ResumeWorkflow(ctx context.Context, progress *TaskProgress) (*ProcessResult, error) {
+	log.Printf("🔄 从任务进度恢复工作流")
+
+	if progress == nil || len(progress.Tasks) == 0 {
+		return nil, fmt.Errorf("no task progress to resume")
+	}
+
+	log.Printf("📋 已完成任务: %v", progress.CompletedTasks)
+
+	nextNode, err := progress.nextNode()
+	if err != nil {
+		log.Printf("❌ 无法确定恢复节点: %v", err)
+		return nil, err
+	}
+
+	data := map[string]any{
+		"user_message":        progress.UserMessage,
+		"timestamp":           time.Now(),
+		"tasks":               progress.Tasks,
+		"completed_tasks":     append([]string(nil), progress.CompletedTasks...),
+		"resumed":             true,
+		"user_id":             progress.UserID,
+		"user_address":        progress.UserAddress,
+		"read_account":        progress.ReadAccount,
+		"manual_confirmation": progress.ManualConfirmation,
+	}
+	if len(progress.SpentAmounts) > 0 {
+		spent := make(map[string]float64, len(progress.SpentAmounts))
+		for token, amount := range progress.SpentAmounts {
+			spent[token] = amount
+		}
+		data["spent_amounts"] = spent
+	}
+	for taskID, amount := range progress.OutputAmounts {
+		data[outputAmountKey(taskID)] = amount
+	}
+	for key, txHash := range progress.TxHashes {
+		data[key] = txHash
+		// 已有授权交易哈希说明授权步骤已确认，恢复时跳过授权
+		if taskID, ok := strings.CutSuffix(key, "_approve_tx_hash"); ok {
+			data[taskID+"_approve_completed"] = true
+		}
+	}
+
+	input := &NodeInput{
+		Data: data,
+		Context: map[string]any{
+			"workflow_id": ctx.Value("workflow_id"),
+			"session_id":  ctx.Value("session_id"),
+		},
+	}
+
+	log.Printf("➡️  从节点恢复执行: %s", nextNode)
+	result, err := lg.invoke(ctx, nextNode, input)
+	if err != nil {
+		log.Printf("❌ 恢复执行失败: %v", err)
+		return nil, err
+	}
+
+	log.Printf("✅ 恢复执行成功")
+	return result, nil
+}
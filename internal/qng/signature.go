@@ -0,0 +1,242 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"qng_agent/internal/rpc"
+	"regexp"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+var (
+	// ErrInvalidSignature 提交的交易哈希格式错误或交易签名无法恢复签名者
+	ErrInvalidSignature = errors.New("invalid transaction signature")
+	// ErrSignerMismatch 交易签名者与工作流中的用户地址不一致
+	ErrSignerMismatch = errors.New("transaction signer does not match user address")
+	// ErrNoExpectedSigner 工作流中没有用户地址，无法确认交易由本次会话的用户签名
+	ErrNoExpectedSigner = errors.New("no user address to verify the transaction signer against")
+)
+
+// txHashPattern 钱包签名广播后返回的交易哈希格式
+var txHashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// verifyTransactionSigner 查询链上交易，由交易签名恢复签名者地址并与期望的用户地址比对，返回签名者地址。
+// 期望地址为空时拒绝，任何人的交易哈希都能通过只校验签名本身的检查
+func verifyTransactionSigner(ctx context.Context, rpcClient *rpc.Client, txHash, expected string) (string, error) {
+	if expected == "" {
+		return "", fmt.Errorf("%w: transaction %s", ErrNoExpectedSigner, txHash)
+	}
+	if !txHashPattern.MatchString(txHash) {
+		return "", fmt.Errorf("%w: %q is not a transaction hash", ErrInvalidSignature, txHash)
+	}
+	if rpcClient == nil {
+		return "", fmt.Errorf("%w: signer verification requires an RPC client", ErrInvalidSignature)
+	}
+
+	tx, err := rpcClient.GetTransactionByHash(ctx, txHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transaction %s: %w", txHash, err)
+	}
+	if tx == nil {
+		return "", fmt.Errorf("%w: transaction %s not found", ErrInvalidSignature, txHash)
+	}
+
+	signer, err := recoverTransactionSender(tx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !strings.EqualFold(signer, expected) {
+		return signer, fmt.Errorf("%w: transaction %s was signed by %s, expected %s", ErrSignerMismatch, txHash, signer, expected)
+	}
+	return signer, nil
+}
+
+// recoverTransactionSender 按交易类型重建签名哈希，并从 v/r/s 恢复签名者地址。
+// 支持传统交易（含 EIP-155）、EIP-2930 与 EIP-1559 交易
+func recoverTransactionSender(tx *rpc.Transaction) (string, error) {
+	nums, err := hexBigs(tx.Nonce, tx.Gas, tx.Value, tx.V, tx.R, tx.S)
+	if err != nil {
+		return "", err
+	}
+	nonce, gas, value, v, r, s := nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]
+
+	to, err := hexBytes(tx.To)
+	if err != nil {
+		return "", fmt.Errorf("invalid to: %w", err)
+	}
+	input, err := hexBytes(tx.Input)
+	if err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+
+	var signingHash []byte
+	var recoveryID int64
+	switch txType := strings.ToLower(tx.Type); txType {
+	case "", "0x0", "0x00":
+		gasPrice, err := hexBig(tx.GasPrice)
+		if err != nil {
+			return "", fmt.Errorf("invalid gasPrice: %w", err)
+		}
+		fields := [][]byte{rlpInt(nonce), rlpInt(gasPrice), rlpInt(gas), rlpBytes(to), rlpInt(value), rlpBytes(input)}
+		switch {
+		case v.Cmp(big.NewInt(35)) >= 0:
+			// EIP-155: v = chainID*2 + 35 + recoveryID
+			offset := new(big.Int).Sub(v, big.NewInt(35))
+			chainID := new(big.Int).Rsh(offset, 1)
+			recoveryID = int64(offset.Bit(0))
+			fields = append(fields, rlpInt(chainID), rlpInt(new(big.Int)), rlpInt(new(big.Int)))
+		case v.Cmp(big.NewInt(27)) == 0 || v.Cmp(big.NewInt(28)) == 0:
+			recoveryID = v.Int64() - 27
+		default:
+			return "", fmt.Errorf("unexpected v value %s", v)
+		}
+		signingHash = keccak256(rlpList(fields...))
+
+	case "0x1", "0x01", "0x2", "0x02":
+		accessList, err := rlpAccessList(tx.AccessList)
+		if err != nil {
+			return "", err
+		}
+		if v.Cmp(big.NewInt(1)) > 0 {
+			return "", fmt.Errorf("unexpected yParity %s", v)
+		}
+		recoveryID = v.Int64()
+
+		chainID, err := hexBig(tx.ChainID)
+		if err != nil {
+			return "", fmt.Errorf("invalid chainId: %w", err)
+		}
+		// EIP-2930: 0x01 || rlp([chainId, nonce, gasPrice, gas, to, value, input, accessList])
+		// EIP-1559: 0x02 || rlp([chainId, nonce, maxPriorityFee, maxFee, gas, to, value, input, accessList])
+		fields := [][]byte{rlpInt(chainID), rlpInt(nonce)}
+		feeFields := []string{tx.GasPrice}
+		if txType == "0x2" || txType == "0x02" {
+			feeFields = []string{tx.MaxPriorityFeePerGas, tx.MaxFeePerGas}
+		}
+		fees, err := hexBigs(feeFields...)
+		if err != nil {
+			return "", err
+		}
+		for _, fee := range fees {
+			fields = append(fields, rlpInt(fee))
+		}
+		fields = append(fields, rlpInt(gas), rlpBytes(to), rlpInt(value), rlpBytes(input), accessList)
+
+		prefix := byte(0x01)
+		if len(fees) == 2 {
+			prefix = 0x02
+		}
+		signingHash = keccak256(append([]byte{prefix}, rlpList(fields...)...))
+
+	default:
+		return "", fmt.Errorf("unsupported transaction type %s", tx.Type)
+	}
+
+	if r.BitLen() > 256 || s.BitLen() > 256 {
+		return "", fmt.Errorf("signature values out of range")
+	}
+
+	// 紧凑签名格式: [27 + recoveryID] || R || S
+	compact := make([]byte, 65)
+	compact[0] = byte(27 + recoveryID)
+	r.FillBytes(compact[1:33])
+	s.FillBytes(compact[33:65])
+	pub, _, err := ecdsa.RecoverCompact(compact, signingHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return pubkeyToAddress(pub), nil
+}
+
+// rlpAccessList 按 RPC 返回的访问列表 [{address, storageKeys}] 编码为 rlp([[address, [key, ...]], ...])
+func rlpAccessList(list []any) ([]byte, error) {
+	entries := make([][]byte, 0, len(list))
+	for _, item := range list {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid access list entry: %v", item)
+		}
+		address, _ := entry["address"].(string)
+		addressBytes, err := hexBytes(address)
+		if err != nil || len(addressBytes) != 20 {
+			return nil, fmt.Errorf("invalid access list address: %v", entry["address"])
+		}
+		rawKeys, _ := entry["storageKeys"].([]any)
+		keys := make([][]byte, 0, len(rawKeys))
+		for _, rawKey := range rawKeys {
+			key, _ := rawKey.(string)
+			keyBytes, err := hexBytes(key)
+			if err != nil || len(keyBytes) != 32 {
+				return nil, fmt.Errorf("invalid access list storage key: %v", rawKey)
+			}
+			keys = append(keys, rlpBytes(keyBytes))
+		}
+		entries = append(entries, rlpList(rlpBytes(addressBytes), rlpList(keys...)))
+	}
+	return rlpList(entries...), nil
+}
+
+// hexBig 解析十六进制数值，空字符串视为 0
+func hexBig(raw string) (*big.Int, error) {
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "0x"), "0X")
+	if raw == "" {
+		return new(big.Int), nil
+	}
+	n, ok := new(big.Int).SetString(raw, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex number %q", raw)
+	}
+	return n, nil
+}
+
+// hexBigs 依次解析多个十六进制数值
+func hexBigs(raws ...string) ([]*big.Int, error) {
+	nums := make([]*big.Int, len(raws))
+	for i, raw := range raws {
+		n, err := hexBig(raw)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// hexBytes 解码十六进制字节串，空字符串（如合约创建交易的 to）返回空切片
+func hexBytes(raw string) ([]byte, error) {
+	return decodeHexField(map[string]any{"value": raw}, "value")
+}
+
+// RecoverPersonalSigner 从 personal_sign（EIP-191）签名恢复签名者地址，签名为 65 字节 r || s || v 的十六进制编码
+func RecoverPersonalSigner(message, signature string) (string, error) {
+	sig, err := hexBytes(signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if len(sig) != 65 {
+		return "", fmt.Errorf("%w: expected 65 bytes, got %d", ErrInvalidSignature, len(sig))
+	}
+
+	// 钱包返回的 v 为 27/28，部分实现为 0/1
+	recoveryID := sig[64]
+	if recoveryID >= 27 {
+		recoveryID -= 27
+	}
+	if recoveryID > 1 {
+		return "", fmt.Errorf("%w: unexpected v value %d", ErrInvalidSignature, sig[64])
+	}
+
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	compact := make([]byte, 65)
+	compact[0] = 27 + recoveryID
+	copy(compact[1:], sig[:64])
+	pub, _, err := ecdsa.RecoverCompact(compact, keccak256([]byte(prefixed)))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to recover signer: %v", ErrInvalidSignature, err)
+	}
+	return pubkeyToAddress(pub), nil
+}
@@ -0,0 +1,29 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxSignatureRounds 未配置 max_signature_rounds 时每个工作流允许的签名轮数
+const DefaultMaxSignatureRounds = 10
+
+// ErrTooManySignatureRounds 工作流请求的签名轮数超过上限，通常说明节点陷入了循环
+var ErrTooManySignatureRounds = errors.New("too many signature rounds")
+
+// MaxSignatureRounds 返回每个工作流允许的签名轮数
+func MaxSignatureRounds(maxRounds int) int {
+	if maxRounds > 0 {
+		return maxRounds
+	}
+	return DefaultMaxSignatureRounds
+}
+
+// CheckSignatureRounds 检查第 round 轮签名是否超过上限
+func CheckSignatureRounds(round, maxRounds int) error {
+	limit := MaxSignatureRounds(maxRounds)
+	if round > limit {
+		return fmt.Errorf("%w: workflow requested signature round %d, limit is %d", ErrTooManySignatureRounds, round, limit)
+	}
+	return nil
+}
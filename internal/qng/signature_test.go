@@ -0,0 +1,215 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"qng_agent/internal/config"
+	"qng_agent/internal/rpc"
+)
+
+// eip155Address EIP-155 规范示例私钥 0x4646...46 对应的地址
+const eip155Address = "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"
+
+// testAccessList 访问列表: 0x3535...35 的存储槽 0 与 1
+var testAccessList = []any{
+	map[string]any{
+		"address": "0x" + strings.Repeat("35", 20),
+		"storageKeys": []any{
+			"0x" + strings.Repeat("00", 32),
+			"0x" + strings.Repeat("00", 31) + "01",
+		},
+	},
+}
+
+func TestRecoverTransactionSender(t *testing.T) {
+	to := "0x" + strings.Repeat("35", 20)
+	tests := []struct {
+		name string
+		tx   rpc.Transaction
+	}{
+		{
+			// EIP-155 规范中的示例交易
+			name: "legacy EIP-155",
+			tx: rpc.Transaction{
+				Type: "0x0", Nonce: "0x9", GasPrice: "0x4a817c800", Gas: "0x5208", To: to, Value: "0xde0b6b3a7640000", Input: "0x",
+				V: "0x25",
+				R: "0x28ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276",
+				S: "0x67cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83",
+			},
+		},
+		{
+			name: "legacy without chain id",
+			tx: rpc.Transaction{
+				Nonce: "0x9", GasPrice: "0x4a817c800", Gas: "0x5208", To: to, Value: "0xde0b6b3a7640000", Input: "0x",
+				V: "0x1b",
+				R: "0x8383adc8b8ae116f918fb44ca7ff9dfd8012596a5c130c6246a2cc717ba41cda",
+				S: "0x53ddfacf5bd4aa7e46d1575acf52636ea659b91f29e2fb91c75567a279738f38",
+			},
+		},
+		{
+			name: "EIP-2930 with access list",
+			tx: rpc.Transaction{
+				Type: "0x1", ChainID: "0x1", Nonce: "0x3", GasPrice: "0x4a817c800", Gas: "0xc350", To: to, Value: "0xde0b6b3a7640000",
+				Input: "0xdeadbeef", AccessList: testAccessList,
+				V: "0x0",
+				R: "0xcc612f440dfc050801159963bec6a1cec7fb895744ec7cf900996085da3749af",
+				S: "0x2f333838f05e5e0336cf4ac6f8bc4c77b273247011796d7c170cd0d3c07974de",
+			},
+		},
+		{
+			// 与 TestSignDynamicFeeTxVector 中服务端签名的交易相同
+			name: "EIP-1559",
+			tx: rpc.Transaction{
+				Type: "0x2", ChainID: "0x1", Nonce: "0x0", MaxPriorityFeePerGas: "0x3b9aca00", MaxFeePerGas: "0x4a817c800", Gas: "0x5208",
+				To: to, Value: "0xde0b6b3a7640000", Input: "0x",
+				V: "0x1",
+				R: "0xb3d7e5d4775918a0ec38e4f9da6263f69c2072c0e177ff9aa274575bfba17d04",
+				S: "0x62182875ae92e4de08aaf8ea1a43d3ea0d836745788801cdc79ccc473a76dfd9",
+			},
+		},
+		{
+			name: "EIP-1559 with access list",
+			tx: rpc.Transaction{
+				Type: "0x2", ChainID: "0x1", Nonce: "0x3", MaxPriorityFeePerGas: "0x3b9aca00", MaxFeePerGas: "0x4a817c800", Gas: "0xc350",
+				To: to, Value: "0xde0b6b3a7640000", Input: "0xdeadbeef", AccessList: testAccessList,
+				V: "0x0",
+				R: "0x02805e45fda107dcd2ee2f60b70db4447f21c3f64863638c2ccb3e0cb70861c4",
+				S: "0x118e3954b30ed8860942df0096eae716187b80f4d7c7a1cfecb3aab070ac228b",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := tt.tx
+			got, err := recoverTransactionSender(&tx)
+			if err != nil {
+				t.Fatalf("recoverTransactionSender: %v", err)
+			}
+			if got != eip155Address {
+				t.Errorf("sender = %s, want %s", got, eip155Address)
+			}
+
+			// 篡改任一签名字段后不应再恢复出原签名者
+			tx.Value = "0xde0b6b3a7640001"
+			if tampered, err := recoverTransactionSender(&tx); err == nil && tampered == eip155Address {
+				t.Errorf("tampered transaction still recovers %s", tampered)
+			}
+		})
+	}
+}
+
+func TestRecoverTransactionSenderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   rpc.Transaction
+	}{
+		{name: "unsupported type", tx: rpc.Transaction{Type: "0x3", V: "0x0", R: "0x1", S: "0x1"}},
+		{name: "invalid yParity", tx: rpc.Transaction{Type: "0x2", ChainID: "0x1", V: "0x2", R: "0x1", S: "0x1"}},
+		{name: "invalid legacy v", tx: rpc.Transaction{V: "0x1d", R: "0x1", S: "0x1"}},
+		{name: "invalid access list", tx: rpc.Transaction{Type: "0x1", ChainID: "0x1", V: "0x0", R: "0x1", S: "0x1", AccessList: []any{"0x35"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := recoverTransactionSender(&tt.tx); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestRecoverPersonalSigner 使用 web3.js 文档中 accounts.sign("Some data", 0x4c08...2318) 的签名
+func TestRecoverPersonalSigner(t *testing.T) {
+	const (
+		signature = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+		signer    = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+	)
+
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		want      string
+		wantErr   bool
+	}{
+		{name: "v 27/28", message: "Some data", signature: signature, want: signer},
+		{name: "v 0/1", message: "Some data", signature: signature[:len(signature)-2] + "01", want: signer},
+		{name: "different message", message: "Some other data", signature: signature},
+		{name: "short signature", message: "Some data", signature: signature[:len(signature)-2], wantErr: true},
+		{name: "invalid v", message: "Some data", signature: signature[:len(signature)-2] + "1d", wantErr: true},
+		{name: "not hex", message: "Some data", signature: "0xzz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecoverPersonalSigner(tt.message, tt.signature)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("err = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecoverPersonalSigner: %v", err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("signer = %s, want %s", got, tt.want)
+			}
+			if tt.want == "" && got == signer {
+				t.Errorf("signature over a different message recovered %s", got)
+			}
+		})
+	}
+}
+
+// TestConfirmTransactionRequiresUserAddress 未开启宽松模式时，没有用户地址的工作流不能确认任意交易哈希
+func TestConfirmTransactionRequiresUserAddress(t *testing.T) {
+	txHash := "0x" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name       string
+		permissive bool
+		data       map[string]any
+		wantErr    error
+	}{
+		{name: "no user address", data: map[string]any{}, wantErr: ErrNoExpectedSigner},
+		{name: "read account only", data: map[string]any{"read_account": eip155Address}, wantErr: ErrNoExpectedSigner},
+		{name: "permissive without user address", permissive: true, data: map[string]any{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := rpc.NewMockNode()
+			defer node.Close()
+			validator := NewSignatureValidatorNode(rpc.NewClient(node.URL()), nil, config.TransactionConfig{
+				ConfirmationTimeout:   10,
+				PollingInterval:       1,
+				RequiredConfirmations: 1,
+				ConfirmationStrategy:  rpc.StrategyConfirmations,
+				PermissiveSignatures:  tt.permissive,
+			})
+
+			err := validator.confirmTransaction(context.Background(), tt.data, "task_1", txHash)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("confirmTransaction: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			// 在等待确认之前拒绝
+			if receipts := node.CallsTo("eth_getTransactionReceipt"); receipts != 0 {
+				t.Errorf("eth_getTransactionReceipt called %d times, want none", receipts)
+			}
+		})
+	}
+
+	if _, err := verifyTransactionSigner(context.Background(), nil, txHash, ""); !errors.Is(err, ErrNoExpectedSigner) {
+		t.Errorf("verifyTransactionSigner without expected address: err = %v, want ErrNoExpectedSigner", err)
+	}
+}
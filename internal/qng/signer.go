@@ -0,0 +1,222 @@
+package qng
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"qng_agent/internal/config"
+	"qng_agent/internal/rpc"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Signer 服务端交易签名器，仅在配置显式启用时创建
+type Signer struct {
+	key     *secp256k1.PrivateKey
+	address string
+	chainID *big.Int
+}
+
+// NewSigner 从环境变量加载私钥创建签名器，未启用时返回 nil
+func NewSigner(cfg config.SignerConfig, chainID int64) (*Signer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	envName := cfg.PrivateKeyEnv
+	if envName == "" {
+		envName = "QNG_SIGNER_PRIVATE_KEY"
+	}
+
+	keyHex := strings.TrimPrefix(strings.TrimSpace(os.Getenv(envName)), "0x")
+	if keyHex == "" {
+		return nil, fmt.Errorf("signer enabled but %s is not set", envName)
+	}
+
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil || len(keyBytes) != 32 {
+		return nil, fmt.Errorf("invalid signer private key in %s", envName)
+	}
+
+	if chainID <= 0 {
+		return nil, fmt.Errorf("signer requires a configured chain id")
+	}
+
+	key := secp256k1.PrivKeyFromBytes(keyBytes)
+	signer := &Signer{
+		key:     key,
+		address: pubkeyToAddress(key.PubKey()),
+		chainID: big.NewInt(chainID),
+	}
+
+	log.Printf("⚠️  服务端签名已启用，签名地址: %s (Chain ID: %d)", signer.address, chainID)
+	return signer, nil
+}
+
+// Address 返回签名账户地址
+func (s *Signer) Address() string {
+	return s.address
+}
+
+// SignAndSend 按签名请求构建交易，签名后通过 eth_sendRawTransaction 广播，返回交易哈希
+func (s *Signer) SignAndSend(ctx context.Context, rpcClient *rpc.Client, request any) (string, error) {
+	if rpcClient == nil {
+		return "", fmt.Errorf("server-side signing requires an RPC client")
+	}
+
+	fields, ok := request.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("invalid signature request type: %T", request)
+	}
+
+	to, err := decodeHexField(fields, "to_address")
+	if err != nil {
+		return "", err
+	}
+	if len(to) != 20 {
+		return "", fmt.Errorf("invalid to_address: %v", fields["to_address"])
+	}
+
+	data, err := decodeHexField(fields, "data")
+	if err != nil {
+		return "", err
+	}
+
+	// EIP-1559 网络的签名请求携带 max_fee_per_gas，此时 gas_price 为空
+	dynamicFee, _ := fields["max_fee_per_gas"].(string)
+	numberFields := []string{"value", "gas_limit", "gas_price"}
+	if dynamicFee != "" {
+		numberFields = []string{"value", "gas_limit", "max_fee_per_gas", "max_priority_fee_per_gas"}
+	}
+	numbers := make([]*big.Int, len(numberFields))
+	for i, name := range numberFields {
+		raw, err := decodeHexField(fields, name)
+		if err != nil {
+			return "", err
+		}
+		numbers[i] = new(big.Int).SetBytes(raw)
+	}
+	value, gasLimit := numbers[0], numbers[1]
+
+	// 替换交易携带被替换交易的 nonce，其它交易使用 pending nonce
+	var nonce uint64
+	if rawNonce, _ := fields["nonce"].(string); rawNonce != "" {
+		raw, err := decodeHexField(fields, "nonce")
+		if err != nil {
+			return "", err
+		}
+		nonce = new(big.Int).SetBytes(raw).Uint64()
+	} else if nonce, err = rpcClient.GetTransactionCount(ctx, s.address); err != nil {
+		return "", err
+	}
+
+	var raw []byte
+	if dynamicFee != "" {
+		raw = s.signDynamicFeeTx(new(big.Int).SetUint64(nonce), numbers[3], numbers[2], gasLimit, to, value, data)
+	} else {
+		raw = s.signLegacyTx(new(big.Int).SetUint64(nonce), numbers[2], gasLimit, to, value, data)
+	}
+
+	log.Printf("✍️  服务端已签名交易: nonce=%d, to=%s", nonce, fields["to_address"])
+	return rpcClient.SendRawTransaction(ctx, "0x"+hex.EncodeToString(raw))
+}
+
+// signLegacyTx 按 EIP-155 签名传统交易并返回 RLP 编码
+func (s *Signer) signLegacyTx(nonce, gasPrice, gasLimit *big.Int, to []byte, value *big.Int, data []byte) []byte {
+	unsigned := rlpList(
+		rlpInt(nonce), rlpInt(gasPrice), rlpInt(gasLimit), rlpBytes(to), rlpInt(value), rlpBytes(data),
+		rlpInt(s.chainID), rlpInt(new(big.Int)), rlpInt(new(big.Int)),
+	)
+
+	// 紧凑签名格式: [27 + recoveryID] || R || S
+	sig := ecdsa.SignCompact(s.key, keccak256(unsigned), false)
+	recoveryID := int64(sig[0] - 27)
+	v := new(big.Int).Add(new(big.Int).Mul(s.chainID, big.NewInt(2)), big.NewInt(35+recoveryID))
+	r := new(big.Int).SetBytes(sig[1:33])
+	sv := new(big.Int).SetBytes(sig[33:65])
+
+	return rlpList(
+		rlpInt(nonce), rlpInt(gasPrice), rlpInt(gasLimit), rlpBytes(to), rlpInt(value), rlpBytes(data),
+		rlpInt(v), rlpInt(r), rlpInt(sv),
+	)
+}
+
+// signDynamicFeeTx 按 EIP-1559 签名 type-2 交易（空访问列表）并返回带类型前缀的编码
+func (s *Signer) signDynamicFeeTx(nonce, maxPriorityFee, maxFee, gasLimit *big.Int, to []byte, value *big.Int, data []byte) []byte {
+	fields := [][]byte{
+		rlpInt(s.chainID), rlpInt(nonce), rlpInt(maxPriorityFee), rlpInt(maxFee), rlpInt(gasLimit),
+		rlpBytes(to), rlpInt(value), rlpBytes(data), rlpList(),
+	}
+	unsigned := append([]byte{0x02}, rlpList(fields...)...)
+
+	// 紧凑签名格式: [27 + recoveryID] || R || S，type-2 交易直接使用 recoveryID 作为 yParity
+	sig := ecdsa.SignCompact(s.key, keccak256(unsigned), false)
+	yParity := big.NewInt(int64(sig[0] - 27))
+	r := new(big.Int).SetBytes(sig[1:33])
+	sv := new(big.Int).SetBytes(sig[33:65])
+
+	fields = append(fields, rlpInt(yParity), rlpInt(r), rlpInt(sv))
+	return append([]byte{0x02}, rlpList(fields...)...)
+}
+
+// decodeHexField 解码签名请求中的十六进制字段，缺省为空
+func decodeHexField(fields map[string]any, name string) ([]byte, error) {
+	raw, _ := fields[name].(string)
+	raw = strings.TrimPrefix(raw, "0x")
+	if len(raw)%2 == 1 {
+		raw = "0" + raw
+	}
+	decoded, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return decoded, nil
+}
+
+// pubkeyToAddress 由公钥计算以太坊地址
+func pubkeyToAddress(pub *secp256k1.PublicKey) string {
+	hash := keccak256(pub.SerializeUncompressed()[1:])
+	return "0x" + hex.EncodeToString(hash[12:])
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// rlpBytes RLP 编码字节串
+func rlpBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// rlpInt RLP 编码非负整数（大端、无前导零）
+func rlpInt(n *big.Int) []byte {
+	return rlpBytes(n.Bytes())
+}
+
+// rlpList RLP 编码已编码元素的列表
+func rlpList(items ...[]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+	return append(rlpHeader(0xc0, len(payload)), payload...)
+}
+
+func rlpHeader(offset byte, length int) []byte {
+	if length <= 55 {
+		return []byte{offset + byte(length)}
+	}
+	lenBytes := big.NewInt(int64(length)).Bytes()
+	return append([]byte{offset + 55 + byte(len(lenBytes))}, lenBytes...)
+}
@@ -0,0 +1,126 @@
+package qng
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// eip155Key EIP-155 规范示例使用的私钥 0x4646...46
+var eip155Key = bytes.Repeat([]byte{0x46}, 32)
+
+// eip155To EIP-155 规范示例的接收地址 0x3535...35
+var eip155To = bytes.Repeat([]byte{0x35}, 20)
+
+func newTestSigner(t *testing.T, key []byte, chainID int64) *Signer {
+	t.Helper()
+	priv := secp256k1.PrivKeyFromBytes(key)
+	return &Signer{key: priv, address: pubkeyToAddress(priv.PubKey()), chainID: big.NewInt(chainID)}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+}
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+// TestSignLegacyTxEIP155Vector 使用 EIP-155 规范中的示例交易校验签名哈希与最终编码
+func TestSignLegacyTxEIP155Vector(t *testing.T) {
+	signer := newTestSigner(t, eip155Key, 1)
+	if signer.Address() != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Fatalf("address = %s, want 0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f", signer.Address())
+	}
+
+	unsigned := rlpList(
+		rlpInt(big.NewInt(9)), rlpInt(gwei(20)), rlpInt(big.NewInt(21000)), rlpBytes(eip155To), rlpInt(ether(1)), rlpBytes(nil),
+		rlpInt(big.NewInt(1)), rlpInt(new(big.Int)), rlpInt(new(big.Int)),
+	)
+	wantUnsigned := "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080018080"
+	if got := hex.EncodeToString(unsigned); got != wantUnsigned {
+		t.Errorf("signing data = %s, want %s", got, wantUnsigned)
+	}
+	wantHash := "daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53"
+	if got := hex.EncodeToString(keccak256(unsigned)); got != wantHash {
+		t.Errorf("signing hash = %s, want %s", got, wantHash)
+	}
+
+	raw := signer.signLegacyTx(big.NewInt(9), gwei(20), big.NewInt(21000), eip155To, ether(1), nil)
+	want := "f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	if got := hex.EncodeToString(raw); got != want {
+		t.Errorf("signed tx =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestSignDynamicFeeTxVector 校验 type-2 交易：签名字段与逐字节手写的 EIP-1559 参考编码一致，
+// 签名可由参考签名哈希恢复出签名地址，完整编码固定为 RFC 6979 确定性签名的结果
+func TestSignDynamicFeeTxVector(t *testing.T) {
+	signer := newTestSigner(t, eip155Key, 1)
+
+	// rlp([chainId=1, nonce=0, maxPriorityFee=1 gwei, maxFee=20 gwei, gas=21000, to, value=1 ether, data="", accessList=[]])
+	fields := "01" + "80" + "843b9aca00" + "8504a817c800" + "825208" +
+		"94" + strings.Repeat("35", 20) + "880de0b6b3a7640000" + "80" + "c0"
+	signingHash := keccak256(mustHex(t, "02f0"+fields))
+
+	raw := signer.signDynamicFeeTx(big.NewInt(0), gwei(1), gwei(20), big.NewInt(21000), eip155To, ether(1), nil)
+
+	// 0x02 || rlp([...fields, yParity, r, s])
+	r := "b3d7e5d4775918a0ec38e4f9da6263f69c2072c0e177ff9aa274575bfba17d04"
+	s := "62182875ae92e4de08aaf8ea1a43d3ea0d836745788801cdc79ccc473a76dfd9"
+	want := "02f873" + fields + "01" + "a0" + r + "a0" + s
+	if got := hex.EncodeToString(raw); got != want {
+		t.Errorf("signed tx =\n%s\nwant\n%s", got, want)
+	}
+
+	compact := append([]byte{27 + 1}, mustHex(t, r+s)...)
+	pub, _, err := ecdsa.RecoverCompact(compact, signingHash)
+	if err != nil {
+		t.Fatalf("RecoverCompact: %v", err)
+	}
+	if got := pubkeyToAddress(pub); got != signer.Address() {
+		t.Errorf("recovered %s, want %s", got, signer.Address())
+	}
+}
+
+// TestRLPSpecExamples 使用以太坊 RLP 规范中的示例校验编码辅助函数
+func TestRLPSpecExamples(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{name: "dog", got: rlpBytes([]byte("dog")), want: "83646f67"},
+		{name: "cat dog list", got: rlpList(rlpBytes([]byte("cat")), rlpBytes([]byte("dog"))), want: "c88363617483646f67"},
+		{name: "empty string", got: rlpBytes(nil), want: "80"},
+		{name: "empty list", got: rlpList(), want: "c0"},
+		{name: "zero", got: rlpInt(new(big.Int)), want: "80"},
+		{name: "single byte", got: rlpBytes([]byte{0x0f}), want: "0f"},
+		{name: "1024", got: rlpInt(big.NewInt(1024)), want: "820400"},
+		{name: "set of three", got: rlpList(rlpList(), rlpList(rlpList()), rlpList(rlpList(), rlpList(rlpList()))), want: "c7c0c1c0c3c0c1c0"},
+		{name: "long string", got: rlpBytes([]byte(lorem)), want: "b838" + hex.EncodeToString([]byte(lorem))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.got); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
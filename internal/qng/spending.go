@@ -0,0 +1,200 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"qng_agent/internal/config"
+	"qng_agent/internal/contracts"
+	"strconv"
+	"strings"
+)
+
+// ErrSpendingLimitExceeded 交易超出配置的支出限额
+var ErrSpendingLimitExceeded = errors.New("exceeds configured limit")
+
+// 支出限额类型
+const (
+	LimitTransactionValue = "max_transaction_value"
+	LimitTokenAmount      = "max_token_amount"
+)
+
+// SpendingLimitError 超出支出限额的详细信息
+type SpendingLimitError struct {
+	Kind   string
+	Token  string
+	Amount float64
+	Limit  float64
+}
+
+func (e *SpendingLimitError) Error() string {
+	return fmt.Sprintf("%g %s %s %g (%s), manual override required", e.Amount, e.Token, ErrSpendingLimitExceeded, e.Limit, e.Kind)
+}
+
+func (e *SpendingLimitError) Unwrap() error {
+	return ErrSpendingLimitExceeded
+}
+
+// SpendingGuard 在构建交易前按全局或钱包地址的限额检查支出
+type SpendingGuard struct {
+	config          config.SpendingLimitsConfig
+	contractManager *contracts.ContractManager
+	// signerAddress 服务端签名账户地址，启用服务端签名时所有交易都由该账户签名
+	signerAddress string
+	// verified 为 false（permissive_signatures）时不校验交易签名者，请求中的地址不可信
+	verified bool
+}
+
+// NewSpendingGuard 创建支出限额检查器，未启用时返回 nil。
+// 用户覆盖按签名交易的钱包地址匹配：启用服务端签名时使用 signerAddress，
+// 否则使用经签名者校验的 user_address；permissive 为 true 时不应用用户覆盖
+func NewSpendingGuard(cfg config.SpendingLimitsConfig, contractManager *contracts.ContractManager, signerAddress string, permissive bool) *SpendingGuard {
+	if !cfg.Enabled {
+		return nil
+	}
+	log.Printf("🛡️  支出限额已启用: 单笔上限 %g, 代币上限 %v, 用户覆盖 %d 个",
+		cfg.MaxTransactionValue, cfg.MaxTokenAmounts, len(cfg.Users))
+	if permissive && signerAddress == "" && len(cfg.Users) > 0 {
+		log.Printf("⚠️  permissive_signatures 已开启，签名者不受校验，用户限额覆盖不会生效")
+	}
+	return &SpendingGuard{
+		config:          cfg,
+		contractManager: contractManager,
+		signerAddress:   signerAddress,
+		verified:        !permissive,
+	}
+}
+
+// Check 检查任务的支出是否超出限额，通过后将数量计入工作流累计支出。
+// 同一任务（如授权+质押）只计入一次。
+func (g *SpendingGuard) Check(data map[string]any, taskID, token, amount string) error {
+	if g == nil {
+		return nil
+	}
+
+	recordedKey := taskID + "_spend_recorded"
+	if recorded, _ := data[recordedKey].(bool); recorded {
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+
+	userID := g.limitAccount(data)
+	limit := g.limitFor(userID)
+
+	if limit.MaxTransactionValue > 0 {
+		nativeValue := value
+		if g.contractManager != nil {
+			nativeValue, err = g.contractManager.NativeValue(token, value)
+			if err != nil {
+				return fmt.Errorf("cannot check spending limit: %w", err)
+			}
+		}
+		if nativeValue > limit.MaxTransactionValue {
+			log.Printf("🛑 交易价值超出限额: %g > %g (用户: %s)", nativeValue, limit.MaxTransactionValue, userID)
+			return &SpendingLimitError{
+				Kind:   LimitTransactionValue,
+				Token:  g.nativeSymbol(),
+				Amount: nativeValue,
+				Limit:  limit.MaxTransactionValue,
+			}
+		}
+	}
+
+	spent, _ := data["spent_amounts"].(map[string]float64)
+	if spent == nil {
+		spent = make(map[string]float64)
+	}
+	total := spent[token] + value
+
+	if max, ok := lookupFold(limit.MaxTokenAmounts, token); ok && max > 0 && total > max {
+		log.Printf("🛑 工作流累计 %s 超出限额: %g > %g (用户: %s)", token, total, max, userID)
+		return &SpendingLimitError{
+			Kind:   LimitTokenAmount,
+			Token:  token,
+			Amount: total,
+			Limit:  max,
+		}
+	}
+
+	spent[token] = total
+	data["spent_amounts"] = spent
+	data[recordedKey] = true
+	return nil
+}
+
+// limitAccount 返回用于匹配用户覆盖的钱包地址。
+// 客户端提交的 user_id 未经认证，不能用来提高限额；user_address 只有在签名者校验开启时才可信，
+// 因为该地址之外的账户签名的交易会在确认时被拒绝
+func (g *SpendingGuard) limitAccount(data map[string]any) string {
+	if g.signerAddress != "" {
+		return g.signerAddress
+	}
+	if !g.verified {
+		return ""
+	}
+	address, _ := data["user_address"].(string)
+	return address
+}
+
+// limitFor 返回用户生效的限额，用户覆盖中未设置的字段使用全局限额
+func (g *SpendingGuard) limitFor(userID string) config.SpendingLimit {
+	limit := config.SpendingLimit{
+		MaxTransactionValue: g.config.MaxTransactionValue,
+		MaxTokenAmounts:     make(map[string]float64),
+	}
+	for token, max := range g.config.MaxTokenAmounts {
+		limit.MaxTokenAmounts[token] = max
+	}
+
+	if userID == "" {
+		return limit
+	}
+
+	// 配置键经 viper 读取后为小写，按不区分大小写匹配
+	for id, override := range g.config.Users {
+		if !strings.EqualFold(id, userID) {
+			continue
+		}
+		if override.MaxTransactionValue > 0 {
+			limit.MaxTransactionValue = override.MaxTransactionValue
+		}
+		for token, max := range override.MaxTokenAmounts {
+			if existing, ok := lookupFoldKey(limit.MaxTokenAmounts, token); ok {
+				delete(limit.MaxTokenAmounts, existing)
+			}
+			limit.MaxTokenAmounts[token] = max
+		}
+		break
+	}
+
+	return limit
+}
+
+func (g *SpendingGuard) nativeSymbol() string {
+	if g.contractManager != nil {
+		return g.contractManager.NativeSymbol()
+	}
+	return "native"
+}
+
+// lookupFold 不区分大小写查找代币限额
+func lookupFold(m map[string]float64, token string) (float64, bool) {
+	key, ok := lookupFoldKey(m, token)
+	if !ok {
+		return 0, false
+	}
+	return m[key], true
+}
+
+func lookupFoldKey(m map[string]float64, token string) (string, bool) {
+	for key := range m {
+		if strings.EqualFold(key, token) {
+			return key, true
+		}
+	}
+	return "", false
+}
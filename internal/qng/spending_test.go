@@ -0,0 +1,105 @@
+package qng
+
+import (
+	"errors"
+	"testing"
+
+	"qng_agent/internal/config"
+)
+
+const (
+	spendingWhale = "0x00000000000000000000000000000000000000A1"
+	spendingOther = "0x00000000000000000000000000000000000000b2"
+)
+
+func spendingLimits() config.SpendingLimitsConfig {
+	return config.SpendingLimitsConfig{
+		Enabled: true,
+		SpendingLimit: config.SpendingLimit{
+			MaxTransactionValue: 10,
+			MaxTokenAmounts:     map[string]float64{"MEER": 15},
+		},
+		Users: map[string]config.SpendingLimit{
+			// viper 读取后配置键为小写
+			"0x00000000000000000000000000000000000000a1": {MaxTransactionValue: 1000, MaxTokenAmounts: map[string]float64{"meer": 2000}},
+		},
+	}
+}
+
+func TestSpendingGuardCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		signer     string
+		permissive bool
+		data       map[string]any
+		amount     string
+		wantKind   string
+	}{
+		{name: "within global limit", data: map[string]any{}, amount: "5"},
+		{name: "exceeds global transaction value", data: map[string]any{}, amount: "11", wantKind: LimitTransactionValue},
+		{name: "exceeds cumulative token amount", data: map[string]any{"spent_amounts": map[string]float64{"MEER": 8}}, amount: "8", wantKind: LimitTokenAmount},
+		{name: "override for verified user address", data: map[string]any{"user_address": spendingWhale}, amount: "500"},
+		{name: "override not applied to other address", data: map[string]any{"user_address": spendingOther}, amount: "500", wantKind: LimitTransactionValue},
+		{name: "unauthenticated user_id ignored", data: map[string]any{"user_id": spendingWhale}, amount: "500", wantKind: LimitTransactionValue},
+		{name: "override ignored when signatures are permissive", permissive: true, data: map[string]any{"user_address": spendingWhale}, amount: "500", wantKind: LimitTransactionValue},
+		{name: "server signer address takes precedence", signer: spendingOther, data: map[string]any{"user_address": spendingWhale}, amount: "500", wantKind: LimitTransactionValue},
+		{name: "override for server signer", signer: spendingWhale, permissive: true, data: map[string]any{}, amount: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewSpendingGuard(spendingLimits(), nil, tt.signer, tt.permissive)
+			err := guard.Check(tt.data, "task_1", "MEER", tt.amount)
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Fatalf("Check: unexpected error %v", err)
+				}
+				return
+			}
+
+			var limitErr *SpendingLimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Check: got %v, want SpendingLimitError", err)
+			}
+			if limitErr.Kind != tt.wantKind {
+				t.Errorf("Kind = %s, want %s", limitErr.Kind, tt.wantKind)
+			}
+			if !errors.Is(err, ErrSpendingLimitExceeded) {
+				t.Error("error does not wrap ErrSpendingLimitExceeded")
+			}
+			if _, recorded := tt.data["task_1_spend_recorded"]; recorded {
+				t.Error("rejected spend was recorded")
+			}
+		})
+	}
+}
+
+func TestSpendingGuardRecordsTaskOnce(t *testing.T) {
+	guard := NewSpendingGuard(spendingLimits(), nil, "", false)
+	data := map[string]any{}
+
+	// 授权与质押属于同一任务，只计入一次
+	for i := 0; i < 2; i++ {
+		if err := guard.Check(data, "task_1", "MEER", "9"); err != nil {
+			t.Fatalf("Check #%d: %v", i+1, err)
+		}
+	}
+	if spent := data["spent_amounts"].(map[string]float64)["MEER"]; spent != 9 {
+		t.Errorf("spent MEER = %g, want 9", spent)
+	}
+
+	// 第二个任务使累计数量超出 15 MEER
+	if err := guard.Check(data, "task_2", "MEER", "9"); !errors.Is(err, ErrSpendingLimitExceeded) {
+		t.Errorf("second task: got %v, want ErrSpendingLimitExceeded", err)
+	}
+}
+
+func TestSpendingGuardDisabled(t *testing.T) {
+	guard := NewSpendingGuard(config.SpendingLimitsConfig{}, nil, "", false)
+	if guard != nil {
+		t.Fatal("disabled guard should be nil")
+	}
+	if err := guard.Check(map[string]any{}, "task_1", "MEER", "1e9"); err != nil {
+		t.Errorf("nil guard Check: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package qng
+
+import (
+	"fmt"
+	"strings"
+
+	"qng_agent/internal/contracts"
+)
+
+// stakingPoolsPrompt 列出可用质押池供LLM选择，返回提示段落和默认池名称
+func stakingPoolsPrompt(cm *contracts.ContractManager) (string, string) {
+	if cm == nil {
+		return "", ""
+	}
+
+	defaultPool := cm.DefaultPool()
+	var b strings.Builder
+	b.WriteString("可用质押池（stake 任务的 pool 填写池名称）：\n")
+	for _, pool := range cm.StakingPools() {
+		b.WriteString("- " + pool.Name)
+		if len(pool.Aliases) > 0 {
+			b.WriteString("（也称 " + strings.Join(pool.Aliases, "、") + "）")
+		}
+		var details []string
+		if pool.Description != "" {
+			details = append(details, pool.Description)
+		}
+		if pool.LockDays > 0 {
+			details = append(details, fmt.Sprintf("锁定 %d 天", pool.LockDays))
+		}
+		if pool.APY != "" {
+			details = append(details, "年化 "+pool.APY)
+		}
+		if len(details) > 0 {
+			b.WriteString(": " + strings.Join(details, "，"))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "用户没有指定质押池时使用默认池 %s；用户指定的池不在列表中时照原样填写，不要替换为其它池\n", defaultPool.Name)
+	return b.String(), defaultPool.Name
+}
+
+// normalizeTaskPools 将质押任务的 pool 解析为配置中的池名称，未指定时使用默认池，未知的池返回错误
+func normalizeTaskPools(cm *contracts.ContractManager, tasks []map[string]any) error {
+	if cm == nil {
+		return nil
+	}
+
+	for _, task := range tasks {
+		if taskType, _ := task["type"].(string); taskType != "stake" {
+			continue
+		}
+		name, _ := task["pool"].(string)
+		pool, err := cm.ResolvePool(name)
+		if err != nil {
+			return fmt.Errorf("task %v: %w", task["id"], err)
+		}
+		task["pool"] = pool.Name
+	}
+	return nil
+}
+
+// poolFromMessage 规则分解时从消息中识别质押池，没有提到时返回 nil（稍后使用默认池）
+func (n *TaskDecomposerNode) poolFromMessage(message string) any {
+	if n.contractManager == nil {
+		return nil
+	}
+	if pool, ok := n.contractManager.MatchPool(message); ok {
+		return pool.Name
+	}
+	return nil
+}
+
+// stakeDescription 质押签名请求的说明，包含格式化的质押数量与所选质押池的锁定期、年化收益率
+func stakeDescription(amountDisplay string, pool contracts.StakingPool) string {
+	description := fmt.Sprintf("将 %s 代币质押到 %s 池", amountDisplay, pool.Name)
+	if pool.LockDays > 0 {
+		description += fmt.Sprintf("，锁定 %d 天", pool.LockDays)
+	}
+	if pool.APY != "" {
+		description += "，预计年化收益率 " + pool.APY
+	}
+	return description
+}
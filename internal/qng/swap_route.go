@@ -0,0 +1,83 @@
+package qng
+
+import (
+	"fmt"
+	"qng_agent/internal/contracts"
+	"sort"
+	"strings"
+)
+
+// stepSwapHop 多跳兑换中间跳的签名步骤标记，签名验证后返回兑换执行节点继续下一跳
+const stepSwapHop = "swap_hop"
+
+// swapRoutesKey 按任务ID保存的兑换路由
+const swapRoutesKey = "swap_routes"
+
+// swapRoute 返回任务在首跳时构建的兑换路由，尚未构建时返回 nil
+func swapRoute(data map[string]any, taskID string) []contracts.SwapHop {
+	routes, _ := data[swapRoutesKey].(map[string][]contracts.SwapHop)
+	return routes[taskID]
+}
+
+// saveSwapRoute 保存任务的兑换路由，中间跳签名确认后由下一跳沿用
+func saveSwapRoute(data map[string]any, taskID string, hops []contracts.SwapHop) {
+	routes, _ := data[swapRoutesKey].(map[string][]contracts.SwapHop)
+	if routes == nil {
+		routes = make(map[string][]contracts.SwapHop)
+		data[swapRoutesKey] = routes
+	}
+	routes[taskID] = hops
+}
+
+// swapHopTxKey 多跳兑换第 i 跳的交易哈希键
+func swapHopTxKey(taskID string, i int) string {
+	return fmt.Sprintf("%s_hop_%d_tx_hash", taskID, i)
+}
+
+// completedSwapHops 返回任务已确认的中间跳数量
+func completedSwapHops(data map[string]any, taskID string) int {
+	count := 0
+	for {
+		if _, exists := data[swapHopTxKey(taskID, count)]; !exists {
+			return count
+		}
+		count++
+	}
+}
+
+// swapPairsPrompt 生成任务分解提示中的代币与兑换对列表，与合约配置保持一致
+func swapPairsPrompt(cm *contracts.ContractManager) string {
+	if cm == nil {
+		return "支持的代币：\n- MEER: 原生代币\n- MTK: ERC20代币\n支持的兑换对：\n- MEER → MTK\n- MTK → MEER\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("支持的代币：\n")
+	for _, token := range cm.GetTokens() {
+		kind := "ERC20代币"
+		if token.IsNative {
+			kind = "原生代币"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", token.Symbol, kind)
+	}
+
+	pairs := cm.GetSupportedPairs()
+	sort.Strings(pairs)
+	b.WriteString("支持的兑换对（其它代币组合经中间代币多跳兑换）：\n")
+	for _, pair := range pairs {
+		b.WriteString("- " + strings.Replace(pair, "-", " → ", 1) + "\n")
+	}
+	return b.String()
+}
+
+// formatSwapRoute 将兑换路由格式化为 "A → B → C"
+func formatSwapRoute(hops []contracts.SwapHop) string {
+	if len(hops) == 0 {
+		return ""
+	}
+	tokens := []string{hops[0].FromToken}
+	for _, hop := range hops {
+		tokens = append(tokens, hop.ToToken)
+	}
+	return strings.Join(tokens, " → ")
+}
@@ -0,0 +1,99 @@
+package qng
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"qng_agent/internal/contracts"
+	"qng_agent/internal/rpc"
+)
+
+// ErrNoDependencyOutput 任务数量为 all_from_previous，但没有已完成的依赖任务记录输出数量
+var ErrNoDependencyOutput = errors.New("no completed dependency to source amount from")
+
+// outputAmountKey 任务输出数量的键，兑换任务构建交易时记录按路由估算的最终输出，确认后替换为实际到账数量
+func outputAmountKey(taskID string) string {
+	return taskID + "_output_amount"
+}
+
+// dependencyOutputAmount 返回任务所依赖的已完成任务记录的输出数量，
+// 没有依赖、依赖未完成或依赖没有记录输出时返回 ErrNoDependencyOutput
+func dependencyOutputAmount(task map[string]any, data map[string]any) (string, error) {
+	taskID, _ := task["id"].(string)
+	depID, _ := task["dependency_tx_id"].(string)
+	if depID == "" {
+		return "", fmt.Errorf("%w: task %s has no dependency", ErrNoDependencyOutput, taskID)
+	}
+
+	completed := false
+	if completedTasks, ok := data["completed_tasks"].([]string); ok {
+		for _, id := range completedTasks {
+			if id == depID {
+				completed = true
+				break
+			}
+		}
+	}
+	if !completed {
+		return "", fmt.Errorf("%w: dependency %s of task %s has not completed", ErrNoDependencyOutput, depID, taskID)
+	}
+
+	amount, _ := data[outputAmountKey(depID)].(string)
+	if amount == "" {
+		return "", fmt.Errorf("%w: dependency %s of task %s recorded no output amount", ErrNoDependencyOutput, depID, taskID)
+	}
+	return amount, nil
+}
+
+// recordReceivedAmount 兑换交易确认后，用收据中转入用户地址的 Transfer 事件数量替换按路由估算的输出，
+// 使依赖任务的 all_from_previous 使用实际到账数量。多跳兑换的中间跳没有记录输出，不做处理；
+// 原生代币输出或收据中没有匹配事件时保留估算值
+func recordReceivedAmount(cm *contracts.ContractManager, data map[string]any, taskID string, receipt *rpc.TransactionReceipt) {
+	estimated, ok := data[outputAmountKey(taskID)].(string)
+	if !ok || receipt == nil || cm == nil {
+		return
+	}
+
+	var toToken string
+	if tasks, ok := data["tasks"].([]map[string]any); ok {
+		for _, task := range tasks {
+			if id, _ := task["id"].(string); id == taskID {
+				toToken, _ = task["to_token"].(string)
+				break
+			}
+		}
+	}
+	recipient, _ := data["user_address"].(string)
+	if recipient == "" {
+		recipient, _ = data["signer_address"].(string)
+	}
+
+	logs := make([]contracts.EventLog, 0, len(receipt.Logs))
+	for _, entry := range receipt.Logs {
+		logs = append(logs, contracts.EventLog(entry))
+	}
+	received, ok := cm.ReceivedAmount(toToken, recipient, logs)
+	if !ok {
+		log.Printf("⚠️  无法从收据确定任务 %s 的 %s 到账数量，使用估算输出 %s", taskID, toToken, estimated)
+		return
+	}
+
+	data[outputAmountKey(taskID)] = received
+	log.Printf("💰 任务 %s 实际到账 %s %s (估算 %s)", taskID, received, toToken, estimated)
+}
+
+// taskTxHashes 收集工作流中各任务步骤的交易哈希，key 为交易哈希字段名（如 task_2_approve_tx_hash）
+func taskTxHashes(data map[string]any) map[string]string {
+	hashes := make(map[string]string)
+	for key, value := range data {
+		if !strings.HasSuffix(key, "_tx_hash") {
+			continue
+		}
+		if txHash, ok := value.(string); ok && txHash != "" {
+			hashes[key] = txHash
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,59 @@
+package qng
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"qng_agent/internal/contracts"
+)
+
+// TestTransferExecutorUsesPreviousOutput 转账任务的 all_from_previous 使用依赖任务记录的输出数量
+func TestTransferExecutorUsesPreviousOutput(t *testing.T) {
+	cm, err := contracts.NewContractManager("../../config/contracts.json")
+	if err != nil {
+		t.Fatalf("NewContractManager: %v", err)
+	}
+	node := NewTransferExecutorNode(cm, nil, nil, nil, 0)
+
+	tests := []struct {
+		name       string
+		output     string
+		wantAmount string
+		wantErr    error
+	}{
+		{name: "recorded output", output: "2.5", wantAmount: "2.5"},
+		{name: "no recorded output", wantErr: ErrNoDependencyOutput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]any{
+				"tasks": []map[string]any{
+					{"id": "task_1", "type": "swap", "from_token": "MEER", "to_token": "MTK", "amount": "1"},
+					{"id": "task_2", "type": "transfer", "token": "MTK", "amount": contracts.AmountFromPrevious,
+						"to_address": testReadAccount, "dependency_tx_id": "task_1"},
+				},
+				"completed_tasks": []string{"task_1"},
+			}
+			if tt.output != "" {
+				data[outputAmountKey("task_1")] = tt.output
+			}
+
+			output, err := node.Execute(context.Background(), NodeInput{Data: data})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Execute error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			request, _ := output.AuthRequest.(map[string]any)
+			if amount := request["amount"]; amount != tt.wantAmount {
+				t.Errorf("signature request amount = %v, want %s", amount, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package qng
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"qng_agent/internal/contracts"
+)
+
+// TrustedAddresses 可信的合约地址列表。交易调用的合约不在列表中时，签名请求带上醒目警告，
+// 防止配置错误把资金发往未知地址
+type TrustedAddresses struct {
+	// addresses 小写地址 -> 显示名称
+	addresses map[string]string
+}
+
+// NewTrustedAddresses 创建可信地址列表。配置了 transaction.trusted_addresses 时只信任这些地址，
+// 未配置时信任 contracts.json 中的代币与合约地址
+func NewTrustedAddresses(configured []string, contractManager *contracts.ContractManager) *TrustedAddresses {
+	known := make(map[string]string)
+	if contractManager != nil {
+		known = contractManager.KnownAddresses()
+	}
+	if len(configured) == 0 {
+		log.Printf("🛡️  未配置可信合约地址，使用 contracts.json 中的 %d 个地址", len(known))
+		return &TrustedAddresses{addresses: known}
+	}
+
+	addresses := make(map[string]string, len(configured))
+	for _, address := range configured {
+		address = strings.ToLower(strings.TrimSpace(address))
+		label, ok := known[address]
+		if !ok {
+			label = address
+		}
+		addresses[address] = label
+	}
+	log.Printf("🛡️  可信合约地址: %d 个（transaction.trusted_addresses）", len(addresses))
+	return &TrustedAddresses{addresses: addresses}
+}
+
+// Check 检查交易调用的合约是否可信，不可信时返回警告。不带调用数据的原生代币转账发往用户指定的收款人，不做检查
+func (t *TrustedAddresses) Check(tx *contracts.TransactionData) string {
+	if t == nil || tx == nil || tx.Data == "" || tx.Data == "0x" {
+		return ""
+	}
+	if _, trusted := t.addresses[strings.ToLower(tx.To)]; trusted {
+		return ""
+	}
+	return fmt.Sprintf("交易发往的合约 %s 不在可信地址列表中，可能是配置错误，请在钱包中核对后再签名", tx.To)
+}
+
+// applyTrustCheck 检查交易目标地址，在签名请求中记录 to_trusted，不可信时追加警告
+func (t *TrustedAddresses) applyTrustCheck(authRequest map[string]any, tx *contracts.TransactionData) {
+	warning := t.Check(tx)
+	authRequest["to_trusted"] = warning == ""
+	if warning == "" {
+		return
+	}
+	log.Printf("⚠️  %s", warning)
+	addWarning(authRequest, warning)
+}
+
+// addWarning 向签名请求追加警告，已有警告时合并显示
+func addWarning(authRequest map[string]any, warning string) {
+	if existing, _ := authRequest["warning"].(string); existing != "" {
+		warning = existing + "；" + warning
+	}
+	authRequest["warning"] = warning
+}
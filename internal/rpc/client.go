@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"net/http"
+	"qng_agent/internal/metrics"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,8 +25,37 @@ type Client struct {
 type TransactionReceipt struct {
 	TransactionHash string `json:"transactionHash"`
 	BlockNumber     string `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
 	Status          string `json:"status"`
 	Success         bool   `json:"success"`
+	Logs            []Log  `json:"logs"`
+}
+
+// Log 交易收据中的事件日志
+type Log struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// Transaction eth_getTransactionByHash 返回的交易，数值字段均为十六进制字符串
+type Transaction struct {
+	Hash                 string `json:"hash"`
+	Type                 string `json:"type"`
+	ChainID              string `json:"chainId"`
+	Nonce                string `json:"nonce"`
+	From                 string `json:"from"`
+	To                   string `json:"to"`
+	Value                string `json:"value"`
+	Gas                  string `json:"gas"`
+	GasPrice             string `json:"gasPrice"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	Input                string `json:"input"`
+	AccessList           []any  `json:"accessList"`
+	V                    string `json:"v"`
+	R                    string `json:"r"`
+	S                    string `json:"s"`
 }
 
 // RPCRequest RPC请求结构
@@ -46,6 +80,65 @@ type RPCError struct {
 	Message string `json:"message"`
 }
 
+// 交易确认失败的类型，调用方可通过 errors.Is 区分
+var (
+	// ErrTransactionReverted 交易已上链但执行失败
+	ErrTransactionReverted = errors.New("交易执行失败")
+	// ErrConfirmationTimeout 在超时前未达到所需确认数
+	ErrConfirmationTimeout = errors.New("等待交易确认超时")
+	// ErrRPCUnavailable 超时前RPC节点持续不可用
+	ErrRPCUnavailable = errors.New("RPC节点不可用")
+	// ErrFinalityUnsupported 节点不支持 "finalized" 区块标签
+	ErrFinalityUnsupported = errors.New("节点不支持finalized区块标签")
+)
+
+// 交易确认策略
+const (
+	// StrategyConfirmations 达到固定确认数即视为确认
+	StrategyConfirmations = "confirmations"
+	// StrategyFinalized 交易所在区块被 "finalized" 标签覆盖才视为确认，节点不支持时回退到固定确认数
+	StrategyFinalized = "finalized"
+)
+
+// 交易广播失败的常见类型，由 SendRawTransaction 返回，调用方可通过 errors.Is 区分
+var (
+	ErrNonceTooLow            = errors.New("nonce过低")
+	ErrNonceTooHigh           = errors.New("nonce过高")
+	ErrInsufficientFunds      = errors.New("余额不足以支付交易费用")
+	ErrReplacementUnderpriced = errors.New("替换交易gas价格过低")
+	ErrTransactionUnderpriced = errors.New("交易gas价格过低")
+	ErrAlreadyKnown           = errors.New("交易已存在")
+	ErrIntrinsicGasTooLow     = errors.New("gas限制低于交易固有消耗")
+	ErrGasLimitExceeded       = errors.New("gas限制超过区块上限")
+)
+
+// broadcastErrors 节点错误消息片段到广播错误的映射
+var broadcastErrors = []struct {
+	fragment string
+	err      error
+}{
+	{"nonce too low", ErrNonceTooLow},
+	{"nonce too high", ErrNonceTooHigh},
+	{"insufficient funds", ErrInsufficientFunds},
+	{"replacement transaction underpriced", ErrReplacementUnderpriced},
+	{"transaction underpriced", ErrTransactionUnderpriced},
+	{"already known", ErrAlreadyKnown},
+	{"known transaction", ErrAlreadyKnown},
+	{"intrinsic gas too low", ErrIntrinsicGasTooLow},
+	{"exceeds block gas limit", ErrGasLimitExceeded},
+}
+
+// decodeBroadcastError 将节点返回的广播错误归类为类型化错误
+func decodeBroadcastError(rpcErr *RPCError) error {
+	message := strings.ToLower(rpcErr.Message)
+	for _, known := range broadcastErrors {
+		if strings.Contains(message, known.fragment) {
+			return fmt.Errorf("%w: %s", known.err, rpcErr.Message)
+		}
+	}
+	return fmt.Errorf("RPC错误(%d): %s", rpcErr.Code, rpcErr.Message)
+}
+
 // NewClient 创建新的RPC客户端
 func NewClient(baseURL string) *Client {
 	return &Client{
@@ -102,6 +195,40 @@ func (c *Client) GetTransactionReceipt(ctx context.Context, txHash string) (*Tra
 	return &receipt, nil
 }
 
+// GetTransactionByHash 获取交易详情，节点上不存在该交易时返回 nil
+func (c *Client) GetTransactionByHash(ctx context.Context, txHash string) (*Transaction, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_getTransactionByHash",
+		Params:  []interface{}{txHash},
+		ID:      1,
+	}
+	
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("发送RPC请求失败: %w", err)
+	}
+	
+	if response.Error != nil {
+		return nil, fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+	
+	if response.Result == nil {
+		return nil, nil
+	}
+	
+	txBytes, err := json.Marshal(response.Result)
+	if err != nil {
+		return nil, fmt.Errorf("解析交易失败: %w", err)
+	}
+	
+	var tx Transaction
+	if err := json.Unmarshal(txBytes, &tx); err != nil {
+		return nil, fmt.Errorf("反序列化交易失败: %w", err)
+	}
+	return &tx, nil
+}
+
 // GetBlockNumber 获取当前区块号
 func (c *Client) GetBlockNumber(ctx context.Context) (int64, error) {
 	request := RPCRequest{
@@ -135,8 +262,311 @@ func (c *Client) GetBlockNumber(ctx context.Context) (int64, error) {
 	return blockNum, nil
 }
 
-// sendRequest 发送RPC请求
+// GetBlockHash 获取规范链上指定高度区块的哈希
+func (c *Client) GetBlockHash(ctx context.Context, number int64) (string, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_getBlockByNumber",
+		Params:  []interface{}{fmt.Sprintf("0x%x", number), false},
+		ID:      1,
+	}
+	
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("获取区块失败: %w", err)
+	}
+	
+	if response.Error != nil {
+		return "", fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+	
+	block, ok := response.Result.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("区块不存在: %d", number)
+	}
+	
+	hash, ok := block["hash"].(string)
+	if !ok {
+		return "", fmt.Errorf("无效的区块哈希格式")
+	}
+	
+	return hash, nil
+}
+
+// GetFinalizedBlockNumber 获取最新已最终确定的区块号，节点不支持 "finalized" 标签时返回错误
+func (c *Client) GetFinalizedBlockNumber(ctx context.Context) (int64, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_getBlockByNumber",
+		Params:  []interface{}{"finalized", false},
+		ID:      1,
+	}
+	
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return 0, fmt.Errorf("获取最终确定区块失败: %w", err)
+	}
+	
+	if response.Error != nil {
+		return 0, fmt.Errorf("%w: %s", ErrFinalityUnsupported, response.Error.Message)
+	}
+	
+	block, ok := response.Result.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("%w: 节点未返回最终确定区块", ErrFinalityUnsupported)
+	}
+	
+	numberHex, ok := block["number"].(string)
+	if !ok {
+		return 0, fmt.Errorf("无效的区块号格式")
+	}
+	
+	var number int64
+	if _, err := fmt.Sscanf(numberHex, "0x%x", &number); err != nil {
+		return 0, fmt.Errorf("解析区块号失败: %w", err)
+	}
+	
+	return number, nil
+}
+
+// CallMsg eth_call 调用参数
+type CallMsg struct {
+	From  string `json:"from,omitempty"`
+	To    string `json:"to"`
+	Value string `json:"value,omitempty"`
+	Data  string `json:"data,omitempty"`
+}
+
+// Call 执行只读合约调用（eth_call），返回十六进制结果
+func (c *Client) Call(ctx context.Context, to, data string) (string, error) {
+	return c.CallFrom(ctx, "", to, data)
+}
+
+// CallFrom 以 from 账户的身份执行只读合约调用（eth_call），from 为空时不指定调用者
+func (c *Client) CallFrom(ctx context.Context, from, to, data string) (string, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_call",
+		Params:  []interface{}{CallMsg{From: from, To: to, Data: data}, "latest"},
+		ID:      1,
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("合约调用失败: %w", err)
+	}
+
+	if response.Error != nil {
+		return "", fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+
+	result, ok := response.Result.(string)
+	if !ok {
+		return "", fmt.Errorf("无效的合约调用结果格式")
+	}
+
+	return result, nil
+}
+
+// EstimateGas 估算交易所需的 gas（eth_estimateGas）
+func (c *Client) EstimateGas(ctx context.Context, msg CallMsg) (uint64, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_estimateGas",
+		Params:  []interface{}{msg},
+		ID:      1,
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return 0, fmt.Errorf("估算gas失败: %w", err)
+	}
+
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+
+	gasHex, ok := response.Result.(string)
+	if !ok {
+		return 0, fmt.Errorf("无效的gas估算结果格式")
+	}
+
+	gas, err := strconv.ParseUint(strings.TrimPrefix(gasHex, "0x"), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析gas估算结果失败: %w", err)
+	}
+
+	return gas, nil
+}
+
+// SendRawTransaction 广播已签名的交易（eth_sendRawTransaction），返回交易哈希
+func (c *Client) SendRawTransaction(ctx context.Context, signedHex string) (string, error) {
+	log.Printf("📤 广播已签名交易")
+	
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_sendRawTransaction",
+		Params:  []interface{}{signedHex},
+		ID:      1,
+	}
+	
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("广播交易失败: %w", err)
+	}
+	
+	if response.Error != nil {
+		log.Printf("❌ 交易广播被拒绝: %s", response.Error.Message)
+		return "", decodeBroadcastError(response.Error)
+	}
+	
+	txHash, ok := response.Result.(string)
+	if !ok {
+		return "", fmt.Errorf("无效的交易哈希格式")
+	}
+	
+	log.Printf("✅ 交易已广播: %s", txHash)
+	return txHash, nil
+}
+
+// NonceGap 账户已确认（latest）与包含待确认交易（pending）的 nonce，两者之差为占用 nonce 的待确认交易数
+type NonceGap struct {
+	Latest  uint64 `json:"latest"`
+	Pending uint64 `json:"pending"`
+}
+
+// PendingCount 返回尚未确认、会排在新交易之前的交易数量
+func (g *NonceGap) PendingCount() uint64 {
+	if g.Pending <= g.Latest {
+		return 0
+	}
+	return g.Pending - g.Latest
+}
+
+// GetTransactionCount 获取账户在 pending 状态下的 nonce
+func (c *Client) GetTransactionCount(ctx context.Context, address string) (uint64, error) {
+	return c.getTransactionCount(ctx, address, "pending")
+}
+
+// GetNonceGap 同时读取账户 latest 与 pending 状态下的 nonce，用于发现仍在交易池中的交易
+func (c *Client) GetNonceGap(ctx context.Context, address string) (*NonceGap, error) {
+	latest, err := c.getTransactionCount(ctx, address, "latest")
+	if err != nil {
+		return nil, err
+	}
+	pending, err := c.getTransactionCount(ctx, address, "pending")
+	if err != nil {
+		return nil, err
+	}
+	return &NonceGap{Latest: latest, Pending: pending}, nil
+}
+
+// getTransactionCount 获取账户在指定区块标签下的 nonce
+func (c *Client) getTransactionCount(ctx context.Context, address, blockTag string) (uint64, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_getTransactionCount",
+		Params:  []interface{}{address, blockTag},
+		ID:      1,
+	}
+	
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return 0, fmt.Errorf("获取nonce失败: %w", err)
+	}
+	
+	if response.Error != nil {
+		return 0, fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+	
+	nonceHex, ok := response.Result.(string)
+	if !ok {
+		return 0, fmt.Errorf("无效的nonce格式")
+	}
+	
+	var nonce uint64
+	if _, err := fmt.Sscanf(nonceHex, "0x%x", &nonce); err != nil {
+		return 0, fmt.Errorf("解析nonce失败: %w", err)
+	}
+	
+	return nonce, nil
+}
+
+// GetBalance 获取账户的原生代币余额（eth_getBalance），单位为 wei
+func (c *Client) GetBalance(ctx context.Context, address string) (*big.Int, error) {
+	request := RPCRequest{
+		JsonRPC: "2.0",
+		Method:  "eth_getBalance",
+		Params:  []interface{}{address, "latest"},
+		ID:      1,
+	}
+
+	response, err := c.sendRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("获取余额失败: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("RPC错误: %s", response.Error.Message)
+	}
+
+	balanceHex, ok := response.Result.(string)
+	if !ok {
+		return nil, fmt.Errorf("无效的余额格式")
+	}
+
+	balance, ok := new(big.Int).SetString(strings.TrimPrefix(balanceHex, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("解析余额失败: %s", balanceHex)
+	}
+
+	return balance, nil
+}
+
+// balanceOfSelector ERC20 balanceOf(address) 函数选择器
+const balanceOfSelector = "0x70a08231"
+
+// GetTokenBalance 获取账户的代币余额（最小单位）。tokenAddress 为空时读取原生代币余额（eth_getBalance），
+// 否则通过 eth_call 调用 ERC20 合约的 balanceOf
+func (c *Client) GetTokenBalance(ctx context.Context, tokenAddress, account string) (*big.Int, error) {
+	if tokenAddress == "" {
+		return c.GetBalance(ctx, account)
+	}
+
+	data := balanceOfSelector + fmt.Sprintf("%064s", strings.ToLower(strings.TrimPrefix(account, "0x")))
+	result, err := c.Call(ctx, tokenAddress, data)
+	if err != nil {
+		return nil, fmt.Errorf("获取代币余额失败: %w", err)
+	}
+
+	raw := strings.TrimPrefix(result, "0x")
+	if raw == "" {
+		return nil, fmt.Errorf("代币合约 %s 未返回余额", tokenAddress)
+	}
+	balance, ok := new(big.Int).SetString(raw, 16)
+	if !ok {
+		return nil, fmt.Errorf("解析代币余额失败: %s", result)
+	}
+	return balance, nil
+}
+
+// sendRequest 发送RPC请求，按方法记录请求耗时与结果
 func (c *Client) sendRequest(ctx context.Context, request RPCRequest) (*RPCResponse, error) {
+	start := time.Now()
+	response, err := c.doRequest(ctx, request)
+	status := metrics.RPCStatusOK
+	switch {
+	case err != nil:
+		status = metrics.RPCStatusError
+	case response.Error != nil:
+		status = metrics.RPCStatusRPCError
+	}
+	metrics.ObserveRPC(request.Method, status, start)
+	return response, err
+}
+
+// doRequest 发送RPC请求并解析响应
+func (c *Client) doRequest(ctx context.Context, request RPCRequest) (*RPCResponse, error) {
 	// 序列化请求
 	requestBytes, err := json.Marshal(request)
 	if err != nil {
@@ -167,38 +597,93 @@ func (c *Client) sendRequest(ctx context.Context, request RPCRequest) (*RPCRespo
 	return &response, nil
 }
 
-// WaitForTransactionConfirmation 等待交易确认
-func (c *Client) WaitForTransactionConfirmation(ctx context.Context, txHash string, requiredConfirmations int, pollingInterval time.Duration) (*TransactionReceipt, error) {
-	log.Printf("⏳ 开始等待交易确认: %s (需要 %d 个确认)", txHash, requiredConfirmations)
+// ConfirmationProgress 等待交易确认过程中的进度
+type ConfirmationProgress struct {
+	TxHash string `json:"tx_hash"`
+	// Mined 交易是否已被打包，未打包时 Confirmations 为 0
+	Mined         bool  `json:"mined"`
+	Confirmations int64 `json:"confirmations"`
+	Required      int   `json:"required"`
+	// Finalized 使用 finalized 策略时交易所在区块是否已最终确定
+	Finalized bool   `json:"finalized,omitempty"`
+	Strategy  string `json:"strategy"`
+}
+
+// WaitForTransactionConfirmation 等待交易确认。
+// progress 不为 nil 时，每当打包状态或确认数变化都会回调当前进度
+func (c *Client) WaitForTransactionConfirmation(ctx context.Context, txHash string, strategy string, requiredConfirmations int, pollingInterval time.Duration, progress func(ConfirmationProgress)) (*TransactionReceipt, error) {
+	if strategy == StrategyFinalized {
+		log.Printf("⏳ 开始等待交易最终确定: %s", txHash)
+	} else {
+		log.Printf("⏳ 开始等待交易确认: %s (需要 %d 个确认)", txHash, requiredConfirmations)
+	}
 	
 	ticker := time.NewTicker(pollingInterval)
 	defer ticker.Stop()
 	
+	// 最近一次RPC查询的错误，成功查询后清空
+	var lastErr error
+	// 上一次看到的收据，用于发现链重组
+	var seen *TransactionReceipt
+	// 上一次回调的进度，只在进度变化时回调
+	var reported *ConfirmationProgress
+	report := func(p ConfirmationProgress) {
+		if progress == nil || (reported != nil && *reported == p) {
+			return
+		}
+		reported = &p
+		progress(p)
+	}
+	
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ctx.Err()
+			}
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %v", ErrRPCUnavailable, lastErr)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrConfirmationTimeout, txHash)
 		case <-ticker.C:
 			// 查询交易收据
 			receipt, err := c.GetTransactionReceipt(ctx, txHash)
 			if err != nil {
 				log.Printf("⚠️ 查询交易收据失败: %v", err)
+				if ctx.Err() == nil {
+					lastErr = err
+				}
 				continue
 			}
+			lastErr = nil
 			
 			if receipt == nil {
-				log.Printf("⏳ 交易尚未被打包，继续等待...")
+				if seen != nil {
+					log.Printf("⚠️ 交易收据消失（区块 %s），可能发生链重组，继续等待...", seen.BlockNumber)
+					seen = nil
+				} else {
+					log.Printf("⏳ 交易尚未被打包，继续等待...")
+				}
+				report(ConfirmationProgress{TxHash: txHash, Required: requiredConfirmations, Strategy: strategy})
 				continue
 			}
 			
+			if seen != nil && seen.BlockHash != receipt.BlockHash {
+				log.Printf("⚠️ 交易所在区块已变化 %s -> %s，可能发生链重组", seen.BlockHash, receipt.BlockHash)
+			}
+			seen = receipt
+			
 			if !receipt.Success {
-				return receipt, fmt.Errorf("交易执行失败")
+				return receipt, fmt.Errorf("%w: %s", ErrTransactionReverted, txHash)
 			}
 			
 			// 获取当前区块号
 			currentBlock, err := c.GetBlockNumber(ctx)
 			if err != nil {
 				log.Printf("⚠️ 获取当前区块号失败: %v", err)
+				if ctx.Err() == nil {
+					lastErr = err
+				}
 				continue
 			}
 			
@@ -211,10 +696,56 @@ func (c *Client) WaitForTransactionConfirmation(ctx context.Context, txHash stri
 			}
 			
 			confirmations := currentBlock - txBlock + 1
-			log.Printf("🔍 交易确认数: %d/%d (当前区块: %d, 交易区块: %d)", 
-				confirmations, requiredConfirmations, currentBlock, txBlock)
+			confirmed := confirmations >= int64(requiredConfirmations)
+			
+			if strategy == StrategyFinalized {
+				finalizedBlock, err := c.GetFinalizedBlockNumber(ctx)
+				if errors.Is(err, ErrFinalityUnsupported) {
+					log.Printf("⚠️ %v，回退到固定确认数 (%d)", err, requiredConfirmations)
+					strategy = StrategyConfirmations
+				} else if err != nil {
+					log.Printf("⚠️ 获取最终确定区块失败: %v", err)
+					if ctx.Err() == nil {
+						lastErr = err
+					}
+					continue
+				} else {
+					confirmed = finalizedBlock >= txBlock
+					log.Printf("🔍 最终确定区块: %d (交易区块: %d)", finalizedBlock, txBlock)
+				}
+			}
+			
+			if strategy != StrategyFinalized {
+				log.Printf("🔍 交易确认数: %d/%d (当前区块: %d, 交易区块: %d)", 
+					confirmations, requiredConfirmations, currentBlock, txBlock)
+			}
+			report(ConfirmationProgress{
+				TxHash:        txHash,
+				Mined:         true,
+				Confirmations: confirmations,
+				Required:      requiredConfirmations,
+				Finalized:     strategy == StrategyFinalized && confirmed,
+				Strategy:      strategy,
+			})
 			
-			if confirmations >= int64(requiredConfirmations) {
+			if confirmed {
+				// 确认前校验交易所在区块仍在规范链上
+				if receipt.BlockHash != "" {
+					canonicalHash, err := c.GetBlockHash(ctx, txBlock)
+					if err != nil {
+						log.Printf("⚠️ 校验规范链区块失败: %v", err)
+						if ctx.Err() == nil {
+							lastErr = err
+						}
+						continue
+					}
+					if !strings.EqualFold(canonicalHash, receipt.BlockHash) {
+						log.Printf("⚠️ 交易区块 %d 已不在规范链上，可能发生链重组，继续等待...", txBlock)
+						seen = nil
+						continue
+					}
+				}
+				
 				log.Printf("✅ 交易确认完成: %s", txHash)
 				return receipt, nil
 			}
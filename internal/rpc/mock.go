@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// MockHandler 动态生成 RPC 方法结果，返回的 RPCError 不为 nil 时作为 JSON-RPC 错误返回
+type MockHandler func(params []interface{}) (interface{}, *RPCError)
+
+// mockBlockHash 模拟节点所有区块使用的哈希，保证收据与规范链校验一致
+const mockBlockHash = "0x00000000000000000000000000000000000000000000000000000000000b10c5"
+
+// MockNode 基于 httptest 的 JSON-RPC 节点测试替身。
+// 默认所有交易立即成功上链并满足确认数，账户余额充足；可通过 On 覆盖任意方法。
+type MockNode struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	handlers map[string]MockHandler
+	calls    []string
+	sent     int
+}
+
+// NewMockNode 启动模拟节点，使用完毕后需调用 Close
+func NewMockNode() *MockNode {
+	m := &MockNode{handlers: make(map[string]MockHandler)}
+	m.registerDefaults()
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// URL 返回模拟节点地址，可直接用于 NewClient
+func (m *MockNode) URL() string {
+	return m.server.URL
+}
+
+// Close 关闭模拟节点
+func (m *MockNode) Close() {
+	m.server.Close()
+}
+
+// On 设置方法的处理函数
+func (m *MockNode) On(method string, handler MockHandler) *MockNode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[method] = handler
+	return m
+}
+
+// Calls 返回按顺序记录的已调用方法名
+func (m *MockNode) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+// CallsTo 返回指定方法的调用次数
+func (m *MockNode) CallsTo(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, call := range m.calls {
+		if call == method {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *MockNode) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var request RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, request.Method)
+	handler, exists := m.handlers[request.Method]
+	m.mu.Unlock()
+
+	response := RPCResponse{JsonRPC: "2.0", ID: request.ID}
+	if !exists {
+		response.Error = &RPCError{Code: -32601, Message: fmt.Sprintf("method %s not found", request.Method)}
+	} else {
+		response.Result, response.Error = handler(request.Params)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// registerDefaults 注册默认行为：当前区块 100，交易在区块 99 成功上链
+func (m *MockNode) registerDefaults() {
+	const latestBlock = "0x64"
+	const txBlock = "0x63"
+	// 1,000,000 个 18 位精度代币
+	balance := new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+
+	m.handlers["eth_blockNumber"] = func([]interface{}) (interface{}, *RPCError) {
+		return latestBlock, nil
+	}
+	m.handlers["eth_getBlockByNumber"] = func(params []interface{}) (interface{}, *RPCError) {
+		number := latestBlock
+		if len(params) > 0 {
+			if tag, ok := params[0].(string); ok && strings.HasPrefix(tag, "0x") {
+				number = tag
+			}
+		}
+		return map[string]interface{}{"number": number, "hash": mockBlockHash}, nil
+	}
+	m.handlers["eth_getTransactionReceipt"] = func(params []interface{}) (interface{}, *RPCError) {
+		txHash := ""
+		if len(params) > 0 {
+			txHash, _ = params[0].(string)
+		}
+		return map[string]interface{}{
+			"transactionHash": txHash,
+			"blockNumber":     txBlock,
+			"blockHash":       mockBlockHash,
+			"status":          "0x1",
+			"gasUsed":         "0x5208",
+		}, nil
+	}
+	m.handlers["eth_getBalance"] = func([]interface{}) (interface{}, *RPCError) {
+		return "0x" + balance.Text(16), nil
+	}
+	m.handlers["eth_call"] = func([]interface{}) (interface{}, *RPCError) {
+		// 作为 balanceOf 等单个 uint256 返回值
+		return "0x" + fmt.Sprintf("%064s", balance.Text(16)), nil
+	}
+	m.handlers["eth_getTransactionCount"] = func([]interface{}) (interface{}, *RPCError) {
+		return "0x0", nil
+	}
+	m.handlers["eth_estimateGas"] = func([]interface{}) (interface{}, *RPCError) {
+		return "0xc350", nil
+	}
+	m.handlers["eth_gasPrice"] = func([]interface{}) (interface{}, *RPCError) {
+		return "0x3b9aca00", nil
+	}
+	m.handlers["eth_sendRawTransaction"] = func([]interface{}) (interface{}, *RPCError) {
+		m.mu.Lock()
+		m.sent++
+		sent := m.sent
+		m.mu.Unlock()
+		return fmt.Sprintf("0x%064x", sent), nil
+	}
+}
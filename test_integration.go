@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"qng_agent/internal/agent"
 	"qng_agent/internal/config"
@@ -11,11 +12,14 @@ import (
 )
 
 func main() {
+	configPath := config.PathFlag()
+	flag.Parse()
+
 	log.Printf("🧪 开始集成测试")
 	
 	// 1. 测试配置加载
 	log.Printf("📋 测试配置加载...")
-	cfg := config.LoadConfig("config/config.yaml")
+	cfg := config.LoadConfig(*configPath)
 	if cfg == nil {
 		log.Fatal("❌ 配置加载失败")
 	}
@@ -42,7 +46,10 @@ func main() {
 
 func testQNGChain(cfg config.QNGConfig) {
 	// 创建QNG Chain
-	chain := qng.NewChain(cfg)
+	chain, err := qng.NewChain(cfg)
+	if err != nil {
+		log.Fatalf("❌ QNG Chain创建失败: %v", err)
+	}
 	
 	// 启动Chain
 	if err := chain.Start(); err != nil {
@@ -134,7 +141,10 @@ func testMCPServer(cfg config.MCPConfig) {
 
 func testAgent(cfg config.AgentConfig) {
 	// 创建智能体
-	agent := agent.NewAgent(cfg)
+	agent, err := agent.NewAgent(cfg)
+	if err != nil {
+		log.Fatalf("❌ 智能体创建失败: %v", err)
+	}
 	
 	// 启动智能体
 	if err := agent.Start(); err != nil {
@@ -193,9 +203,15 @@ func testCompleteWorkflow(cfg *config.Config) {
 	log.Printf("🔄 测试完整工作流...")
 	
 	// 创建所有组件
-	chain := qng.NewChain(cfg.MCP.QNG)
+	chain, err := qng.NewChain(cfg.MCP.QNG)
+	if err != nil {
+		log.Fatalf("❌ QNG Chain创建失败: %v", err)
+	}
 	server := mcp.NewServer(cfg.MCP)
-	agent := agent.NewAgent(cfg.Agent)
+	agent, err := agent.NewAgent(cfg.Agent)
+	if err != nil {
+		log.Fatalf("❌ 智能体创建失败: %v", err)
+	}
 
 	// 启动所有服务
 	if err := chain.Start(); err != nil {